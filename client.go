@@ -5,16 +5,24 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	querynorm "github.com/open-rails/searchkit/internal/normalize"
 	"github.com/open-rails/searchkit/search"
+	pgvector "github.com/pgvector/pgvector-go"
 )
 
 type Embedder interface {
 	EmbedQueryText(ctx context.Context, model string, text string) ([]float32, error)
 }
 
+// SparseEmbedder embeds queries for sparse models (implemented by
+// runtime.Runtime).
+type SparseEmbedder interface {
+	EmbedQuerySparse(ctx context.Context, model string, language string, text string) (pgvector.SparseVector, error)
+}
+
 type SearchMode string
 
 const (
@@ -36,6 +44,53 @@ type ClientConfig struct {
 	DefaultRRFK      int
 	TwoStage         bool
 	OversampleFactor int
+
+	// ChunkAggregate collapses chunked embeddings into one semantic hit per
+	// entity. Set it when the semantic model is configured with chunking.
+	ChunkAggregate search.ChunkAggregate
+
+	// SparseEmbedder and DefaultSparseModel add a learned sparse (SPLADE/BM42)
+	// list to semantic and dual searches, fused with the others by RRF.
+	SparseEmbedder     SparseEmbedder
+	DefaultSparseModel string
+
+	// DefaultVLModel adds a VL list to semantic and dual searches: the query
+	// text is embedded for the VL model (its text encoder for dual encoders)
+	// and searched against entity VL vectors, so image-heavy entities with
+	// little text still rank.
+	DefaultVLModel string
+
+	// Weights scales each list in the RRF fusion (zero fields = 1).
+	Weights FusionWeights
+
+	// StatementTimeout bounds every search query (see
+	// search.Options.StatementTimeout), e.g. 500ms. Zero uses the session's
+	// statement_timeout.
+	StatementTimeout time.Duration
+}
+
+// FusionWeights are per-list RRF weights for Search; zero means 1.
+type FusionWeights struct {
+	Lexical  float32
+	Semantic float32
+	Sparse   float32
+	VL       float32
+}
+
+// or returns w with zero fields taken from def.
+func (w FusionWeights) or(def FusionWeights) FusionWeights {
+	pick := func(v, d float32) float32 {
+		if v > 0 {
+			return v
+		}
+		return d
+	}
+	return FusionWeights{
+		Lexical:  pick(w.Lexical, def.Lexical),
+		Semantic: pick(w.Semantic, def.Semantic),
+		Sparse:   pick(w.Sparse, def.Sparse),
+		VL:       pick(w.VL, def.VL),
+	}
 }
 
 type Client struct {
@@ -49,6 +104,15 @@ type Client struct {
 	defaultRRFK       int
 	defaultTwoStage   bool
 	defaultOversample int
+	defaultChunkAgg   search.ChunkAggregate
+
+	sparseEmbedder     SparseEmbedder
+	defaultSparseModel string
+
+	defaultVLModel string
+	weights        FusionWeights
+
+	statementTimeout time.Duration
 }
 
 func NewClient(cfg ClientConfig) (*Client, error) {
@@ -68,6 +132,15 @@ func NewClient(cfg ClientConfig) (*Client, error) {
 		defaultRRFK:       cfg.DefaultRRFK,
 		defaultTwoStage:   cfg.TwoStage,
 		defaultOversample: cfg.OversampleFactor,
+		defaultChunkAgg:   cfg.ChunkAggregate,
+
+		sparseEmbedder:     cfg.SparseEmbedder,
+		defaultSparseModel: strings.TrimSpace(cfg.DefaultSparseModel),
+
+		defaultVLModel: strings.TrimSpace(cfg.DefaultVLModel),
+		weights:        cfg.Weights,
+
+		statementTimeout: cfg.StatementTimeout,
 	}
 	if c.defaultLanguage == "" {
 		c.defaultLanguage = "en"
@@ -103,6 +176,31 @@ type SearchOptions struct {
 	OversampleFactor int
 	RRFK             int
 
+	// ChunkAggregate overrides the client default.
+	ChunkAggregate search.ChunkAggregate
+
+	// SparseModel overrides the client's DefaultSparseModel; set NoSparse to
+	// skip the sparse list.
+	SparseModel string
+	NoSparse    bool
+
+	// VLModel overrides the client's DefaultVLModel; set NoVL to skip the VL
+	// list. VLQueryVec, when set, is used as the VL query instead of
+	// embedding the text (e.g. a vector from Runtime.EmbedQueryImage).
+	VLModel    string
+	NoVL       bool
+	VLQueryVec []float32
+
+	// Weights overrides the client's Weights per field.
+	Weights FusionWeights
+
+	// IncludeShadow allows Model to be a shadow model (offline evaluation).
+	IncludeShadow bool
+
+	// AllTenants searches every tenant instead of ctx's (see
+	// search.Options.AllTenants).
+	AllTenants bool
+
 	FilterSQL  string
 	FilterArgs map[string]any
 }
@@ -163,14 +261,22 @@ func (c *Client) Search(ctx context.Context, userText string, opts SearchOptions
 		return nil, fmt.Errorf("SemanticEntityTypes is required for semantic/dual search")
 	}
 
-	lists := make([][]search.RRFKey, 0, 3)
+	lists := make([][]search.RRFKey, 0, 4)
+	var weights []float32
+	w := opts.Weights.or(c.weights)
+	add := func(weight float32, l ...[]search.RRFKey) {
+		for _, list := range l {
+			lists = append(lists, list)
+			weights = append(weights, weight)
+		}
+	}
 
 	if mode == SearchModeLexical || mode == SearchModeDual {
-		lexLists, err := c.searchLexical(ctx, qEmbed, language, limit, lexTypes)
+		lexLists, err := c.searchLexical(ctx, qEmbed, language, opts.AllTenants, limit, lexTypes)
 		if err != nil {
 			return nil, err
 		}
-		lists = append(lists, lexLists...)
+		add(w.Lexical, lexLists...)
 	}
 
 	if mode == SearchModeSemantic || mode == SearchModeDual {
@@ -193,6 +299,10 @@ func (c *Client) Search(ctx context.Context, userText string, opts SearchOptions
 		if oversample <= 0 {
 			oversample = c.defaultOversample
 		}
+		chunkAgg := opts.ChunkAggregate
+		if chunkAgg == "" {
+			chunkAgg = c.defaultChunkAgg
+		}
 
 		vec, err := c.embedder.EmbedQueryText(ctx, model, qEmbed)
 		if err != nil {
@@ -202,18 +312,70 @@ func (c *Client) Search(ctx context.Context, userText string, opts SearchOptions
 			return []SearchHit{}, nil
 		}
 
-		semKeys, err := c.searchSemantic(ctx, language, model, vec, limit, semTypes, twoStage, oversample, opts.FilterSQL, opts.FilterArgs)
+		semKeys, err := c.searchSemantic(ctx, language, model, vec, limit, semTypes, twoStage, oversample, chunkAgg, opts.IncludeShadow, opts.AllTenants, opts.FilterSQL, opts.FilterArgs)
 		if err != nil {
 			return nil, err
 		}
-		lists = append(lists, semKeys)
+		add(w.Semantic, semKeys)
+
+		sparseModel := strings.TrimSpace(opts.SparseModel)
+		if sparseModel == "" {
+			sparseModel = c.defaultSparseModel
+		}
+		if sparseModel != "" && !opts.NoSparse {
+			if c.sparseEmbedder == nil {
+				return nil, fmt.Errorf("SparseEmbedder is required for sparse search")
+			}
+			qvec, err := c.sparseEmbedder.EmbedQuerySparse(ctx, sparseModel, language, qEmbed)
+			if err != nil {
+				return nil, err
+			}
+			sparse, err := search.SparseSearch(ctx, c.pool, search.SparseQuery{
+				Schema:        c.schema,
+				Model:         sparseModel,
+				Language:      language,
+				QueryVec:      qvec,
+				Limit:         limit,
+				IncludeShadow: opts.IncludeShadow,
+				Options: search.Options{
+					EntityTypes:      semTypes,
+					AllTenants:       opts.AllTenants,
+					StatementTimeout: c.statementTimeout,
+					FilterSQL:        opts.FilterSQL,
+					FilterArgs:       opts.FilterArgs,
+				},
+			})
+			if err != nil {
+				return nil, err
+			}
+			add(w.Sparse, search.HitKeys(sparse))
+		}
+
+		vlModel := strings.TrimSpace(opts.VLModel)
+		if vlModel == "" {
+			vlModel = c.defaultVLModel
+		}
+		if vlModel != "" && !opts.NoVL {
+			vlVec := opts.VLQueryVec
+			if len(vlVec) == 0 {
+				vlVec, err = c.embedder.EmbedQueryText(ctx, vlModel, qEmbed)
+				if err != nil {
+					return nil, err
+				}
+			}
+			vlKeys, err := c.searchSemantic(ctx, language, vlModel, vlVec, limit, semTypes, false, 0, "", opts.IncludeShadow, opts.AllTenants, opts.FilterSQL, opts.FilterArgs)
+			if err != nil {
+				return nil, err
+			}
+			add(w.VL, vlKeys)
+		}
 	}
 
 	if len(lists) == 0 {
 		return []SearchHit{}, nil
 	}
 
-	fused := search.FuseRRF(lists, search.RRFOptions{K: rrfk})
+	fused := search.FuseRRF(lists, search.RRFOptions{K: rrfk, Weights: weights})
 	out := make([]SearchHit, 0, minInt(limit, len(fused)))
 	for _, h := range fused {
 		out = append(out, SearchHit{
@@ -229,7 +391,7 @@ func (c *Client) Search(ctx context.Context, userText string, opts SearchOptions
 	return out, nil
 }
 
-func (c *Client) searchLexical(ctx context.Context, q string, language string, limit int, entityTypes []string) ([][]search.RRFKey, error) {
+func (c *Client) searchLexical(ctx context.Context, q string, language string, allTenants bool, limit int, entityTypes []string) ([][]search.RRFKey, error) {
 	lang := strings.ToLower(strings.TrimSpace(language))
 	if lang == "ja" || lang == "zh" || lang == "ko" {
 		usePGroonga := containsCJKScript(q)
@@ -238,11 +400,13 @@ func (c *Client) searchLexical(ctx context.Context, q string, language string, l
 		out := make([][]search.RRFKey, 0, 2)
 		if useTrigram {
 			lex, err := search.LexicalSearch(ctx, c.pool, q, search.LexicalOptions{
-				Schema:        c.schema,
-				Language:      language,
-				EntityTypes:   entityTypes,
-				Limit:         limit,
-				MinSimilarity: 0.1,
+				Schema:           c.schema,
+				AllTenants:       allTenants,
+				StatementTimeout: c.statementTimeout,
+				Language:         language,
+				EntityTypes:      entityTypes,
+				Limit:            limit,
+				MinSimilarity:    0.1,
 			})
 			if err != nil {
 				return nil, err
@@ -256,12 +420,14 @@ func (c *Client) searchLexical(ctx context.Context, q string, language string, l
 
 		if usePGroonga {
 			lex, err := search.PGroongaSearch(ctx, c.pool, q, search.PGroongaOptions{
-				Schema:      c.schema,
-				Language:    language,
-				EntityTypes: entityTypes,
-				Limit:       limit,
-				Prefix:      false,
-				ScoreK:      1,
+				Schema:           c.schema,
+				AllTenants:       allTenants,
+				StatementTimeout: c.statementTimeout,
+				Language:         language,
+				EntityTypes:      entityTypes,
+				Limit:            limit,
+				Prefix:           false,
+				ScoreK:           1,
 			})
 			if err != nil {
 				return nil, err
@@ -280,10 +446,12 @@ func (c *Client) searchLexical(ctx context.Context, q string, language string, l
 	}
 
 	lex, err := search.FTSSearch(ctx, c.pool, q, search.FTSOptions{
-		Schema:      c.schema,
-		Language:    language,
-		EntityTypes: entityTypes,
-		Limit:       limit,
+		Schema:           c.schema,
+		AllTenants:       allTenants,
+		StatementTimeout: c.statementTimeout,
+		Language:         language,
+		EntityTypes:      entityTypes,
+		Limit:            limit,
 	})
 	if err != nil {
 		return nil, err
@@ -304,20 +472,27 @@ func (c *Client) searchSemantic(
 	entityTypes []string,
 	twoStage bool,
 	oversampleFactor int,
+	chunkAgg search.ChunkAggregate,
+	includeShadow bool,
+	allTenants bool,
 	filterSQL string,
 	filterArgs map[string]any,
 ) ([]search.RRFKey, error) {
 	sem, err := search.SemanticSearch(ctx, c.pool, search.Query{
-		Schema:     c.schema,
-		Model:      model,
-		Language:   language,
-		QueryVec:   queryVec,
-		Limit:      limit,
-		Dimensions: len(queryVec),
+		Schema:        c.schema,
+		Model:         model,
+		Language:      language,
+		QueryVec:      queryVec,
+		Limit:         limit,
+		Dimensions:    len(queryVec),
+		IncludeShadow: includeShadow,
 		Options: search.Options{
 			EntityTypes:      entityTypes,
 			TwoStage:         twoStage,
 			OversampleFactor: oversampleFactor,
+			ChunkAggregate:   chunkAgg,
+			AllTenants:       allTenants,
+			StatementTimeout: c.statementTimeout,
 			FilterSQL:        filterSQL,
 			FilterArgs:       filterArgs,
 		},
@@ -337,6 +512,10 @@ type TypeaheadOptions struct {
 	EntityTypes   []string
 	Limit         int
 	MinSimilarity float32
+
+	// AllTenants suggests from every tenant instead of ctx's (see
+	// search.Options.AllTenants).
+	AllTenants bool
 }
 
 type TypeaheadHit struct {
@@ -366,14 +545,17 @@ func (c *Client) Typeahead(ctx context.Context, userText string, opts TypeaheadO
 		limit = 10
 	}
 	minSim := opts.MinSimilarity
+	allTenants := opts.AllTenants
 
 	if !isCJKLanguage(language) {
 		hits, err := search.LexicalSearch(ctx, c.pool, q, search.LexicalOptions{
-			Schema:        c.schema,
-			Language:      language,
-			EntityTypes:   entityTypes,
-			Limit:         limit,
-			MinSimilarity: minSim,
+			Schema:           c.schema,
+			AllTenants:       allTenants,
+			StatementTimeout: c.statementTimeout,
+			Language:         language,
+			EntityTypes:      entityTypes,
+			Limit:            limit,
+			MinSimilarity:    minSim,
 		})
 		if err != nil {
 			return nil, err
@@ -403,11 +585,13 @@ func (c *Client) Typeahead(ctx context.Context, userText string, opts TypeaheadO
 
 	if useTrigram {
 		hits, err := search.LexicalSearch(ctx, c.pool, q, search.LexicalOptions{
-			Schema:        c.schema,
-			Language:      language,
-			EntityTypes:   entityTypes,
-			Limit:         limit,
-			MinSimilarity: minSim,
+			Schema:           c.schema,
+			AllTenants:       allTenants,
+			StatementTimeout: c.statementTimeout,
+			Language:         language,
+			EntityTypes:      entityTypes,
+			Limit:            limit,
+			MinSimilarity:    minSim,
 		})
 		if err != nil {
 			return nil, err
@@ -419,12 +603,14 @@ func (c *Client) Typeahead(ctx context.Context, userText string, opts TypeaheadO
 
 	if usePGroonga {
 		hits, err := search.PGroongaSearch(ctx, c.pool, q, search.PGroongaOptions{
-			Schema:      c.schema,
-			Language:    language,
-			EntityTypes: entityTypes,
-			Limit:       limit,
-			Prefix:      true,
-			ScoreK:      1,
+			Schema:           c.schema,
+			AllTenants:       allTenants,
+			StatementTimeout: c.statementTimeout,
+			Language:         language,
+			EntityTypes:      entityTypes,
+			Limit:            limit,
+			Prefix:           true,
+			ScoreK:           1,
 		})
 		if err != nil {
 			return nil, err
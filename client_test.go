@@ -108,3 +108,31 @@ func TestClientSearch_LexicalDoesNotCallEmbedder(t *testing.T) {
 		t.Fatalf("expected embedder not to be called in lexical mode")
 	}
 }
+
+func TestImageQueryURL_EncodesBytesAsDataURL(t *testing.T) {
+	t.Parallel()
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	url, err := imageQueryURL(ImageSearchRequest{ImageBytes: png})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(url, "data:image/png;base64,") {
+		t.Fatalf("unexpected data URL %q", url)
+	}
+	if _, err := imageQueryURL(ImageSearchRequest{ImageBytes: []byte("hello")}); err == nil {
+		t.Fatalf("expected non-image bytes to be rejected")
+	}
+	if _, err := imageQueryURL(ImageSearchRequest{ImageURL: "https://x/a.jpg", ImageBytes: png}); err == nil {
+		t.Fatalf("expected an error when both URL and bytes are set")
+	}
+}
+
+func TestFusionWeights_OverrideFieldsIndividually(t *testing.T) {
+	t.Parallel()
+
+	got := FusionWeights{VL: 2}.or(FusionWeights{Lexical: 0.5, VL: 1.5})
+	if got != (FusionWeights{Lexical: 0.5, VL: 2}) {
+		t.Fatalf("unexpected weights %+v", got)
+	}
+}
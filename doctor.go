@@ -0,0 +1,40 @@
+package searchkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// ModelSpecSource lists the configured models (implemented by
+// runtime.Runtime).
+type ModelSpecSource interface {
+	ModelSpecs() []pg.ModelSpec
+}
+
+type DoctorOptions struct {
+	Pool   pg.Querier
+	Schema string
+
+	// Models are the configured models, usually the host's *runtime.Runtime.
+	Models ModelSpecSource
+
+	pg.DiagnoseOptions
+}
+
+// Doctor checks that the searchkit tables agree with the host's configuration
+// and are healthy: registered models vs configured embedders, ANN indexes vs
+// model specs, stored vector dims vs the indexes' casts, FTS population,
+// backfill state and queue backlog. It returns a report of problems with
+// suggested fixes (see pg.Diagnose); check report.OK() for blocking ones.
+func Doctor(ctx context.Context, opts DoctorOptions) (pg.DoctorReport, error) {
+	if opts.Models == nil {
+		return pg.DoctorReport{}, fmt.Errorf("Models is required")
+	}
+	if strings.TrimSpace(opts.Schema) == "" {
+		return pg.DoctorReport{}, fmt.Errorf("Schema is required")
+	}
+	return pg.Diagnose(ctx, opts.Pool, opts.Schema, opts.Models.ModelSpecs(), opts.DiagnoseOptions)
+}
@@ -0,0 +1,125 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// AzureOpenAIConfig configures an Azure OpenAI embeddings deployment. Azure
+// addresses models by deployment (…/openai/deployments/<name>/embeddings) and
+// authenticates with an api-key header or a Microsoft Entra ID (Azure AD)
+// bearer token, so it can't be reached through OpenAICompatibleConfig.BaseURL.
+type AzureOpenAIConfig struct {
+	Endpoint   string // https://<resource>.openai.azure.com
+	Deployment string // deployment name serving the embedding model
+	APIVersion string // optional; defaults to AzureOpenAIDefaultAPIVersion
+
+	// Exactly one of APIKey and TokenSource is required. TokenSource supplies
+	// Entra ID access tokens (scope https://cognitiveservices.azure.com/.default)
+	// and is called per request, so it should cache.
+	APIKey      string
+	TokenSource AzureTokenSource
+
+	Model      string // canonical model name used by the host app
+	Dimensions int    // optional; 0 means the deployment's default
+	// MaxBatch is the per-request input limit (default 2048, Azure's limit for
+	// the text-embedding-3 models). Larger EmbedTexts calls are split.
+	MaxBatch int
+	// Base64 requests base64-encoded embeddings (see OpenAICompatibleConfig).
+	Base64 bool
+
+	Timeout    time.Duration
+	HTTPClient *http.Client      // optional; see OpenAICompatibleConfig.HTTPClient
+	Transport  http.RoundTripper // optional; see OpenAICompatibleConfig.Transport
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// AzureOpenAIDefaultAPIVersion is the GA data-plane API version used when
+// AzureOpenAIConfig.APIVersion is empty.
+const AzureOpenAIDefaultAPIVersion = "2024-10-21"
+
+// AzureTokenSource returns Microsoft Entra ID access tokens.
+type AzureTokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// AzureTokenFunc adapts a function (e.g. wrapping azidentity's GetToken) to
+// AzureTokenSource.
+type AzureTokenFunc func(ctx context.Context) (string, error)
+
+func (f AzureTokenFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+// NewAzureOpenAI returns an embedder for an Azure OpenAI deployment. It shares
+// the OpenAI-compatible request path (batching, hooks).
+func NewAzureOpenAI(cfg AzureOpenAIConfig) (*OpenAICompatibleEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(cfg.Endpoint) == "" {
+		return nil, fmt.Errorf("endpoint is required")
+	}
+	deployment := strings.TrimSpace(cfg.Deployment)
+	if deployment == "" {
+		return nil, fmt.Errorf("deployment is required")
+	}
+	if (cfg.APIKey == "") == (cfg.TokenSource == nil) {
+		return nil, fmt.Errorf("exactly one of api key and token source is required")
+	}
+
+	openaiCfg := openai.DefaultAzureConfig(cfg.APIKey, strings.TrimRight(cfg.Endpoint, "/"))
+	openaiCfg.APIVersion = cfg.APIVersion
+	if openaiCfg.APIVersion == "" {
+		openaiCfg.APIVersion = AzureOpenAIDefaultAPIVersion
+	}
+	openaiCfg.AzureModelMapperFunc = func(string) string { return deployment }
+	client := configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout)
+	if cfg.TokenSource != nil {
+		openaiCfg.APIType = openai.APITypeAzureAD
+		wrapped := *client
+		next := wrapped.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		wrapped.Transport = azureADTransport{source: cfg.TokenSource, next: next}
+		client = &wrapped
+	}
+	openaiCfg.HTTPClient = client
+
+	maxBatch := cfg.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = 2048
+	}
+	return &OpenAICompatibleEmbedder{
+		client:     openai.NewClientWithConfig(openaiCfg),
+		model:      cfg.Model,
+		dimensions: cfg.Dimensions,
+		provider:   "azure",
+		name:       "azure-openai",
+		resolver:   func(string, string) string { return deployment },
+		hooks:      cfg.Hooks,
+		maxBatch:   maxBatch,
+		encoding:   encodingFormat(cfg.Base64),
+	}, nil
+}
+
+// azureADTransport sets an Entra ID bearer token on each request.
+type azureADTransport struct {
+	source AzureTokenSource
+	next   http.RoundTripper
+}
+
+func (t azureADTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(r.Context())
+	if err != nil {
+		return nil, fmt.Errorf("azure-openai: token: %w", err)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return t.next.RoundTrip(r)
+}
@@ -0,0 +1,35 @@
+package embedder
+
+// MaxBatcher is implemented by embedders that know their provider's per-request
+// input limit. The worker batches up to it; EmbedTexts still splits larger
+// inputs itself.
+type MaxBatcher interface {
+	MaxBatch() int
+}
+
+// MaxBatch returns e's per-request input limit, or 0 when unknown.
+func MaxBatch(e Embedder) int {
+	if b, ok := e.(MaxBatcher); ok {
+		return b.MaxBatch()
+	}
+	return 0
+}
+
+func (e *CohereEmbedder) MaxBatch() int { return cohereMaxBatch }
+func (e *VoyageEmbedder) MaxBatch() int { return voyageMaxBatch }
+func (e *GoogleEmbedder) MaxBatch() int { return e.maxBatch }
+func (e *OllamaEmbedder) MaxBatch() int { return e.batchSize }
+
+func (e *BedrockEmbedder) MaxBatch() int {
+	if e.cohere {
+		return cohereMaxBatch
+	}
+	return 1
+}
+
+func (e *OpenAICompatibleEmbedder) MaxBatch() int { return e.maxBatch }
+
+// Decorators report the wrapped embedder's limit.
+func (e *cachingEmbedder) MaxBatch() int     { return MaxBatch(e.inner) }
+func (e *rateLimitedEmbedder) MaxBatch() int { return MaxBatch(e.inner) }
+func (e *retryingEmbedder) MaxBatch() int    { return MaxBatch(e.inner) }
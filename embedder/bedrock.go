@@ -0,0 +1,201 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// bedrockDims are the default output dimensions of the supported Bedrock models.
+var bedrockDims = map[string]int{
+	"amazon.titan-embed-text-v1":   1536,
+	"amazon.titan-embed-text-v2:0": 1024,
+	"cohere.embed-english-v3":      1024,
+	"cohere.embed-multilingual-v3": 1024,
+}
+
+// bedrockStatus maps Bedrock error types to the status the worker's retry
+// classification expects (throttling as 429, timeouts as 408, capacity as 503).
+var bedrockStatus = map[string]int{
+	"ThrottlingException":           http.StatusTooManyRequests,
+	"ServiceQuotaExceededException": http.StatusTooManyRequests,
+	"ModelNotReadyException":        http.StatusTooManyRequests,
+	"ModelTimeoutException":         http.StatusRequestTimeout,
+	"ServiceUnavailableException":   http.StatusServiceUnavailable,
+	"InternalServerException":       http.StatusInternalServerError,
+}
+
+type BedrockConfig struct {
+	// Model is the Bedrock model id: "amazon.titan-embed-text-v2:0",
+	// "amazon.titan-embed-text-v1" or "cohere.embed-*-v3".
+	Model      string
+	Dimensions int    // optional; Titan v2 accepts 256, 512 or 1024
+	Region     string // optional; defaults to AWS_REGION / AWS_DEFAULT_REGION
+
+	// Credentials is required. searchkit doesn't resolve the AWS credential
+	// chain (profiles, SSO, web identity, instance roles) itself: adapt the
+	// AWS SDK's with AWSCredentialsFunc, or pass EnvAWSCredentials for static
+	// keys from the environment.
+	Credentials AWSCredentialsProvider
+
+	BaseURL string // optional endpoint override (e.g. a VPC endpoint)
+	Timeout time.Duration
+	// HTTPClient, when set, is used for provider requests as-is (proxies,
+	// mTLS, instrumentation); Timeout and Transport are then ignored.
+	HTTPClient *http.Client
+	// Transport, when set (and HTTPClient is not), carries provider requests
+	// in a client with Timeout.
+	Transport http.RoundTripper
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// BedrockEmbedder calls Bedrock's InvokeModel API signed with SigV4. Titan
+// models embed one text per request (batches are sent sequentially); Cohere
+// models are batched (96 texts) and use input_type search_query/search_document.
+type BedrockEmbedder struct {
+	client     *http.Client
+	baseURL    string
+	region     string
+	creds      AWSCredentialsProvider
+	model      string
+	dimensions int
+	outputDim  int
+	cohere     bool
+	hooks      *Hooks
+}
+
+func NewBedrock(cfg BedrockConfig) (*BedrockEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if cfg.Credentials == nil {
+		return nil, fmt.Errorf("credentials are required")
+	}
+	cohere := strings.HasPrefix(cfg.Model, "cohere.")
+	if !cohere && !strings.HasPrefix(cfg.Model, "amazon.titan-embed") {
+		return nil, fmt.Errorf("unsupported Bedrock model %q (Titan or Cohere embed models)", cfg.Model)
+	}
+	dims := bedrockDims[cfg.Model]
+	outputDim := 0
+	if cfg.Dimensions > 0 && cfg.Dimensions != dims {
+		if cfg.Model != "amazon.titan-embed-text-v2:0" {
+			return nil, fmt.Errorf("model %q does not support custom dimensions", cfg.Model)
+		}
+		dims = cfg.Dimensions
+		outputDim = cfg.Dimensions
+	}
+	if dims <= 0 {
+		return nil, fmt.Errorf("dimensions are required for model %q", cfg.Model)
+	}
+	region := strings.TrimSpace(cfg.Region)
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, fmt.Errorf("region is required")
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = "https://bedrock-runtime." + region + ".amazonaws.com"
+	}
+	return &BedrockEmbedder{
+		client:     configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		baseURL:    baseURL,
+		region:     region,
+		creds:      cfg.Credentials,
+		model:      cfg.Model,
+		dimensions: dims,
+		outputDim:  outputDim,
+		cohere:     cohere,
+		hooks:      cfg.Hooks,
+	}, nil
+}
+
+func (e *BedrockEmbedder) Model() string   { return e.model }
+func (e *BedrockEmbedder) Dimensions() int { return e.dimensions }
+
+func (e *BedrockEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+func (e *BedrockEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if e.cohere {
+		inputType := "search_document"
+		if InputTypeFromContext(ctx) == InputQuery {
+			inputType = "search_query"
+		}
+		return embedInBatches(texts, cohereMaxBatch, func(batch []string) ([][]float32, error) {
+			var resp struct {
+				Embeddings [][]float32 `json:"embeddings"`
+			}
+			done := e.hooks.start(ctx, "bedrock", e.model, batch)
+			err := e.invoke(ctx, map[string]any{"texts": batch, "input_type": inputType}, &resp)
+			done(0, err)
+			return resp.Embeddings, err
+		})
+	}
+	return embedInBatches(texts, 1, func(batch []string) ([][]float32, error) {
+		body := map[string]any{"inputText": batch[0]}
+		if e.outputDim > 0 {
+			body["dimensions"] = e.outputDim
+		}
+		var resp struct {
+			Embedding           []float32 `json:"embedding"`
+			InputTextTokenCount int       `json:"inputTextTokenCount"`
+		}
+		done := e.hooks.start(ctx, "bedrock", e.model, batch)
+		err := e.invoke(ctx, body, &resp)
+		done(resp.InputTextTokenCount, err)
+		if err != nil {
+			return nil, err
+		}
+		return [][]float32{resp.Embedding}, nil
+	})
+}
+
+func (e *BedrockEmbedder) invoke(ctx context.Context, body any, out any) error {
+	creds, err := e.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("bedrock credentials: %w", err)
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/model/"+awsURIEncode(e.model)+"/invoke", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	signV4(req, payload, creds, e.region, "bedrock", time.Now())
+
+	err = sendJSON(e.client, "bedrock", req, out)
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if code, ok := bedrockStatus[httpErr.Type]; ok {
+			httpErr.StatusCode = code
+		}
+	}
+	return err
+}
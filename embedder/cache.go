@@ -0,0 +1,271 @@
+package embedder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Cache stores embeddings keyed by model and a hash of the input text. It has
+// the same method set as runtime.EmbeddingCache, so pg.EmbeddingCache can back
+// WithCache too.
+type Cache interface {
+	GetEmbeddings(ctx context.Context, model string, hashes []string) (map[string][]float32, error)
+	PutEmbeddings(ctx context.Context, model string, entries map[string][]float32) error
+}
+
+// WithCache memoizes inner's embeddings in cache, e.g. for repeated search
+// queries or documents re-embedded by several hosts. Cache errors are ignored
+// (the provider is called instead). Query inputs (see WithInputType) are keyed
+// separately from documents, since asymmetric providers embed them differently.
+func WithCache(inner Embedder, cache Cache) Embedder {
+	return &cachingEmbedder{inner: inner, cache: cache}
+}
+
+type cachingEmbedder struct {
+	inner Embedder
+	cache Cache
+}
+
+func (e *cachingEmbedder) Model() string   { return e.inner.Model() }
+func (e *cachingEmbedder) Dimensions() int { return e.inner.Dimensions() }
+
+func (e *cachingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+func (e *cachingEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	model := e.inner.Model()
+	query := InputTypeFromContext(ctx) == InputQuery
+	hashes := make([]string, len(texts))
+	for i, t := range texts {
+		hashes[i] = cacheKey(t, query)
+	}
+	found, err := e.cache.GetEmbeddings(ctx, model, hashes)
+	if err != nil || found == nil {
+		found = map[string][]float32{}
+	}
+
+	var missIdx []int
+	var missTexts []string
+	for i, h := range hashes {
+		if _, ok := found[h]; !ok {
+			missIdx = append(missIdx, i)
+			missTexts = append(missTexts, texts[i])
+		}
+	}
+	if len(missTexts) > 0 {
+		var vecs [][]float32
+		if len(missTexts) == 1 {
+			var vec []float32
+			vec, err = e.inner.EmbedText(ctx, missTexts[0])
+			vecs = [][]float32{vec}
+		} else {
+			vecs, err = e.inner.EmbedTexts(ctx, missTexts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != len(missTexts) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(missTexts), len(vecs))
+		}
+		fresh := make(map[string][]float32, len(vecs))
+		for k, vec := range vecs {
+			fresh[hashes[missIdx[k]]] = vec
+			found[hashes[missIdx[k]]] = vec
+		}
+		_ = e.cache.PutEmbeddings(ctx, model, fresh)
+	}
+
+	out := make([][]float32, len(texts))
+	for i, h := range hashes {
+		out[i] = append([]float32(nil), found[h]...)
+	}
+	return out, nil
+}
+
+// cacheKey is the hex SHA-256 of text (matching pg.ContentHash for documents);
+// queries get a distinct key.
+func cacheKey(text string, query bool) string {
+	if query {
+		text = "query\x00" + text
+	}
+	h := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(h[:])
+}
+
+// MemoryCache is an in-process Cache with a TTL and a size bound.
+type MemoryCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	vec     []float32
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache. ttl <= 0 never expires entries;
+// maxEntries <= 0 defaults to 10000. When full, expired entries are dropped
+// first, then arbitrary ones.
+func NewMemoryCache(ttl time.Duration, maxEntries int) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &MemoryCache{ttl: ttl, maxEntries: maxEntries, entries: map[string]memoryCacheEntry{}}
+}
+
+func (c *MemoryCache) GetEmbeddings(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string][]float32, len(hashes))
+	for _, h := range hashes {
+		e, ok := c.entries[model+"\x00"+h]
+		if !ok {
+			continue
+		}
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(c.entries, model+"\x00"+h)
+			continue
+		}
+		out[h] = e.vec
+	}
+	return out, nil
+}
+
+func (c *MemoryCache) PutEmbeddings(ctx context.Context, model string, entries map[string][]float32) error {
+	now := time.Now()
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = now.Add(c.ttl)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for h, vec := range entries {
+		if len(c.entries) >= c.maxEntries {
+			c.evict(now)
+		}
+		c.entries[model+"\x00"+h] = memoryCacheEntry{vec: append([]float32(nil), vec...), expires: expires}
+	}
+	return nil
+}
+
+// evict drops expired entries, or one arbitrary entry when none have expired.
+func (c *MemoryCache) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	for k := range c.entries {
+		delete(c.entries, k)
+		return
+	}
+}
+
+// RedisClient is the subset of a Redis client RedisCache needs; adapt e.g.
+// go-redis with MGet(...).Result() and Set(...).Err().
+type RedisClient interface {
+	// MGet returns one value per key: nil when missing, else string or []byte.
+	MGet(ctx context.Context, keys ...string) ([]any, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// RedisCache is a Cache stored in Redis as little-endian float32 blobs under
+// Prefix + model + ":" + hash.
+type RedisCache struct {
+	Client RedisClient
+	Prefix string        // defaults to "searchkit:emb:"
+	TTL    time.Duration // 0 keeps entries until evicted by Redis
+}
+
+func (c *RedisCache) key(model string, hash string) string {
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = "searchkit:emb:"
+	}
+	return prefix + model + ":" + hash
+}
+
+func (c *RedisCache) GetEmbeddings(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	out := make(map[string][]float32, len(hashes))
+	if len(hashes) == 0 {
+		return out, nil
+	}
+	keys := make([]string, len(hashes))
+	for i, h := range hashes {
+		keys[i] = c.key(model, h)
+	}
+	vals, err := c.Client.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		if i >= len(hashes) {
+			break
+		}
+		var b []byte
+		switch v := v.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			continue
+		}
+		if vec, ok := decodeVector(b); ok {
+			out[hashes[i]] = vec
+		}
+	}
+	return out, nil
+}
+
+func (c *RedisCache) PutEmbeddings(ctx context.Context, model string, entries map[string][]float32) error {
+	for h, vec := range entries {
+		if err := c.Client.Set(ctx, c.key(model, h), encodeVector(vec), c.TTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeVector(vec []float32) []byte {
+	b := make([]byte, 4*len(vec))
+	for i, x := range vec {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+func decodeVector(b []byte) ([]float32, bool) {
+	if len(b) == 0 || len(b)%4 != 0 {
+		return nil, false
+	}
+	vec := make([]float32, len(b)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return vec, true
+}
@@ -0,0 +1,75 @@
+package embedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type countingEmbedder struct{ inputs int }
+
+func (e *countingEmbedder) Model() string   { return "m" }
+func (e *countingEmbedder) Dimensions() int { return 1 }
+
+func (e *countingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	e.inputs++
+	return []float32{float32(len(text))}, nil
+}
+
+func (e *countingEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i], _ = e.EmbedText(ctx, t)
+	}
+	return out, nil
+}
+
+func TestWithCache_ServesRepeatedInputs(t *testing.T) {
+	inner := &countingEmbedder{}
+	e := WithCache(inner, NewMemoryCache(time.Minute, 0))
+	ctx := context.Background()
+
+	if _, err := e.EmbedTexts(ctx, []string{"a", "bb"}); err != nil {
+		t.Fatal(err)
+	}
+	vecs, err := e.EmbedTexts(ctx, []string{"bb", "ccc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.inputs != 3 || vecs[0][0] != 2 || vecs[1][0] != 3 {
+		t.Fatalf("expected 3 provider inputs and aligned vectors, got %d %v", inner.inputs, vecs)
+	}
+	if _, err := e.EmbedText(WithInputType(ctx, InputQuery), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if inner.inputs != 4 {
+		t.Fatalf("expected queries to be cached separately from documents, got %d inputs", inner.inputs)
+	}
+}
+
+func TestEmbedTextsWithUsage_ThroughDecorators(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"embedding":[1],"index":0}],"usage":{"total_tokens":7}}`))
+	}))
+	defer srv.Close()
+	inner, err := NewVoyage(VoyageConfig{APIKey: "k", Model: "voyage-3", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := WithRetry(WithCache(inner, NewMemoryCache(0, 0)), RetryPolicy{})
+	ctx, total := TrackUsage(context.Background())
+
+	_, usage, err := EmbedTextsWithUsage(ctx, e, []string{"a"})
+	if err != nil || usage.PromptTokens != 7 {
+		t.Fatalf("expected 7 prompt tokens, got %+v (%v)", usage, err)
+	}
+	// Served from the cache: no provider call, no usage.
+	if _, usage, _ = EmbedTextsWithUsage(ctx, e, []string{"a"}); usage.PromptTokens != 0 {
+		t.Fatalf("expected no usage for a cache hit, got %+v", usage)
+	}
+	if total().PromptTokens != 7 {
+		t.Fatalf("expected the outer tracker to see 7 tokens, got %+v", total())
+	}
+}
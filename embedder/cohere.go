@@ -0,0 +1,142 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCohereBaseURL = "https://api.cohere.com"
+	// cohereMaxBatch is Cohere's per-request limit on texts.
+	cohereMaxBatch = 96
+)
+
+// cohereDims are the output dimensions of Cohere's v3 embedding models.
+var cohereDims = map[string]int{
+	"embed-english-v3.0":            1024,
+	"embed-multilingual-v3.0":       1024,
+	"embed-english-light-v3.0":      384,
+	"embed-multilingual-light-v3.0": 384,
+}
+
+type CohereConfig struct {
+	APIKey     string
+	Model      string // e.g. "embed-multilingual-v3.0"
+	Dimensions int    // optional for the known v3 models
+	BaseURL    string // optional; defaults to https://api.cohere.com
+	Timeout    time.Duration
+	// HTTPClient, when set, is used for provider requests as-is (proxies,
+	// mTLS, instrumentation); Timeout and Transport are then ignored.
+	HTTPClient *http.Client
+	// Transport, when set (and HTTPClient is not), carries provider requests
+	// in a client with Timeout.
+	Transport http.RoundTripper
+	// Truncate is Cohere's handling of over-long inputs: "NONE" | "START" |
+	// "END" (provider default END).
+	Truncate string
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// CohereEmbedder calls Cohere's v2 embed endpoint. Texts are embedded with
+// input_type "search_query" when ctx carries InputQuery and "search_document"
+// otherwise.
+type CohereEmbedder struct {
+	client     *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	dimensions int
+	truncate   string
+	hooks      *Hooks
+}
+
+func NewCohere(cfg CohereConfig) (*CohereEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(cfg.APIKey) == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	dims := cfg.Dimensions
+	if dims <= 0 {
+		dims = cohereDims[cfg.Model]
+	}
+	if dims <= 0 {
+		return nil, fmt.Errorf("dimensions are required for model %q", cfg.Model)
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = defaultCohereBaseURL
+	}
+	return &CohereEmbedder{
+		client:     configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		dimensions: dims,
+		truncate:   cfg.Truncate,
+		hooks:      cfg.Hooks,
+	}, nil
+}
+
+func (e *CohereEmbedder) Model() string   { return e.model }
+func (e *CohereEmbedder) Dimensions() int { return e.dimensions }
+
+func (e *CohereEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+func (e *CohereEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	inputType := "search_document"
+	if InputTypeFromContext(ctx) == InputQuery {
+		inputType = "search_query"
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+e.apiKey)
+	return embedInBatches(texts, cohereMaxBatch, func(batch []string) ([][]float32, error) {
+		req := struct {
+			Model          string   `json:"model"`
+			Texts          []string `json:"texts"`
+			InputType      string   `json:"input_type"`
+			EmbeddingTypes []string `json:"embedding_types"`
+			Truncate       string   `json:"truncate,omitempty"`
+		}{
+			Model:          e.model,
+			Texts:          batch,
+			InputType:      inputType,
+			EmbeddingTypes: []string{"float"},
+			Truncate:       e.truncate,
+		}
+		var resp struct {
+			Embeddings struct {
+				Float [][]float32 `json:"float"`
+			} `json:"embeddings"`
+			Meta struct {
+				BilledUnits struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"billed_units"`
+			} `json:"meta"`
+		}
+		done := e.hooks.start(ctx, "cohere", e.model, batch)
+		err := postJSON(ctx, e.client, "cohere", e.baseURL+"/v2/embed", header, req, &resp)
+		done(resp.Meta.BilledUnits.InputTokens, err)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Embeddings.Float, nil
+	})
+}
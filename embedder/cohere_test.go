@@ -0,0 +1,70 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCohere_InputTypeAndBatching(t *testing.T) {
+	var inputTypes []string
+	var batchSizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts     []string `json:"texts"`
+			InputType string   `json:"input_type"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		inputTypes = append(inputTypes, req.InputType)
+		batchSizes = append(batchSizes, len(req.Texts))
+		vecs := make([][]float32, len(req.Texts))
+		for i := range vecs {
+			vecs[i] = []float32{1, 0, 0}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": map[string]any{"float": vecs}})
+	}))
+	defer srv.Close()
+
+	e, err := NewCohere(CohereConfig{APIKey: "k", Model: "embed-multilingual-v3.0", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewCohere: %v", err)
+	}
+	texts := make([]string, 100)
+	vecs, err := e.EmbedTexts(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("EmbedTexts: %v", err)
+	}
+	if len(vecs) != 100 || len(batchSizes) != 2 || batchSizes[0] != 96 {
+		t.Fatalf("expected 100 vectors in batches of 96, got %d vectors, batches %v", len(vecs), batchSizes)
+	}
+	if _, err := e.EmbedText(WithInputType(context.Background(), InputQuery), "q"); err != nil {
+		t.Fatalf("EmbedText: %v", err)
+	}
+	if inputTypes[0] != "search_document" || inputTypes[2] != "search_query" {
+		t.Fatalf("unexpected input types %v", inputTypes)
+	}
+}
+
+func TestCohere_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"message":"slow down"}`))
+	}))
+	defer srv.Close()
+
+	e, err := NewCohere(CohereConfig{APIKey: "k", Model: "embed-english-v3.0", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewCohere: %v", err)
+	}
+	_, err = e.EmbedText(context.Background(), "x")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 429 || httpErr.Message != "slow down" || httpErr.RetryAfter.Seconds() != 7 {
+		t.Fatalf("unexpected error %#v", err)
+	}
+}
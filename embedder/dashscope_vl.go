@@ -0,0 +1,405 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/open-rails/searchkit/vl"
+)
+
+const (
+	defaultDashScopeBaseURL = "https://dashscope.aliyuncs.com"
+	dashScopeVLPath         = "/api/v1/services/embeddings/multimodal-embedding/multimodal-embedding"
+
+	// dashScopeMaxContents caps contents per request when batching several
+	// entities into one request.
+	dashScopeMaxContents     = 20
+	defaultDashScopeMaxBatch = 8
+)
+
+// dashScopeVLModels holds the default dimensions and per-request image limit
+// of DashScope's multimodal embedding models.
+var dashScopeVLModels = map[string]struct {
+	dims      int
+	maxImages int
+}{
+	"multimodal-embedding-v1":       {dims: 1024, maxImages: 1},
+	"tongyi-embedding-vision-plus":  {dims: 1152, maxImages: 8},
+	"tongyi-embedding-vision-flash": {dims: 768, maxImages: 8},
+	"qwen3-vl-embedding":            {dims: 2560, maxImages: 8},
+}
+
+// dashScopeStatus maps DashScope error codes (by prefix) to the status the
+// worker's retry classification expects; DashScope sometimes reports
+// throttling and timeouts with a 400.
+var dashScopeStatus = []struct {
+	prefix string
+	status int
+}{
+	{"Throttling", http.StatusTooManyRequests},
+	{"RequestTimeOut", http.StatusRequestTimeout},
+	{"InternalError", http.StatusInternalServerError},
+	{"SystemError", http.StatusInternalServerError},
+}
+
+type DashScopeVLConfig struct {
+	APIKey string
+	Model  string // canonical model name used by the host app
+	// ProviderModel is the DashScope model id (e.g. "tongyi-embedding-vision-plus");
+	// defaults to Model.
+	ProviderModel string
+	// Dimensions is the output dimension; required for models not in the
+	// built-in table. A value other than the model's default is sent as the
+	// dimension parameter (supported by the tongyi and qwen3 models).
+	Dimensions int
+	// BaseURL defaults to https://dashscope.aliyuncs.com (use
+	// https://dashscope-intl.aliyuncs.com for the international region).
+	BaseURL string
+	// MaxImagesPerRequest caps image/frame URLs per request (default: the
+	// model's limit, else 1). Extra assets go in further requests.
+	MaxImagesPerRequest int
+	// MaxBatch is the most entities the worker sends per EmbedBatch call
+	// (default 8); their contents are packed into shared requests within the
+	// per-request limits.
+	MaxBatch int
+	// RequestsPerSecond limits this embedder's requests (0 = unlimited), to
+	// stay under the account's QPS quota.
+	RequestsPerSecond float64
+	// Fusion combines the text and asset vectors into the entity vector
+	// (default vl.FuseAverage), e.g. vl.FuseFirstImages(1, 3) so covers
+	// dominate. Changing it changes stored vectors: re-embed the model.
+	Fusion vl.Fusion
+
+	Timeout    time.Duration
+	HTTPClient *http.Client      // optional; see OpenAICompatibleConfig.HTTPClient
+	Transport  http.RoundTripper // optional; see OpenAICompatibleConfig.Transport
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// DashScopeVLEmbedder implements vl.Embedder with DashScope's
+// multimodal-embedding API (Alibaba Cloud Model Studio). The text and every
+// asset URL are embedded as separate contents and the returned vectors are
+// fused into one vector (DashScopeVLConfig.Fusion). Images and
+// frames are sent as "image" contents, videos as "video" (one per request).
+type DashScopeVLEmbedder struct {
+	client     *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	provider   string
+	dimensions int
+	outputDim  int
+	maxImages  int
+	maxBatch   int
+	limiter    *rate.Limiter
+	fusion     vl.Fusion
+	hooks      *Hooks
+}
+
+var (
+	_ vl.AssetVectorEmbedder = (*DashScopeVLEmbedder)(nil)
+	_ vl.BytesEmbedder       = (*DashScopeVLEmbedder)(nil)
+	_ vl.BatchEmbedder       = (*DashScopeVLEmbedder)(nil)
+)
+
+func NewDashScopeVL(cfg DashScopeVLConfig) (*DashScopeVLEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(cfg.APIKey) == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	provider := strings.TrimSpace(cfg.ProviderModel)
+	if provider == "" {
+		provider = cfg.Model
+	}
+	known := dashScopeVLModels[provider]
+	dims := known.dims
+	outputDim := 0
+	if cfg.Dimensions > 0 && cfg.Dimensions != dims {
+		dims = cfg.Dimensions
+		outputDim = cfg.Dimensions
+	}
+	if dims <= 0 {
+		return nil, fmt.Errorf("dimensions are required for model %q", provider)
+	}
+	maxImages := cfg.MaxImagesPerRequest
+	if maxImages <= 0 {
+		maxImages = max(known.maxImages, 1)
+	}
+	maxBatch := cfg.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = defaultDashScopeMaxBatch
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = defaultDashScopeBaseURL
+	}
+	var limiter *rate.Limiter
+	if cfg.RequestsPerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), 1)
+	}
+	fusion := cfg.Fusion
+	if fusion == nil {
+		fusion = vl.FuseAverage
+	}
+	return &DashScopeVLEmbedder{
+		client:     configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		provider:   provider,
+		dimensions: dims,
+		outputDim:  outputDim,
+		maxImages:  maxImages,
+		maxBatch:   maxBatch,
+		limiter:    limiter,
+		fusion:     fusion,
+		hooks:      cfg.Hooks,
+	}, nil
+}
+
+func (e *DashScopeVLEmbedder) Model() string   { return e.model }
+func (e *DashScopeVLEmbedder) Dimensions() int { return e.dimensions }
+func (e *DashScopeVLEmbedder) MaxBatch() int   { return e.maxBatch }
+
+// dashScopeContent is one multimodal input; exactly one of Text, Image and
+// Video is set.
+type dashScopeContent struct {
+	Text  string `json:"text,omitempty"`
+	Image string `json:"image,omitempty"`
+	Video string `json:"video,omitempty"`
+
+	input int // index into the batch's inputs
+	asset int // index into the input's assets; -1 for the text
+}
+
+func (e *DashScopeVLEmbedder) EmbedTextAndAssetURLs(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, error) {
+	fused, _, err := e.EmbedAssetVectors(ctx, text, assets)
+	return fused, err
+}
+
+// EmbedAssetVectors returns the fused vector and each asset's own vector
+// (as the provider returned it; nil for assets with an empty URL).
+func (e *DashScopeVLEmbedder) EmbedAssetVectors(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, [][]float32, error) {
+	out, err := e.EmbedBatch(ctx, []vl.Input{{Text: text, Assets: assets}})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out[0].Fused, out[0].PerAsset, nil
+}
+
+// EmbedBatch embeds several entities, packing their contents into shared
+// requests (within the per-request image and content limits), and fuses each
+// entity's vectors separately.
+func (e *DashScopeVLEmbedder) EmbedBatch(ctx context.Context, inputs []vl.Input) ([]vl.BatchResult, error) {
+	var planned [][]dashScopeContent
+	for n, in := range inputs {
+		requests := e.plan(in.Text, in.Assets)
+		if len(requests) == 0 {
+			return nil, fmt.Errorf("text or assets are required")
+		}
+		for _, contents := range requests {
+			for i := range contents {
+				contents[i].input = n
+			}
+			planned = append(planned, contents)
+		}
+	}
+
+	results := make([]vl.BatchResult, len(inputs))
+	parts := make([][]vl.FusionPart, len(inputs))
+	for n, in := range inputs {
+		results[n].PerAsset = make([][]float32, len(in.Assets))
+	}
+	for _, contents := range e.pack(planned) {
+		out, err := e.embedContents(ctx, contents)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) != len(contents) {
+			return nil, fmt.Errorf("dashscope: expected %d embeddings, got %d", len(contents), len(out))
+		}
+		for i, c := range contents {
+			part := vl.FusionPart{Asset: c.asset, Vector: out[i]}
+			if c.asset >= 0 {
+				part.Kind = inputs[c.input].Assets[c.asset].Kind
+				results[c.input].PerAsset[c.asset] = out[i]
+			}
+			parts[c.input] = append(parts[c.input], part)
+		}
+	}
+	for n := range inputs {
+		// Requests put videos last; fuse in input order (text, then assets).
+		sort.SliceStable(parts[n], func(i, j int) bool { return parts[n][i].Asset < parts[n][j].Asset })
+		fused := e.fusion(parts[n])
+		if fused == nil {
+			return nil, fmt.Errorf("dashscope: embeddings have inconsistent dimensions")
+		}
+		if len(fused) != e.dimensions {
+			return nil, fmt.Errorf("dashscope: expected %d dimensions, got %d", e.dimensions, len(fused))
+		}
+		results[n].Fused = fused
+	}
+	return results, nil
+}
+
+// EmbedTextAndAssetBytes embeds uploaded images and frames inline as base64
+// data URLs; assets without Data (videos) are sent by URL.
+func (e *DashScopeVLEmbedder) EmbedTextAndAssetBytes(ctx context.Context, text string, assets []vl.AssetBytes) ([]float32, [][]float32, error) {
+	urls := make([]vl.AssetURL, len(assets))
+	for i, a := range assets {
+		urls[i] = a.AssetURL
+		if a.Data != nil {
+			urls[i].URL = vl.DataURL(a.MIMEType, a.Data)
+		}
+	}
+	return e.EmbedAssetVectors(ctx, text, urls)
+}
+
+// plan groups the inputs into requests: the text with the first images, up to
+// maxImages images per request, and each video on its own.
+func (e *DashScopeVLEmbedder) plan(text string, assets []vl.AssetURL) [][]dashScopeContent {
+	var requests, videos [][]dashScopeContent
+	var cur []dashScopeContent
+	images := 0
+	if strings.TrimSpace(text) != "" {
+		cur = append(cur, dashScopeContent{Text: text, asset: -1})
+	}
+	for i, a := range assets {
+		url := strings.TrimSpace(a.URL)
+		if url == "" {
+			continue
+		}
+		if a.Kind == vl.AssetKindVideo {
+			videos = append(videos, []dashScopeContent{{Video: url, asset: i}})
+			continue
+		}
+		if images == e.maxImages {
+			requests = append(requests, cur)
+			cur, images = nil, 0
+		}
+		cur = append(cur, dashScopeContent{Image: url, asset: i})
+		images++
+	}
+	if len(cur) > 0 {
+		requests = append(requests, cur)
+	}
+	return append(requests, videos...)
+}
+
+// pack merges planned requests (of one or more inputs) into as few requests as
+// the per-request image and content limits allow; videos stay alone.
+func (e *DashScopeVLEmbedder) pack(planned [][]dashScopeContent) [][]dashScopeContent {
+	var requests, videos [][]dashScopeContent
+	var cur []dashScopeContent
+	images := 0
+	for _, contents := range planned {
+		if contents[0].Video != "" {
+			videos = append(videos, contents)
+			continue
+		}
+		n := 0
+		for _, c := range contents {
+			if c.Image != "" {
+				n++
+			}
+		}
+		if len(cur) > 0 && (images+n > e.maxImages || len(cur)+len(contents) > dashScopeMaxContents) {
+			requests = append(requests, cur)
+			cur, images = nil, 0
+		}
+		cur = append(cur, contents...)
+		images += n
+	}
+	if len(cur) > 0 {
+		requests = append(requests, cur)
+	}
+	return append(requests, videos...)
+}
+
+func (e *DashScopeVLEmbedder) embedContents(ctx context.Context, contents []dashScopeContent) ([][]float32, error) {
+	if e.limiter != nil {
+		if err := e.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	type params struct {
+		Dimension int `json:"dimension,omitempty"`
+	}
+	req := struct {
+		Model string `json:"model"`
+		Input struct {
+			Contents []dashScopeContent `json:"contents"`
+		} `json:"input"`
+		Parameters *params `json:"parameters,omitempty"`
+	}{Model: e.provider}
+	req.Input.Contents = contents
+	if e.outputDim > 0 {
+		req.Parameters = &params{Dimension: e.outputDim}
+	}
+	var resp struct {
+		Output struct {
+			Embeddings []struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			} `json:"embeddings"`
+		} `json:"output"`
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+e.apiKey)
+
+	previews := make([]string, len(contents))
+	for i, c := range contents {
+		previews[i] = c.Text + c.Image + c.Video
+	}
+	done := e.hooks.start(ctx, "dashscope", e.provider, previews)
+	err := postJSON(ctx, e.client, "dashscope", e.baseURL+dashScopeVLPath, header, req, &resp)
+	err = mapDashScopeError(err)
+	done(resp.Usage.InputTokens, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Output.Embeddings) == 0 {
+		return nil, fmt.Errorf("dashscope: response has no embeddings")
+	}
+	sort.Slice(resp.Output.Embeddings, func(i, j int) bool {
+		return resp.Output.Embeddings[i].Index < resp.Output.Embeddings[j].Index
+	})
+	out := make([][]float32, len(resp.Output.Embeddings))
+	for i, d := range resp.Output.Embeddings {
+		out[i] = d.Embedding
+	}
+	return out, nil
+}
+
+// mapDashScopeError rewrites err's status from its DashScope error code (see
+// dashScopeStatus) and marks failures to download an asset URL with
+// vl.ErrAssetUnreachable.
+func mapDashScopeError(err error) error {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	for _, m := range dashScopeStatus {
+		if strings.HasPrefix(httpErr.Type, m.prefix) {
+			httpErr.StatusCode = m.status
+			return err
+		}
+	}
+	if httpErr.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(httpErr.Message), "download") {
+		return fmt.Errorf("%w: %w", vl.ErrAssetUnreachable, err)
+	}
+	return err
+}
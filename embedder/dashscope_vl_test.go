@@ -0,0 +1,140 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-rails/searchkit/vl"
+)
+
+func TestDashScopeVL_SplitsAssetsAndFuses(t *testing.T) {
+	var requests [][]map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != dashScopeVLPath || r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("unexpected request %s (auth %q)", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req struct {
+			Model string `json:"model"`
+			Input struct {
+				Contents []map[string]string `json:"contents"`
+			} `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "multimodal-embedding-v1" {
+			t.Errorf("model = %q", req.Model)
+		}
+		requests = append(requests, req.Input.Contents)
+		type row struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var resp struct {
+			Output struct {
+				Embeddings []row `json:"embeddings"`
+			} `json:"output"`
+		}
+		for i := range req.Input.Contents {
+			vec := make([]float32, 1024)
+			vec[len(requests)-1] = 1
+			resp.Output.Embeddings = append(resp.Output.Embeddings, row{Index: i, Embedding: vec})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	e, err := NewDashScopeVL(DashScopeVLConfig{APIKey: "k", Model: "vl", ProviderModel: "multimodal-embedding-v1", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vec, err := e.EmbedTextAndAssetURLs(context.Background(), "a cat", []vl.AssetURL{
+		{Kind: vl.AssetKindImage, URL: "https://x/1.jpg"},
+		{Kind: vl.AssetKindVideo, URL: "https://x/v.mp4"},
+		{Kind: vl.AssetKindFrame, URL: "https://x/2.jpg"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// multimodal-embedding-v1 takes one image per request; the video goes alone.
+	if len(requests) != 3 || len(requests[2]) != 1 || requests[2][0]["video"] == "" {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if requests[0][0]["text"] != "a cat" || requests[0][1]["image"] != "https://x/1.jpg" || requests[1][0]["image"] != "https://x/2.jpg" {
+		t.Fatalf("unexpected request contents %v", requests)
+	}
+	if len(vec) != 1024 || vec[0] <= vec[1] || vec[1] != vec[2] {
+		t.Fatalf("expected a fused vector weighted by content count, got %v", vec[:3])
+	}
+}
+
+func TestDashScopeVL_MapsThrottlingCode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"code":"Throttling.RateQuota","message":"Requests rate limit exceeded","request_id":"r"}`))
+	}))
+	defer srv.Close()
+
+	e, err := NewDashScopeVL(DashScopeVLConfig{APIKey: "k", Model: "tongyi-embedding-vision-plus", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.EmbedTextAndAssetURLs(context.Background(), "q", nil)
+	if code, _ := StatusCode(err); code != http.StatusTooManyRequests || !IsTransient(err) {
+		t.Fatalf("expected throttling to map to a transient 429, got %v", err)
+	}
+}
+
+func TestDashScopeVL_BatchPacksEntitiesIntoSharedRequests(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req struct {
+			Input struct {
+				Contents []map[string]string `json:"contents"`
+			} `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		type row struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var resp struct {
+			Output struct {
+				Embeddings []row `json:"embeddings"`
+			} `json:"output"`
+		}
+		// Each content's vector points along its entity's axis.
+		for i, c := range req.Input.Contents {
+			vec := make([]float32, 768)
+			if strings.Contains(c["text"]+c["image"], "b") {
+				vec[1] = 1
+			} else {
+				vec[0] = 1
+			}
+			resp.Output.Embeddings = append(resp.Output.Embeddings, row{Index: i, Embedding: vec})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	e, err := NewDashScopeVL(DashScopeVLConfig{APIKey: "k", Model: "tongyi-embedding-vision-flash", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := e.EmbedBatch(context.Background(), []vl.Input{
+		{Text: "a", Assets: []vl.AssetURL{{Kind: vl.AssetKindImage, URL: "https://x/a1.jpg"}, {Kind: vl.AssetKindImage, URL: "https://x/a2.jpg"}}},
+		{Text: "b", Assets: []vl.AssetURL{{Kind: vl.AssetKindImage, URL: "https://x/b1.jpg"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Fatalf("expected both entities in one request, got %d requests", requests)
+	}
+	if len(out) != 2 || out[0].Fused[0] != 1 || out[1].Fused[1] != 1 || len(out[0].PerAsset) != 2 {
+		t.Fatalf("expected per-entity fusion, got %+v", out)
+	}
+}
@@ -0,0 +1,115 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/internal/normalize"
+	"github.com/open-rails/searchkit/vl"
+)
+
+type DualEncoderVLConfig struct {
+	Model string // canonical model name used by the host app
+
+	// Text embeds text queries into the shared space (the CLIP/SigLIP text
+	// tower).
+	Text Embedder
+	// Image embeds asset URLs passed as its inputs (the image tower), e.g. an
+	// OpenAI-compatible server such as Infinity serving the same CLIP model.
+	Image Embedder
+
+	// Fusion combines an entity's image vectors (default vl.FuseAverage).
+	Fusion vl.Fusion
+}
+
+// DualEncoderVL adapts separate text and image encoders to vl.DualEncoder.
+// Entity vectors fuse the image vectors only; videos are skipped since image
+// towers can't embed them (use runtime FrameSampling to turn them into frames).
+type DualEncoderVL struct {
+	model  string
+	text   Embedder
+	image  Embedder
+	fusion vl.Fusion
+}
+
+var (
+	_ vl.DualEncoder         = (*DualEncoderVL)(nil)
+	_ vl.AssetVectorEmbedder = (*DualEncoderVL)(nil)
+)
+
+func NewDualEncoderVL(cfg DualEncoderVLConfig) (*DualEncoderVL, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if cfg.Text == nil || cfg.Image == nil {
+		return nil, fmt.Errorf("text and image encoders are required")
+	}
+	if cfg.Text.Dimensions() != cfg.Image.Dimensions() {
+		return nil, fmt.Errorf("text encoder has %d dimensions but image encoder has %d", cfg.Text.Dimensions(), cfg.Image.Dimensions())
+	}
+	fusion := cfg.Fusion
+	if fusion == nil {
+		fusion = vl.FuseAverage
+	}
+	return &DualEncoderVL{model: cfg.Model, text: cfg.Text, image: cfg.Image, fusion: fusion}, nil
+}
+
+func (e *DualEncoderVL) Model() string   { return e.model }
+func (e *DualEncoderVL) Dimensions() int { return e.image.Dimensions() }
+
+// AssetKinds reports that only images and frames are embedded; videos are
+// filtered out before reaching the image encoder.
+func (e *DualEncoderVL) AssetKinds() []vl.AssetKind {
+	return []vl.AssetKind{vl.AssetKindImage, vl.AssetKindFrame}
+}
+
+// EmbedQueryText embeds a text query with the text encoder.
+func (e *DualEncoderVL) EmbedQueryText(ctx context.Context, text string) ([]float32, error) {
+	return e.text.EmbedText(WithInputType(ctx, InputQuery), text)
+}
+
+// EmbedTextAndAssetURLs embeds the images and frames; text is ignored.
+func (e *DualEncoderVL) EmbedTextAndAssetURLs(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, error) {
+	fused, _, err := e.EmbedAssetVectors(ctx, text, assets)
+	return fused, err
+}
+
+// EmbedAssetVectors returns the fused image vector and each image's own
+// vector (as the image encoder returned it; nil for videos and empty URLs).
+func (e *DualEncoderVL) EmbedAssetVectors(ctx context.Context, _ string, assets []vl.AssetURL) ([]float32, [][]float32, error) {
+	var urls []string
+	var idx []int
+	for i, a := range assets {
+		if a.Kind == vl.AssetKindVideo || strings.TrimSpace(a.URL) == "" {
+			continue
+		}
+		urls = append(urls, a.URL)
+		idx = append(idx, i)
+	}
+	if len(urls) == 0 {
+		return nil, nil, fmt.Errorf("image or frame assets are required")
+	}
+	vecs, err := e.image.EmbedTexts(ctx, urls)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(vecs) != len(urls) {
+		return nil, nil, fmt.Errorf("image encoder: expected %d embeddings, got %d", len(urls), len(vecs))
+	}
+	perAsset := make([][]float32, len(assets))
+	parts := make([]vl.FusionPart, len(vecs))
+	for k, v := range vecs {
+		i := idx[k]
+		perAsset[i] = v
+		// Fuse unit vectors so no image outweighs another by its norm.
+		unit := append([]float32(nil), v...)
+		normalize.L2NormalizeInPlace(unit)
+		parts[k] = vl.FusionPart{Kind: assets[i].Kind, Asset: i, Vector: unit}
+	}
+	fused := e.fusion(parts)
+	if fused == nil {
+		return nil, nil, fmt.Errorf("image encoder: embeddings have inconsistent dimensions")
+	}
+	return fused, perAsset, nil
+}
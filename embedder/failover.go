@@ -0,0 +1,172 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailoverPolicy configures NewFailover. Zero fields take the defaults noted
+// below.
+type FailoverPolicy struct {
+	// FailureThreshold is how many consecutive failover-worthy primary errors
+	// route traffic to the secondary (default 3). A rate limit (429) does so
+	// immediately.
+	FailureThreshold int
+	// Cooldown is how long the primary is bypassed before a trial request
+	// probes it again (default 30s; a longer Retry-After wins).
+	Cooldown time.Duration
+	// ShouldFailover classifies primary errors (default IsTransient). Other
+	// errors (bad input, auth) are returned without trying the secondary.
+	ShouldFailover func(err error) bool
+	// OnSwitch, when set, is called when traffic moves to the secondary
+	// (toSecondary) or back to the primary, with the error that caused it.
+	OnSwitch func(toSecondary bool, cause error)
+}
+
+func (p FailoverPolicy) withDefaults() FailoverPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 3
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = 30 * time.Second
+	}
+	if p.ShouldFailover == nil {
+		p.ShouldFailover = IsTransient
+	}
+	return p
+}
+
+// FailoverEmbedder serves requests from a primary embedder and falls back to
+// a secondary serving the same model (e.g. another vendor hosting the same
+// open-weights model) while the primary is erroring or rate-limited.
+//
+// A failover-worthy primary error retries that request on the secondary. After
+// FailureThreshold consecutive errors (or a 429) the primary is bypassed for
+// Cooldown; the next request then tries the primary again, and a success moves
+// traffic back.
+type FailoverEmbedder struct {
+	primary   Embedder
+	secondary Embedder
+	policy    FailoverPolicy
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	onSecond  bool
+	now       func() time.Time
+}
+
+// NewFailover returns an embedder that fails over from primary to secondary.
+// Both must produce vectors of the same dimensions (the same model); Model()
+// reports the primary's name.
+func NewFailover(primary Embedder, secondary Embedder, policy FailoverPolicy) (*FailoverEmbedder, error) {
+	if primary == nil || secondary == nil {
+		return nil, fmt.Errorf("primary and secondary embedders are required")
+	}
+	if primary.Dimensions() != secondary.Dimensions() {
+		return nil, fmt.Errorf("failover embedders must have equal dimensions (primary %d, secondary %d)", primary.Dimensions(), secondary.Dimensions())
+	}
+	return &FailoverEmbedder{
+		primary:   primary,
+		secondary: secondary,
+		policy:    policy.withDefaults(),
+		now:       time.Now,
+	}, nil
+}
+
+func (e *FailoverEmbedder) Model() string   { return e.primary.Model() }
+func (e *FailoverEmbedder) Dimensions() int { return e.primary.Dimensions() }
+
+// MaxBatch reports the smaller known limit of the two providers.
+func (e *FailoverEmbedder) MaxBatch() int {
+	p, s := MaxBatch(e.primary), MaxBatch(e.secondary)
+	if p == 0 || (s > 0 && s < p) {
+		return s
+	}
+	return p
+}
+
+// UsingSecondary reports whether traffic is currently routed to the secondary.
+func (e *FailoverEmbedder) UsingSecondary() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.onSecond
+}
+
+func (e *FailoverEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	var vec []float32
+	err := e.do(func(emb Embedder) (err error) {
+		vec, err = emb.EmbedText(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+func (e *FailoverEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	var vecs [][]float32
+	err := e.do(func(emb Embedder) (err error) {
+		vecs, err = emb.EmbedTexts(ctx, texts)
+		return err
+	})
+	return vecs, err
+}
+
+func (e *FailoverEmbedder) do(call func(Embedder) error) error {
+	e.mu.Lock()
+	bypass := e.now().Before(e.openUntil)
+	e.mu.Unlock()
+	if bypass {
+		return call(e.secondary)
+	}
+
+	err := call(e.primary)
+	if err == nil {
+		e.primaryOK()
+		return nil
+	}
+	if !e.policy.ShouldFailover(err) {
+		return err
+	}
+	e.primaryFailed(err)
+	if serr := call(e.secondary); serr != nil {
+		return fmt.Errorf("primary failed (%v); secondary: %w", err, serr)
+	}
+	return nil
+}
+
+func (e *FailoverEmbedder) primaryOK() {
+	e.mu.Lock()
+	e.failures = 0
+	switched := e.onSecond
+	e.onSecond = false
+	e.mu.Unlock()
+	if switched && e.policy.OnSwitch != nil {
+		e.policy.OnSwitch(false, nil)
+	}
+}
+
+func (e *FailoverEmbedder) primaryFailed(err error) {
+	code, _ := StatusCode(err)
+	e.mu.Lock()
+	e.failures++
+	if e.failures < e.policy.FailureThreshold && code != http.StatusTooManyRequests {
+		e.mu.Unlock()
+		return
+	}
+	cooldown := e.policy.Cooldown
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > cooldown {
+		cooldown = httpErr.RetryAfter
+	}
+	e.openUntil = e.now().Add(cooldown)
+	switched := !e.onSecond
+	e.onSecond = true
+	e.mu.Unlock()
+	if switched && e.policy.OnSwitch != nil {
+		e.policy.OnSwitch(true, err)
+	}
+}
@@ -0,0 +1,46 @@
+package embedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFailover_SwitchesAndRecovers(t *testing.T) {
+	primary := &flakyEmbedder{failures: []error{&HTTPError{StatusCode: 429}}}
+	secondary := &countingEmbedder{}
+	var switches []bool
+	f, err := NewFailover(primary, secondary, FailoverPolicy{
+		Cooldown: time.Minute,
+		OnSwitch: func(toSecondary bool, _ error) { switches = append(switches, toSecondary) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	f.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// A rate limit is served by the secondary and bypasses the primary.
+	if _, err := f.EmbedText(ctx, "a"); err != nil || secondary.inputs != 1 || !f.UsingSecondary() {
+		t.Fatalf("expected failover to the secondary, got err=%v inputs=%d", err, secondary.inputs)
+	}
+	if _, err := f.EmbedText(ctx, "b"); err != nil || secondary.inputs != 2 || primary.inputs != 0 {
+		t.Fatalf("expected the primary to be bypassed during cooldown")
+	}
+
+	// After the cooldown a trial request succeeds on the primary.
+	now = now.Add(2 * time.Minute)
+	if _, err := f.EmbedText(ctx, "c"); err != nil || primary.inputs != 1 || f.UsingSecondary() {
+		t.Fatalf("expected recovery to the primary, got err=%v", err)
+	}
+	if len(switches) != 2 || !switches[0] || switches[1] {
+		t.Fatalf("unexpected switches %v", switches)
+	}
+
+	// Permanent errors are not failed over.
+	primary.failures = []error{&HTTPError{StatusCode: 400}}
+	if _, err := f.EmbedText(ctx, "d"); err == nil || secondary.inputs != 2 {
+		t.Fatalf("expected a 400 to be returned without failover")
+	}
+}
@@ -0,0 +1,245 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGeminiBaseURL = "https://generativelanguage.googleapis.com"
+	// geminiMaxBatch is the Gemini API's batchEmbedContents request limit.
+	geminiMaxBatch = 100
+	// vertexMaxBatch is Vertex AI's instance limit for text-embedding models
+	// (gemini-embedding-001 accepts one instance per request).
+	vertexMaxBatch = 250
+)
+
+// googleDims are the default output dimensions of Google's embedding models.
+var googleDims = map[string]int{
+	"text-embedding-004":              768,
+	"text-embedding-005":              768,
+	"text-multilingual-embedding-002": 768,
+	"gemini-embedding-001":            3072,
+}
+
+type GoogleConfig struct {
+	Model      string // e.g. "text-embedding-004" or "gemini-embedding-001"
+	Dimensions int    // optional; sets outputDimensionality when it differs from the model default
+
+	// Gemini API (generativelanguage.googleapis.com): set APIKey.
+	APIKey string
+
+	// Vertex AI: set Project and Location (e.g. "us-central1"). Requests are
+	// authorized with TokenSource, or Application Default Credentials (see
+	// GoogleDefaultCredentials) when nil.
+	Project     string
+	Location    string
+	TokenSource GoogleTokenSource
+
+	BaseURL string // optional endpoint override
+	Timeout time.Duration
+	// HTTPClient, when set, is used for provider requests as-is (proxies,
+	// mTLS, instrumentation); Timeout and Transport are then ignored.
+	HTTPClient *http.Client
+	// Transport, when set (and HTTPClient is not), carries provider requests
+	// in a client with Timeout.
+	Transport http.RoundTripper
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// GoogleEmbedder calls the Gemini API (batchEmbedContents) or Vertex AI
+// (predict). Texts are embedded with task type RETRIEVAL_QUERY when ctx carries
+// InputQuery and RETRIEVAL_DOCUMENT otherwise.
+type GoogleEmbedder struct {
+	client     *http.Client
+	model      string
+	dimensions int
+	outputDim  int
+
+	apiKey   string
+	endpoint string
+	tokens   GoogleTokenSource
+	maxBatch int
+	hooks    *Hooks
+}
+
+func NewGoogle(cfg GoogleConfig) (*GoogleEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	dims := googleDims[cfg.Model]
+	outputDim := 0
+	if cfg.Dimensions > 0 && cfg.Dimensions != dims {
+		dims = cfg.Dimensions
+		outputDim = cfg.Dimensions
+	}
+	if dims <= 0 {
+		return nil, fmt.Errorf("dimensions are required for model %q", cfg.Model)
+	}
+	e := &GoogleEmbedder{
+		client:     configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		model:      cfg.Model,
+		dimensions: dims,
+		outputDim:  outputDim,
+		hooks:      cfg.Hooks,
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+
+	if strings.TrimSpace(cfg.APIKey) != "" {
+		if baseURL == "" {
+			baseURL = defaultGeminiBaseURL
+		}
+		e.apiKey = cfg.APIKey
+		e.endpoint = fmt.Sprintf("%s/v1beta/models/%s:batchEmbedContents", baseURL, url.PathEscape(cfg.Model))
+		e.maxBatch = geminiMaxBatch
+		return e, nil
+	}
+
+	if strings.TrimSpace(cfg.Project) == "" || strings.TrimSpace(cfg.Location) == "" {
+		return nil, fmt.Errorf("APIKey (Gemini API) or Project and Location (Vertex AI) are required")
+	}
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://%s-aiplatform.googleapis.com", cfg.Location)
+	}
+	e.endpoint = fmt.Sprintf("%s/v1/projects/%s/locations/%s/publishers/google/models/%s:predict",
+		baseURL, url.PathEscape(cfg.Project), url.PathEscape(cfg.Location), url.PathEscape(cfg.Model))
+	e.tokens = cfg.TokenSource
+	if e.tokens == nil {
+		ts, err := GoogleDefaultCredentials(e.client)
+		if err != nil {
+			return nil, err
+		}
+		e.tokens = ts
+	}
+	e.maxBatch = vertexMaxBatch
+	if cfg.Model == "gemini-embedding-001" {
+		e.maxBatch = 1
+	}
+	return e, nil
+}
+
+func (e *GoogleEmbedder) Model() string   { return e.model }
+func (e *GoogleEmbedder) Dimensions() int { return e.dimensions }
+
+func (e *GoogleEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+func (e *GoogleEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	taskType := "RETRIEVAL_DOCUMENT"
+	if InputTypeFromContext(ctx) == InputQuery {
+		taskType = "RETRIEVAL_QUERY"
+	}
+	return embedInBatches(texts, e.maxBatch, func(batch []string) ([][]float32, error) {
+		if e.apiKey != "" {
+			return e.embedGemini(ctx, batch, taskType)
+		}
+		return e.embedVertex(ctx, batch, taskType)
+	})
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiEmbedRequest struct {
+	Model                string        `json:"model"`
+	Content              geminiContent `json:"content"`
+	TaskType             string        `json:"taskType"`
+	OutputDimensionality int           `json:"outputDimensionality,omitempty"`
+}
+
+func (e *GoogleEmbedder) embedGemini(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	reqs := make([]geminiEmbedRequest, len(texts))
+	for i, t := range texts {
+		reqs[i] = geminiEmbedRequest{
+			Model:                "models/" + e.model,
+			Content:              geminiContent{Parts: []geminiPart{{Text: t}}},
+			TaskType:             taskType,
+			OutputDimensionality: e.outputDim,
+		}
+	}
+	var resp struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+	header := http.Header{}
+	header.Set("x-goog-api-key", e.apiKey)
+	done := e.hooks.start(ctx, "gemini", e.model, texts)
+	err := postJSON(ctx, e.client, "gemini", e.endpoint, header, map[string]any{"requests": reqs}, &resp)
+	done(0, err)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(resp.Embeddings))
+	for i, emb := range resp.Embeddings {
+		out[i] = emb.Values
+	}
+	return out, nil
+}
+
+func (e *GoogleEmbedder) embedVertex(ctx context.Context, texts []string, taskType string) ([][]float32, error) {
+	type instance struct {
+		Content  string `json:"content"`
+		TaskType string `json:"task_type"`
+	}
+	instances := make([]instance, len(texts))
+	for i, t := range texts {
+		instances[i] = instance{Content: t, TaskType: taskType}
+	}
+	body := map[string]any{"instances": instances}
+	if e.outputDim > 0 {
+		body["parameters"] = map[string]any{"outputDimensionality": e.outputDim}
+	}
+	token, err := e.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Predictions []struct {
+			Embeddings struct {
+				Values     []float32 `json:"values"`
+				Statistics struct {
+					TokenCount int `json:"token_count"`
+				} `json:"statistics"`
+			} `json:"embeddings"`
+		} `json:"predictions"`
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	done := e.hooks.start(ctx, "vertex", e.model, texts)
+	err = postJSON(ctx, e.client, "vertex", e.endpoint, header, body, &resp)
+	tokens := 0
+	for _, p := range resp.Predictions {
+		tokens += p.Embeddings.Statistics.TokenCount
+	}
+	done(tokens, err)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(resp.Predictions))
+	for i, p := range resp.Predictions {
+		out[i] = p.Embeddings.Values
+	}
+	return out, nil
+}
@@ -0,0 +1,242 @@
+package embedder
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const googleCloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// GoogleTokenSource returns OAuth2 access tokens for Google Cloud APIs.
+type GoogleTokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// GoogleTokenFunc adapts a function (e.g. wrapping an oauth2.TokenSource) to
+// GoogleTokenSource.
+type GoogleTokenFunc func(ctx context.Context) (string, error)
+
+func (f GoogleTokenFunc) Token(ctx context.Context) (string, error) { return f(ctx) }
+
+// googleToken is an access token with its expiry.
+type googleToken struct {
+	value  string
+	expiry time.Time
+}
+
+// cachedTokenSource refreshes tokens a minute before they expire.
+type cachedTokenSource struct {
+	fetch func(ctx context.Context) (googleToken, error)
+
+	mu  sync.Mutex
+	tok googleToken
+}
+
+func (s *cachedTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tok.value != "" && time.Until(s.tok.expiry) > time.Minute {
+		return s.tok.value, nil
+	}
+	tok, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.tok = tok
+	return tok.value, nil
+}
+
+// GoogleDefaultCredentials resolves Application Default Credentials: the JSON
+// file named by GOOGLE_APPLICATION_CREDENTIALS, then gcloud's
+// application_default_credentials.json, then the GCE/GKE/Cloud Run metadata
+// server. Service account and authorized user (gcloud login) files are
+// supported.
+func GoogleDefaultCredentials(client *http.Client) (GoogleTokenSource, error) {
+	if client == nil {
+		client = newHTTPClient(0)
+	}
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			wellKnown := filepath.Join(dir, "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(wellKnown); err == nil {
+				path = wellKnown
+			}
+		}
+	}
+	if path == "" {
+		return &cachedTokenSource{fetch: func(ctx context.Context) (googleToken, error) {
+			return metadataToken(ctx, client)
+		}}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read google credentials: %w", err)
+	}
+	return GoogleCredentialsFromJSON(client, data)
+}
+
+// GoogleCredentialsFromJSON builds a token source from a service account or
+// authorized user credentials file.
+func GoogleCredentialsFromJSON(client *http.Client, data []byte) (GoogleTokenSource, error) {
+	if client == nil {
+		client = newHTTPClient(0)
+	}
+	var f struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		TokenURI     string `json:"token_uri"`
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse google credentials: %w", err)
+	}
+	tokenURI := f.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+	switch f.Type {
+	case "service_account":
+		key, err := parseRSAPrivateKey(f.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return &cachedTokenSource{fetch: func(ctx context.Context) (googleToken, error) {
+			assertion, err := signServiceAccountJWT(key, f.ClientEmail, tokenURI, time.Now())
+			if err != nil {
+				return googleToken{}, err
+			}
+			return exchangeToken(ctx, client, tokenURI, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}}, nil
+	case "authorized_user":
+		return &cachedTokenSource{fetch: func(ctx context.Context) (googleToken, error) {
+			return exchangeToken(ctx, client, tokenURI, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {f.ClientID},
+				"client_secret": {f.ClientSecret},
+				"refresh_token": {f.RefreshToken},
+			})
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported google credentials type %q", f.Type)
+	}
+}
+
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("google credentials: invalid private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("google credentials: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("google credentials: private key is not RSA")
+	}
+	return key, nil
+}
+
+// signServiceAccountJWT builds the RS256 assertion for the JWT bearer grant.
+func signServiceAccountJWT(key *rsa.PrivateKey, email string, aud string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   email,
+		"scope": googleCloudPlatformScope,
+		"aud":   aud,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signing := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + enc.EncodeToString(sig), nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func (t tokenResponse) token() (googleToken, error) {
+	if t.AccessToken == "" {
+		return googleToken{}, errors.New("google auth: empty access token")
+	}
+	return googleToken{value: t.AccessToken, expiry: time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)}, nil
+}
+
+func exchangeToken(ctx context.Context, client *http.Client, tokenURI string, form url.Values) (googleToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return googleToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(client, req)
+}
+
+func metadataToken(ctx context.Context, client *http.Client) (googleToken, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "169.254.169.254"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return googleToken{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	return doTokenRequest(client, req)
+}
+
+func doTokenRequest(client *http.Client, req *http.Request) (googleToken, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return googleToken{}, fmt.Errorf("google auth: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return googleToken{}, fmt.Errorf("google auth: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return googleToken{}, &HTTPError{Provider: "google auth", StatusCode: resp.StatusCode, Message: errorMessage(data)}
+	}
+	var tr tokenResponse
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return googleToken{}, fmt.Errorf("google auth: decode token: %w", err)
+	}
+	return tr.token()
+}
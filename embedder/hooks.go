@@ -0,0 +1,90 @@
+package embedder
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+)
+
+// RequestInfo describes one provider request.
+type RequestInfo struct {
+	Provider  string
+	Model     string // provider model id
+	InputType InputType
+	Inputs    int
+	// Preview holds the first inputs (at most 3), each cut to
+	// Hooks.PreviewChars.
+	Preview []string
+}
+
+// ResponseInfo describes the outcome of a provider request.
+type ResponseInfo struct {
+	RequestInfo
+	Latency    time.Duration
+	StatusCode int // HTTP status of a failed request, when known
+	// PromptTokens is the provider-reported token usage (0 when the provider
+	// does not report it).
+	PromptTokens int
+	Err          error
+}
+
+// Hooks observe provider requests, e.g. to log slow calls or debug a
+// provider's error responses. Callbacks run synchronously on the request path.
+type Hooks struct {
+	OnRequest  func(ctx context.Context, req RequestInfo)
+	OnResponse func(ctx context.Context, resp ResponseInfo)
+	// PreviewChars bounds each input preview (default 200; < 0 disables
+	// previews).
+	PreviewChars int
+}
+
+// start reports a request and returns the function reporting its outcome,
+// which also records token usage for EmbedTextsWithUsage. Nil hooks only
+// record usage.
+func (h *Hooks) start(ctx context.Context, provider string, model string, texts []string) func(promptTokens int, err error) {
+	usage := usageFromContext(ctx)
+	if h == nil || (h.OnRequest == nil && h.OnResponse == nil) {
+		return func(promptTokens int, err error) { usage.add(promptTokens) }
+	}
+	req := RequestInfo{
+		Provider:  provider,
+		Model:     model,
+		InputType: InputTypeFromContext(ctx),
+		Inputs:    len(texts),
+		Preview:   h.preview(texts),
+	}
+	if h.OnRequest != nil {
+		h.OnRequest(ctx, req)
+	}
+	started := time.Now()
+	return func(promptTokens int, err error) {
+		usage.add(promptTokens)
+		if h.OnResponse == nil {
+			return
+		}
+		resp := ResponseInfo{RequestInfo: req, Latency: time.Since(started), PromptTokens: promptTokens, Err: err}
+		if code, ok := StatusCode(err); ok {
+			resp.StatusCode = code
+		}
+		h.OnResponse(ctx, resp)
+	}
+}
+
+func (h *Hooks) preview(texts []string) []string {
+	limit := h.PreviewChars
+	if limit < 0 {
+		return nil
+	}
+	if limit == 0 {
+		limit = 200
+	}
+	n := min(len(texts), 3)
+	out := make([]string, n)
+	for i, t := range texts[:n] {
+		if utf8.RuneCountInString(t) > limit {
+			t = string([]rune(t)[:limit]) + "…"
+		}
+		out[i] = t
+	}
+	return out
+}
@@ -0,0 +1,202 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPError is returned by the HTTP-based providers for non-2xx responses. The
+// worker classifies it like the OpenAI client's errors (429/5xx back off and
+// retry, other 4xx wait for a config fix).
+type HTTPError struct {
+	Provider   string
+	StatusCode int
+	// Type is the provider's error type when it reports one (e.g. Bedrock's
+	// "ThrottlingException").
+	Type    string
+	Message string
+	// RetryAfter is the server's Retry-After hint (0 when absent).
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("%s: http %d: %s: %s", e.Provider, e.StatusCode, e.Type, e.Message)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%s: http %d", e.Provider, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: http %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return configHTTPClient(nil, nil, timeout)
+}
+
+// configHTTPClient picks the client for a provider config: the host's client
+// as-is when set, otherwise a client over transport (nil: the default
+// transport) with timeout (default 60s).
+func configHTTPClient(client *http.Client, transport http.RoundTripper, timeout time.Duration) *http.Client {
+	if client != nil {
+		return client
+	}
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// postJSON sends body as JSON and decodes a 2xx response into out.
+func postJSON(ctx context.Context, client *http.Client, provider string, url string, header http.Header, body any, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sendJSON(client, provider, req, out)
+}
+
+// sendJSON sends req and decodes a 2xx JSON response into out; other responses
+// become *HTTPError.
+func sendJSON(client *http.Client, provider string, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPError{
+			Provider:   provider,
+			StatusCode: resp.StatusCode,
+			Type:       errorType(resp.Header, data),
+			Message:    errorMessage(data),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: decode response: %w", provider, err)
+	}
+	return nil
+}
+
+// errorType returns the provider's error type: AWS services report it in a
+// header ("ThrottlingException:http://internal.amazon.com/..."), DashScope as
+// the body's string "code" ("Throttling.RateQuota").
+func errorType(header http.Header, body []byte) string {
+	if t := strings.SplitN(header.Get("X-Amzn-ErrorType"), ":", 2)[0]; t != "" {
+		return t
+	}
+	var shape struct {
+		Code json.RawMessage `json:"code"`
+	}
+	var code string
+	if json.Unmarshal(body, &shape) == nil && json.Unmarshal(shape.Code, &code) == nil {
+		return code
+	}
+	return ""
+}
+
+// errorMessage extracts a provider error message from common JSON error
+// shapes ({"message"}, {"detail"}, {"error": "..."} or {"error": {"message"}}),
+// falling back to the (truncated) body.
+func errorMessage(body []byte) string {
+	var shape struct {
+		Message string          `json:"message"`
+		Detail  string          `json:"detail"`
+		Error   json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &shape) == nil {
+		if shape.Message != "" {
+			return shape.Message
+		}
+		if shape.Detail != "" {
+			return shape.Detail
+		}
+		var s string
+		if json.Unmarshal(shape.Error, &s) == nil && s != "" {
+			return s
+		}
+		var nested struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(shape.Error, &nested) == nil && nested.Message != "" {
+			return nested.Message
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 512 {
+		msg = msg[:512]
+	}
+	return msg
+}
+
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// embedInBatches calls embed for consecutive batches of at most size texts and
+// concatenates the results.
+func embedInBatches(texts []string, size int, embed func(batch []string) ([][]float32, error)) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		vecs, err := embed(texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(vecs))
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+// byteBatches splits texts into consecutive runs whose summed length stays
+// within maxBytes; a text longer than maxBytes gets a run of its own. It
+// returns a single run when maxBytes <= 0.
+func byteBatches(texts []string, maxBytes int) [][]string {
+	if maxBytes <= 0 {
+		return [][]string{texts}
+	}
+	var runs [][]string
+	start, size := 0, 0
+	for i, t := range texts {
+		if i > start && size+len(t) > maxBytes {
+			runs = append(runs, texts[start:i])
+			start, size = i, 0
+		}
+		size += len(t)
+	}
+	return append(runs, texts[start:])
+}
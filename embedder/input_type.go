@@ -0,0 +1,29 @@
+package embedder
+
+import "context"
+
+// InputType tells providers with asymmetric embeddings (Cohere, Voyage, ...)
+// whether a text is a search query or a stored document.
+type InputType string
+
+const (
+	InputDocument InputType = "document"
+	InputQuery    InputType = "query"
+)
+
+type inputTypeKey struct{}
+
+// WithInputType marks texts embedded with ctx as queries or documents. The
+// runtime sets InputQuery for query embeddings; unmarked texts are documents.
+func WithInputType(ctx context.Context, t InputType) context.Context {
+	return context.WithValue(ctx, inputTypeKey{}, t)
+}
+
+// InputTypeFromContext returns the input type set by WithInputType, or
+// InputDocument.
+func InputTypeFromContext(ctx context.Context) InputType {
+	if t, ok := ctx.Value(inputTypeKey{}).(InputType); ok && t != "" {
+		return t
+	}
+	return InputDocument
+}
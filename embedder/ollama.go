@@ -0,0 +1,175 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LocalServer selects the API of a self-hosted embedding server.
+type LocalServer string
+
+const (
+	// LocalServerOllama uses Ollama's batch endpoint /api/embed (default).
+	LocalServerOllama LocalServer = "ollama"
+	// LocalServerOllamaLegacy uses Ollama's older /api/embeddings, one text
+	// per request.
+	LocalServerOllamaLegacy LocalServer = "ollama-legacy"
+	// LocalServerLlamaCpp uses llama.cpp server's /embedding, one text per
+	// request.
+	LocalServerLlamaCpp LocalServer = "llamacpp"
+)
+
+type OllamaConfig struct {
+	BaseURL    string // optional; defaults to http://localhost:11434
+	Model      string // e.g. "nomic-embed-text"; informational for llama.cpp
+	Dimensions int    // required: local models have no registry to look it up
+	Server     LocalServer
+	APIKey     string // optional (llama.cpp --api-key)
+	Timeout    time.Duration
+	// HTTPClient, when set, is used for provider requests as-is (proxies,
+	// mTLS, instrumentation); Timeout and Transport are then ignored.
+	HTTPClient *http.Client
+	// Transport, when set (and HTTPClient is not), carries provider requests
+	// in a client with Timeout.
+	Transport http.RoundTripper
+
+	// BatchSize caps texts per /api/embed request (default 32). Sequential
+	// sends one text per request even to /api/embed, for servers that accept
+	// the endpoint but mishandle batches.
+	BatchSize  int
+	Sequential bool
+
+	// Hooks observe each server request (optional).
+	Hooks *Hooks
+}
+
+// OllamaEmbedder calls a local Ollama or llama.cpp server, so searchkit can
+// run without any hosted provider. Servers without a batch endpoint get
+// batches emulated with sequential requests.
+type OllamaEmbedder struct {
+	client     *http.Client
+	baseURL    string
+	model      string
+	dimensions int
+	server     LocalServer
+	apiKey     string
+	batchSize  int
+	hooks      *Hooks
+}
+
+func NewOllama(cfg OllamaConfig) (*OllamaEmbedder, error) {
+	server := cfg.Server
+	if server == "" {
+		server = LocalServerOllama
+	}
+	switch server {
+	case LocalServerOllama, LocalServerOllamaLegacy:
+		if strings.TrimSpace(cfg.Model) == "" {
+			return nil, fmt.Errorf("model is required")
+		}
+	case LocalServerLlamaCpp:
+	default:
+		return nil, fmt.Errorf("invalid local server %q", server)
+	}
+	if cfg.Dimensions <= 0 {
+		return nil, fmt.Errorf("dimensions are required")
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	batch := cfg.BatchSize
+	if batch <= 0 {
+		batch = 32
+	}
+	if cfg.Sequential || server != LocalServerOllama {
+		batch = 1
+	}
+	return &OllamaEmbedder{
+		client:     configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		baseURL:    baseURL,
+		model:      cfg.Model,
+		dimensions: cfg.Dimensions,
+		server:     server,
+		apiKey:     cfg.APIKey,
+		batchSize:  batch,
+		hooks:      cfg.Hooks,
+	}, nil
+}
+
+func (e *OllamaEmbedder) Model() string   { return e.model }
+func (e *OllamaEmbedder) Dimensions() int { return e.dimensions }
+
+func (e *OllamaEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+func (e *OllamaEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	header := http.Header{}
+	if e.apiKey != "" {
+		header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	return embedInBatches(texts, e.batchSize, func(batch []string) (vecs [][]float32, err error) {
+		tokens := 0
+		done := e.hooks.start(ctx, string(e.server), e.model, batch)
+		defer func() { done(tokens, err) }()
+		switch e.server {
+		case LocalServerOllamaLegacy:
+			var resp struct {
+				Embedding []float32 `json:"embedding"`
+			}
+			err := postJSON(ctx, e.client, "ollama", e.baseURL+"/api/embeddings", header, map[string]any{"model": e.model, "prompt": batch[0]}, &resp)
+			return [][]float32{resp.Embedding}, err
+		case LocalServerLlamaCpp:
+			var raw json.RawMessage
+			if err := postJSON(ctx, e.client, "llamacpp", e.baseURL+"/embedding", header, map[string]any{"content": batch[0]}, &raw); err != nil {
+				return nil, err
+			}
+			vec, err := parseLlamaCppEmbedding(raw)
+			if err != nil {
+				return nil, err
+			}
+			return [][]float32{vec}, nil
+		default:
+			var resp struct {
+				Embeddings      [][]float32 `json:"embeddings"`
+				PromptEvalCount int         `json:"prompt_eval_count"`
+			}
+			err := postJSON(ctx, e.client, "ollama", e.baseURL+"/api/embed", header, map[string]any{"model": e.model, "input": batch}, &resp)
+			tokens = resp.PromptEvalCount
+			return resp.Embeddings, err
+		}
+	})
+}
+
+// parseLlamaCppEmbedding accepts both llama.cpp /embedding response shapes:
+// {"embedding": [...]} and [{"index": 0, "embedding": [[...]]}] (pooled).
+func parseLlamaCppEmbedding(raw json.RawMessage) ([]float32, error) {
+	var flat struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(raw, &flat); err == nil && len(flat.Embedding) > 0 {
+		return flat.Embedding, nil
+	}
+	var pooled []struct {
+		Embedding [][]float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(raw, &pooled); err == nil && len(pooled) > 0 && len(pooled[0].Embedding) == 1 {
+		return pooled[0].Embedding[0], nil
+	}
+	return nil, fmt.Errorf("llamacpp: unexpected embedding response (is the server running with pooling enabled?)")
+}
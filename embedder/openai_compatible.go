@@ -8,8 +8,6 @@ import (
 	"time"
 
 	"github.com/sashabaranov/go-openai"
-
-	"github.com/open-rails/searchkit/internal/normalize"
 )
 
 type OpenAICompatibleConfig struct {
@@ -19,6 +17,49 @@ type OpenAICompatibleConfig struct {
 	Dimensions int    // optional; 0 means provider default
 	Timeout    time.Duration
 	Provider   string // advisory (deepinfra|dashscope|modelscope|...)
+	// HTTPClient, when set, is used for provider requests as-is (proxies,
+	// mTLS, instrumentation); Timeout and Transport are then ignored.
+	HTTPClient *http.Client
+	// Transport, when set (and HTTPClient is not), carries provider requests
+	// in a client with Timeout.
+	Transport http.RoundTripper
+	// MaxBatch is the provider's per-request input limit. 0 uses the known
+	// limit for the Provider hint (openai: 2048, dashscope: 10), else inputs
+	// are sent in one request. Larger EmbedTexts calls are split.
+	MaxBatch int
+	// MaxBatchBytes caps the summed input length (bytes) per request
+	// (0: unlimited); larger EmbedTexts calls are split, a single oversized
+	// input going alone. Independently of it, a batch rejected with 413 is
+	// halved and retried.
+	MaxBatchBytes int
+	// Base64 requests base64-encoded (little-endian float32) embeddings instead
+	// of JSON float arrays, cutting response size ~4x for large models. The
+	// provider must support encoding_format=base64 (OpenAI, Azure, vLLM, TEI).
+	Base64 bool
+
+	// ModelMapping maps canonical model names (case-insensitive) to the
+	// provider's model id, taking precedence over the built-in table.
+	ModelMapping map[string]string
+	// ModelResolver, when set, maps the canonical model name and Provider to the
+	// provider's model id; it takes precedence over ModelMapping. Return "" to
+	// fall back to the mapping tables.
+	ModelResolver func(canonical string, provider string) string
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// defaultProviderModels is the built-in canonical -> provider model id table,
+// keyed by (lowercase) provider hint and canonical name.
+var defaultProviderModels = map[string]map[string]string{
+	"deepinfra": {"qwen-3-embedding-4b": "Qwen/Qwen3-Embedding-4B"},
+	"dashscope": {"qwen-3-embedding-4b": "text-embedding-v4"},
+}
+
+// defaultProviderMaxBatch holds known per-request input limits, keyed by
+// (lowercase) provider hint.
+var defaultProviderMaxBatch = map[string]int{
+	"openai":    2048,
+	"dashscope": 10,
 }
 
 type OpenAICompatibleEmbedder struct {
@@ -26,6 +67,14 @@ type OpenAICompatibleEmbedder struct {
 	model      string
 	dimensions int
 	provider   string
+	name       string // provider label reported to hooks
+
+	mapping  map[string]string
+	resolver func(canonical string, provider string) string
+	hooks    *Hooks
+	maxBatch int
+	maxBytes int
+	encoding openai.EmbeddingEncodingFormat
 }
 
 func NewOpenAICompatible(cfg OpenAICompatibleConfig) (*OpenAICompatibleEmbedder, error) {
@@ -37,41 +86,60 @@ func NewOpenAICompatible(cfg OpenAICompatibleConfig) (*OpenAICompatibleEmbedder,
 	}
 	openaiCfg := openai.DefaultConfig(cfg.APIKey)
 	openaiCfg.BaseURL = cfg.BaseURL
-	timeout := cfg.Timeout
-	if timeout <= 0 {
-		timeout = 60 * time.Second
+	openaiCfg.HTTPClient = configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout)
+	mapping := make(map[string]string, len(cfg.ModelMapping))
+	for k, v := range cfg.ModelMapping {
+		mapping[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+	maxBatch := cfg.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = defaultProviderMaxBatch[strings.ToLower(strings.TrimSpace(cfg.Provider))]
 	}
-	openaiCfg.HTTPClient = &http.Client{Timeout: timeout}
 	return &OpenAICompatibleEmbedder{
 		client:     openai.NewClientWithConfig(openaiCfg),
 		model:      cfg.Model,
 		dimensions: cfg.Dimensions,
 		provider:   cfg.Provider,
+		name:       "openai-compatible",
+		mapping:    mapping,
+		resolver:   cfg.ModelResolver,
+		hooks:      cfg.Hooks,
+		maxBatch:   maxBatch,
+		maxBytes:   cfg.MaxBatchBytes,
+		encoding:   encodingFormat(cfg.Base64),
 	}, nil
 }
 
+func encodingFormat(base64 bool) openai.EmbeddingEncodingFormat {
+	if base64 {
+		return openai.EmbeddingEncodingFormatBase64
+	}
+	return ""
+}
+
 func (e *OpenAICompatibleEmbedder) Model() string { return e.model }
 func (e *OpenAICompatibleEmbedder) Dimensions() int {
 	return e.dimensions
 }
 
-// mapCanonicalModel maps a canonical model name to a provider-specific model id.
-// This intentionally mirrors the current doujins behavior and can be expanded later.
+// mapCanonicalModel maps a canonical model name to a provider-specific model id:
+// ModelResolver, then ModelMapping, then the built-in table for the provider
+// hint, else the canonical name itself.
 func (e *OpenAICompatibleEmbedder) mapCanonicalModel(canonical string) string {
-	hint := strings.ToLower(strings.TrimSpace(e.provider))
-	name := strings.ToLower(strings.TrimSpace(canonical))
-	switch name {
-	case "qwen-3-embedding-4b":
-		if hint == "deepinfra" {
-			return "Qwen/Qwen3-Embedding-4B"
-		}
-		if hint == "dashscope" {
-			return "text-embedding-v4"
+	if e.resolver != nil {
+		if id := e.resolver(canonical, e.provider); id != "" {
+			return id
 		}
-		return canonical
-	default:
-		return canonical
 	}
+	name := strings.ToLower(strings.TrimSpace(canonical))
+	if id, ok := e.mapping[name]; ok && id != "" {
+		return id
+	}
+	hint := strings.ToLower(strings.TrimSpace(e.provider))
+	if id, ok := defaultProviderModels[hint][name]; ok {
+		return id
+	}
+	return canonical
 }
 
 func (e *OpenAICompatibleEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
@@ -89,15 +157,41 @@ func (e *OpenAICompatibleEmbedder) EmbedTexts(ctx context.Context, texts []strin
 	if len(texts) == 0 {
 		return nil, nil
 	}
+	if e.maxBatch > 0 && len(texts) > e.maxBatch {
+		return embedInBatches(texts, e.maxBatch, func(batch []string) ([][]float32, error) {
+			return e.EmbedTexts(ctx, batch)
+		})
+	}
+	if runs := byteBatches(texts, e.maxBytes); len(runs) > 1 {
+		out := make([][]float32, 0, len(texts))
+		for _, run := range runs {
+			vecs, err := e.EmbedTexts(ctx, run)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, vecs...)
+		}
+		return out, nil
+	}
 	req := openai.EmbeddingRequest{
-		Input: texts,
-		Model: openai.EmbeddingModel(e.mapCanonicalModel(e.model)),
+		Input:          texts,
+		Model:          openai.EmbeddingModel(e.mapCanonicalModel(e.model)),
+		EncodingFormat: e.encoding,
 	}
 	if e.dimensions > 0 {
 		req.Dimensions = e.dimensions
 	}
 
+	done := e.hooks.start(ctx, e.name, string(req.Model), texts)
 	resp, err := e.client.CreateEmbeddings(ctx, req)
+	done(resp.Usage.PromptTokens, err)
+	if code, _ := StatusCode(err); code == http.StatusRequestEntityTooLarge && len(texts) > 1 {
+		// The provider's payload limit is lower than configured (or unknown):
+		// halve the batch until it fits.
+		return embedInBatches(texts, (len(texts)+1)/2, func(batch []string) ([][]float32, error) {
+			return e.EmbedTexts(ctx, batch)
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -105,14 +199,11 @@ func (e *OpenAICompatibleEmbedder) EmbedTexts(ctx context.Context, texts []strin
 		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
 	}
 
+	// Vectors are returned as the provider sent them; the runtime applies the
+	// model's normalization policy.
 	out := make([][]float32, len(resp.Data))
 	for i, row := range resp.Data {
-		vec := make([]float32, len(row.Embedding))
-		for j, v := range row.Embedding {
-			vec[j] = float32(v)
-		}
-		normalize.L2NormalizeInPlace(vec)
-		out[i] = vec
+		out[i] = row.Embedding
 	}
 	return out, nil
 }
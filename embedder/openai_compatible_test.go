@@ -0,0 +1,165 @@
+package embedder
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type headerTransport struct {
+	header string
+	next   http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-Proxy", t.header)
+	return t.next.RoundTrip(r)
+}
+
+func TestOpenAICompatible_UsesHostTransport(t *testing.T) {
+	var seen string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Proxy")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","embedding":[3,4],"index":0}],"usage":{"prompt_tokens":1,"total_tokens":1}}`))
+	}))
+	defer srv.Close()
+
+	e, err := NewOpenAICompatible(OpenAICompatibleConfig{
+		BaseURL:   srv.URL,
+		Model:     "m",
+		Transport: headerTransport{header: "mesh", next: http.DefaultTransport},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.EmbedText(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if seen != "mesh" {
+		t.Fatalf("expected request to go through the host transport, got header %q", seen)
+	}
+}
+
+func TestAzureOpenAI_DeploymentURLAndEntraToken(t *testing.T) {
+	var path, version, auth, key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, version = r.URL.Path, r.URL.Query().Get("api-version")
+		auth, key = r.Header.Get("Authorization"), r.Header.Get("api-key")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","embedding":[3,4],"index":0}],"usage":{"prompt_tokens":1,"total_tokens":1}}`))
+	}))
+	defer srv.Close()
+
+	e, err := NewAzureOpenAI(AzureOpenAIConfig{
+		Endpoint:    srv.URL,
+		Deployment:  "embed-prod",
+		Model:       "text-embedding-3-large",
+		TokenSource: AzureTokenFunc(func(context.Context) (string, error) { return "entra", nil }),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.EmbedText(context.Background(), "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/openai/deployments/embed-prod/embeddings" || version != AzureOpenAIDefaultAPIVersion {
+		t.Fatalf("unexpected request %s?api-version=%s", path, version)
+	}
+	if auth != "Bearer entra" || key != "" {
+		t.Fatalf("expected bearer token auth only, got Authorization=%q api-key=%q", auth, key)
+	}
+}
+
+func TestOpenAICompatible_Base64Embeddings(t *testing.T) {
+	var format string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			EncodingFormat string `json:"encoding_format"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		format = req.EncodingFormat
+		raw := make([]byte, 8)
+		binary.LittleEndian.PutUint32(raw[0:], math.Float32bits(3))
+		binary.LittleEndian.PutUint32(raw[4:], math.Float32bits(4))
+		fmt.Fprintf(w, `{"object":"list","data":[{"object":"embedding","embedding":%q,"index":0}],"usage":{"prompt_tokens":1,"total_tokens":1}}`,
+			base64.StdEncoding.EncodeToString(raw))
+	}))
+	defer srv.Close()
+
+	e, err := NewOpenAICompatible(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "m", Base64: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vec, err := e.EmbedText(context.Background(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "base64" {
+		t.Fatalf("expected encoding_format=base64, got %q", format)
+	}
+	if len(vec) != 2 || vec[0] != 3 || vec[1] != 4 {
+		t.Fatalf("unexpected decoded vector %v", vec)
+	}
+}
+
+func TestOpenAICompatible_SplitsOversizedBatches(t *testing.T) {
+	var sizes []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sizes = append(sizes, len(req.Input))
+		if len(req.Input) > 2 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			_, _ = w.Write([]byte(`{"error":{"message":"payload too large"}}`))
+			return
+		}
+		type row struct {
+			Object    string    `json:"object"`
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}
+		resp := struct {
+			Object string `json:"object"`
+			Data   []row  `json:"data"`
+		}{Object: "list"}
+		for i, in := range req.Input {
+			resp.Data = append(resp.Data, row{Object: "embedding", Embedding: []float32{float32(len(in)), 1}, Index: i})
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	e, err := NewOpenAICompatible(OpenAICompatibleConfig{BaseURL: srv.URL, Model: "m", MaxBatchBytes: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{"a", "bb", "ccc", "dddddddd", "e", "f", "g"}
+	vecs, err := e.EmbedTexts(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != len(texts) {
+		t.Fatalf("expected %d vectors, got %d", len(texts), len(vecs))
+	}
+	// Each vector is (len(text), 1), so the ratio of its components identifies
+	// the text it belongs to.
+	for i, v := range vecs {
+		if got := math.Round(float64(v[0] / v[1])); got != float64(len(texts[i])) {
+			t.Fatalf("vector %d belongs to a text of length %v, want %d", i, got, len(texts[i]))
+		}
+	}
+	// byte runs: [a bb ccc] [dddddddd] [e f g]; the 3-input runs get a 413 and
+	// are halved.
+	want := fmt.Sprint([]int{3, 2, 1, 1, 3, 2, 1})
+	if got := fmt.Sprint(sizes); got != want {
+		t.Fatalf("request sizes = %s, want %s", got, want)
+	}
+}
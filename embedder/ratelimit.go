@@ -0,0 +1,69 @@
+package embedder
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit bounds inner's calls to rps requests per second and at most
+// concurrent in flight (<= 0 disables either bound), so every caller of the
+// embedder (the worker, Runtime.GenerateAndStore*, query embedding) shares
+// the provider's limits. Waiting honours ctx cancellation.
+func WithRateLimit(inner Embedder, rps float64, concurrent int) Embedder {
+	e := &rateLimitedEmbedder{inner: inner}
+	if rps > 0 {
+		e.limiter = rate.NewLimiter(rate.Limit(rps), max(concurrent, 1))
+	}
+	if concurrent > 0 {
+		e.slots = make(chan struct{}, concurrent)
+	}
+	return e
+}
+
+type rateLimitedEmbedder struct {
+	inner   Embedder
+	limiter *rate.Limiter
+	slots   chan struct{}
+}
+
+func (e *rateLimitedEmbedder) Model() string   { return e.inner.Model() }
+func (e *rateLimitedEmbedder) Dimensions() int { return e.inner.Dimensions() }
+
+func (e *rateLimitedEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return e.inner.EmbedText(ctx, text)
+}
+
+func (e *rateLimitedEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return e.inner.EmbedTexts(ctx, texts)
+}
+
+// acquire takes a concurrency slot, then a rate token.
+func (e *rateLimitedEmbedder) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if e.slots != nil {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case e.slots <- struct{}{}:
+		}
+		release = func() { <-e.slots }
+	}
+	if e.limiter != nil {
+		if err := e.limiter.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,137 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// RetryPolicy configures WithRetry. Zero fields take the defaults noted below.
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts, including the first (default 3)
+	BaseDelay      time.Duration // first backoff (default 500ms), doubled per attempt
+	MaxDelay       time.Duration // backoff cap (default 10s)
+	JitterFraction float64       // up to this fraction of the delay is added (default 0.2)
+
+	// Retryable classifies errors (default IsTransient). Override it per
+	// provider, e.g. to also retry a provider-specific "model loading" 4xx.
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = 10 * time.Second
+	}
+	if p.JitterFraction <= 0 {
+		p.JitterFraction = 0.2
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsTransient
+	}
+	return p
+}
+
+// IsTransient reports whether err is worth retrying immediately: HTTP 408, 429
+// and 5xx from any provider, and network timeouts. Configuration errors (other
+// 4xx) are not; the worker's task queue retries those on a slower cadence.
+func IsTransient(err error) bool {
+	if code, ok := StatusCode(err); ok {
+		return code == 408 || code == 429 || (code >= 500 && code <= 599)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// StatusCode returns the HTTP status carried by a provider error (*HTTPError or
+// the OpenAI client's error types).
+func StatusCode(err error) (int, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode, true
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode, true
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode, true
+	}
+	return 0, false
+}
+
+// WithRetry retries inner's transient failures with bounded, jittered
+// exponential backoff (honouring Retry-After), so single-item Runtime calls get
+// basic resilience without the task queue.
+func WithRetry(inner Embedder, policy RetryPolicy) Embedder {
+	return &retryingEmbedder{inner: inner, policy: policy.withDefaults()}
+}
+
+type retryingEmbedder struct {
+	inner  Embedder
+	policy RetryPolicy
+}
+
+func (e *retryingEmbedder) Model() string   { return e.inner.Model() }
+func (e *retryingEmbedder) Dimensions() int { return e.inner.Dimensions() }
+
+func (e *retryingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	var vec []float32
+	err := e.do(ctx, func() (err error) {
+		vec, err = e.inner.EmbedText(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+func (e *retryingEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	var vecs [][]float32
+	err := e.do(ctx, func() (err error) {
+		vecs, err = e.inner.EmbedTexts(ctx, texts)
+		return err
+	})
+	return vecs, err
+}
+
+func (e *retryingEmbedder) do(ctx context.Context, call func() error) error {
+	p := e.policy
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= p.MaxAttempts || !p.Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(attempt-1)))
+		if delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+		if j := int64(float64(delay) * p.JitterFraction); j > 0 {
+			delay += time.Duration(rand.Int64N(j))
+		}
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+			if httpErr.RetryAfter > p.MaxDelay {
+				// Waiting longer than the policy allows: leave it to the caller.
+				return err
+			}
+			delay = httpErr.RetryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
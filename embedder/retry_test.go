@@ -0,0 +1,37 @@
+package embedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type flakyEmbedder struct {
+	countingEmbedder
+	failures []error
+}
+
+func (e *flakyEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	if len(e.failures) > 0 {
+		err := e.failures[0]
+		e.failures = e.failures[1:]
+		return nil, err
+	}
+	return e.countingEmbedder.EmbedText(ctx, text)
+}
+
+func TestWithRetry_RetriesTransientErrorsOnly(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Millisecond}
+	inner := &flakyEmbedder{failures: []error{&HTTPError{StatusCode: 503}, &HTTPError{StatusCode: 429}}}
+	if _, err := WithRetry(inner, policy).EmbedText(context.Background(), "x"); err != nil {
+		t.Fatalf("expected success after transient errors, got %v", err)
+	}
+
+	inner = &flakyEmbedder{failures: []error{&HTTPError{StatusCode: 401}}}
+	if _, err := WithRetry(inner, policy).EmbedText(context.Background(), "x"); err == nil {
+		t.Fatal("expected a 401 to be returned without retrying")
+	}
+	if len(inner.failures) != 0 || inner.inputs != 0 {
+		t.Fatalf("unexpected retry of a permanent error")
+	}
+}
@@ -0,0 +1,152 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SparseVector is a learned sparse embedding (SPLADE, BM42, ...): Indices are
+// vocabulary positions in ascending order and Values their weights.
+type SparseVector struct {
+	Indices []int32
+	Values  []float32
+}
+
+// SparseEmbedder generates sparse text embeddings. Dimensions is the
+// vocabulary size (the sparse vector's dimension).
+type SparseEmbedder interface {
+	Model() string
+	Dimensions() int
+	EmbedSparseTexts(ctx context.Context, texts []string) ([]SparseVector, error)
+}
+
+// TopK returns v restricted to its k largest weights (indices kept in
+// ascending order). v is returned unchanged when it has at most k entries.
+func (v SparseVector) TopK(k int) SparseVector {
+	if k <= 0 || len(v.Indices) <= k {
+		return v
+	}
+	order := make([]int, len(v.Indices))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return v.Values[order[a]] > v.Values[order[b]] })
+	order = order[:k]
+	sort.Ints(order)
+	out := SparseVector{Indices: make([]int32, k), Values: make([]float32, k)}
+	for i, j := range order {
+		out.Indices[i] = v.Indices[j]
+		out.Values[i] = v.Values[j]
+	}
+	return out
+}
+
+const (
+	// defaultSparseVocab is the BERT WordPiece vocabulary size used by SPLADE
+	// models.
+	defaultSparseVocab  = 30522
+	defaultTEISparseMax = 32
+)
+
+type TEISparseConfig struct {
+	BaseURL    string // text-embeddings-inference server, e.g. http://splade:8080
+	Model      string // canonical model name used by the host app
+	Dimensions int    // vocabulary size; defaults to 30522 (BERT/SPLADE)
+	APIKey     string // optional bearer token
+	// MaxBatch caps texts per request (default 32, TEI's default
+	// --max-client-batch-size).
+	MaxBatch int
+
+	Timeout    time.Duration
+	HTTPClient *http.Client      // optional; see OpenAICompatibleConfig.HTTPClient
+	Transport  http.RoundTripper // optional; see OpenAICompatibleConfig.Transport
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// TEISparseEmbedder calls the /embed_sparse endpoint of Hugging Face
+// text-embeddings-inference serving a SPLADE-style model.
+type TEISparseEmbedder struct {
+	client     *http.Client
+	baseURL    string
+	apiKey     string
+	model      string
+	dimensions int
+	maxBatch   int
+	hooks      *Hooks
+}
+
+func NewTEISparse(cfg TEISparseConfig) (*TEISparseEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("base URL is required")
+	}
+	dims := cfg.Dimensions
+	if dims <= 0 {
+		dims = defaultSparseVocab
+	}
+	maxBatch := cfg.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = defaultTEISparseMax
+	}
+	return &TEISparseEmbedder{
+		client:     configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		dimensions: dims,
+		maxBatch:   maxBatch,
+		hooks:      cfg.Hooks,
+	}, nil
+}
+
+func (e *TEISparseEmbedder) Model() string   { return e.model }
+func (e *TEISparseEmbedder) Dimensions() int { return e.dimensions }
+func (e *TEISparseEmbedder) MaxBatch() int   { return e.maxBatch }
+
+func (e *TEISparseEmbedder) EmbedSparseTexts(ctx context.Context, texts []string) ([]SparseVector, error) {
+	header := http.Header{}
+	if e.apiKey != "" {
+		header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	out := make([]SparseVector, 0, len(texts))
+	for start := 0; start < len(texts); start += e.maxBatch {
+		batch := texts[start:min(start+e.maxBatch, len(texts))]
+		req := struct {
+			Inputs   []string `json:"inputs"`
+			Truncate bool     `json:"truncate"`
+		}{Inputs: batch, Truncate: true}
+		var resp [][]struct {
+			Index int32   `json:"index"`
+			Value float32 `json:"value"`
+		}
+		done := e.hooks.start(ctx, "tei-sparse", e.model, batch)
+		err := postJSON(ctx, e.client, "tei-sparse", e.baseURL+"/embed_sparse", header, req, &resp)
+		done(0, err)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(resp))
+		}
+		for _, terms := range resp {
+			sort.Slice(terms, func(i, j int) bool { return terms[i].Index < terms[j].Index })
+			v := SparseVector{Indices: make([]int32, len(terms)), Values: make([]float32, len(terms))}
+			for i, t := range terms {
+				if int(t.Index) >= e.dimensions {
+					return nil, fmt.Errorf("tei-sparse: index %d outside vocabulary of %d", t.Index, e.dimensions)
+				}
+				v.Indices[i], v.Values[i] = t.Index, t.Value
+			}
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,18 @@
+package embedder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSparseVector_TopKKeepsLargestWeightsInIndexOrder(t *testing.T) {
+	v := SparseVector{Indices: []int32{2, 5, 9, 12}, Values: []float32{0.1, 3, 0.2, 1}}
+	got := v.TopK(2)
+	want := SparseVector{Indices: []int32{5, 12}, Values: []float32{3, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("TopK(2) = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(v.TopK(10), v) {
+		t.Fatalf("expected short vectors to be returned unchanged")
+	}
+}
@@ -0,0 +1,62 @@
+package embedder
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Usage is the provider-reported usage of embedding calls. Providers that do
+// not report usage (and cache hits) contribute nothing.
+type Usage struct {
+	PromptTokens int
+}
+
+type usageKey struct{}
+
+// usageCollector sums usage; nested collectors also add to their parent.
+type usageCollector struct {
+	parent       *usageCollector
+	promptTokens atomic.Int64
+}
+
+func (c *usageCollector) add(promptTokens int) {
+	if promptTokens <= 0 {
+		return
+	}
+	for ; c != nil; c = c.parent {
+		c.promptTokens.Add(int64(promptTokens))
+	}
+}
+
+func (c *usageCollector) usage() Usage {
+	return Usage{PromptTokens: int(c.promptTokens.Load())}
+}
+
+func usageFromContext(ctx context.Context) *usageCollector {
+	c, _ := ctx.Value(usageKey{}).(*usageCollector)
+	return c
+}
+
+// TrackUsage returns a context whose embedding calls (at any depth, e.g.
+// through Runtime.GenerateAndStore*) add their provider-reported usage to the
+// returned total.
+func TrackUsage(ctx context.Context) (context.Context, func() Usage) {
+	c := &usageCollector{parent: usageFromContext(ctx)}
+	return context.WithValue(ctx, usageKey{}, c), c.usage
+}
+
+// EmbedTextsWithUsage calls e.EmbedTexts and returns the token usage reported
+// by the provider(s) it called, through any decorators.
+func EmbedTextsWithUsage(ctx context.Context, e Embedder, texts []string) ([][]float32, Usage, error) {
+	ctx, usage := TrackUsage(ctx)
+	vecs, err := e.EmbedTexts(ctx, texts)
+	return vecs, usage(), err
+}
+
+// EmbedTextWithUsage is EmbedTextsWithUsage for a single text (calling
+// e.EmbedText).
+func EmbedTextWithUsage(ctx context.Context, e Embedder, text string) ([]float32, Usage, error) {
+	ctx, usage := TrackUsage(ctx)
+	vec, err := e.EmbedText(ctx, text)
+	return vec, usage(), err
+}
@@ -0,0 +1,183 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-rails/searchkit/internal/tokens"
+)
+
+const (
+	defaultVoyageBaseURL = "https://api.voyageai.com"
+	// voyageMaxBatch is Voyage's per-request limit on inputs.
+	voyageMaxBatch = 1000
+	// defaultVoyageMaxBatchTokens is the strictest per-request token limit of
+	// the voyage-3 family (voyage-3-large); the smaller models allow more.
+	defaultVoyageMaxBatchTokens = 120_000
+)
+
+// voyageDims are the default output dimensions of the voyage-3 family.
+var voyageDims = map[string]int{
+	"voyage-3":        1024,
+	"voyage-3-lite":   512,
+	"voyage-3-large":  1024,
+	"voyage-3.5":      1024,
+	"voyage-3.5-lite": 1024,
+	"voyage-code-3":   1024,
+}
+
+type VoyageConfig struct {
+	APIKey     string
+	Model      string // e.g. "voyage-3"
+	Dimensions int    // optional; sets output_dimension when it differs from the model default
+	BaseURL    string // optional; defaults to https://api.voyageai.com
+	Timeout    time.Duration
+	// HTTPClient, when set, is used for provider requests as-is (proxies,
+	// mTLS, instrumentation); Timeout and Transport are then ignored.
+	HTTPClient *http.Client
+	// Transport, when set (and HTTPClient is not), carries provider requests
+	// in a client with Timeout.
+	Transport http.RoundTripper
+	// MaxBatchTokens caps the estimated tokens per request (default 120k, the
+	// voyage-3-large limit). Raise it for models with larger limits.
+	MaxBatchTokens int
+	// Hooks observe each provider request (optional).
+	Hooks *Hooks
+}
+
+// VoyageEmbedder calls Voyage AI's embeddings endpoint. Texts are embedded with
+// input_type "query" when ctx carries InputQuery and "document" otherwise.
+// Batches are split by Voyage's input count and (estimated) token limits.
+type VoyageEmbedder struct {
+	client         *http.Client
+	baseURL        string
+	apiKey         string
+	model          string
+	dimensions     int
+	outputDim      int
+	maxBatchTokens int
+	hooks          *Hooks
+}
+
+func NewVoyage(cfg VoyageConfig) (*VoyageEmbedder, error) {
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(cfg.APIKey) == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+	dims := voyageDims[cfg.Model]
+	outputDim := 0
+	if cfg.Dimensions > 0 && cfg.Dimensions != dims {
+		dims = cfg.Dimensions
+		outputDim = cfg.Dimensions
+	}
+	if dims <= 0 {
+		return nil, fmt.Errorf("dimensions are required for model %q", cfg.Model)
+	}
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		baseURL = defaultVoyageBaseURL
+	}
+	maxTokens := cfg.MaxBatchTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultVoyageMaxBatchTokens
+	}
+	return &VoyageEmbedder{
+		client:         configHTTPClient(cfg.HTTPClient, cfg.Transport, cfg.Timeout),
+		baseURL:        baseURL,
+		apiKey:         cfg.APIKey,
+		model:          cfg.Model,
+		dimensions:     dims,
+		outputDim:      outputDim,
+		maxBatchTokens: maxTokens,
+		hooks:          cfg.Hooks,
+	}, nil
+}
+
+func (e *VoyageEmbedder) Model() string   { return e.model }
+func (e *VoyageEmbedder) Dimensions() int { return e.dimensions }
+
+func (e *VoyageEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := e.EmbedTexts(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vecs))
+	}
+	return vecs[0], nil
+}
+
+func (e *VoyageEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	out := make([][]float32, 0, len(texts))
+	start, budget := 0, 0
+	for i, t := range texts {
+		n := tokens.Estimate(t)
+		if i > start && (i-start >= voyageMaxBatch || budget+n > e.maxBatchTokens) {
+			vecs, err := e.embedBatch(ctx, texts[start:i])
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, vecs...)
+			start, budget = i, 0
+		}
+		budget += n
+	}
+	vecs, err := e.embedBatch(ctx, texts[start:])
+	if err != nil {
+		return nil, err
+	}
+	return append(out, vecs...), nil
+}
+
+func (e *VoyageEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	inputType := "document"
+	if InputTypeFromContext(ctx) == InputQuery {
+		inputType = "query"
+	}
+	req := struct {
+		Input           []string `json:"input"`
+		Model           string   `json:"model"`
+		InputType       string   `json:"input_type"`
+		OutputDimension int      `json:"output_dimension,omitempty"`
+	}{
+		Input:           texts,
+		Model:           e.model,
+		InputType:       inputType,
+		OutputDimension: e.outputDim,
+	}
+	var resp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		} `json:"data"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+e.apiKey)
+	done := e.hooks.start(ctx, "voyage", e.model, texts)
+	err := postJSON(ctx, e.client, "voyage", e.baseURL+"/v1/embeddings", header, req, &resp)
+	done(resp.Usage.TotalTokens, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	out := make([][]float32, len(resp.Data))
+	for i, d := range resp.Data {
+		out[i] = d.Embedding
+	}
+	return out, nil
+}
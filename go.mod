@@ -10,6 +10,7 @@ require (
 	github.com/pgvector/pgvector-go v0.2.2
 	github.com/sashabaranov/go-openai v1.40.3
 	golang.org/x/text v0.29.0
+	golang.org/x/time v0.14.0
 )
 
 require (
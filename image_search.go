@@ -0,0 +1,98 @@
+package searchkit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/search"
+	"github.com/open-rails/searchkit/vl"
+)
+
+// ImageEmbedder embeds query images for VL models (implemented by
+// runtime.Runtime).
+type ImageEmbedder interface {
+	EmbedQueryImage(ctx context.Context, model string, image vl.AssetURL) ([]float32, error)
+}
+
+type ImageSearchRequest struct {
+	Schema string
+	// Model is the VL model to search.
+	Model    string
+	Language string // defaults to "en"
+
+	// Set one of ImageURL (fetched by the provider) or ImageBytes (sent inline
+	// as a data: URL; the provider must accept data URLs, as DashScope does).
+	ImageURL   string
+	ImageBytes []byte
+	// ImageMIMEType is ImageBytes' type; sniffed when empty.
+	ImageMIMEType string
+
+	Limit         int // defaults to 20
+	Options       search.Options
+	IncludeShadow bool
+}
+
+// SearchByImage embeds a query image with the model's VL embedder and runs a
+// KNN search against the model's entity vectors, for reverse-image search and
+// "find similar covers". For asset-level matches, embed with
+// EmbedQueryImage and call search.AssetSearch.
+func SearchByImage(ctx context.Context, pool pg.Querier, emb ImageEmbedder, req ImageSearchRequest) ([]search.Hit, error) {
+	if emb == nil {
+		return nil, fmt.Errorf("ImageEmbedder is required")
+	}
+	if strings.TrimSpace(req.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	url, err := imageQueryURL(req)
+	if err != nil {
+		return nil, err
+	}
+	language := strings.TrimSpace(req.Language)
+	if language == "" {
+		language = "en"
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	vec, err := emb.EmbedQueryImage(ctx, req.Model, vl.AssetURL{Kind: vl.AssetKindImage, URL: url})
+	if err != nil {
+		return nil, err
+	}
+	return search.SemanticSearch(ctx, pool, search.Query{
+		Schema:        req.Schema,
+		Model:         req.Model,
+		Language:      language,
+		QueryVec:      vec,
+		Limit:         limit,
+		Dimensions:    len(vec),
+		Options:       req.Options,
+		IncludeShadow: req.IncludeShadow,
+	})
+}
+
+// imageQueryURL returns req's image as a URL, encoding ImageBytes as a data:
+// URL.
+func imageQueryURL(req ImageSearchRequest) (string, error) {
+	url := strings.TrimSpace(req.ImageURL)
+	switch {
+	case url != "" && len(req.ImageBytes) > 0:
+		return "", fmt.Errorf("set only one of ImageURL and ImageBytes")
+	case url != "":
+		return url, nil
+	case len(req.ImageBytes) == 0:
+		return "", fmt.Errorf("ImageURL or ImageBytes is required")
+	}
+	mime := strings.TrimSpace(req.ImageMIMEType)
+	if mime == "" {
+		mime = http.DetectContentType(req.ImageBytes)
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return "", fmt.Errorf("ImageBytes is not an image (%s)", mime)
+	}
+	return vl.DataURL(mime, req.ImageBytes), nil
+}
@@ -0,0 +1,17 @@
+package tokens
+
+import "unicode"
+
+// Estimate is a tokenizer-free estimate: one token per CJK character and one
+// per ~4 other characters. It errs high for typical English text.
+func Estimate(text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
@@ -0,0 +1,24 @@
+-- searchkit: explicit lease ownership for embedding_tasks.
+--
+-- Previously a task was "leased" by bumping next_run_at and later matched on
+-- next_run_at equality in Complete/Fail, which breaks if anything else touches
+-- the row and depends on the app server clock.
+--
+-- This migration adds:
+--   - worker_id: which worker currently holds the lease
+--   - lease_expires_at: DB-clock lease expiry; expired leases can be taken over
+--
+-- Leases are set and compared using the database clock (now()), so worker clock
+-- skew does not affect them.
+
+BEGIN;
+
+ALTER TABLE embedding_tasks
+    ADD COLUMN IF NOT EXISTS worker_id text,
+    ADD COLUMN IF NOT EXISTS lease_expires_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_embedding_tasks_lease_expires_at
+    ON embedding_tasks(lease_expires_at)
+    WHERE lease_expires_at IS NOT NULL;
+
+COMMIT;
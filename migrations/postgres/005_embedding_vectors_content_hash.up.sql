@@ -0,0 +1,13 @@
+-- searchkit: track the semantic document each embedding was generated from.
+--
+-- content_hash is a hash of the exact document text sent to the provider.
+-- The runtime compares it against the freshly built document and skips the
+-- provider call when nothing changed (e.g. bulk metadata touches that do not
+-- affect the semantic document).
+
+BEGIN;
+
+ALTER TABLE embedding_vectors
+    ADD COLUMN IF NOT EXISTS content_hash text;
+
+COMMIT;
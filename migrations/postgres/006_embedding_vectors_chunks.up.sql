@@ -0,0 +1,20 @@
+-- searchkit: multi-vector (chunked) embeddings.
+--
+-- Long semantic documents can be split into overlapping chunks, each stored as
+-- its own embedding_vectors row. chunk_idx = 0 is the only row for unchunked
+-- models, so existing data and single-vector search are unaffected.
+--
+-- content_hash holds the hash of the full document on every chunk row.
+
+BEGIN;
+
+ALTER TABLE embedding_vectors
+    ADD COLUMN IF NOT EXISTS chunk_idx integer NOT NULL DEFAULT 0;
+
+ALTER TABLE embedding_vectors
+    DROP CONSTRAINT IF EXISTS embedding_vectors_pkey;
+
+ALTER TABLE embedding_vectors
+    ADD PRIMARY KEY (entity_type, entity_id, model, language, chunk_idx);
+
+COMMIT;
@@ -0,0 +1,32 @@
+-- searchkit: per-model vector storage representation.
+--
+-- embedding_models.storage selects where a model's vectors live:
+--   - halfvec: embedding_vectors.embedding (default; fp16)
+--   - vector:  embedding_vectors.embedding_vec (fp32)
+--   - bit:     embedding_vectors.embedding_bits (binary quantized) for ANN, with
+--              exact halfvec vectors in embedding_vectors_exact for rescoring.
+--
+-- Bit storage keeps the ANN-indexed table small for very large corpora; the
+-- exact table has no ANN index and is only read by primary key.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS storage text NOT NULL DEFAULT 'halfvec';
+
+ALTER TABLE embedding_vectors
+    ADD COLUMN IF NOT EXISTS embedding_vec vector,
+    ADD COLUMN IF NOT EXISTS embedding_bits bit varying;
+
+CREATE TABLE IF NOT EXISTS embedding_vectors_exact (
+    entity_type text NOT NULL,
+    entity_id text NOT NULL,
+    model text NOT NULL,
+    language text NOT NULL,
+    chunk_idx integer NOT NULL DEFAULT 0,
+    embedding halfvec NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (entity_type, entity_id, model, language, chunk_idx)
+);
+
+COMMIT;
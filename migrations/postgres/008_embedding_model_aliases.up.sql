@@ -0,0 +1,16 @@
+-- searchkit: model aliases.
+--
+-- A canonical model (embedding_models.model, the key vectors and tasks are
+-- stored under) can be reached by other names, e.g. after renaming a model in
+-- config or when the provider renames its model id. Search resolves aliases to
+-- the canonical model so existing vectors stay valid.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS aliases text[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_embedding_models_aliases
+    ON embedding_models USING gin (aliases);
+
+COMMIT;
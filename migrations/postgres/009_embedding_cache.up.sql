@@ -0,0 +1,21 @@
+-- searchkit: cross-entity embedding cache.
+--
+-- Provider outputs keyed by (model, hash of the exact provider input), so
+-- identical documents (duplicate titles, templated descriptions) reuse a vector
+-- instead of calling the provider again. Vectors are stored as returned by the
+-- provider (before truncation/normalization).
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS embedding_cache (
+    model text NOT NULL,
+    content_hash text NOT NULL,
+    embedding halfvec NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (model, content_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_embedding_cache_created_at
+    ON embedding_cache(created_at);
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- searchkit: shadow models.
+--
+-- A shadow model receives embedding tasks and stores vectors like any other
+-- model, but search refuses it unless explicitly requested, so a candidate
+-- model can be evaluated offline against production before switching over.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS shadow boolean NOT NULL DEFAULT false;
+
+COMMIT;
@@ -0,0 +1,24 @@
+-- searchkit: soft-deleted entities.
+--
+-- With soft deletes, a deleted entity's lexical document and vectors are kept
+-- with deleted_at set (and excluded from search) until purged, so restoring the
+-- entity does not require re-embedding it. embedding_vectors_exact rows follow
+-- their embedding_vectors rows.
+
+BEGIN;
+
+ALTER TABLE search_documents
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+ALTER TABLE embedding_vectors
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_search_documents_deleted_at
+    ON search_documents (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_embedding_vectors_deleted_at
+    ON embedding_vectors (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+COMMIT;
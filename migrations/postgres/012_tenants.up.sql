@@ -0,0 +1,90 @@
+-- searchkit: optional tenant dimension.
+--
+-- tenant_id scopes lexical documents, embeddings, tasks, dirty rows and
+-- backfill state for hosts running many customer datasets in one schema. The
+-- empty tenant ('') is the default single-tenant dataset, so existing rows and
+-- hosts are unaffected.
+--
+-- tenant_id leads the primary key of every per-entity table, so tenants may
+-- use the same entity IDs. Backfill cursors are tracked per tenant.
+--
+-- Rebuilding the primary keys locks and rewrites each table's key index; run
+-- it in a maintenance window on large datasets.
+
+BEGIN;
+
+ALTER TABLE search_documents
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE search_dirty
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE embedding_tasks
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE embedding_vectors
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE embedding_dead_letters
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE embedding_vectors_exact
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE search_documents DROP CONSTRAINT IF EXISTS search_documents_pkey;
+ALTER TABLE search_documents
+    ADD PRIMARY KEY (tenant_id, entity_type, entity_id, language);
+
+ALTER TABLE search_dirty DROP CONSTRAINT IF EXISTS search_dirty_pkey;
+ALTER TABLE search_dirty
+    ADD PRIMARY KEY (tenant_id, entity_type, entity_id, language);
+
+ALTER TABLE embedding_tasks DROP CONSTRAINT IF EXISTS embedding_tasks_pkey;
+ALTER TABLE embedding_tasks
+    ADD PRIMARY KEY (tenant_id, entity_type, entity_id, model, language);
+
+ALTER TABLE embedding_dead_letters DROP CONSTRAINT IF EXISTS embedding_dead_letters_pkey;
+ALTER TABLE embedding_dead_letters
+    ADD PRIMARY KEY (tenant_id, entity_type, entity_id, model, language);
+
+ALTER TABLE embedding_vectors DROP CONSTRAINT IF EXISTS embedding_vectors_pkey;
+ALTER TABLE embedding_vectors
+    ADD PRIMARY KEY (tenant_id, entity_type, entity_id, model, language, chunk_idx);
+
+ALTER TABLE embedding_vectors_exact DROP CONSTRAINT IF EXISTS embedding_vectors_exact_pkey;
+ALTER TABLE embedding_vectors_exact
+    ADD PRIMARY KEY (tenant_id, entity_type, entity_id, model, language, chunk_idx);
+
+ALTER TABLE embedding_vectors_backfill_state
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE embedding_vectors_backfill_state
+    DROP CONSTRAINT IF EXISTS embedding_vectors_backfill_state_pkey;
+
+ALTER TABLE embedding_vectors_backfill_state
+    ADD PRIMARY KEY (tenant_id, model, entity_type, language);
+
+ALTER TABLE search_documents_backfill_state
+    ADD COLUMN IF NOT EXISTS tenant_id text NOT NULL DEFAULT '';
+
+ALTER TABLE search_documents_backfill_state
+    DROP CONSTRAINT IF EXISTS search_documents_backfill_state_pkey;
+
+ALTER TABLE search_documents_backfill_state
+    ADD PRIMARY KEY (tenant_id, entity_type, language);
+
+-- Tenant-filtered lexical search and maintenance. Only non-default tenants are
+-- indexed; single-tenant hosts pay nothing.
+CREATE INDEX IF NOT EXISTS idx_search_documents_tenant
+    ON search_documents (tenant_id, entity_type, language)
+    WHERE tenant_id <> '';
+
+CREATE INDEX IF NOT EXISTS idx_embedding_vectors_tenant
+    ON embedding_vectors (tenant_id, model, language)
+    WHERE tenant_id <> '';
+
+CREATE INDEX IF NOT EXISTS idx_embedding_tasks_tenant
+    ON embedding_tasks (tenant_id)
+    WHERE tenant_id <> '';
+
+COMMIT;
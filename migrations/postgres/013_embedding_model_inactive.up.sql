@@ -0,0 +1,13 @@
+-- searchkit: non-destructive model pruning.
+--
+-- Models missing from the host config are marked inactive (inactive_since)
+-- instead of having their registry row, tasks, backfill state and dead letters
+-- deleted, so a misconfigured deploy does not wipe queue state. Re-adding the
+-- model clears the mark.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS inactive_since timestamptz;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- searchkit: embedding task priority.
+--
+-- Higher-priority tasks (e.g. forced re-embeds from support tooling) are leased
+-- before ordinary tasks regardless of age. Re-enqueueing never lowers a task's
+-- priority.
+
+BEGIN;
+
+ALTER TABLE embedding_tasks
+    ADD COLUMN IF NOT EXISTS priority smallint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_embedding_tasks_priority_ready
+    ON embedding_tasks(priority DESC, next_run_at, entity_type, entity_id, model, language);
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- searchkit: language-agnostic models.
+--
+-- A multilingual model stores one vector per entity under the "*" language
+-- instead of one per language; search ignores the query language for it.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS language_agnostic boolean NOT NULL DEFAULT false;
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- searchkit: per-model normalization policy.
+--
+-- Records how a model's vectors are post-processed ('l2' or 'none') so that
+-- stored vectors and query vectors are always produced the same way; a
+-- mismatch is reported by pg.CheckModels.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS normalization text NOT NULL DEFAULT 'l2';
+
+COMMIT;
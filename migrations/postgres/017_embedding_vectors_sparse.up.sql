@@ -0,0 +1,16 @@
+-- searchkit: learned sparse embeddings (SPLADE, BM42).
+--
+-- Sparse models (embedding_models.modality = 'sparse', storage = 'sparsevec')
+-- store one vector per entity in embedding_vectors.embedding_sparse, keyed,
+-- tenanted and soft-deleted like dense vectors. Per-model HNSW indexes use
+-- sparsevec_ip_ops; pgvector indexes at most 1000 non-zero elements, so
+-- searchkit keeps each stored vector's 1000 largest weights.
+--
+-- Requires pgvector >= 0.7.
+
+BEGIN;
+
+ALTER TABLE embedding_vectors
+    ADD COLUMN IF NOT EXISTS embedding_sparse sparsevec;
+
+COMMIT;
@@ -0,0 +1,36 @@
+-- searchkit: per-asset VL embeddings.
+--
+-- VL models store one fused vector per entity in embedding_vectors. Models
+-- listed in runtime Options.VLAssetModels additionally store one vector per
+-- asset (image, page, video frame) here, so search can report which asset
+-- matched (search.AssetSearch). asset_key is the host's stable asset id (the
+-- URL when none is given); frame_idx orders frames within a video.
+--
+-- Vectors are halfvec regardless of the model's storage mode. Rows are
+-- tenanted and soft-deleted like embedding_vectors; per-model HNSW indexes are
+-- created by pg.EnsureAssetIndexes.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS embedding_vector_assets (
+    entity_type text NOT NULL,
+    entity_id text NOT NULL,
+    model text NOT NULL,
+    language text NOT NULL,
+    asset_key text NOT NULL,
+    frame_idx integer NOT NULL DEFAULT 0,
+    asset_kind text NOT NULL,
+    asset_url text NOT NULL DEFAULT '',
+    embedding halfvec NOT NULL,
+    tenant_id text NOT NULL DEFAULT '',
+    deleted_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, entity_type, entity_id, model, language, asset_key, frame_idx)
+);
+
+CREATE INDEX IF NOT EXISTS idx_embedding_vector_assets_deleted_at
+    ON embedding_vector_assets (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+COMMIT;
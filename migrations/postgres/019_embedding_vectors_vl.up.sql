@@ -0,0 +1,58 @@
+-- searchkit: separate table for fused VL embeddings.
+--
+-- VL models (embedding_models.modality = 'vl') store one fused vector per
+-- entity here instead of in embedding_vectors, so VL vectors get their own
+-- per-model HNSW indexes (pg.EnsureVLIndexes), dims and retention without
+-- adding partial indexes to the text table. VL vectors are not chunked and
+-- are always halfvec. Rows are tenanted and soft-deleted like embedding_vectors.
+--
+-- Existing VL rows are moved over. Their old per-model indexes on
+-- embedding_vectors are left in place (now empty); drop them with
+-- DROP INDEX CONCURRENTLY after upgrading.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS embedding_vectors_vl (
+    entity_type text NOT NULL,
+    entity_id text NOT NULL,
+    model text NOT NULL,
+    language text NOT NULL,
+    embedding halfvec NOT NULL,
+    content_hash text,
+    tenant_id text NOT NULL DEFAULT '',
+    deleted_at timestamptz,
+    created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, entity_type, entity_id, model, language)
+);
+
+CREATE INDEX IF NOT EXISTS idx_embedding_vectors_vl_deleted_at
+    ON embedding_vectors_vl (deleted_at)
+    WHERE deleted_at IS NOT NULL;
+
+INSERT INTO embedding_vectors_vl (entity_type, entity_id, model, language, embedding, content_hash, tenant_id, deleted_at, created_at, updated_at)
+SELECT ev.entity_type, ev.entity_id, ev.model, ev.language,
+       COALESCE(ev.embedding, ev.embedding_vec::halfvec, ex.embedding),
+       ev.content_hash, ev.tenant_id, ev.deleted_at, ev.created_at, ev.updated_at
+FROM embedding_vectors ev
+JOIN embedding_models m ON m.model = ev.model AND m.modality = 'vl'
+LEFT JOIN embedding_vectors_exact ex
+    ON ex.tenant_id = ev.tenant_id
+    AND ex.entity_type = ev.entity_type
+    AND ex.entity_id = ev.entity_id
+    AND ex.model = ev.model
+    AND ex.language = ev.language
+    AND ex.chunk_idx = ev.chunk_idx
+WHERE ev.chunk_idx = 0
+  AND COALESCE(ev.embedding, ev.embedding_vec::halfvec, ex.embedding) IS NOT NULL
+ON CONFLICT (tenant_id, entity_type, entity_id, model, language) DO NOTHING;
+
+DELETE FROM embedding_vectors_exact ex
+USING embedding_models m
+WHERE m.model = ex.model AND m.modality = 'vl';
+
+DELETE FROM embedding_vectors ev
+USING embedding_models m
+WHERE m.model = ev.model AND m.modality = 'vl';
+
+COMMIT;
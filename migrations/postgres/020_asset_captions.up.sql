@@ -0,0 +1,26 @@
+-- searchkit: generated asset captions.
+--
+-- Captions produced by the host's runtime Options.CaptionAssets hook (a VLM
+-- or captioning service), one per entity asset (asset_key = the host's
+-- stable asset id, the URL when none is given; frame_idx orders video
+-- frames) and language. They are generated once and reused on later document
+-- builds; an entity's captions are replaced with its current asset set, so
+-- captions of removed assets are dropped. Hosts change asset keys when an
+-- asset's content changes, which re-captions it.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS asset_captions (
+    entity_type text NOT NULL,
+    entity_id text NOT NULL,
+    language text NOT NULL,
+    asset_key text NOT NULL,
+    frame_idx integer NOT NULL DEFAULT 0,
+    caption text NOT NULL,
+    tenant_id text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, entity_type, entity_id, language, asset_key, frame_idx)
+);
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- searchkit: video segment bounds on per-asset VL embeddings.
+--
+-- Models with runtime Options.VideoSegments store one vector per video time
+-- segment in embedding_vector_assets (asset_kind 'segment', asset_key the
+-- video's key plus a "#t=start,end" fragment, frame_idx the segment index).
+-- start_ms/end_ms hold the segment's offsets within the video so search hits
+-- can jump to the matching moment; they are NULL for other assets.
+
+BEGIN;
+
+ALTER TABLE embedding_vector_assets
+    ADD COLUMN IF NOT EXISTS start_ms bigint,
+    ADD COLUMN IF NOT EXISTS end_ms bigint;
+
+COMMIT;
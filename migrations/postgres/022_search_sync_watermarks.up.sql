@@ -0,0 +1,21 @@
+-- searchkit: incremental re-sync watermarks.
+--
+-- SyncOnce with SearchkitOptions.ListChangedEntityIDs marks entities the host
+-- reports as changed since the stored watermark dirty, per tenant, entity
+-- type and language. watermark_ids holds the entities already marked at
+-- exactly the watermark, so the next (inclusive) listing skips them.
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS search_sync_watermarks (
+    tenant_id text NOT NULL DEFAULT '',
+    entity_type text NOT NULL,
+    language text NOT NULL,
+    watermark timestamptz, -- NULL: list from the beginning
+    watermark_ids text[] NOT NULL DEFAULT '{}',
+    last_error text,
+    updated_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, entity_type, language)
+);
+
+COMMIT;
@@ -0,0 +1,21 @@
+-- searchkit: fp32 storage for fused VL vectors.
+--
+-- VL models with storage 'vector' (runtime Options.StorageModes) keep their
+-- fused vectors in embedding_vectors_vl.embedding_vec (fp32) instead of the
+-- halfvec embedding column, like text models in embedding_vectors. Exactly
+-- one of the two is set per row. Per-asset vectors stay halfvec.
+
+BEGIN;
+
+ALTER TABLE embedding_vectors_vl
+    ADD COLUMN IF NOT EXISTS embedding_vec vector,
+    ALTER COLUMN embedding DROP NOT NULL;
+
+ALTER TABLE embedding_vectors_vl
+    DROP CONSTRAINT IF EXISTS embedding_vectors_vl_vector_check;
+
+ALTER TABLE embedding_vectors_vl
+    ADD CONSTRAINT embedding_vectors_vl_vector_check
+    CHECK (embedding IS NOT NULL OR embedding_vec IS NOT NULL);
+
+COMMIT;
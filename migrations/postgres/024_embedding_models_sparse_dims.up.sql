@@ -0,0 +1,14 @@
+-- searchkit: hybrid dense+sparse text models (e.g. BGE-M3).
+--
+-- A text model with sparse_dims > 0 also stores a learned sparse vector of
+-- that vocabulary size per entity, in embedding_vectors.embedding_sparse of
+-- the entity's chunk 0 (next to its dense vector), instead of registering a
+-- separate sparse model. Its per-model sparsevec_ip_ops HNSW index is created
+-- with the dense ones.
+
+BEGIN;
+
+ALTER TABLE embedding_models
+    ADD COLUMN IF NOT EXISTS sparse_dims integer NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,19 @@
+-- searchkit: backfill progress.
+--
+-- processed counts the entity IDs the worker has listed since the cursor was
+-- last reset, and started_at is when it listed the first page, so
+-- pg.BackfillProgress can combine them with a host-provided total to report
+-- percent complete and an ETA. Rows in progress when this runs start counting
+-- from zero.
+
+BEGIN;
+
+ALTER TABLE search_documents_backfill_state
+    ADD COLUMN IF NOT EXISTS processed bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS started_at timestamptz;
+
+ALTER TABLE embedding_vectors_backfill_state
+    ADD COLUMN IF NOT EXISTS processed bigint NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS started_at timestamptz;
+
+COMMIT;
@@ -0,0 +1,18 @@
+-- searchkit: fp32 embedding cache.
+--
+-- embedding_cache stored halfvec, so models with storage 'vector' got
+-- fp16-rounded vectors back on a cache hit. The cache now stores vector
+-- (fp32) for every model; halfvec models round when the vector is stored, as
+-- on a cache miss. Entries already cached for 'vector' models were rounded
+-- and are dropped, so those inputs are embedded again on next use.
+
+BEGIN;
+
+ALTER TABLE embedding_cache
+    ALTER COLUMN embedding TYPE vector USING embedding::vector;
+
+DELETE FROM embedding_cache c
+USING embedding_models m
+WHERE m.model = c.model AND m.storage = 'vector';
+
+COMMIT;
@@ -0,0 +1,138 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// Prerequisite is one database object the migrations expect to exist before
+// they run.
+type Prerequisite struct {
+	// Name is "schema" or an extension name.
+	Name string
+	// Required prerequisites break the migrations when missing; optional ones
+	// (pgroonga) only disable a feature and its migration must be marked
+	// applied by hand.
+	Required bool
+	// Present reports whether it exists after EnsureSchema; Created whether
+	// EnsureSchema created it.
+	Present bool
+	Created bool
+	// Problem explains why a missing prerequisite could not be created.
+	Problem string
+}
+
+// SchemaReport is the result of EnsureSchema.
+type SchemaReport struct {
+	Prerequisites []Prerequisite
+}
+
+// Missing returns the prerequisites that are still missing.
+func (r SchemaReport) Missing() []Prerequisite {
+	var out []Prerequisite
+	for _, p := range r.Prerequisites {
+		if !p.Present {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// OK reports whether every required prerequisite is present.
+func (r SchemaReport) OK() bool {
+	for _, p := range r.Prerequisites {
+		if p.Required && !p.Present {
+			return false
+		}
+	}
+	return true
+}
+
+// schemaExtensions are the extensions the migrations create, in order.
+var schemaExtensions = []struct {
+	name     string
+	required bool
+}{
+	{"vector", true},
+	{"pg_trgm", true},
+	{"pgroonga", false},
+}
+
+// EnsureSchema brings up the prerequisites of the migrations in a new
+// environment: it creates schema and the vector, pg_trgm and (when its package
+// is installed) pgroonga extensions if missing and the role is allowed to.
+// Anything it can't create, e.g. for lack of privileges or an extension
+// package missing from the server, is reported rather than returned as an
+// error, so callers can print the report and decide; check OK() before
+// applying the migrations. Errors are only returned for failed lookups.
+//
+// Extensions are created in the default schema of the connection (usually
+// public), where the migrations and queries find them on the search_path.
+// Run it on a pool, not inside a transaction: a failed CREATE would abort the
+// transaction.
+func EnsureSchema(ctx context.Context, pool pg.Querier, schema string) (SchemaReport, error) {
+	var report SchemaReport
+	if pool == nil {
+		return report, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return report, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	p := Prerequisite{Name: "schema", Required: true}
+	if err := pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)`, strings.TrimSpace(schema)).Scan(&p.Present); err != nil {
+		return report, fmt.Errorf("check schema: %w", err)
+	}
+	if !p.Present {
+		if _, err := pool.Exec(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, qs)); err != nil {
+			p.Problem = err.Error()
+		} else {
+			p.Present, p.Created = true, true
+		}
+	}
+	report.Prerequisites = append(report.Prerequisites, p)
+
+	for _, ext := range schemaExtensions {
+		p := Prerequisite{Name: ext.name, Required: ext.required}
+		var available bool
+		if err := pool.QueryRow(ctx, `
+			SELECT
+				EXISTS (SELECT 1 FROM pg_extension WHERE extname = $1),
+				EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = $1)
+		`, ext.name).Scan(&p.Present, &available); err != nil {
+			return report, fmt.Errorf("check extension %s: %w", ext.name, err)
+		}
+		switch {
+		case p.Present:
+		case !available:
+			p.Problem = "extension package is not installed on the server"
+		default:
+			// Names come from schemaExtensions, so they need no quoting.
+			if _, err := pool.Exec(ctx, fmt.Sprintf(`CREATE EXTENSION IF NOT EXISTS %s`, ext.name)); err != nil {
+				p.Problem = err.Error()
+			} else {
+				p.Present, p.Created = true, true
+			}
+		}
+		report.Prerequisites = append(report.Prerequisites, p)
+	}
+	return report, nil
+}
+
+func quoteIdent(ident string) (string, error) {
+	ident = strings.TrimSpace(ident)
+	if ident == "" {
+		return "", fmt.Errorf("empty identifier")
+	}
+	for _, r := range ident {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			continue
+		}
+		return "", fmt.Errorf("invalid identifier %q", ident)
+	}
+	return `"` + ident + `"`, nil
+}
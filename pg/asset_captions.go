@@ -0,0 +1,100 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+const assetCaptionsTable = "asset_captions"
+
+// AssetCaption is a generated caption for one asset of an entity, stored in
+// asset_captions.
+type AssetCaption struct {
+	// Key is the host's stable asset id (vl.AssetURL.StorageKey).
+	Key      string
+	FrameIdx int
+	Caption  string
+}
+
+// AssetCaptions returns the stored captions of entityIDs of ctx's tenant in
+// language, keyed by entity ID.
+func (s *PostgresStorage) AssetCaptions(ctx context.Context, entityType string, language string, entityIDs []string) (map[string][]AssetCaption, error) {
+	if s.schema == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(language) == "" {
+		return nil, fmt.Errorf("entityType and language are required")
+	}
+	out := map[string][]AssetCaption{}
+	if len(entityIDs) == 0 {
+		return out, nil
+	}
+	rows, err := s.pool.Query(ctx, fmt.Sprintf(`
+		SELECT entity_id, asset_key, frame_idx, caption
+		FROM %s.%s
+		WHERE tenant_id = $4 AND entity_type = $1 AND language = $2 AND entity_id = ANY($3::text[])
+		ORDER BY entity_id, asset_key, frame_idx
+	`, s.schema, assetCaptionsTable), entityType, language, entityIDs, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var c AssetCaption
+		if err := rows.Scan(&id, &c.Key, &c.FrameIdx, &c.Caption); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], c)
+	}
+	return out, rows.Err()
+}
+
+// ReplaceAssetCaptions replaces an entity's captions in language with
+// captions, removing captions of assets that are no longer listed. Rows are
+// tagged with ctx's tenant (see WithTenant).
+func (s *PostgresStorage) ReplaceAssetCaptions(ctx context.Context, entityType string, entityID string, language string, captions []AssetCaption) error {
+	if s.schema == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" || strings.TrimSpace(language) == "" {
+		return fmt.Errorf("entityType, entityID and language are required")
+	}
+	keys := make([]string, len(captions))
+	frames := make([]int32, len(captions))
+	for i, c := range captions {
+		if strings.TrimSpace(c.Key) == "" {
+			return fmt.Errorf("asset key is required")
+		}
+		keys[i] = c.Key
+		frames[i] = int32(c.FrameIdx)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	q := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, language, asset_key, frame_idx, caption, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, language, asset_key, frame_idx) DO UPDATE SET
+			caption = EXCLUDED.caption,
+			updated_at = now()
+	`, s.schema, assetCaptionsTable)
+	tenant := TenantFromContext(ctx)
+	for _, c := range captions {
+		if _, err := tx.Exec(ctx, q, entityType, entityID, language, c.Key, c.FrameIdx, c.Caption, tenant); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s.%s
+		WHERE tenant_id = $6 AND entity_type = $1 AND entity_id = $2 AND language = $3
+		  AND (asset_key, frame_idx) NOT IN (SELECT * FROM unnest($4::text[], $5::int[]))
+	`, s.schema, assetCaptionsTable), entityType, entityID, language, keys, frames, tenant); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
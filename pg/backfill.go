@@ -0,0 +1,235 @@
+package pg
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// BackfillScope selects the backfill state rows of ctx's tenant (see
+// WithTenant) to reset. EntityType is required; an empty Language matches
+// every language.
+// Language-agnostic models keep their embedding backfill state under
+// AnyLanguage.
+type BackfillScope struct {
+	EntityType string
+	Language   string
+	// Model is required for embedding backfill state and unused for lexical
+	// backfill state.
+	Model string
+	// AllTenants resets the rows of every tenant instead of ctx's.
+	AllTenants bool
+}
+
+// ResetLexicalBackfill clears the cursor of the search_documents backfill
+// state rows in scope and sets them back to running, so the worker's next
+// SyncOnce lists every entity again and rebuilds its lexical document (e.g.
+// after fixing a BuildLexicalString bug). It returns the number of rows reset;
+// scopes the worker hasn't started yet have no rows and need no reset.
+func ResetLexicalBackfill(ctx context.Context, pool Querier, schema string, scope BackfillScope) (int64, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema: %w", err)
+	}
+	if strings.TrimSpace(scope.EntityType) == "" {
+		return 0, fmt.Errorf("entity type is required")
+	}
+	tag, err := pool.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s.search_documents_backfill_state
+		SET cursor = '', state = 'running', last_error = NULL, processed = 0, started_at = NULL, updated_at = now()
+		WHERE entity_type = $1
+			AND ($2 = '' OR language = $2)
+			AND ($3::text IS NULL OR tenant_id = $3)
+	`, qs), scope.EntityType, scope.Language, tenantArg(ctx, scope.AllTenants))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ResetEmbeddingBackfill is ResetLexicalBackfill for the embedding_vectors
+// backfill state of scope.Model. The backfill only enqueues entities without
+// a vector for the model, so a reset re-checks every entity (e.g. after
+// ListEntityIDsPage skipped some) but doesn't re-embed stored vectors.
+func ResetEmbeddingBackfill(ctx context.Context, pool Querier, schema string, scope BackfillScope) (int64, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema: %w", err)
+	}
+	if strings.TrimSpace(scope.EntityType) == "" {
+		return 0, fmt.Errorf("entity type is required")
+	}
+	if strings.TrimSpace(scope.Model) == "" {
+		return 0, fmt.Errorf("model is required")
+	}
+	tag, err := pool.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s.embedding_vectors_backfill_state
+		SET cursor = '', state = 'running', last_error = NULL, processed = 0, started_at = NULL, updated_at = now()
+		WHERE model = $1 AND entity_type = $2
+			AND ($3 = '' OR language = $3)
+			AND ($4::text IS NULL OR tenant_id = $4)
+	`, qs), scope.Model, scope.EntityType, scope.Language, tenantArg(ctx, scope.AllTenants))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// BackfillTotal returns how many entities ListEntityIDsPage lists for
+// entityType and language (every entity for AnyLanguage) in ctx's tenant (see
+// TenantFromContext), e.g. a count(*) over the host's table.
+type BackfillTotal func(ctx context.Context, entityType string, language string) (int64, error)
+
+// BackfillProgressOptions filters BackfillProgress. Empty filters match
+// everything; only ctx's tenant (see WithTenant) is reported unless
+// AllTenants.
+type BackfillProgressOptions struct {
+	EntityTypes []string
+	// Models selects embedding backfills by model; "" selects the lexical
+	// backfill.
+	Models []string
+	// AllTenants reports every tenant instead of ctx's.
+	AllTenants bool
+
+	// Total, when set, is called once per (tenant, entity type, language) to
+	// compute Percent and ETA.
+	Total BackfillTotal
+}
+
+// BackfillStat is the progress of one backfill state row.
+type BackfillStat struct {
+	TenantID   string
+	EntityType string
+	Language   string
+	// Model is empty for the lexical backfill.
+	Model string
+
+	// State is running, done or failed; LastError is set for failed.
+	State     string
+	LastError string
+
+	// Processed counts the entity IDs listed since the pass started (or the
+	// backfill was last reset). For embedding backfills these are checked
+	// entities: tasks for the missing ones may still be pending (see
+	// CoverageStats).
+	Processed int64
+	// Total is BackfillProgressOptions.Total's count (0 without it).
+	Total int64
+	// Percent is Processed of Total, capped at 100, and 100 once done.
+	Percent float64
+	// ETA extrapolates the pass's average rate from StartedAt to UpdatedAt
+	// to the remaining entities, counted from UpdatedAt. Zero when done,
+	// failed, or without a Total or progress to measure.
+	ETA time.Duration
+
+	// StartedAt is when the pass listed its first page (zero if it hasn't
+	// yet); UpdatedAt is the last state change.
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
+
+// BackfillProgress reports the worker's cursor backfills per (tenant, entity
+// type, language, model): state, entities processed and, with
+// BackfillProgressOptions.Total, percent complete and an ETA, for dashboards
+// during long backfills. Scopes the worker hasn't reached yet have no state
+// and are not reported. Passes already running when migration 025 was applied
+// count from then on.
+func BackfillProgress(ctx context.Context, pool Querier, schema string, opts BackfillProgressOptions) ([]BackfillStat, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT * FROM (
+			SELECT tenant_id, entity_type, language, model, state, COALESCE(last_error, ''), processed, started_at, updated_at
+			FROM %[1]s.embedding_vectors_backfill_state
+			UNION ALL
+			SELECT tenant_id, entity_type, language, '', state, COALESCE(last_error, ''), processed, started_at, updated_at
+			FROM %[1]s.search_documents_backfill_state
+		) b
+		WHERE (COALESCE(cardinality($1::text[]), 0) = 0 OR entity_type = ANY($1))
+			AND (COALESCE(cardinality($2::text[]), 0) = 0 OR model = ANY($2))
+			AND ($3::text IS NULL OR tenant_id = $3)
+	`, qs), opts.EntityTypes, opts.Models, tenantArg(ctx, opts.AllTenants))
+	if err != nil {
+		return nil, err
+	}
+	var out []BackfillStat
+	for rows.Next() {
+		var s BackfillStat
+		var started *time.Time
+		if err := rows.Scan(&s.TenantID, &s.EntityType, &s.Language, &s.Model, &s.State, &s.LastError, &s.Processed, &started, &s.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if started != nil {
+			s.StartedAt = *started
+		}
+		out = append(out, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Totals are fetched after the rows are read, as the callback may use the
+	// same connection.
+	type key struct{ tenant, entityType, language string }
+	totals := map[key]int64{}
+	for i := range out {
+		s := &out[i]
+		if opts.Total != nil {
+			k := key{s.TenantID, s.EntityType, s.Language}
+			total, ok := totals[k]
+			if !ok {
+				total, err = opts.Total(WithTenant(ctx, s.TenantID), s.EntityType, s.Language)
+				if err != nil {
+					return nil, fmt.Errorf("total for %s/%s (tenant %q): %w", s.EntityType, s.Language, s.TenantID, err)
+				}
+				totals[k] = total
+			}
+			s.Total = total
+		}
+		s.estimate()
+	}
+
+	slices.SortFunc(out, func(a, b BackfillStat) int {
+		return cmp.Or(
+			cmp.Compare(a.TenantID, b.TenantID),
+			cmp.Compare(a.EntityType, b.EntityType),
+			cmp.Compare(a.Language, b.Language),
+			cmp.Compare(a.Model, b.Model),
+		)
+	})
+	return out, nil
+}
+
+func (s *BackfillStat) estimate() {
+	if s.State == "done" {
+		s.Percent = 100
+		return
+	}
+	if s.Total <= 0 {
+		return
+	}
+	s.Percent = min(100, 100*float64(s.Processed)/float64(s.Total))
+	elapsed := s.UpdatedAt.Sub(s.StartedAt)
+	remaining := s.Total - s.Processed
+	if s.State != "running" || s.StartedAt.IsZero() || s.Processed <= 0 || remaining <= 0 || elapsed <= 0 {
+		return
+	}
+	s.ETA = time.Duration(float64(elapsed) / float64(s.Processed) * float64(remaining))
+}
@@ -0,0 +1,259 @@
+package pg
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// CoverageStat describes how completely one model covers one entity type and
+// language (AnyLanguage for language-agnostic models).
+type CoverageStat struct {
+	EntityType string
+	Language   string
+	Model      string
+
+	// Documents counts lexical documents (search_documents); for
+	// language-agnostic models, distinct entities with a document in any
+	// language.
+	Documents int64
+	// Vectors counts entities with a stored vector (chunks count once).
+	Vectors int64
+	// Missing counts documents whose entity has no vector for the model yet.
+	Missing int64
+	// PendingTasks counts queued embedding tasks, including leased ones.
+	PendingTasks int64
+
+	// OldestVector and NewestVector are the oldest and newest vector
+	// updated_at; OldestTask is the oldest pending task's created_at. Zero
+	// when there are none.
+	OldestVector time.Time
+	NewestVector time.Time
+	OldestTask   time.Time
+}
+
+// CoverageOptions filters CoverageStats. Empty filters match everything.
+type CoverageOptions struct {
+	EntityTypes []string
+	Models      []string
+	// AllTenants counts every tenant instead of ctx's (see WithTenant).
+	AllTenants bool
+}
+
+// CoverageStats returns, per (entity type, language, model) for every active
+// registered model, the number of lexical documents, stored vectors, documents
+// still missing a vector, pending tasks, and vector and task ages, for index
+// health dashboards. Soft-deleted rows are not counted.
+//
+// Missing is measured against search_documents, so entity types that are
+// embedded but not lexically indexed report no documents and nothing
+// missing; restrict EntityTypes to the semantic ones to avoid lexical-only
+// types showing as entirely missing. The counts scan the tables: cache the
+// result rather than calling it per request on large datasets.
+func CoverageStats(ctx context.Context, pool Querier, schema string, opts CoverageOptions) ([]CoverageStat, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	// $1 entity types and $2 tenant (NULL means all), then per query $3 the
+	// selected models.
+	filter := func(alias string) string {
+		return fmt.Sprintf(`(COALESCE(cardinality($1::text[]), 0) = 0 OR %[1]s.entity_type = ANY($1))
+			AND ($2::text IS NULL OR %[1]s.tenant_id = $2)`, alias)
+	}
+	args := []any{opts.EntityTypes, tenantArg(ctx, opts.AllTenants)}
+
+	type key struct{ entityType, language, model string }
+	stats := map[key]*CoverageStat{}
+	stat := func(k key) *CoverageStat {
+		s, ok := stats[k]
+		if !ok {
+			s = &CoverageStat{EntityType: k.entityType, Language: k.language, Model: k.model}
+			stats[k] = s
+		}
+		return s
+	}
+
+	// Active models; documents are counted per language, or per entity for
+	// language-agnostic models.
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT model, language_agnostic
+		FROM %s.embedding_models
+		WHERE inactive_since IS NULL
+		  AND (COALESCE(cardinality($1::text[]), 0) = 0 OR model = ANY($1))
+		ORDER BY model
+	`, qs), opts.Models)
+	if err != nil {
+		return nil, err
+	}
+	agnostic := map[string]bool{}
+	var models []string
+	var agnosticFlags []bool
+	for rows.Next() {
+		var model string
+		var languageAgnostic bool
+		if err := rows.Scan(&model, &languageAgnostic); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		agnostic[model] = languageAgnostic
+		models = append(models, model)
+		agnosticFlags = append(agnosticFlags, languageAgnostic)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return []CoverageStat{}, nil
+	}
+
+	rows, err = pool.Query(ctx, fmt.Sprintf(`
+		SELECT entity_type, language, count(*) FROM %[1]s.%[2]s sd
+		WHERE deleted_at IS NULL AND %[3]s
+		GROUP BY entity_type, language
+		UNION ALL
+		SELECT entity_type, %[4]s, count(DISTINCT (tenant_id, entity_id)) FROM %[1]s.%[2]s sd
+		WHERE deleted_at IS NULL AND %[3]s
+		GROUP BY entity_type
+	`, qs, searchDocumentsTable, filter("sd"), quoteLiteral(AnyLanguage)), args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var entityType, language string
+		var n int64
+		if err := rows.Scan(&entityType, &language, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		for _, model := range models {
+			if agnostic[model] == (language == AnyLanguage) {
+				stat(key{entityType, language, model}).Documents = n
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pool.Query(ctx, fmt.Sprintf(`
+		SELECT entity_type, language, model, count(DISTINCT (tenant_id, entity_id)), min(updated_at), max(updated_at)
+		FROM %[1]s.%[2]s ev
+		WHERE deleted_at IS NULL AND %[4]s AND model = ANY($3)
+		GROUP BY entity_type, language, model
+		UNION ALL
+		SELECT entity_type, language, model, count(*), min(updated_at), max(updated_at)
+		FROM %[1]s.%[3]s ev
+		WHERE deleted_at IS NULL AND %[4]s AND model = ANY($3)
+		GROUP BY entity_type, language, model
+	`, qs, embeddingVectorsTable, embeddingVectorsVLTable, filter("ev")), append(args, models)...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var k key
+		var n int64
+		var oldest, newest time.Time
+		if err := rows.Scan(&k.entityType, &k.language, &k.model, &n, &oldest, &newest); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s := stat(k)
+		s.Vectors += n
+		if s.OldestVector.IsZero() || oldest.Before(s.OldestVector) {
+			s.OldestVector = oldest
+		}
+		if newest.After(s.NewestVector) {
+			s.NewestVector = newest
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = pool.Query(ctx, fmt.Sprintf(`
+		SELECT entity_type, language, model, count(*), min(created_at)
+		FROM %s.embedding_tasks t
+		WHERE %s AND model = ANY($3)
+		GROUP BY entity_type, language, model
+	`, qs, filter("t")), append(args, models)...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var k key
+		var n int64
+		var oldest time.Time
+		if err := rows.Scan(&k.entityType, &k.language, &k.model, &n, &oldest); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s := stat(k)
+		s.PendingTasks, s.OldestTask = n, oldest
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Documents without a vector, per model; language-agnostic models look
+	// for the entity's AnyLanguage vector whatever the document's language.
+	rows, err = pool.Query(ctx, fmt.Sprintf(`
+		SELECT sd.entity_type, CASE WHEN m.agnostic THEN %[5]s ELSE sd.language END AS lang, m.model,
+			count(DISTINCT (sd.tenant_id, sd.entity_id))
+		FROM %[1]s.%[2]s sd
+		CROSS JOIN unnest($3::text[], $4::boolean[]) AS m(model, agnostic)
+		WHERE sd.deleted_at IS NULL AND %[6]s
+		  AND NOT EXISTS (
+			SELECT 1 FROM %[1]s.%[3]s ev
+			WHERE ev.tenant_id = sd.tenant_id AND ev.entity_type = sd.entity_type AND ev.entity_id = sd.entity_id AND ev.model = m.model
+			  AND ev.language = CASE WHEN m.agnostic THEN %[5]s ELSE sd.language END
+			  AND ev.deleted_at IS NULL
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM %[1]s.%[4]s ev
+			WHERE ev.tenant_id = sd.tenant_id AND ev.entity_type = sd.entity_type AND ev.entity_id = sd.entity_id AND ev.model = m.model
+			  AND ev.language = CASE WHEN m.agnostic THEN %[5]s ELSE sd.language END
+			  AND ev.deleted_at IS NULL
+		  )
+		GROUP BY 1, 2, 3
+	`, qs, searchDocumentsTable, embeddingVectorsTable, embeddingVectorsVLTable, quoteLiteral(AnyLanguage), filter("sd")), append(args, models, agnosticFlags)...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var k key
+		var n int64
+		if err := rows.Scan(&k.entityType, &k.language, &k.model, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stat(k).Missing = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]CoverageStat, 0, len(stats))
+	for _, s := range stats {
+		out = append(out, *s)
+	}
+	slices.SortFunc(out, func(a, b CoverageStat) int {
+		return cmp.Or(
+			cmp.Compare(a.EntityType, b.EntityType),
+			cmp.Compare(a.Language, b.Language),
+			cmp.Compare(a.Model, b.Model),
+		)
+	})
+	return out, nil
+}
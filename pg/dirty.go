@@ -0,0 +1,116 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Execer is satisfied by *pgxpool.Pool, *pgx.Conn and pgx.Tx, so writes can
+// join a host transaction.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Querier is the database handle the pg, search and tasks packages run on.
+// *pgxpool.Pool, *pgx.Conn and pgx.Tx all satisfy it, so callers can pass
+// their own transaction, a single connection or an instrumented wrapper. On a
+// pgx.Tx, Begin opens a savepoint. Functions that create indexes
+// CONCURRENTLY must not be given a transaction.
+type Querier interface {
+	Execer
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+var (
+	_ Querier = (*pgxpool.Pool)(nil)
+	_ Querier = (*pgx.Conn)(nil)
+	_ Querier = (pgx.Tx)(nil)
+)
+
+// ReasonAssetsChanged is the dirty reason for an entity whose assets (images,
+// pages, videos) changed but whose other fields didn't: the worker re-embeds
+// only the models whose inputs depend on assets (see
+// runtime.Runtime.AssetDependentModels) instead of the whole entity. A pending
+// mark with any other reason is never narrowed by it.
+const ReasonAssetsChanged = "assets_changed"
+
+// DirtyMark marks one entity+language as changed (or deleted) in search_dirty.
+type DirtyMark struct {
+	EntityType string
+	EntityID   string
+	Language   string
+	Deleted    bool
+	Reason     string // defaults to "unknown"
+}
+
+// MarkDirty upserts marks into `<schema>.search_dirty` for the worker to pick
+// up, tagged with ctx's tenant (see WithTenant). Pass a pgx.Tx as db to commit
+// the marks atomically with the host's own writes.
+func MarkDirty(ctx context.Context, db Execer, schema string, marks []DirtyMark) error {
+	if db == nil {
+		return fmt.Errorf("db is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	if len(marks) == 0 {
+		return nil
+	}
+
+	types := make([]string, 0, len(marks))
+	ids := make([]string, 0, len(marks))
+	langs := make([]string, 0, len(marks))
+	deleted := make([]bool, 0, len(marks))
+	reasons := make([]string, 0, len(marks))
+	// A later assets-only mark keeps the reason of an earlier full mark for
+	// the same key.
+	fullReason := make(map[[3]string]string)
+	for _, m := range marks {
+		if strings.TrimSpace(m.EntityType) == "" || strings.TrimSpace(m.EntityID) == "" || strings.TrimSpace(m.Language) == "" {
+			return fmt.Errorf("entityType, entityID and language are required")
+		}
+		reason := strings.TrimSpace(m.Reason)
+		if reason == "" {
+			reason = "unknown"
+		}
+		key := [3]string{m.EntityType, m.EntityID, m.Language}
+		if reason != ReasonAssetsChanged {
+			fullReason[key] = reason
+		} else if r, ok := fullReason[key]; ok {
+			reason = r
+		}
+		types = append(types, m.EntityType)
+		ids = append(ids, m.EntityID)
+		langs = append(langs, m.Language)
+		deleted = append(deleted, m.Deleted)
+		reasons = append(reasons, reason)
+	}
+
+	// DISTINCT ON keeps the last mark per key so one statement never updates
+	// the same row twice.
+	q := fmt.Sprintf(`
+		WITH marks AS (
+			SELECT DISTINCT ON (entity_type, entity_id, language) *
+			FROM unnest($1::text[], $2::text[], $3::text[], $4::bool[], $5::text[])
+				WITH ORDINALITY AS m(entity_type, entity_id, language, is_deleted, reason, ord)
+			ORDER BY entity_type, entity_id, language, ord DESC
+		)
+		INSERT INTO %[1]s.search_dirty (entity_type, entity_id, language, is_deleted, reason, tenant_id, created_at, updated_at)
+		SELECT entity_type, entity_id, language, is_deleted, reason, $6, now(), now()
+		FROM marks
+		ON CONFLICT (tenant_id, entity_type, entity_id, language) DO UPDATE SET
+			is_deleted = EXCLUDED.is_deleted,
+			reason = CASE WHEN EXCLUDED.reason = '%[2]s' THEN %[1]s.search_dirty.reason ELSE EXCLUDED.reason END,
+			updated_at = now()
+	`, qs, ReasonAssetsChanged)
+	_, err = db.Exec(ctx, q, types, ids, langs, deleted, reasons, TenantFromContext(ctx))
+	return err
+}
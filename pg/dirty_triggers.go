@@ -0,0 +1,273 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DirtyTrigger maps a host table to the search_dirty marks its row changes
+// produce (see InstallDirtyTriggers).
+type DirtyTrigger struct {
+	// Table is the host table, optionally schema-qualified ("app.posts").
+	Table string
+	// EntityType is written to search_dirty.entity_type.
+	EntityType string
+	// IDColumn holds the entity ID (cast to text).
+	IDColumn string
+
+	// LanguageColumn holds the entity's language; otherwise every row is
+	// marked in each of Languages. One of the two is required.
+	LanguageColumn string
+	Languages      []string
+
+	// TenantColumn holds the tenant ID. Without it, marks get the tenant in
+	// TenantSetting (the default tenant when unset).
+	TenantColumn string
+
+	// SoftDeleteColumn, when set, marks rows where it is NOT NULL as deleted
+	// (e.g. deleted_at).
+	SoftDeleteColumn string
+
+	// WatchColumns limits UPDATE marks to changes of these columns (plus the
+	// ID, language and soft-delete columns). Empty marks every UPDATE.
+	WatchColumns []string
+
+	// Reason defaults to "trigger".
+	Reason string
+}
+
+// name returns the trigger (and trigger function) name, unique per table and
+// entity type.
+func (t DirtyTrigger) name() string {
+	h := sha1.Sum([]byte(strings.TrimSpace(t.Table) + "\x00" + strings.TrimSpace(t.EntityType)))
+	return "searchkit_dirty__" + hex.EncodeToString(h[:8])
+}
+
+// InstallDirtyTriggers (re)creates, in one transaction, a row-level AFTER
+// INSERT/UPDATE/DELETE trigger on each host table that upserts the changed
+// entity into `<schema>.search_dirty`, like MarkDirty, so writes that bypass
+// the host's MarkDirty calls (other services, SQL consoles, bulk jobs) are
+// still indexed. A DELETE, a soft delete or an UPDATE that changes the ID or
+// language marks the old key deleted.
+//
+// The trigger functions live in schema and run as SECURITY DEFINER, so host
+// roles need no privileges on search_dirty and tenant RLS does not reject
+// marks of other tenants. The caller must own the host tables. Calling it
+// again with changed config replaces the triggers; DropDirtyTriggers removes
+// them.
+func InstallDirtyTriggers(ctx context.Context, db Querier, schema string, triggers []DirtyTrigger) error {
+	if db == nil {
+		return fmt.Errorf("db is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	stmts := make([]string, 0, 3*len(triggers))
+	for _, t := range triggers {
+		s, err := dirtyTriggerSQL(qs, t)
+		if err != nil {
+			return fmt.Errorf("dirty trigger %s/%s: %w", t.Table, t.EntityType, err)
+		}
+		stmts = append(stmts, s...)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	for _, q := range stmts {
+		if _, err := tx.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// DropDirtyTriggers removes the triggers and trigger functions
+// InstallDirtyTriggers created for triggers (only Table and EntityType are
+// used).
+func DropDirtyTriggers(ctx context.Context, db Querier, schema string, triggers []DirtyTrigger) error {
+	if db == nil {
+		return fmt.Errorf("db is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	for _, t := range triggers {
+		table, err := quoteQualified(t.Table)
+		if err != nil {
+			return fmt.Errorf("invalid table: %w", err)
+		}
+		name := t.name()
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON %s`, name, table)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`DROP FUNCTION IF EXISTS %s.%s()`, qs, name)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// dirtyTriggerSQL returns the statements creating t's trigger function and
+// trigger.
+func dirtyTriggerSQL(qs string, t DirtyTrigger) ([]string, error) {
+	entityType := strings.TrimSpace(t.EntityType)
+	if entityType == "" {
+		return nil, fmt.Errorf("entityType is required")
+	}
+	table, err := quoteQualified(t.Table)
+	if err != nil {
+		return nil, fmt.Errorf("invalid table: %w", err)
+	}
+	idCol, err := quoteIdent(t.IDColumn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id column: %w", err)
+	}
+
+	// keyCols are compared to detect an UPDATE that moves the entity.
+	keyCols := []string{idCol}
+	var langs func(row string) string
+	switch {
+	case t.LanguageColumn != "" && len(t.Languages) > 0:
+		return nil, fmt.Errorf("set LanguageColumn or Languages, not both")
+	case t.LanguageColumn != "":
+		col, err := quoteIdent(t.LanguageColumn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid language column: %w", err)
+		}
+		keyCols = append(keyCols, col)
+		langs = func(row string) string { return fmt.Sprintf("ARRAY[%s.%s::text]", row, col) }
+	case len(t.Languages) > 0:
+		lits := make([]string, 0, len(t.Languages))
+		for _, l := range t.Languages {
+			if strings.TrimSpace(l) == "" {
+				return nil, fmt.Errorf("language is required")
+			}
+			lits = append(lits, quoteLiteral(strings.TrimSpace(l)))
+		}
+		fixed := "ARRAY[" + strings.Join(lits, ", ") + "]::text[]"
+		langs = func(string) string { return fixed }
+	default:
+		return nil, fmt.Errorf("LanguageColumn or Languages is required")
+	}
+
+	tenant := func(string) string {
+		return fmt.Sprintf("COALESCE(current_setting(%s, true), '')", quoteLiteral(TenantSetting))
+	}
+	if t.TenantColumn != "" {
+		col, err := quoteIdent(t.TenantColumn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenant column: %w", err)
+		}
+		keyCols = append(keyCols, col)
+		tenant = func(row string) string { return fmt.Sprintf("COALESCE(%s.%s::text, '')", row, col) }
+	}
+
+	// deleted is the is_deleted value of a mark for row (OLD or NEW) of a
+	// non-DELETE operation.
+	deleted := func(string) string { return "false" }
+	watch := append([]string(nil), keyCols...)
+	if t.SoftDeleteColumn != "" {
+		col, err := quoteIdent(t.SoftDeleteColumn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid soft-delete column: %w", err)
+		}
+		watch = append(watch, col)
+		deleted = func(row string) string { return fmt.Sprintf("(%s.%s IS NOT NULL)", row, col) }
+	}
+	for _, c := range t.WatchColumns {
+		col, err := quoteIdent(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid watch column: %w", err)
+		}
+		watch = append(watch, col)
+	}
+
+	reason := strings.TrimSpace(t.Reason)
+	if reason == "" {
+		reason = "trigger"
+	}
+
+	mark := func(row, isDeleted string) string {
+		return fmt.Sprintf(`
+			INSERT INTO %[1]s.search_dirty (entity_type, entity_id, language, is_deleted, reason, tenant_id, created_at, updated_at)
+			SELECT %[2]s, %[3]s.%[4]s::text, lang, %[5]s, %[6]s, %[7]s, now(), now()
+			FROM unnest(%[8]s) AS lang
+			WHERE lang IS NOT NULL AND lang <> ''
+			ON CONFLICT (tenant_id, entity_type, entity_id, language) DO UPDATE SET
+				is_deleted = EXCLUDED.is_deleted,
+				reason = EXCLUDED.reason,
+				updated_at = now();`,
+			qs, quoteLiteral(entityType), row, idCol, isDeleted, quoteLiteral(reason), tenant(row), langs(row))
+	}
+	distinct := func(cols []string) string {
+		parts := make([]string, 0, len(cols))
+		for _, c := range cols {
+			parts = append(parts, fmt.Sprintf("OLD.%[1]s IS DISTINCT FROM NEW.%[1]s", c))
+		}
+		return strings.Join(parts, " OR ")
+	}
+	updateFilter := ""
+	if len(t.WatchColumns) > 0 {
+		updateFilter = fmt.Sprintf(`
+			IF NOT (%s) THEN
+				RETURN NULL;
+			END IF;`, distinct(watch))
+	}
+
+	name := t.name()
+	fn := fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s.%[2]s() RETURNS trigger
+		LANGUAGE plpgsql SECURITY DEFINER SET search_path = pg_catalog, pg_temp
+		AS $searchkit$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN%[3]s
+				RETURN NULL;
+			END IF;
+			IF TG_OP = 'UPDATE' THEN%[4]s
+				IF %[5]s THEN%[6]s
+				END IF;
+			END IF;%[7]s
+			RETURN NULL;
+		END
+		$searchkit$
+	`, qs, name, mark("OLD", "true"), updateFilter, distinct(keyCols), mark("OLD", "true"), mark("NEW", deleted("NEW")))
+	return []string{
+		fn,
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON %s`, name, table),
+		fmt.Sprintf(`
+			CREATE TRIGGER %s
+			AFTER INSERT OR UPDATE OR DELETE ON %s
+			FOR EACH ROW EXECUTE FUNCTION %s.%s()
+		`, name, table, qs, name),
+	}, nil
+}
+
+// quoteQualified quotes a table name with an optional schema prefix.
+func quoteQualified(name string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(name), ".")
+	if len(parts) > 2 {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+	for i, p := range parts {
+		q, err := quoteIdent(p)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = q
+	}
+	return strings.Join(parts, "."), nil
+}
@@ -0,0 +1,406 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DoctorSeverity ranks a DoctorIssue.
+type DoctorSeverity string
+
+const (
+	// DoctorError issues break search or indexing (wrong dims, missing or
+	// invalid indexes, unregistered models).
+	DoctorError DoctorSeverity = "error"
+	// DoctorWarning issues degrade results or waste work (stale state,
+	// backlog, orphaned indexes).
+	DoctorWarning DoctorSeverity = "warning"
+)
+
+// DoctorIssue is one problem found by Diagnose.
+type DoctorIssue struct {
+	Severity DoctorSeverity
+	// Check names the failing check: "models", "indexes", "vector_dims",
+	// "tsv", "backfill" or "queue".
+	Check   string
+	Model   string // when the issue concerns one model
+	Message string
+	// Fix suggests how to resolve it.
+	Fix string
+}
+
+func (i DoctorIssue) String() string {
+	s := fmt.Sprintf("[%s] %s: %s", i.Severity, i.Check, i.Message)
+	if i.Fix != "" {
+		s += " (fix: " + i.Fix + ")"
+	}
+	return s
+}
+
+// DoctorQueueStats is a snapshot of the work queues taken by Diagnose.
+type DoctorQueueStats struct {
+	ReadyTasks  int
+	OldestReady time.Duration // age of the oldest ready task (0 if none)
+	DeadLetters int
+	DirtyRows   int
+	OldestDirty time.Duration // age of the oldest search_dirty row (0 if none)
+}
+
+// DoctorReport is the result of Diagnose.
+type DoctorReport struct {
+	Issues []DoctorIssue
+	Queue  DoctorQueueStats
+}
+
+// OK reports whether no DoctorError issue was found.
+func (r DoctorReport) OK() bool {
+	return !slices.ContainsFunc(r.Issues, func(i DoctorIssue) bool { return i.Severity == DoctorError })
+}
+
+// DiagnoseOptions tunes Diagnose.
+type DiagnoseOptions struct {
+	// StaleAfter is how old queued work or a running backfill may get before
+	// it is reported (default 1h).
+	StaleAfter time.Duration
+	// SampleSize bounds the vectors read per model by the dims check
+	// (default 1000).
+	SampleSize int
+}
+
+// Diagnose cross-checks the searchkit tables in schema against the configured
+// models (see CheckModels for the blocking subset run at startup): model
+// registry, per-model ANN indexes (existence, validity, dims), the dims of a
+// sample of stored vectors against the indexes' casts, unpopulated FTS
+// vectors, backfill state, and queue health. Problems are returned as issues
+// with suggested fixes; the error is only set when a check query fails.
+//
+// It only reads, and is meant for admin endpoints, CLIs and deploy checks.
+func Diagnose(ctx context.Context, pool Querier, schema string, models []ModelSpec, opts DiagnoseOptions) (DoctorReport, error) {
+	var report DoctorReport
+	if pool == nil {
+		return report, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return report, fmt.Errorf("invalid schema: %w", err)
+	}
+	if opts.StaleAfter <= 0 {
+		opts.StaleAfter = time.Hour
+	}
+	if opts.SampleSize <= 0 {
+		opts.SampleSize = 1000
+	}
+	d := &doctor{pool: pool, schema: strings.TrimSpace(schema), qs: qs, opts: opts, report: &report}
+	d.configured = make(map[string]ModelSpec, len(models))
+	for _, m := range models {
+		d.configured[strings.TrimSpace(m.Name)] = m
+	}
+	for _, check := range []func(context.Context) error{
+		d.checkModels,
+		d.checkIndexes,
+		d.checkVectorDims,
+		d.checkTSV,
+		d.checkBackfill,
+		d.checkQueue,
+	} {
+		if err := check(ctx); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+type doctor struct {
+	pool       Querier
+	schema, qs string
+	opts       DiagnoseOptions
+	configured map[string]ModelSpec
+	report     *DoctorReport
+}
+
+func (d *doctor) add(sev DoctorSeverity, check, model, fix, format string, args ...any) {
+	d.report.Issues = append(d.report.Issues, DoctorIssue{Severity: sev, Check: check, Model: model, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+func (d *doctor) sortedConfigured() []string {
+	names := make([]string, 0, len(d.configured))
+	for name := range d.configured {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (d *doctor) checkModels(ctx context.Context) error {
+	type registered struct {
+		dims                             int
+		modality, storage, normalization string
+		inactive                         bool
+	}
+	rows, err := d.pool.Query(ctx, fmt.Sprintf(`
+		SELECT model, dims, modality, storage, normalization, inactive_since IS NOT NULL
+		FROM %s.embedding_models
+		ORDER BY model
+	`, d.qs))
+	if err != nil {
+		return err
+	}
+	stored := map[string]registered{}
+	var names []string
+	for rows.Next() {
+		var name string
+		var r registered
+		if err := rows.Scan(&name, &r.dims, &r.modality, &r.storage, &r.normalization, &r.inactive); err != nil {
+			rows.Close()
+			return err
+		}
+		stored[name] = r
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	const restart = "start the runtime with runtime.NewWithContext, which registers models and builds their indexes"
+	const rename = "use a new model name, or delete the model's vectors and indexes before re-embedding"
+	for _, name := range d.sortedConfigured() {
+		m := d.configured[name]
+		r, ok := stored[name]
+		switch {
+		case !ok:
+			d.add(DoctorError, "models", name, restart, "model %q is configured but not registered in embedding_models", name)
+			continue
+		case r.inactive:
+			d.add(DoctorError, "models", name, restart, "model %q is configured but registered as inactive", name)
+		}
+		if want := m.IndexDims(); r.dims != want {
+			d.add(DoctorError, "models", name, rename, "model %q produces %d dims but %d-dim vectors are registered", name, want, r.dims)
+		}
+		if m.Modality != "" && r.modality != m.Modality {
+			d.add(DoctorError, "models", name, rename, "model %q is configured as %s but registered as %s", name, m.Modality, r.modality)
+		}
+		if want := string(m.Storage.OrDefault()); r.storage != want {
+			d.add(DoctorError, "models", name, restart+"; existing vectors stay in the old representation until re-embedded", "model %q is configured with %s storage but registered with %s", name, want, r.storage)
+		}
+		if want := string(m.Normalization.OrDefault()); m.Modality != "sparse" && r.normalization != want {
+			d.add(DoctorError, "models", name, "use a new model name so queries never mix both policies", "model %q is configured with %s normalization but registered with %s", name, want, r.normalization)
+		}
+	}
+	for _, name := range names {
+		if _, ok := d.configured[name]; !ok && !stored[name].inactive {
+			d.add(DoctorWarning, "models", name, "restart the runtime to mark it inactive, then pg.PruneInactiveModels once it is no longer needed", "model %q is registered as active but not configured", name)
+		}
+	}
+	return nil
+}
+
+func (d *doctor) checkIndexes(ctx context.Context) error {
+	rows, err := d.pool.Query(ctx, `
+		SELECT i.tablename, i.indexname, i.indexdef, ix.indisvalid
+		FROM pg_indexes i
+		JOIN pg_namespace n ON n.nspname = i.schemaname
+		JOIN pg_class c ON c.relname = i.indexname AND c.relnamespace = n.oid
+		JOIN pg_index ix ON ix.indexrelid = c.oid
+		WHERE i.schemaname = $1
+		  AND i.tablename IN ('embedding_vectors', 'embedding_vectors_vl', 'embedding_vector_assets')
+		  AND i.indexname LIKE 'idx_embedding_%hnsw%'
+		ORDER BY i.indexname
+	`, d.schema)
+	if err != nil {
+		return err
+	}
+	type index struct {
+		table, name string
+		def         string
+		model       string
+		dims        int
+		valid       bool
+	}
+	var indexes []index
+	for rows.Next() {
+		var idx index
+		var def string
+		if err := rows.Scan(&idx.table, &idx.name, &def, &idx.valid); err != nil {
+			rows.Close()
+			return err
+		}
+		model, dims, ok := parseModelIndexDef(def)
+		if !ok {
+			continue
+		}
+		idx.model, idx.dims, idx.def = model, dims, def
+		indexes = append(indexes, idx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	hasIndex := map[string]bool{}
+	for _, idx := range indexes {
+		drop := fmt.Sprintf("DROP INDEX CONCURRENTLY %s.%s", d.qs, idx.name)
+		m, ok := d.configured[idx.model]
+		switch {
+		case !idx.valid:
+			d.add(DoctorError, "indexes", idx.model, drop+", then restart the runtime to rebuild it", "index %s is invalid (an interrupted CREATE INDEX CONCURRENTLY); it is maintained on writes but never used", idx.name)
+		case !ok:
+			d.add(DoctorWarning, "indexes", idx.model, drop+" once the model's vectors are gone", "index %s belongs to model %q, which is not configured", idx.name, idx.model)
+		case idx.dims != indexDimsFor(m, idx.def):
+			d.add(DoctorError, "indexes", idx.model, drop+" once the model's old vectors are gone", "index %s is built for %d dims, expected %d", idx.name, idx.dims, indexDimsFor(m, idx.def))
+		default:
+			if idx.table != embeddingVectorAssetsTable {
+				hasIndex[idx.model] = true
+			}
+		}
+	}
+	for _, name := range d.sortedConfigured() {
+		if !hasIndex[name] {
+			d.add(DoctorError, "indexes", name, "restart the runtime (pg.EnsureIndexesForModels) outside a transaction", "model %q has no valid ANN index; semantic search on it scans every vector", name)
+		}
+	}
+	return nil
+}
+
+func (d *doctor) checkVectorDims(ctx context.Context) error {
+	for _, name := range d.sortedConfigured() {
+		m := d.configured[name]
+		if m.Modality == "sparse" {
+			continue
+		}
+		table := embeddingVectorsTable
+		dims := "COALESCE(vector_dims(embedding), vector_dims(embedding_vec), bit_length(embedding_bits))"
+		if m.Modality == "vl" {
+			table, dims = embeddingVectorsVLTable, "COALESCE(vector_dims(embedding), vector_dims(embedding_vec))"
+		}
+		var sampled, bad int
+		err := d.pool.QueryRow(ctx, fmt.Sprintf(`
+			SELECT count(*), count(*) FILTER (WHERE d <> $2)
+			FROM (
+				SELECT %s AS d
+				FROM %s.%s
+				WHERE model = $1
+				LIMIT $3
+			) s
+		`, dims, d.qs, table), name, m.IndexDims(), d.opts.SampleSize).Scan(&sampled, &bad)
+		if err != nil {
+			return fmt.Errorf("vector dims of %q: %w", name, err)
+		}
+		if bad > 0 {
+			d.add(DoctorError, "vector_dims", name, "delete the model's mismatched vectors and re-embed them (runtime.WithReembed), or move the new vectors to a new model name",
+				"%d of %d sampled %s vectors of model %q are not %d-dim; they fail the index cast and break searches that reach them", bad, sampled, table, name, m.IndexDims())
+		}
+	}
+	return nil
+}
+
+func (d *doctor) checkTSV(ctx context.Context) error {
+	var missing int
+	err := d.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT count(*) FROM %s.%s WHERE tsv IS NULL AND deleted_at IS NULL
+	`, d.qs, searchDocumentsTable)).Scan(&missing)
+	if err != nil {
+		return err
+	}
+	if missing > 0 {
+		d.add(DoctorWarning, "tsv", "",
+			fmt.Sprintf("UPDATE %[1]s.%[2]s SET tsv = to_tsvector(%[1]s.searchkit_regconfig_for_language(language), coalesce(raw_document, document, '')) WHERE tsv IS NULL", d.qs, searchDocumentsTable),
+			"%d lexical documents have no tsv and are invisible to full-text search (written outside searchkit?)", missing)
+	}
+	return nil
+}
+
+func (d *doctor) checkBackfill(ctx context.Context) error {
+	rows, err := d.pool.Query(ctx, fmt.Sprintf(`
+		SELECT model, entity_type, language, tenant_id, state, COALESCE(last_error, ''), updated_at < now() - make_interval(secs => $1)
+		FROM %[1]s.embedding_vectors_backfill_state
+		WHERE state <> 'done'
+		UNION ALL
+		SELECT '', entity_type, language, tenant_id, state, COALESCE(last_error, ''), updated_at < now() - make_interval(secs => $1)
+		FROM %[1]s.search_documents_backfill_state
+		WHERE state <> 'done'
+		ORDER BY 1, 2, 3, 4
+	`, d.qs), d.opts.StaleAfter.Seconds())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var model, entityType, language, tenant, state, lastErr string
+		var stale bool
+		if err := rows.Scan(&model, &entityType, &language, &tenant, &state, &lastErr, &stale); err != nil {
+			return err
+		}
+		what := "lexical"
+		if model != "" {
+			what = fmt.Sprintf("model %q", model)
+		}
+		scope := fmt.Sprintf("%s backfill of %s/%s (tenant %q)", what, entityType, language, tenant)
+		_, configured := d.configured[model]
+		switch {
+		case model != "" && !configured:
+			d.add(DoctorWarning, "backfill", model, "pg.PruneInactiveModels deletes the state of inactive models", "%s is %s but the model is not configured", scope, state)
+		case state == "failed":
+			d.add(DoctorWarning, "backfill", model, "fix the cause, then set the row's state back to 'running' to resume from its cursor", "%s failed: %s", scope, lastErr)
+		case stale:
+			d.add(DoctorWarning, "backfill", model, "check that worker.SyncOnce runs and its ListEntityIDsPage callback returns pages", "%s is %s but made no progress in %s", scope, state, d.opts.StaleAfter)
+		}
+	}
+	return rows.Err()
+}
+
+func (d *doctor) checkQueue(ctx context.Context) error {
+	q := &d.report.Queue
+	var oldestReady, oldestDirty float64
+	err := d.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT
+			(SELECT count(*) FROM %[1]s.embedding_tasks
+			 WHERE next_run_at <= now() AND (lease_expires_at IS NULL OR lease_expires_at <= now())),
+			(SELECT COALESCE(EXTRACT(EPOCH FROM now() - min(next_run_at)), 0) FROM %[1]s.embedding_tasks
+			 WHERE next_run_at <= now() AND (lease_expires_at IS NULL OR lease_expires_at <= now())),
+			(SELECT count(*) FROM %[1]s.embedding_dead_letters),
+			(SELECT count(*) FROM %[1]s.search_dirty),
+			(SELECT COALESCE(EXTRACT(EPOCH FROM now() - min(updated_at)), 0) FROM %[1]s.search_dirty)
+	`, d.qs)).Scan(&q.ReadyTasks, &oldestReady, &q.DeadLetters, &q.DirtyRows, &oldestDirty)
+	if err != nil {
+		return err
+	}
+	q.OldestReady = time.Duration(oldestReady * float64(time.Second))
+	q.OldestDirty = time.Duration(oldestDirty * float64(time.Second))
+
+	if q.OldestReady > d.opts.StaleAfter {
+		d.add(DoctorWarning, "queue", "", "run more workers or raise their concurrency; check spend budgets and provider rate limits",
+			"%d embedding tasks are ready, the oldest for %s", q.ReadyTasks, q.OldestReady.Round(time.Second))
+	}
+	if q.OldestDirty > d.opts.StaleAfter {
+		d.add(DoctorWarning, "queue", "", "check that worker.SyncOnce runs regularly",
+			"%d search_dirty rows are pending, the oldest for %s", q.DirtyRows, q.OldestDirty.Round(time.Second))
+	}
+	if q.DeadLetters > 0 {
+		d.add(DoctorWarning, "queue", "", fmt.Sprintf("inspect %s.embedding_dead_letters, fix the cause and re-mark the entities dirty", d.qs),
+			"%d embedding tasks are dead-lettered", q.DeadLetters)
+	}
+
+	rows, err := d.pool.Query(ctx, fmt.Sprintf(`
+		SELECT model, count(*) FROM %s.embedding_tasks GROUP BY model ORDER BY model
+	`, d.qs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var model string
+		var n int
+		if err := rows.Scan(&model, &n); err != nil {
+			return err
+		}
+		if _, ok := d.configured[model]; !ok {
+			d.add(DoctorWarning, "queue", model, "pg.PruneInactiveModels deletes the tasks of inactive models",
+				"%d embedding tasks are queued for model %q, which is not configured and will never run them", n, model)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,98 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+const embeddingCacheTable = "embedding_cache"
+
+// EmbeddingCache is a Postgres-backed runtime.EmbeddingCache stored in
+// <schema>.embedding_cache. Vectors are kept at full (fp32) precision, so a
+// cache hit returns what the provider returned whatever the model's storage
+// mode.
+type EmbeddingCache struct {
+	pool   Querier
+	schema string
+}
+
+func NewEmbeddingCache(pool Querier, schema string) *EmbeddingCache {
+	return &EmbeddingCache{pool: pool, schema: schema}
+}
+
+// GetEmbeddings returns cached vectors for the given input hashes.
+func (c *EmbeddingCache) GetEmbeddings(ctx context.Context, model string, hashes []string) (map[string][]float32, error) {
+	if c.schema == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	out := make(map[string][]float32, len(hashes))
+	if len(hashes) == 0 {
+		return out, nil
+	}
+	q := fmt.Sprintf(`
+		SELECT content_hash, embedding
+		FROM %s.%s
+		WHERE model = $1 AND content_hash = ANY($2::text[])
+	`, c.schema, embeddingCacheTable)
+	rows, err := c.pool.Query(ctx, q, model, hashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			h   string
+			vec pgvector.Vector
+		)
+		if err := rows.Scan(&h, &vec); err != nil {
+			return nil, err
+		}
+		out[h] = vec.Slice()
+	}
+	return out, rows.Err()
+}
+
+// PutEmbeddings stores vectors keyed by input hash. Existing entries are kept.
+func (c *EmbeddingCache) PutEmbeddings(ctx context.Context, model string, entries map[string][]float32) error {
+	if c.schema == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return fmt.Errorf("model is required")
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	hashes := make([]string, 0, len(entries))
+	vecs := make([]string, 0, len(entries))
+	for h, v := range entries {
+		hashes = append(hashes, h)
+		vecs = append(vecs, pgvector.NewVector(v).String())
+	}
+	q := fmt.Sprintf(`
+		INSERT INTO %s.%s (model, content_hash, embedding, created_at)
+		SELECT $1, h, v::vector, now()
+		FROM unnest($2::text[], $3::text[]) AS t(h, v)
+		ON CONFLICT (model, content_hash) DO NOTHING
+	`, c.schema, embeddingCacheTable)
+	_, err := c.pool.Exec(ctx, q, model, hashes, vecs)
+	return err
+}
+
+// Prune deletes cache entries created before olderThan and returns how many
+// were removed.
+func (c *EmbeddingCache) Prune(ctx context.Context, olderThan time.Time) (int64, error) {
+	if c.schema == "" {
+		return 0, fmt.Errorf("schema is required")
+	}
+	q := fmt.Sprintf(`DELETE FROM %s.%s WHERE created_at < $1`, c.schema, embeddingCacheTable)
+	tag, err := c.pool.Exec(ctx, q, olderThan)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
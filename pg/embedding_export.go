@@ -0,0 +1,420 @@
+package pg
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+// EmbeddingFormat is the file format of ExportEmbeddings and ImportEmbeddings.
+type EmbeddingFormat string
+
+const (
+	// EmbeddingFormatNDJSON writes one JSON EmbeddingRecord per line (default).
+	EmbeddingFormatNDJSON EmbeddingFormat = "ndjson"
+	// EmbeddingFormatCSV writes a header row followed by one row per record,
+	// with the EmbeddingRecord JSON names as column names.
+	EmbeddingFormatCSV EmbeddingFormat = "csv"
+)
+
+// OrDefault returns f, or EmbeddingFormatNDJSON when f is empty.
+func (f EmbeddingFormat) OrDefault() EmbeddingFormat {
+	if f == "" {
+		return EmbeddingFormatNDJSON
+	}
+	return f
+}
+
+func (f EmbeddingFormat) Validate() error {
+	switch f.OrDefault() {
+	case EmbeddingFormatNDJSON, EmbeddingFormatCSV:
+		return nil
+	default:
+		return fmt.Errorf("invalid embedding format %q (expected ndjson or csv)", string(f))
+	}
+}
+
+// EmbeddingRecord is one exported vector: a chunk of an entity's text
+// embedding, or (chunk 0) its fused VL vector. In files, Vector is the
+// base64 of its little-endian float32s.
+type EmbeddingRecord struct {
+	EntityType  string    `json:"entity_type"`
+	EntityID    string    `json:"entity_id"`
+	Model       string    `json:"model"`
+	Language    string    `json:"language"`
+	ChunkIdx    int       `json:"chunk_idx"`
+	TenantID    string    `json:"tenant_id"`
+	ContentHash string    `json:"content_hash"`
+	Vector      []float32 `json:"-"`
+}
+
+// embeddingRecordColumns are the CSV columns, in order.
+var embeddingRecordColumns = []string{"entity_type", "entity_id", "model", "language", "chunk_idx", "tenant_id", "content_hash", "vector"}
+
+func (r EmbeddingRecord) MarshalJSON() ([]byte, error) {
+	type plain EmbeddingRecord
+	return json.Marshal(struct {
+		plain
+		Vector string `json:"vector"`
+	}{plain(r), encodeVector(r.Vector)})
+}
+
+func (r *EmbeddingRecord) UnmarshalJSON(data []byte) error {
+	type plain EmbeddingRecord
+	var v struct {
+		plain
+		Vector string `json:"vector"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	vec, err := decodeVector(v.Vector)
+	if err != nil {
+		return err
+	}
+	*r = EmbeddingRecord(v.plain)
+	r.Vector = vec
+	return nil
+}
+
+func encodeVector(vec []float32) string {
+	buf := make([]byte, 4*len(vec))
+	for i, x := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func decodeVector(s string) ([]float32, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vector: %w", err)
+	}
+	if len(buf) == 0 || len(buf)%4 != 0 {
+		return nil, fmt.Errorf("invalid vector: %d bytes is not a non-empty float32 array", len(buf))
+	}
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vec, nil
+}
+
+// EmbeddingExportOptions selects the vectors ExportEmbeddings writes and
+// ImportEmbeddings reads. Empty filters match everything.
+type EmbeddingExportOptions struct {
+	Format      EmbeddingFormat
+	Models      []string
+	EntityTypes []string
+}
+
+func (o EmbeddingExportOptions) match(r EmbeddingRecord) bool {
+	return (len(o.Models) == 0 || slices.Contains(o.Models, r.Model)) &&
+		(len(o.EntityTypes) == 0 || slices.Contains(o.EntityTypes, r.EntityType))
+}
+
+// ExportEmbeddings writes the stored dense vectors (embedding_vectors, with
+// the exact halfvec for bit models, and fused VL vectors from
+// embedding_vectors_vl) to w, so they can be backed up before risky model or
+// config changes, or copied to another environment with ImportEmbeddings,
+// without paying the provider again. Soft-deleted rows, sparse vectors and
+// per-asset VL vectors are not exported. It returns the number of records
+// written; records of one entity are contiguous and ordered by chunk.
+func ExportEmbeddings(ctx context.Context, pool Querier, schema string, w io.Writer, opts EmbeddingExportOptions) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("pool is required")
+	}
+	if w == nil {
+		return 0, fmt.Errorf("writer is required")
+	}
+	if err := opts.Format.Validate(); err != nil {
+		return 0, err
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	q := fmt.Sprintf(`
+		SELECT entity_type, entity_id, model, language, chunk_idx, tenant_id, content_hash, embedding
+		FROM (
+			SELECT ev.entity_type, ev.entity_id, ev.model, ev.language, ev.chunk_idx, ev.tenant_id,
+				COALESCE(ev.content_hash, '') AS content_hash,
+				COALESCE(ev.embedding_vec, ev.embedding::vector, ex.embedding::vector) AS embedding
+			FROM %[1]s.%[2]s ev
+			LEFT JOIN %[1]s.%[3]s ex
+				ON ex.tenant_id = ev.tenant_id AND ex.entity_type = ev.entity_type AND ex.entity_id = ev.entity_id AND ex.model = ev.model
+				AND ex.language = ev.language AND ex.chunk_idx = ev.chunk_idx
+			WHERE ev.deleted_at IS NULL
+			UNION ALL
+			SELECT entity_type, entity_id, model, language, 0, tenant_id, COALESCE(content_hash, ''), COALESCE(embedding_vec, embedding::vector)
+			FROM %[1]s.%[4]s
+			WHERE deleted_at IS NULL
+		) v
+		WHERE embedding IS NOT NULL
+		  AND (COALESCE(cardinality($1::text[]), 0) = 0 OR model = ANY($1))
+		  AND (COALESCE(cardinality($2::text[]), 0) = 0 OR entity_type = ANY($2))
+		ORDER BY model, tenant_id, entity_type, entity_id, language, chunk_idx
+	`, qs, embeddingVectorsTable, embeddingVectorsExactTable, embeddingVectorsVLTable)
+	rows, err := pool.Query(ctx, q, opts.Models, opts.EntityTypes)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	bw := bufio.NewWriter(w)
+	var cw *csv.Writer
+	if opts.Format.OrDefault() == EmbeddingFormatCSV {
+		cw = csv.NewWriter(bw)
+		if err := cw.Write(embeddingRecordColumns); err != nil {
+			return 0, err
+		}
+	}
+	enc := json.NewEncoder(bw)
+	n := 0
+	for rows.Next() {
+		var r EmbeddingRecord
+		var vec pgvector.Vector
+		if err := rows.Scan(&r.EntityType, &r.EntityID, &r.Model, &r.Language, &r.ChunkIdx, &r.TenantID, &r.ContentHash, &vec); err != nil {
+			return n, err
+		}
+		r.Vector = vec.Slice()
+		if cw != nil {
+			err = cw.Write([]string{r.EntityType, r.EntityID, r.Model, r.Language, strconv.Itoa(r.ChunkIdx), r.TenantID, r.ContentHash, encodeVector(r.Vector)})
+		} else {
+			err = enc.Encode(r)
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, err
+	}
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// ImportEmbeddings reads records written by ExportEmbeddings from r and
+// upserts those matching opts, keeping their tenants and content hashes so
+// the worker treats them as current. Text vectors are stored in each model's
+// registered storage mode with UpsertTextEmbeddingsBulk; models registered as
+// "vl" go to embedding_vectors_vl. Target models must already be registered
+// (embedding_models) with the records' dimensions.
+//
+// An entity's records must be contiguous and start at chunk 0, as exported;
+// each entity's chunks replace its stored ones. It returns the number of
+// records imported.
+func ImportEmbeddings(ctx context.Context, pool Querier, schema string, r io.Reader, opts EmbeddingExportOptions) (int, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("pool is required")
+	}
+	if r == nil {
+		return 0, fmt.Errorf("reader is required")
+	}
+	if err := opts.Format.Validate(); err != nil {
+		return 0, err
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	type registered struct {
+		dims     int
+		modality string
+		storage  StorageMode
+	}
+	models := map[string]registered{}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`SELECT model, dims, modality, storage FROM %s.embedding_models`, qs))
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var name, storage string
+		var m registered
+		if err := rows.Scan(&name, &m.dims, &m.modality, &storage); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		m.storage = StorageMode(storage)
+		models[name] = m
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	modes := make(map[string]StorageMode, len(models))
+	for name, m := range models {
+		modes[name] = m.storage
+	}
+	store := NewPostgresStorage(pool, schema)
+	store.SetStorageModes(modes)
+
+	const batchSize = 500
+	var (
+		batch       []TextEmbeddingWrite
+		batchModel  string
+		batchTenant string
+		n           int
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := store.UpsertTextEmbeddingsBulk(WithTenant(ctx, batchTenant), batchModel, batch)
+		batch = batch[:0]
+		return err
+	}
+	var (
+		cur       *EmbeddingRecord // first record of the entity being collected
+		curChunks [][]float32
+	)
+	// finish stores the collected entity (VL vectors at once, text vectors
+	// through the batch).
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		m := models[cur.Model]
+		if m.modality == "vl" {
+			if len(curChunks) != 1 {
+				return fmt.Errorf("vl model %q: %s/%s has %d vectors (expected 1)", cur.Model, cur.EntityType, cur.EntityID, len(curChunks))
+			}
+			return store.UpsertVLEmbedding(WithTenant(ctx, cur.TenantID), cur.EntityType, cur.EntityID, cur.Model, cur.Language, curChunks[0], cur.ContentHash)
+		}
+		if len(batch) > 0 && (batchModel != cur.Model || batchTenant != cur.TenantID || len(batch) >= batchSize) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batchModel, batchTenant = cur.Model, cur.TenantID
+		batch = append(batch, TextEmbeddingWrite{
+			EntityType:  cur.EntityType,
+			EntityID:    cur.EntityID,
+			Language:    cur.Language,
+			Chunks:      curChunks,
+			ContentHash: cur.ContentHash,
+		})
+		return nil
+	}
+
+	next := embeddingRecordReader(r, opts.Format.OrDefault())
+	for line := 1; ; line++ {
+		rec, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", line, err)
+		}
+		if !opts.match(rec) {
+			continue
+		}
+		m, ok := models[rec.Model]
+		switch {
+		case !ok:
+			return n, fmt.Errorf("record %d: model %q is not registered in embedding_models", line, rec.Model)
+		case m.modality == "sparse":
+			return n, fmt.Errorf("record %d: model %q stores sparse vectors", line, rec.Model)
+		case len(rec.Vector) != m.dims:
+			return n, fmt.Errorf("record %d: model %q has %d dims but the vector has %d", line, rec.Model, m.dims, len(rec.Vector))
+		}
+
+		same := cur != nil && cur.Model == rec.Model && cur.TenantID == rec.TenantID && cur.EntityType == rec.EntityType && cur.EntityID == rec.EntityID && cur.Language == rec.Language
+		if !same {
+			if err := finish(); err != nil {
+				return n, err
+			}
+			cur, curChunks = &rec, nil
+		}
+		if rec.ChunkIdx != len(curChunks) {
+			return n, fmt.Errorf("record %d: %s/%s chunk %d is out of order (records of an entity must be contiguous from chunk 0)", line, rec.EntityType, rec.EntityID, rec.ChunkIdx)
+		}
+		curChunks = append(curChunks, rec.Vector)
+		n++
+	}
+	if err := finish(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// embeddingRecordReader returns a function reading the next record from r,
+// or io.EOF.
+func embeddingRecordReader(r io.Reader, format EmbeddingFormat) func() (EmbeddingRecord, error) {
+	if format == EmbeddingFormatCSV {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		var cols map[string]int
+		return func() (EmbeddingRecord, error) {
+			if cols == nil {
+				header, err := cr.Read()
+				if err != nil {
+					return EmbeddingRecord{}, err
+				}
+				cols = make(map[string]int, len(header))
+				for i, h := range header {
+					cols[strings.TrimSpace(h)] = i
+				}
+				for _, c := range embeddingRecordColumns {
+					if _, ok := cols[c]; !ok {
+						return EmbeddingRecord{}, fmt.Errorf("csv header is missing column %q", c)
+					}
+				}
+			}
+			row, err := cr.Read()
+			if err != nil {
+				return EmbeddingRecord{}, err
+			}
+			field := func(name string) string {
+				if i := cols[name]; i < len(row) {
+					return row[i]
+				}
+				return ""
+			}
+			chunk, err := strconv.Atoi(field("chunk_idx"))
+			if err != nil {
+				return EmbeddingRecord{}, fmt.Errorf("invalid chunk_idx: %w", err)
+			}
+			vec, err := decodeVector(field("vector"))
+			if err != nil {
+				return EmbeddingRecord{}, err
+			}
+			return EmbeddingRecord{
+				EntityType:  field("entity_type"),
+				EntityID:    field("entity_id"),
+				Model:       field("model"),
+				Language:    field("language"),
+				ChunkIdx:    chunk,
+				TenantID:    field("tenant_id"),
+				ContentHash: field("content_hash"),
+				Vector:      vec,
+			}, nil
+		}
+	}
+	dec := json.NewDecoder(r)
+	return func() (EmbeddingRecord, error) {
+		var rec EmbeddingRecord
+		err := dec.Decode(&rec)
+		return rec, err
+	}
+}
@@ -4,12 +4,11 @@ import (
 	"context"
 	"fmt"
 	"strings"
-
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// DeleteEmbeddingVectorsForEntity deletes all embeddings (all models) for an entity+language.
-func DeleteEmbeddingVectorsForEntity(ctx context.Context, pool *pgxpool.Pool, schema string, entityType string, entityID string, language string) error {
+// DeleteEmbeddingVectorsForEntity deletes all embeddings (all models) for an
+// entity+language of ctx's tenant (see WithTenant).
+func DeleteEmbeddingVectorsForEntity(ctx context.Context, pool Querier, schema string, entityType string, entityID string, language string) error {
 	if pool == nil {
 		return fmt.Errorf("pool is required")
 	}
@@ -23,17 +22,22 @@ func DeleteEmbeddingVectorsForEntity(ctx context.Context, pool *pgxpool.Pool, sc
 	if err != nil {
 		return fmt.Errorf("invalid schema: %w", err)
 	}
-	q := fmt.Sprintf(`
-		DELETE FROM %s.embedding_vectors
-		WHERE entity_type = $1 AND entity_id = $2 AND language = $3
-	`, qs)
-	_, err = pool.Exec(ctx, q, entityType, entityID, language)
-	return err
+	for _, table := range []string{embeddingVectorsTable, embeddingVectorsExactTable, embeddingVectorsVLTable, embeddingVectorAssetsTable} {
+		q := fmt.Sprintf(`
+			DELETE FROM %s.%s
+			WHERE tenant_id = $4 AND entity_type = $1 AND entity_id = $2 AND language = $3
+		`, qs, table)
+		if _, err := pool.Exec(ctx, q, entityType, entityID, language, TenantFromContext(ctx)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // FilterMissingEmbeddings returns the subset of entityIDs that do NOT currently
-// have an embedding vector for (entity_type, model, language).
-func FilterMissingEmbeddings(ctx context.Context, pool *pgxpool.Pool, schema string, entityType string, model string, language string, entityIDs []string) ([]string, error) {
+// have an embedding vector for (entity_type, model, language) in ctx's tenant,
+// in embedding_vectors or (for VL models) embedding_vectors_vl.
+func FilterMissingEmbeddings(ctx context.Context, pool Querier, schema string, entityType string, model string, language string, entityIDs []string) ([]string, error) {
 	if pool == nil {
 		return nil, fmt.Errorf("pool is required")
 	}
@@ -56,14 +60,16 @@ func FilterMissingEmbeddings(ctx context.Context, pool *pgxpool.Pool, schema str
 		)
 		SELECT ids.entity_id
 		FROM ids
-		LEFT JOIN %s.embedding_vectors ev
-			ON ev.entity_type = $1
-			AND ev.entity_id = ids.entity_id
-			AND ev.model = $2
-			AND ev.language = $3
-		WHERE ev.entity_id IS NULL
-	`, qs)
-	rows, err := pool.Query(ctx, q, entityType, model, language, entityIDs)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM %[1]s.%[2]s ev
+			WHERE ev.tenant_id = $5 AND ev.entity_type = $1 AND ev.entity_id = ids.entity_id AND ev.model = $2 AND ev.language = $3
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM %[1]s.%[3]s ev
+			WHERE ev.tenant_id = $5 AND ev.entity_type = $1 AND ev.entity_id = ids.entity_id AND ev.model = $2 AND ev.language = $3
+		)
+	`, qs, embeddingVectorsTable, embeddingVectorsVLTable)
+	rows, err := pool.Query(ctx, q, entityType, model, language, entityIDs, TenantFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -80,3 +86,110 @@ func FilterMissingEmbeddings(ctx context.Context, pool *pgxpool.Pool, schema str
 	}
 	return out, rows.Err()
 }
+
+// DeleteEntity removes every piece of searchkit state for an entity of ctx's
+// tenant (see WithTenant), across all languages and models, in one
+// transaction: lexical documents, embeddings
+// (including exact vectors), asset captions, pending tasks, dead letters and
+// dirty rows.
+func DeleteEntity(ctx context.Context, pool Querier, schema string, entityType string, entityID string) error {
+	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" {
+		return fmt.Errorf("entityType and entityID are required")
+	}
+	return DeleteEntities(ctx, pool, schema, entityType, []string{entityID})
+}
+
+// DeleteEntities is DeleteEntity for several entities of one type, in one
+// transaction.
+func DeleteEntities(ctx context.Context, pool Querier, schema string, entityType string, entityIDs []string) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(entityType) == "" {
+		return fmt.Errorf("entityType is required")
+	}
+	if len(entityIDs) == 0 {
+		return nil
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	for _, table := range []string{
+		searchDocumentsTable,
+		embeddingVectorsTable,
+		embeddingVectorsExactTable,
+		embeddingVectorsVLTable,
+		embeddingVectorAssetsTable,
+		assetCaptionsTable,
+		"embedding_tasks",
+		"embedding_dead_letters",
+		"search_dirty",
+	} {
+		q := fmt.Sprintf(`
+			DELETE FROM %s.%s
+			WHERE tenant_id = $3 AND entity_type = $1 AND entity_id = ANY($2::text[])
+		`, qs, table)
+		if _, err := tx.Exec(ctx, q, entityType, entityIDs, TenantFromContext(ctx)); err != nil {
+			return fmt.Errorf("delete from %s: %w", table, err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// ListStoredEntityIDs returns up to limit distinct IDs of entityType, ordered
+// and greater than afterID, that have a lexical document or an embedding (text
+// or VL) stored in ctx's tenant (see WithTenant), in any language or model. Page through with the last
+// returned ID as afterID; a short page is the last one.
+func ListStoredEntityIDs(ctx context.Context, pool Querier, schema string, entityType string, afterID string, limit int) ([]string, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(entityType) == "" {
+		return nil, fmt.Errorf("entityType is required")
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be > 0")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	// Each branch reads its table's primary key (tenant_id, entity_type,
+	// entity_id, ...) in order from afterID and stops after limit distinct IDs.
+	branch := func(table string) string {
+		return fmt.Sprintf(`(
+			SELECT DISTINCT entity_id FROM %s.%s
+			WHERE tenant_id = $4 AND entity_type = $1 AND entity_id > $2
+			ORDER BY entity_id
+			LIMIT $3
+		)`, qs, table)
+	}
+	q := fmt.Sprintf(`
+		SELECT entity_id FROM (
+			%s UNION %s UNION %s
+		) ids
+		ORDER BY entity_id
+		LIMIT $3
+	`, branch(searchDocumentsTable), branch(embeddingVectorsTable), branch(embeddingVectorsVLTable))
+	rows, err := pool.Query(ctx, q, entityType, afterID, limit, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
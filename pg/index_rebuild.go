@@ -0,0 +1,147 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// HNSWParams are the HNSW build parameters of a model index. Zero values use
+// pgvector's defaults (m = 16, ef_construction = 64).
+type HNSWParams struct {
+	M              int
+	EFConstruction int
+}
+
+// Validate checks the parameters against pgvector's limits.
+func (p HNSWParams) Validate() error {
+	if p.M != 0 && (p.M < 2 || p.M > 100) {
+		return fmt.Errorf("m must be between 2 and 100")
+	}
+	if p.EFConstruction != 0 && (p.EFConstruction < 4 || p.EFConstruction > 1000) {
+		return fmt.Errorf("ef_construction must be between 4 and 1000")
+	}
+	m, ef := p.M, p.EFConstruction
+	if m == 0 {
+		m = 16
+	}
+	if ef == 0 {
+		ef = 64
+	}
+	if ef < 2*m {
+		return fmt.Errorf("ef_construction must be >= 2 * m")
+	}
+	return nil
+}
+
+func (p HNSWParams) withClause() string {
+	var opts []string
+	if p.M > 0 {
+		opts = append(opts, fmt.Sprintf("m = %d", p.M))
+	}
+	if p.EFConstruction > 0 {
+		opts = append(opts, fmt.Sprintf("ef_construction = %d", p.EFConstruction))
+	}
+	if len(opts) == 0 {
+		return ""
+	}
+	return " WITH (" + strings.Join(opts, ", ") + ")"
+}
+
+// Suffixes of the transient index names used by RebuildModelIndexes. The
+// longest model index name plus either still fits Postgres' 63-byte limit.
+const (
+	rebuildIndexSuffix = "_rebuild"
+	retiredIndexSuffix = "_old"
+)
+
+// RebuildModelIndexes replaces the HNSW indexes of one model (the ones
+// EnsureIndexesForModels creates for the spec, including the asset index of
+// VL models and the sparse index of hybrid models) without blocking reads or writes: for each index it builds a
+// replacement with CREATE INDEX CONCURRENTLY and params, swaps the names in a
+// short transaction, then drops the old index concurrently. Use it to change
+// HNSW parameters or to rebuild a degraded graph after a bulk load. Missing
+// indexes are simply created with params.
+//
+// The replacement keeps the index name, so EnsureIndexesForModels leaves it
+// (and its params) alone afterwards. Leftovers of an interrupted rebuild (an
+// unswapped or invalid replacement, an undropped old index) are dropped on the
+// next call, so it is safe to retry. Each build takes as long as a fresh index
+// build; raise maintenance_work_mem on the session for large tables.
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func RebuildModelIndexes(ctx context.Context, pool Querier, schema string, spec ModelSpec, params HNSWParams) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	model := strings.TrimSpace(spec.Name)
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	dims := spec.IndexDims()
+	if dims <= 0 {
+		return fmt.Errorf("dims must be > 0")
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+
+	mode := spec.Storage
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+	var indexes []modelIndex
+	if spec.Modality == "vl" {
+		indexes = append(vlModelIndexes(model, dims, mode.OrDefault()), assetModelIndex(model, dims))
+	} else {
+		indexes = textModelIndexes(model, dims, mode.OrDefault())
+		if spec.SparseDims > 0 {
+			indexes = append(indexes, hybridSparseIndex(model, spec.SparseDims))
+		}
+	}
+
+	for _, idx := range indexes {
+		if err := rebuildIndex(ctx, pool, qs, idx, params); err != nil {
+			return fmt.Errorf("rebuild %s: %w", idx.name, err)
+		}
+	}
+	return nil
+}
+
+func rebuildIndex(ctx context.Context, pool Querier, qs string, idx modelIndex, params HNSWParams) error {
+	tmp := idx.name + rebuildIndexSuffix
+	old := idx.name + retiredIndexSuffix
+
+	// Leftovers of an interrupted run; a replacement that was built but not
+	// swapped in is rebuilt too, as its params may differ.
+	for _, name := range []string{tmp, old} {
+		if _, err := pool.Exec(ctx, fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s.%s`, qs, name)); err != nil {
+			return err
+		}
+	}
+	if _, err := pool.Exec(ctx, idx.createSQL(qs, tmp, params)); err != nil {
+		return err
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER INDEX IF EXISTS %s.%s RENAME TO %s`, qs, idx.name, old)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER INDEX %s.%s RENAME TO %s`, qs, tmp, idx.name)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	_, err = pool.Exec(ctx, fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s.%s`, qs, old))
+	return err
+}
@@ -0,0 +1,141 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maintainedTables are the searchkit tables MaintenanceStats reports and
+// AnalyzeStale analyzes.
+var maintainedTables = []string{
+	searchDocumentsTable,
+	"search_dirty",
+	"embedding_tasks",
+	embeddingVectorsTable,
+	embeddingVectorsExactTable,
+	embeddingVectorsVLTable,
+	embeddingVectorAssetsTable,
+	assetCaptionsTable,
+	embeddingCacheTable,
+	"embedding_dead_letters",
+}
+
+// TableStats are the planner and vacuum statistics of one searchkit table
+// (from pg_stat_user_tables; row counts are the statistics collector's
+// estimates).
+type TableStats struct {
+	Table string
+
+	LiveRows int64
+	DeadRows int64
+	// ModifiedSinceAnalyze counts rows inserted, updated or deleted since the
+	// table was last analyzed.
+	ModifiedSinceAnalyze int64
+	// TotalBytes includes indexes and TOAST.
+	TotalBytes int64
+
+	// LastVacuum and LastAnalyze are the latest manual or automatic run (zero
+	// if never).
+	LastVacuum  time.Time
+	LastAnalyze time.Time
+}
+
+// DeadRatio estimates bloat as the share of dead rows (0 for empty tables).
+func (s TableStats) DeadRatio() float64 {
+	total := s.LiveRows + s.DeadRows
+	if total == 0 {
+		return 0
+	}
+	return float64(s.DeadRows) / float64(total)
+}
+
+// MaintenanceStats returns the statistics of the searchkit tables in schema,
+// so hosts can watch bloat of the churn-heavy queue tables (embedding_tasks,
+// search_dirty) and stale planner statistics after bulk loads. Tables not
+// created yet are omitted.
+func MaintenanceStats(ctx context.Context, pool Querier, schema string) ([]TableStats, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if _, err := quoteIdent(schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT relname, n_live_tup, n_dead_tup, n_mod_since_analyze, pg_total_relation_size(relid),
+			GREATEST(last_vacuum, last_autovacuum), GREATEST(last_analyze, last_autoanalyze)
+		FROM pg_stat_user_tables
+		WHERE schemaname = $1 AND relname = ANY($2)
+		ORDER BY relname
+	`, strings.TrimSpace(schema), maintainedTables)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TableStats
+	for rows.Next() {
+		var s TableStats
+		var vacuumed, analyzed *time.Time
+		if err := rows.Scan(&s.Table, &s.LiveRows, &s.DeadRows, &s.ModifiedSinceAnalyze, &s.TotalBytes, &vacuumed, &analyzed); err != nil {
+			return nil, err
+		}
+		if vacuumed != nil {
+			s.LastVacuum = *vacuumed
+		}
+		if analyzed != nil {
+			s.LastAnalyze = *analyzed
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// AnalyzeOptions configures AnalyzeStale. A table is analyzed once
+// ModifiedSinceAnalyze reaches both MinModifiedRows and ModifiedFraction of
+// its live rows.
+type AnalyzeOptions struct {
+	// MinModifiedRows defaults to 10000.
+	MinModifiedRows int64
+	// ModifiedFraction defaults to 0.1.
+	ModifiedFraction float64
+}
+
+func (o AnalyzeOptions) withDefaults() AnalyzeOptions {
+	if o.MinModifiedRows <= 0 {
+		o.MinModifiedRows = 10000
+	}
+	if o.ModifiedFraction <= 0 {
+		o.ModifiedFraction = 0.1
+	}
+	return o
+}
+
+// AnalyzeStale runs ANALYZE on the searchkit tables modified enough since
+// their last analyze (see AnalyzeOptions) and returns their names. Autovacuum
+// analyzes them eventually, but after a bulk load (backfill, import) the
+// planner can meanwhile estimate per-model vector counts badly enough to skip
+// the HNSW index or pick a pathological KNN plan. It is cheap to call when
+// nothing is stale.
+func AnalyzeStale(ctx context.Context, pool Querier, schema string, opts AnalyzeOptions) ([]string, error) {
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	stats, err := MaintenanceStats(ctx, pool, schema)
+	if err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+	var analyzed []string
+	for _, s := range stats {
+		if s.ModifiedSinceAnalyze < opts.MinModifiedRows || float64(s.ModifiedSinceAnalyze) < opts.ModifiedFraction*float64(s.LiveRows) {
+			continue
+		}
+		if _, err := pool.Exec(ctx, fmt.Sprintf(`ANALYZE %s.%s`, qs, s.Table)); err != nil {
+			return analyzed, fmt.Errorf("analyze %s: %w", s.Table, err)
+		}
+		analyzed = append(analyzed, s.Table)
+	}
+	return analyzed, nil
+}
@@ -0,0 +1,126 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	indexModelRe = regexp.MustCompile(`model = '((?:[^']|'')*)'::text`)
+	indexDimsRe  = regexp.MustCompile(`::(?:halfvec|vector|bit|sparsevec)\((\d+)\)`)
+)
+
+// parseModelIndexDef extracts the model and vector dimensions from a per-model
+// HNSW index definition (as returned by pg_indexes.indexdef).
+func parseModelIndexDef(def string) (model string, dims int, ok bool) {
+	m := indexModelRe.FindStringSubmatch(def)
+	d := indexDimsRe.FindStringSubmatch(def)
+	if m == nil || d == nil {
+		return "", 0, false
+	}
+	dims, err := strconv.Atoi(d[1])
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.ReplaceAll(m[1], "''", "'"), dims, true
+}
+
+// CheckModels compares model specs against the registered embedding_models rows
+// and the existing per-model HNSW indexes. It reports (joined) errors for models
+// whose dimensions, modality or normalization differ from what is stored, which
+// otherwise only surface as pgvector dimension errors (or silently skewed
+// similarities) at query time. Unregistered models are
+// not an error.
+//
+// Run it before UpsertModels, which overwrites the registered dims.
+func CheckModels(ctx context.Context, pool Querier, schema string, models []ModelSpec) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	type registered struct {
+		dims          int
+		modality      string
+		normalization string
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`SELECT model, dims, modality, normalization FROM %s.embedding_models`, qs))
+	if err != nil {
+		return err
+	}
+	stored := map[string]registered{}
+	for rows.Next() {
+		var name string
+		var r registered
+		if err := rows.Scan(&name, &r.dims, &r.modality, &r.normalization); err != nil {
+			rows.Close()
+			return err
+		}
+		stored[name] = r
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = pool.Query(ctx, `
+		SELECT indexname, indexdef
+		FROM pg_indexes
+		WHERE schemaname = $1
+		  AND tablename IN ('embedding_vectors', 'embedding_vectors_vl')
+		  AND indexname LIKE 'idx_embedding_vectors_%hnsw_%'
+	`, strings.TrimSpace(schema))
+	if err != nil {
+		return err
+	}
+	type modelIndex struct {
+		name string
+		def  string
+		dims int
+	}
+	indexes := map[string][]modelIndex{}
+	for rows.Next() {
+		var name, def string
+		if err := rows.Scan(&name, &def); err != nil {
+			rows.Close()
+			return err
+		}
+		if model, dims, ok := parseModelIndexDef(def); ok {
+			indexes[model] = append(indexes[model], modelIndex{name: name, def: def, dims: dims})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, m := range models {
+		name := strings.TrimSpace(m.Name)
+		want := m.IndexDims()
+		if r, ok := stored[name]; ok {
+			if r.dims != want {
+				errs = append(errs, fmt.Errorf("model %q: embedder produces %d dims but %d-dim vectors are registered; use a new model name for the new dimensions, or delete the model's vectors and indexes before re-embedding", name, want, r.dims))
+			}
+			if m.Modality != "" && r.modality != m.Modality {
+				errs = append(errs, fmt.Errorf("model %q: configured as %s but registered as %s; use a new model name or delete the model's vectors", name, m.Modality, r.modality))
+			}
+			if n := m.Normalization.OrDefault(); r.normalization != string(n) {
+				errs = append(errs, fmt.Errorf("model %q: configured with %s normalization but registered with %s; use a new model name so queries never mix both policies", name, n, r.normalization))
+			}
+		}
+		for _, idx := range indexes[name] {
+			if want := indexDimsFor(m, idx.def); idx.dims != want {
+				errs = append(errs, fmt.Errorf("model %q: index %s is built for %d dims, expected %d; drop it (DROP INDEX CONCURRENTLY %s.%s) once the model's old vectors are gone", name, idx.name, idx.dims, want, qs, idx.name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
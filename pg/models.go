@@ -6,14 +6,53 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
-
-	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type ModelSpec struct {
 	Name     string // stored in embedding_models.model
 	Dims     int    // fixed dims for the model
-	Modality string // "text" | "vl"
+	Modality string // "text" | "vl" | "sparse"
+
+	// StoredDims stores a Matryoshka (MRL) prefix of the model output instead
+	// of the full vector (e.g. 1024 of 4096). 0 stores all Dims.
+	StoredDims int
+
+	// Storage selects the vector representation (defaults to StorageHalfvec).
+	Storage StorageMode
+
+	// Aliases are alternate names that resolve to this model (e.g. a previous
+	// config name or the provider's model id).
+	Aliases []string
+
+	// Shadow models are embedded and stored but excluded from search unless
+	// explicitly requested (see search.Query.IncludeShadow).
+	Shadow bool
+
+	// LanguageAgnostic (multilingual) models store one vector per entity under
+	// AnyLanguage, and search ignores the query language for them.
+	LanguageAgnostic bool
+
+	// Normalization is how vectors are post-processed (defaults to
+	// NormalizeL2).
+	Normalization Normalization
+
+	// SparseDims > 0 makes a text model hybrid: each entity also gets a learned
+	// sparse vector of this vocabulary size, stored next to its dense chunk 0
+	// (see UpsertHybridEmbedding and search.HybridSearch).
+	SparseDims int
+}
+
+// AnyLanguage is the language under which language-agnostic models store
+// their vectors, tasks and backfill state.
+const AnyLanguage = "*"
+
+// IndexDims returns the dimensions of stored vectors (and their indexes).
+func (m ModelSpec) IndexDims() int {
+	if m.StoredDims > 0 {
+		return m.StoredDims
+	}
+	return m.Dims
 }
 
 func quoteIdent(ident string) (string, error) {
@@ -39,8 +78,38 @@ func indexSuffix(model string, dims int) string {
 	return hex.EncodeToString(h[:8])
 }
 
-// UpsertModels syncs the configured model specs into `<schema>.embedding_models`.
-func UpsertModels(ctx context.Context, pool *pgxpool.Pool, schema string, models []ModelSpec) error {
+// PruneMode controls what UpsertModels does with registered models that are no
+// longer configured.
+type PruneMode string
+
+const (
+	// PruneMarkInactive (the default) sets embedding_models.inactive_since and
+	// keeps the model's tasks, backfill state and dead letters.
+	PruneMarkInactive PruneMode = "mark-inactive"
+	// PruneOff leaves removed models untouched.
+	PruneOff PruneMode = "off"
+	// PruneDelete deletes removed models' registry rows, tasks, backfill state
+	// and dead letters.
+	PruneDelete PruneMode = "delete"
+)
+
+// Validate returns an error for unknown prune modes ("" is the default).
+func (m PruneMode) Validate() error {
+	switch m {
+	case "", PruneMarkInactive, PruneOff, PruneDelete:
+		return nil
+	}
+	return fmt.Errorf("unknown prune mode %q", m)
+}
+
+// UpsertModels syncs the configured model specs into `<schema>.embedding_models`
+// and marks models missing from specs inactive (PruneMarkInactive).
+func UpsertModels(ctx context.Context, pool Querier, schema string, models []ModelSpec) error {
+	return UpsertModelsWithPrune(ctx, pool, schema, models, PruneMarkInactive)
+}
+
+// UpsertModelsWithPrune is UpsertModels with an explicit PruneMode.
+func UpsertModelsWithPrune(ctx context.Context, pool Querier, schema string, models []ModelSpec, prune PruneMode) error {
 	if pool == nil {
 		return fmt.Errorf("pool is required")
 	}
@@ -48,10 +117,33 @@ func UpsertModels(ctx context.Context, pool *pgxpool.Pool, schema string, models
 	if err != nil {
 		return fmt.Errorf("invalid schema: %w", err)
 	}
+	if err := prune.Validate(); err != nil {
+		return err
+	}
 
 	// Treat `models` as the active configured set. We upsert everything provided,
-	// then prune any rows (and related state) for models that are no longer active.
+	// then prune (per prune) any rows for models that are no longer active.
 	var active []string
+	names := make(map[string]struct{}, len(models))
+	for _, m := range models {
+		names[strings.TrimSpace(m.Name)] = struct{}{}
+	}
+	aliasOf := make(map[string]string)
+	for _, m := range models {
+		for _, a := range m.Aliases {
+			a = strings.TrimSpace(a)
+			if a == "" {
+				return fmt.Errorf("model %q has an empty alias", m.Name)
+			}
+			if _, ok := names[a]; ok {
+				return fmt.Errorf("alias %q of model %q is also a model name", a, m.Name)
+			}
+			if prev, ok := aliasOf[a]; ok && prev != m.Name {
+				return fmt.Errorf("alias %q is used by models %q and %q", a, prev, m.Name)
+			}
+			aliasOf[a] = m.Name
+		}
+	}
 	for _, m := range models {
 		name := strings.TrimSpace(m.Name)
 		if name == "" {
@@ -60,64 +152,130 @@ func UpsertModels(ctx context.Context, pool *pgxpool.Pool, schema string, models
 		if m.Dims <= 0 {
 			return fmt.Errorf("model %q dims must be > 0", name)
 		}
+		if m.StoredDims < 0 || m.StoredDims > m.Dims {
+			return fmt.Errorf("model %q stored dims must be in [1..%d]", name, m.Dims)
+		}
 		modality := strings.TrimSpace(m.Modality)
 		if modality == "" {
 			return fmt.Errorf("model %q modality is required", name)
 		}
+		if err := m.Storage.Validate(); err != nil {
+			return fmt.Errorf("model %q: %w", name, err)
+		}
+		if err := m.Normalization.Validate(); err != nil {
+			return fmt.Errorf("model %q: %w", name, err)
+		}
+		if m.SparseDims < 0 {
+			return fmt.Errorf("model %q sparse dims must be >= 0", name)
+		}
+		if m.SparseDims > 0 && (modality != "text" || m.Storage.OrDefault() == StorageSparse) {
+			return fmt.Errorf("model %q: only dense text models can have sparse dims", name)
+		}
+
+		aliases := make([]string, 0, len(m.Aliases))
+		for _, a := range m.Aliases {
+			aliases = append(aliases, strings.TrimSpace(a))
+		}
 
 		q := fmt.Sprintf(`
-			INSERT INTO %s.embedding_models (model, dims, modality, created_at, updated_at)
-			VALUES ($1, $2, $3, now(), now())
+			INSERT INTO %s.embedding_models (model, dims, modality, storage, aliases, shadow, language_agnostic, normalization, sparse_dims, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now())
 			ON CONFLICT (model) DO UPDATE SET
 				dims = EXCLUDED.dims,
 				modality = EXCLUDED.modality,
+				storage = EXCLUDED.storage,
+				aliases = EXCLUDED.aliases,
+				shadow = EXCLUDED.shadow,
+				language_agnostic = EXCLUDED.language_agnostic,
+				normalization = EXCLUDED.normalization,
+				sparse_dims = EXCLUDED.sparse_dims,
+				inactive_since = NULL,
 				updated_at = now()
 		`, qs)
-		if _, err := pool.Exec(ctx, q, name, m.Dims, modality); err != nil {
+		if _, err := pool.Exec(ctx, q, name, m.IndexDims(), modality, string(m.Storage.OrDefault()), aliases, m.Shadow, m.LanguageAgnostic, string(m.Normalization.OrDefault()), m.SparseDims); err != nil {
 			return err
 		}
 
 		active = append(active, name)
 	}
 
-	// Prune removed models (and their auxiliary state) so `embedding_models`
-	// represents only active configured models.
-	//
-	// NOTE: We intentionally do NOT delete from embedding_vectors here; that data
-	// can be large and is not required for correctness (search won’t use removed
-	// models if the host config no longer references them).
-	qPruneModels := fmt.Sprintf(`
-		DELETE FROM %s.embedding_models
-		WHERE NOT (model = ANY($1::text[]))
-	`, qs)
-	if _, err := pool.Exec(ctx, qPruneModels, active); err != nil {
+	switch prune {
+	case PruneOff:
+		return nil
+	case PruneDelete:
+		return deleteModelsExcept(ctx, pool, qs, active)
+	default:
+		// Mark removed models inactive; their tasks, backfill state and dead
+		// letters are kept until PruneInactiveModels (or re-activation).
+		q := fmt.Sprintf(`
+			UPDATE %s.embedding_models
+			SET inactive_since = now(), updated_at = now()
+			WHERE NOT (model = ANY($1::text[])) AND inactive_since IS NULL
+		`, qs)
+		_, err := pool.Exec(ctx, q, active)
 		return err
 	}
+}
 
-	qPruneTasks := fmt.Sprintf(`
-		DELETE FROM %s.embedding_tasks
-		WHERE NOT (model = ANY($1::text[]))
-	`, qs)
-	if _, err := pool.Exec(ctx, qPruneTasks, active); err != nil {
-		return err
+// PruneInactiveModels deletes models marked inactive before olderThan, along
+// with their tasks, backfill state and dead letters, and returns the pruned
+// model names. Stored vectors and indexes are kept (see agents/NOTES.md).
+func PruneInactiveModels(ctx context.Context, pool Querier, schema string, olderThan time.Time) ([]string, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
 	}
-
-	qPruneBackfill := fmt.Sprintf(`
-		DELETE FROM %s.embedding_vectors_backfill_state
-		WHERE NOT (model = ANY($1::text[]))
-	`, qs)
-	if _, err := pool.Exec(ctx, qPruneBackfill, active); err != nil {
-		return err
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
 	}
-
-	qPruneDLQ := fmt.Sprintf(`
-		DELETE FROM %s.embedding_dead_letters
-		WHERE NOT (model = ANY($1::text[]))
-	`, qs)
-	if _, err := pool.Exec(ctx, qPruneDLQ, active); err != nil {
-		return err
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT model FROM %s.embedding_models
+		WHERE inactive_since IS NOT NULL AND inactive_since < $1
+	`, qs), olderThan)
+	if err != nil {
+		return nil, err
+	}
+	var pruned []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		pruned = append(pruned, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(pruned) == 0 {
+		return nil, nil
 	}
+	for _, table := range []string{"embedding_models", "embedding_tasks", "embedding_vectors_backfill_state", "embedding_dead_letters"} {
+		q := fmt.Sprintf(`DELETE FROM %s.%s WHERE model = ANY($1::text[])`, qs, table)
+		if _, err := pool.Exec(ctx, q, pruned); err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
 
+// deleteModelsExcept deletes registry rows and auxiliary state for every model
+// not in active.
+//
+// NOTE: We intentionally do NOT delete from embedding_vectors here; that data
+// can be large and is not required for correctness (search won’t use removed
+// models if the host config no longer references them).
+func deleteModelsExcept(ctx context.Context, pool Querier, qs string, active []string) error {
+	for _, table := range []string{"embedding_models", "embedding_tasks", "embedding_vectors_backfill_state", "embedding_dead_letters"} {
+		q := fmt.Sprintf(`
+			DELETE FROM %s.%s
+			WHERE NOT (model = ANY($1::text[]))
+		`, qs, table)
+		if _, err := pool.Exec(ctx, q, active); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -126,7 +284,16 @@ func UpsertModels(ctx context.Context, pool *pgxpool.Pool, schema string, models
 //   - binary quantize + Hamming distance (2-stage stage-1)
 //
 // This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
-func EnsureModelIndexes(ctx context.Context, pool *pgxpool.Pool, schema string, model string, dims int) error {
+func EnsureModelIndexes(ctx context.Context, pool Querier, schema string, model string, dims int) error {
+	return EnsureModelIndexesWithStorage(ctx, pool, schema, model, dims, StorageHalfvec)
+}
+
+// EnsureModelIndexesWithStorage is EnsureModelIndexes for a specific storage
+// mode. StorageBit models only get the Hamming index (on embedding_bits) and
+// StorageSparse models an inner-product index (on embedding_sparse).
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func EnsureModelIndexesWithStorage(ctx context.Context, pool Querier, schema string, model string, dims int, mode StorageMode) error {
 	if pool == nil {
 		return fmt.Errorf("pool is required")
 	}
@@ -141,48 +308,107 @@ func EnsureModelIndexes(ctx context.Context, pool *pgxpool.Pool, schema string,
 	if dims <= 0 {
 		return fmt.Errorf("dims must be > 0")
 	}
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+	mode = mode.OrDefault()
+
+	for _, idx := range textModelIndexes(model, dims, mode) {
+		if _, err := pool.Exec(ctx, idx.createSQL(qs, idx.name, HNSWParams{})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// NOTE: We intentionally cast embedding to halfvec(dims) inside the index
-	// expression so each model index has fixed dimensions.
-	half := fmt.Sprintf("halfvec(%d)", dims)
-	pred := "model = " + quoteLiteral(model) + " AND embedding IS NOT NULL"
+// modelIndex is one per-model partial HNSW index.
+type modelIndex struct {
+	name  string
+	table string
+	// using is the index expression and operator class.
+	using string
+	where string
+}
 
+// createSQL returns the CREATE INDEX CONCURRENTLY IF NOT EXISTS statement for
+// idx under name.
+func (idx modelIndex) createSQL(qs, name string, params HNSWParams) string {
+	return fmt.Sprintf(`
+		CREATE INDEX CONCURRENTLY IF NOT EXISTS %s
+		ON %s.%s
+		USING hnsw (%s)%s
+		WHERE %s
+	`, name, qs, idx.table, idx.using, params.withClause(), idx.where)
+}
+
+// textModelIndexes lists the indexes EnsureModelIndexesWithStorage creates for
+// a text model in the given (validated, defaulted) storage mode.
+func textModelIndexes(model string, dims int, mode StorageMode) []modelIndex {
+	// Halfvec index names predate storage modes and stay unchanged so existing
+	// indexes are reused.
 	suffix := indexSuffix(model, dims)
+	if mode != StorageHalfvec {
+		suffix = indexSuffix(model+"/"+string(mode), dims)
+	}
 	cosIdx := fmt.Sprintf("idx_embedding_vectors_hnsw_cosine__%s", suffix)
 	binIdx := fmt.Sprintf("idx_embedding_vectors_hnsw_binary__%s", suffix)
 
-	// 1) Cosine HNSW (expression index).
-	q1 := fmt.Sprintf(`
-		CREATE INDEX CONCURRENTLY IF NOT EXISTS %s
-		ON %s.embedding_vectors
-		USING hnsw ((embedding::%s) halfvec_cosine_ops)
-		WHERE %s
-	`, cosIdx, qs, half, pred)
-	if _, err := pool.Exec(ctx, q1); err != nil {
-		return err
+	switch mode {
+	case StorageSparse:
+		// Inner product is the scoring function of learned sparse models.
+		return []modelIndex{{
+			name:  fmt.Sprintf("idx_embedding_vectors_hnsw_sparse__%s", suffix),
+			table: embeddingVectorsTable,
+			using: fmt.Sprintf("(embedding_sparse::sparsevec(%d)) sparsevec_ip_ops", dims),
+			where: "model = " + quoteLiteral(model) + " AND embedding_sparse IS NOT NULL",
+		}}
+	case StorageBit:
+		return []modelIndex{{
+			name:  binIdx,
+			table: embeddingVectorsTable,
+			using: fmt.Sprintf("(embedding_bits::bit(%d)) bit_hamming_ops", dims),
+			where: "model = " + quoteLiteral(model) + " AND embedding_bits IS NOT NULL",
+		}}
 	}
 
-	// 2) Binary HNSW for two-stage retrieval (expression index).
-	// binary_quantize(halfvec) -> bit(dims); <~> is Hamming distance.
-	q2 := fmt.Sprintf(`
-		CREATE INDEX CONCURRENTLY IF NOT EXISTS %s
-		ON %s.embedding_vectors
-		USING hnsw ((binary_quantize(embedding::%s)::bit(%d)) bit_hamming_ops)
-		WHERE %s
-	`, binIdx, qs, half, dims, pred)
-	if _, err := pool.Exec(ctx, q2); err != nil {
-		return err
+	// NOTE: We intentionally cast the column to halfvec(dims)/vector(dims) inside
+	// the index expression so each model index has fixed dimensions.
+	col, typ, ops := "embedding", fmt.Sprintf("halfvec(%d)", dims), "halfvec_cosine_ops"
+	if mode == StorageVector {
+		col, typ, ops = "embedding_vec", fmt.Sprintf("vector(%d)", dims), "vector_cosine_ops"
+	}
+	pred := "model = " + quoteLiteral(model) + " AND " + col + " IS NOT NULL"
+	return []modelIndex{
+		// 1) Cosine HNSW (expression index).
+		{name: cosIdx, table: embeddingVectorsTable, using: fmt.Sprintf("(%s::%s) %s", col, typ, ops), where: pred},
+		// 2) Binary HNSW for two-stage retrieval (expression index).
+		// binary_quantize(halfvec|vector) -> bit(dims); <~> is Hamming distance.
+		{name: binIdx, table: embeddingVectorsTable, using: fmt.Sprintf("(binary_quantize(%s::%s)::bit(%d)) bit_hamming_ops", col, typ, dims), where: pred},
 	}
-
-	return nil
 }
 
-// EnsureIndexesForModels ensures per-model cosine+binary indexes for every model spec.
-func EnsureIndexesForModels(ctx context.Context, pool *pgxpool.Pool, schema string, models []ModelSpec) error {
+// EnsureIndexesForModels ensures per-model cosine+binary indexes for every model spec
+// (at the spec's IndexDims); VL models get theirs on embedding_vectors_vl, plus
+// the asset index, and hybrid models their sparse index.
+func EnsureIndexesForModels(ctx context.Context, pool Querier, schema string, models []ModelSpec) error {
 	for _, m := range models {
-		if err := EnsureModelIndexes(ctx, pool, schema, m.Name, m.Dims); err != nil {
+		if m.Modality == "vl" {
+			if err := EnsureVLIndexesWithStorage(ctx, pool, schema, m.Name, m.IndexDims(), m.Storage); err != nil {
+				return err
+			}
+			if err := EnsureAssetIndexes(ctx, pool, schema, m.Name, m.IndexDims()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := EnsureModelIndexesWithStorage(ctx, pool, schema, m.Name, m.IndexDims(), m.Storage); err != nil {
 			return err
 		}
+		if m.SparseDims > 0 {
+			if err := EnsureHybridSparseIndex(ctx, pool, schema, m.Name, m.SparseDims); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
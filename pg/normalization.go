@@ -0,0 +1,34 @@
+package pg
+
+import "fmt"
+
+// Normalization selects how searchkit post-processes a model's vectors before
+// storage and at query time.
+type Normalization string
+
+const (
+	// NormalizeL2 scales vectors to unit length (default).
+	NormalizeL2 Normalization = "l2"
+	// NormalizeNone stores vectors as the embedder returns them (after
+	// Matryoshka truncation), for models that expect raw vectors or providers
+	// that already return normalized output.
+	NormalizeNone Normalization = "none"
+)
+
+// OrDefault returns NormalizeL2 for the zero value.
+func (n Normalization) OrDefault() Normalization {
+	if n == "" {
+		return NormalizeL2
+	}
+	return n
+}
+
+// Validate reports whether n is a known normalization (the zero value is valid).
+func (n Normalization) Validate() error {
+	switch n.OrDefault() {
+	case NormalizeL2, NormalizeNone:
+		return nil
+	default:
+		return fmt.Errorf("invalid normalization %q", n)
+	}
+}
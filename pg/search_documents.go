@@ -6,7 +6,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/open-rails/searchkit/internal/textnormalize"
 )
 
@@ -15,8 +14,8 @@ const searchDocumentsTable = "search_documents"
 // UpsertSearchDocuments upserts lexical (trigram) documents for one (entity_type, language).
 //
 // Documents are heavy-normalized by searchkit before storage so host apps can pass
-// "raw-ish" display strings.
-func UpsertSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema string, entityType string, language string, docs map[string]string) error {
+// "raw-ish" display strings. Rows are tagged with ctx's tenant (see WithTenant).
+func UpsertSearchDocuments(ctx context.Context, pool Querier, schema string, entityType string, language string, docs map[string]string) error {
 	if pool == nil {
 		return fmt.Errorf("pool is required")
 	}
@@ -71,6 +70,7 @@ func UpsertSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema strin
 		rawArr = append(rawArr, rawTrim)
 	}
 
+	tenant := TenantFromContext(ctx)
 	if len(idArr) > 0 {
 		q := fmt.Sprintf(`
 			WITH rows AS (
@@ -79,7 +79,7 @@ func UpsertSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema strin
 					unnest($4::text[]) AS raw_document,
 					unnest($5::text[]) AS document
 			)
-			INSERT INTO %s.%s (entity_type, entity_id, language, raw_document, document, tsv, created_at, updated_at)
+			INSERT INTO %s.%s (entity_type, entity_id, language, raw_document, document, tsv, tenant_id, created_at, updated_at)
 			SELECT
 				$1,
 				rows.entity_id,
@@ -87,16 +87,18 @@ func UpsertSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema strin
 				rows.raw_document,
 				rows.document,
 				to_tsvector(%s.searchkit_regconfig_for_language($2), rows.raw_document),
+				$6,
 				now(),
 				now()
 			FROM rows
-			ON CONFLICT (entity_type, entity_id, language) DO UPDATE SET
+			ON CONFLICT (tenant_id, entity_type, entity_id, language) DO UPDATE SET
 				raw_document = EXCLUDED.raw_document,
 				document = EXCLUDED.document,
 				tsv = EXCLUDED.tsv,
+				deleted_at = NULL,
 				updated_at = now()
 		`, qs, searchDocumentsTable, qs)
-		if _, err := pool.Exec(ctx, q, entityType, language, idArr, rawArr, docArr); err != nil {
+		if _, err := pool.Exec(ctx, q, entityType, language, idArr, rawArr, docArr, tenant); err != nil {
 			return err
 		}
 	}
@@ -104,9 +106,9 @@ func UpsertSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema strin
 	if len(deleteIDs) > 0 {
 		q := fmt.Sprintf(`
 			DELETE FROM %s.%s
-			WHERE entity_type = $1 AND language = $2 AND entity_id = ANY($3::text[])
+			WHERE tenant_id = $4 AND entity_type = $1 AND language = $2 AND entity_id = ANY($3::text[])
 		`, qs, searchDocumentsTable)
-		if _, err := pool.Exec(ctx, q, entityType, language, deleteIDs); err != nil {
+		if _, err := pool.Exec(ctx, q, entityType, language, deleteIDs, tenant); err != nil {
 			return err
 		}
 	}
@@ -114,11 +116,13 @@ func UpsertSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema strin
 	return nil
 }
 
-func DeleteSearchDocuments(ctx context.Context, pool *pgxpool.Pool, schema string, entityType string, entityID string, language string) error {
+func DeleteSearchDocuments(ctx context.Context, pool Querier, schema string, entityType string, entityID string, language string) error {
 	return DeleteSearchDocumentsMany(ctx, pool, schema, entityType, []string{entityID}, language)
 }
 
-func DeleteSearchDocumentsMany(ctx context.Context, pool *pgxpool.Pool, schema string, entityType string, entityIDs []string, language string) error {
+// DeleteSearchDocumentsMany deletes the lexical documents of entityIDs in
+// language from ctx's tenant (see WithTenant).
+func DeleteSearchDocumentsMany(ctx context.Context, pool Querier, schema string, entityType string, entityIDs []string, language string) error {
 	if pool == nil {
 		return fmt.Errorf("pool is required")
 	}
@@ -140,8 +144,8 @@ func DeleteSearchDocumentsMany(ctx context.Context, pool *pgxpool.Pool, schema s
 	}
 	q := fmt.Sprintf(`
 		DELETE FROM %s.%s
-		WHERE entity_type = $1 AND language = $2 AND entity_id = ANY($3::text[])
+		WHERE tenant_id = $4 AND entity_type = $1 AND language = $2 AND entity_id = ANY($3::text[])
 	`, qs, searchDocumentsTable)
-	_, err = pool.Exec(ctx, q, entityType, language, entityIDs)
+	_, err = pool.Exec(ctx, q, entityType, language, entityIDs, TenantFromContext(ctx))
 	return err
 }
@@ -0,0 +1,95 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SoftDeleteEntity marks an entity+language's lexical document and embeddings
+// (all models) in ctx's tenant (see WithTenant) deleted. Search ignores them until RestoreEntity or
+// PurgeSoftDeleted.
+func SoftDeleteEntity(ctx context.Context, pool Querier, schema string, entityType string, entityID string, language string) error {
+	return setDeletedAt(ctx, pool, schema, entityType, entityID, language, true)
+}
+
+// RestoreEntity clears the soft-delete mark set by SoftDeleteEntity. Restored
+// embeddings keep their content hash, so re-embedding an unchanged document is
+// skipped.
+func RestoreEntity(ctx context.Context, pool Querier, schema string, entityType string, entityID string, language string) error {
+	return setDeletedAt(ctx, pool, schema, entityType, entityID, language, false)
+}
+
+func setDeletedAt(ctx context.Context, pool Querier, schema string, entityType string, entityID string, language string, deleted bool) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(schema) == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" || strings.TrimSpace(language) == "" {
+		return nil
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	set, cond := "deleted_at = now()", "deleted_at IS NULL"
+	if !deleted {
+		set, cond = "deleted_at = NULL", "deleted_at IS NOT NULL"
+	}
+	for _, table := range []string{searchDocumentsTable, embeddingVectorsTable, embeddingVectorsVLTable, embeddingVectorAssetsTable} {
+		q := fmt.Sprintf(`
+			UPDATE %s.%s SET %s
+			WHERE tenant_id = $4 AND entity_type = $1 AND entity_id = $2 AND language = $3 AND %s
+		`, qs, table, set, cond)
+		if _, err := pool.Exec(ctx, q, entityType, entityID, language, TenantFromContext(ctx)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeSoftDeleted hard-deletes lexical documents and embeddings that were
+// soft-deleted before olderThan, and returns the number of rows removed.
+func PurgeSoftDeleted(ctx context.Context, pool Querier, schema string, olderThan time.Time) (int64, error) {
+	if pool == nil {
+		return 0, fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var n int64
+	tag, err := tx.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %[1]s.%[2]s ex
+		USING %[1]s.%[3]s ev
+		WHERE ev.deleted_at < $1
+		  AND ex.tenant_id = ev.tenant_id
+		  AND ex.entity_type = ev.entity_type
+		  AND ex.entity_id = ev.entity_id
+		  AND ex.model = ev.model
+		  AND ex.language = ev.language
+		  AND ex.chunk_idx = ev.chunk_idx
+	`, qs, embeddingVectorsExactTable, embeddingVectorsTable), olderThan)
+	if err != nil {
+		return 0, err
+	}
+	n += tag.RowsAffected()
+	for _, table := range []string{embeddingVectorsTable, embeddingVectorsVLTable, embeddingVectorAssetsTable, searchDocumentsTable} {
+		tag, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s.%s WHERE deleted_at < $1`, qs, table), olderThan)
+		if err != nil {
+			return 0, err
+		}
+		n += tag.RowsAffected()
+	}
+	return n, tx.Commit(ctx)
+}
@@ -0,0 +1,142 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+// MaxSparseNonZero is the most non-zero elements pgvector's HNSW index accepts
+// per sparsevec; stored sparse vectors are pruned to their largest weights.
+const MaxSparseNonZero = 1000
+
+// UpsertSparseEmbedding stores an entity's sparse vector (StorageSparse models)
+// in embedding_vectors.embedding_sparse as chunk 0, clearing dense columns and
+// any leftover chunks. Rows are tagged with ctx's tenant (see WithTenant).
+func (s *PostgresStorage) UpsertSparseEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, vec pgvector.SparseVector, contentHash string) error {
+	if s.schema == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if entityType == "" || model == "" {
+		return fmt.Errorf("entityType and model are required")
+	}
+	if strings.TrimSpace(language) == "" {
+		return fmt.Errorf("language is required")
+	}
+	if strings.TrimSpace(entityID) == "" {
+		return fmt.Errorf("entityID is required")
+	}
+	if vec.Dimensions() <= 0 {
+		return fmt.Errorf("sparse vector dimensions are required")
+	}
+	if n := len(vec.Indices()); n > MaxSparseNonZero {
+		return fmt.Errorf("sparse vector has %d non-zero elements, more than %d", n, MaxSparseNonZero)
+	}
+
+	qUpsert := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, chunk_idx, embedding, embedding_vec, embedding_bits, embedding_sparse, content_hash, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 0, NULL, NULL, NULL, $5::text::sparsevec, NULLIF($6, ''), $7, now(), now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language, chunk_idx) DO UPDATE SET
+			embedding = NULL,
+			embedding_vec = NULL,
+			embedding_bits = NULL,
+			embedding_sparse = EXCLUDED.embedding_sparse,
+			content_hash = EXCLUDED.content_hash,
+			deleted_at = NULL,
+			updated_at = now()
+	`, s.schema, embeddingVectorsTable)
+	qPrune := fmt.Sprintf(`
+		DELETE FROM %s.%s
+		WHERE tenant_id = $5 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4 AND chunk_idx > 0
+	`, s.schema, embeddingVectorsTable)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	tenant := TenantFromContext(ctx)
+	if _, err := tx.Exec(ctx, qUpsert, entityType, entityID, model, language, vec.String(), contentHash, tenant); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, qPrune, entityType, entityID, model, language, tenant); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// hybridSparseIndex is the inner-product index over a hybrid model's sparse
+// vectors (see ModelSpec.SparseDims).
+func hybridSparseIndex(model string, sparseDims int) modelIndex {
+	return modelIndex{
+		name:  "idx_embedding_vectors_hnsw_hsparse__" + indexSuffix(model+"/hybrid", sparseDims),
+		table: embeddingVectorsTable,
+		using: fmt.Sprintf("(embedding_sparse::sparsevec(%d)) sparsevec_ip_ops", sparseDims),
+		where: "model = " + quoteLiteral(model) + " AND embedding_sparse IS NOT NULL",
+	}
+}
+
+// indexDimsFor returns the dims an existing index of m (with definition def)
+// should be built for: the sparse vocabulary size for the sparse indexes of
+// hybrid models, IndexDims otherwise.
+func indexDimsFor(m ModelSpec, def string) int {
+	if m.Storage.OrDefault() != StorageSparse && strings.Contains(def, "::sparsevec(") {
+		return m.SparseDims
+	}
+	return m.IndexDims()
+}
+
+// EnsureHybridSparseIndex creates the sparse inner-product HNSW index of a
+// hybrid model (ModelSpec.SparseDims > 0); EnsureIndexesForModels calls it.
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func EnsureHybridSparseIndex(ctx context.Context, pool Querier, schema string, model string, sparseDims int) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if sparseDims <= 0 {
+		return fmt.Errorf("sparse dims must be > 0")
+	}
+	idx := hybridSparseIndex(model, sparseDims)
+	_, err = pool.Exec(ctx, idx.createSQL(qs, idx.name, HNSWParams{}))
+	return err
+}
+
+// UpsertHybridEmbedding stores an entity's dense chunks like
+// UpsertTextEmbeddingChunks and, in the same transaction, its sparse vector
+// in embedding_sparse of chunk 0 (hybrid models, see ModelSpec.SparseDims), so
+// dense and sparse vectors of one document are never out of step. Dense-only
+// writes (UpsertTextEmbeddingChunks, the bulk path) leave embedding_sparse
+// untouched.
+func (s *PostgresStorage) UpsertHybridEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, chunks [][]float32, sparse pgvector.SparseVector, contentHash string) error {
+	if sparse.Dimensions() <= 0 {
+		return fmt.Errorf("sparse vector dimensions are required")
+	}
+	if n := len(sparse.Indices()); n > MaxSparseNonZero {
+		return fmt.Errorf("sparse vector has %d non-zero elements, more than %d", n, MaxSparseNonZero)
+	}
+	tx, err := s.beginChunkWrite(ctx, entityType, entityID, model, language, chunks, contentHash)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	q := fmt.Sprintf(`
+		UPDATE %s.%s
+		SET embedding_sparse = CASE WHEN chunk_idx = 0 THEN $5::text::sparsevec END
+		WHERE tenant_id = $6 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4
+	`, s.schema, embeddingVectorsTable)
+	if _, err := tx.Exec(ctx, q, entityType, entityID, model, language, sparse.String(), TenantFromContext(ctx)); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -2,34 +2,226 @@ package pg
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
 	pgvector "github.com/pgvector/pgvector-go"
 )
 
-const embeddingVectorsTable = "embedding_vectors"
+const (
+	embeddingVectorsTable      = "embedding_vectors"
+	embeddingVectorsExactTable = "embedding_vectors_exact"
+)
+
+// ErrDimsMismatch is returned (wrapped) when a vector's length differs from
+// its model's registered embedding_models.dims.
+var ErrDimsMismatch = errors.New("pg: embedding dims do not match the registered model")
 
-// PostgresStorage is a reference implementation of runtime.Storage that writes
+// PostgresStorage is the default implementation of runtime.Storage that writes
 // embeddings into searchkit-owned tables in the host application's schema.
 //
 // Tables:
 //   - <schema>.embedding_vectors
+//   - <schema>.embedding_vectors_exact (StorageBit models only)
+//   - <schema>.embedding_vectors_vl (fused VL vectors, see UpsertVLEmbedding)
+//   - <schema>.embedding_vector_assets (per-asset VL vectors)
+//
+// Sparse models share embedding_vectors (see UpsertSparseEmbedding).
 type PostgresStorage struct {
-	pool   *pgxpool.Pool
+	pool   Querier
 	schema string
+
+	storage map[string]StorageMode
+
+	// dims caches embedding_models.dims per registered model for the lifetime
+	// of the storage; re-registering a model with new dims requires a new
+	// storage (a restart).
+	dims sync.Map
 }
 
-func NewPostgresStorage(pool *pgxpool.Pool, schema string) *PostgresStorage {
+func NewPostgresStorage(pool Querier, schema string) *PostgresStorage {
 	return &PostgresStorage{pool: pool, schema: schema}
 }
 
-func (s *PostgresStorage) UpsertTextEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, dim int, embedding []float32) error {
+// SetStorageModes sets the storage mode per model (models not listed use
+// StorageHalfvec). It must be called before the storage is used concurrently.
+func (s *PostgresStorage) SetStorageModes(modes map[string]StorageMode) {
+	s.storage = make(map[string]StorageMode, len(modes))
+	for m, mode := range modes {
+		s.storage[m] = mode.OrDefault()
+	}
+}
+
+func (s *PostgresStorage) storageMode(model string) StorageMode {
+	return s.storage[model].OrDefault()
+}
+
+// checkDims returns ErrDimsMismatch when a chunk's length differs from model's
+// registered dims, since the per-model indexes cast to fixed dims. Models
+// missing from embedding_models are not checked.
+func (s *PostgresStorage) checkDims(ctx context.Context, model string, chunks [][]float32) error {
+	var want int
+	if v, ok := s.dims.Load(model); ok {
+		want = v.(int)
+	} else {
+		err := s.pool.QueryRow(ctx, fmt.Sprintf(`SELECT dims FROM %s.embedding_models WHERE model = $1`, s.schema), model).Scan(&want)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("load dims for model %q: %w", model, err)
+		}
+		s.dims.Store(model, want)
+	}
+	for i, emb := range chunks {
+		if len(emb) != want {
+			return fmt.Errorf("%w: model %q chunk %d has %d dims, registered %d", ErrDimsMismatch, model, i, len(emb), want)
+		}
+	}
+	return nil
+}
+
+// ContentHash returns the hash stored in embedding_vectors.content_hash for a
+// semantic document.
+func ContentHash(document string) string {
+	h := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(h[:])
+}
+
+// EmbeddingKey identifies one stored embedding for a model.
+type EmbeddingKey struct {
+	EntityType string
+	EntityID   string
+	Language   string
+}
+
+// UpsertTextEmbedding stores a single (unchunked) embedding. contentHash (see
+// ContentHash) records which document produced it; pass "" when not applicable.
+func (s *PostgresStorage) UpsertTextEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, dim int, embedding []float32, contentHash string) error {
+	return s.UpsertTextEmbeddingChunks(ctx, entityType, entityID, model, language, dim, [][]float32{embedding}, contentHash)
+}
+
+// UpsertTextEmbeddingChunks stores one embedding row per chunk (chunk_idx is the
+// slice index) and removes chunks left over from a previously longer document.
+// Rows are tagged with ctx's tenant (see WithTenant). Chunks whose length
+// differs from the model's registered dims are rejected with ErrDimsMismatch.
+func (s *PostgresStorage) UpsertTextEmbeddingChunks(ctx context.Context, entityType string, entityID string, model string, language string, dim int, chunks [][]float32, contentHash string) error {
+	tx, err := s.beginChunkWrite(ctx, entityType, entityID, model, language, chunks, contentHash)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	return tx.Commit(ctx)
+}
+
+// beginChunkWrite validates and writes one entity's chunks in a new
+// transaction and returns it uncommitted, for the caller to extend and commit
+// (or roll back).
+func (s *PostgresStorage) beginChunkWrite(ctx context.Context, entityType string, entityID string, model string, language string, chunks [][]float32, contentHash string) (pgx.Tx, error) {
 	if s.schema == "" {
-		return fmt.Errorf("schema is required")
+		return nil, fmt.Errorf("schema is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("entityType and model are required")
+	}
+	if err := validateChunkWrite(entityType, entityID, language, chunks); err != nil {
+		return nil, err
+	}
+
+	mode := s.storageMode(model)
+	if mode == StorageSparse {
+		return nil, fmt.Errorf("model %q stores sparse vectors; use UpsertSparseEmbedding", model)
+	}
+	if err := s.checkDims(ctx, model, chunks); err != nil {
+		return nil, err
+	}
+	tenant := TenantFromContext(ctx)
+
+	// Only the column for the model's storage mode is set; the others are
+	// cleared so switching modes does not leave stale vectors behind once
+	// the entity is re-embedded (the runtime's content hash includes the
+	// mode, so a mode change re-embeds).
+	qUpsert := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, chunk_idx, embedding, embedding_vec, embedding_bits, content_hash, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::text::bit varying, NULLIF($9, ''), $10, now(), now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language, chunk_idx) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			embedding_vec = EXCLUDED.embedding_vec,
+			embedding_bits = EXCLUDED.embedding_bits,
+			content_hash = EXCLUDED.content_hash,
+			deleted_at = NULL,
+			updated_at = now()
+	`, s.schema, embeddingVectorsTable)
+	qPrune := fmt.Sprintf(`
+		DELETE FROM %s.%s
+		WHERE tenant_id = $6 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4 AND chunk_idx >= $5
+	`, s.schema, embeddingVectorsTable)
+	qUpsertExact := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, chunk_idx, embedding, tenant_id, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language, chunk_idx) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			updated_at = now()
+	`, s.schema, embeddingVectorsExactTable)
+	qPruneExact := fmt.Sprintf(`
+		DELETE FROM %s.%s
+		WHERE tenant_id = $6 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4 AND chunk_idx >= $5
+	`, s.schema, embeddingVectorsExactTable)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if entityType == "" || model == "" {
+	fail := func(err error) (pgx.Tx, error) {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+
+	for i, emb := range chunks {
+		var (
+			half any
+			full any
+			bits any
+		)
+		switch mode {
+		case StorageVector:
+			full = pgvector.NewVector(emb)
+		case StorageBit:
+			bits = BinaryQuantize(emb)
+		default:
+			half = pgvector.NewHalfVector(emb)
+		}
+		if _, err := tx.Exec(ctx, qUpsert, entityType, entityID, model, language, i, half, full, bits, contentHash, tenant); err != nil {
+			return fail(err)
+		}
+		if mode == StorageBit {
+			if _, err := tx.Exec(ctx, qUpsertExact, entityType, entityID, model, language, i, pgvector.NewHalfVector(emb), tenant); err != nil {
+				return fail(err)
+			}
+		}
+	}
+	if _, err := tx.Exec(ctx, qPrune, entityType, entityID, model, language, len(chunks), tenant); err != nil {
+		return fail(err)
+	}
+	exactKeep := 0
+	if mode == StorageBit {
+		exactKeep = len(chunks)
+	}
+	if _, err := tx.Exec(ctx, qPruneExact, entityType, entityID, model, language, exactKeep, tenant); err != nil {
+		return fail(err)
+	}
+	return tx, nil
+}
+
+// validateChunkWrite checks one entity's chunk vectors before they are
+// upserted.
+func validateChunkWrite(entityType string, entityID string, language string, chunks [][]float32) error {
+	if entityType == "" {
 		return fmt.Errorf("entityType and model are required")
 	}
 	if strings.TrimSpace(language) == "" {
@@ -38,18 +230,69 @@ func (s *PostgresStorage) UpsertTextEmbedding(ctx context.Context, entityType st
 	if strings.TrimSpace(entityID) == "" {
 		return fmt.Errorf("entityID is required")
 	}
-	if len(embedding) == 0 {
+	if len(chunks) == 0 {
 		return fmt.Errorf("embedding is empty")
 	}
+	for _, emb := range chunks {
+		if len(emb) == 0 {
+			return fmt.Errorf("embedding is empty")
+		}
+	}
+	return nil
+}
+
+// ContentHashes returns the stored content_hash for each key of ctx's tenant
+// that has an embedding with a recorded hash for model.
+func (s *PostgresStorage) ContentHashes(ctx context.Context, model string, keys []EmbeddingKey) (map[EmbeddingKey]string, error) {
+	if s.schema == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	out := make(map[EmbeddingKey]string, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	types := make([]string, len(keys))
+	ids := make([]string, len(keys))
+	langs := make([]string, len(keys))
+	for i, k := range keys {
+		types[i] = k.EntityType
+		ids[i] = k.EntityID
+		langs[i] = k.Language
+	}
 
 	q := fmt.Sprintf(`
-		INSERT INTO %s.%s (entity_type, entity_id, model, language, embedding, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, now(), now())
-		ON CONFLICT (entity_type, entity_id, model, language) DO UPDATE SET
-			embedding = EXCLUDED.embedding,
-			updated_at = now()
+		WITH keys AS (
+			SELECT
+				unnest($2::text[]) AS entity_type,
+				unnest($3::text[]) AS entity_id,
+				unnest($4::text[]) AS language
+		)
+		SELECT ev.entity_type, ev.entity_id, ev.language, ev.content_hash
+		FROM keys
+		JOIN %s.%s ev
+			ON ev.entity_type = keys.entity_type
+			AND ev.entity_id = keys.entity_id
+			AND ev.language = keys.language
+			AND ev.model = $1
+			AND ev.chunk_idx = 0
+			AND ev.tenant_id = $5
+		WHERE ev.content_hash IS NOT NULL
 	`, s.schema, embeddingVectorsTable)
-
-	_, err := s.pool.Exec(ctx, q, entityType, entityID, model, language, pgvector.NewHalfVector(embedding))
-	return err
+	rows, err := s.pool.Query(ctx, q, model, types, ids, langs, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k EmbeddingKey
+		var h string
+		if err := rows.Scan(&k.EntityType, &k.EntityID, &k.Language, &h); err != nil {
+			return nil, err
+		}
+		out[k] = h
+	}
+	return out, rows.Err()
 }
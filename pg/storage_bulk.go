@@ -0,0 +1,157 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+// TextEmbeddingWrite is one entity's chunk vectors for
+// UpsertTextEmbeddingsBulk.
+type TextEmbeddingWrite struct {
+	EntityType  string
+	EntityID    string
+	Language    string
+	Chunks      [][]float32
+	ContentHash string
+}
+
+// UpsertTextEmbeddingsBulk stores the chunk vectors of many entities for model
+// in one transaction with a fixed number of statements (unnest-based upserts
+// and prunes), instead of one round trip per chunk as in
+// UpsertTextEmbeddingChunks. The end state matches calling
+// UpsertTextEmbeddingChunks per write; when a key repeats, the last write wins.
+// Rows are tagged with ctx's tenant (see WithTenant). Vector dims are checked
+// like in UpsertTextEmbeddingChunks.
+//
+// The batch is all-or-nothing: on error nothing is stored.
+func (s *PostgresStorage) UpsertTextEmbeddingsBulk(ctx context.Context, model string, writes []TextEmbeddingWrite) error {
+	if s.schema == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return fmt.Errorf("model is required")
+	}
+	mode := s.storageMode(model)
+	if mode == StorageSparse {
+		return fmt.Errorf("model %q stores sparse vectors; use UpsertSparseEmbedding", model)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	last := make(map[EmbeddingKey]int, len(writes))
+	for i, w := range writes {
+		if err := validateChunkWrite(w.EntityType, w.EntityID, w.Language, w.Chunks); err != nil {
+			return fmt.Errorf("write %d: %w", i, err)
+		}
+		if err := s.checkDims(ctx, model, w.Chunks); err != nil {
+			return fmt.Errorf("write %d: %w", i, err)
+		}
+		last[EmbeddingKey{EntityType: w.EntityType, EntityID: w.EntityID, Language: w.Language}] = i
+	}
+
+	var (
+		// Per entity, for the prunes.
+		keyTypes, keyIDs, keyLangs []string
+		keyCounts                  []int
+		// Per chunk, for the upserts. Vector columns not used by the model's
+		// storage mode are '' (NULL) so switching modes clears them.
+		types, ids, langs, halves, fulls, bits, hashes []string
+		chunkIdx                                       []int
+	)
+	for i, w := range writes {
+		if last[EmbeddingKey{EntityType: w.EntityType, EntityID: w.EntityID, Language: w.Language}] != i {
+			continue
+		}
+		keyTypes = append(keyTypes, w.EntityType)
+		keyIDs = append(keyIDs, w.EntityID)
+		keyLangs = append(keyLangs, w.Language)
+		keyCounts = append(keyCounts, len(w.Chunks))
+		for c, emb := range w.Chunks {
+			var half, full, bit string
+			switch mode {
+			case StorageVector:
+				full = pgvector.NewVector(emb).String()
+			case StorageBit:
+				bit = BinaryQuantize(emb)
+				half = pgvector.NewHalfVector(emb).String() // exact copy for rescoring
+			default:
+				half = pgvector.NewHalfVector(emb).String()
+			}
+			types = append(types, w.EntityType)
+			ids = append(ids, w.EntityID)
+			langs = append(langs, w.Language)
+			chunkIdx = append(chunkIdx, c)
+			halves = append(halves, half)
+			fulls = append(fulls, full)
+			bits = append(bits, bit)
+			hashes = append(hashes, w.ContentHash)
+		}
+	}
+
+	// In StorageBit mode the halfvec goes to embedding_vectors_exact only.
+	halfCol := "NULLIF(k.vec_half, '')::halfvec"
+	if mode == StorageBit {
+		halfCol = "NULL::halfvec"
+	}
+	qUpsert := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, chunk_idx, embedding, embedding_vec, embedding_bits, content_hash, tenant_id, created_at, updated_at)
+		SELECT k.entity_type, k.entity_id, $1, k.language, k.chunk_idx, %s, NULLIF(k.vec_full, '')::vector, NULLIF(k.vec_bits, '')::bit varying, NULLIF(k.content_hash, ''), $2, now(), now()
+		FROM unnest($3::text[], $4::text[], $5::text[], $6::int[], $7::text[], $8::text[], $9::text[], $10::text[])
+			AS k(entity_type, entity_id, language, chunk_idx, vec_half, vec_full, vec_bits, content_hash)
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language, chunk_idx) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			embedding_vec = EXCLUDED.embedding_vec,
+			embedding_bits = EXCLUDED.embedding_bits,
+			content_hash = EXCLUDED.content_hash,
+			deleted_at = NULL,
+			updated_at = now()
+	`, s.schema, embeddingVectorsTable, halfCol)
+	qUpsertExact := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, chunk_idx, embedding, tenant_id, updated_at)
+		SELECT k.entity_type, k.entity_id, $1, k.language, k.chunk_idx, k.vec_half::halfvec, $7, now()
+		FROM unnest($2::text[], $3::text[], $4::text[], $5::int[], $6::text[])
+			AS k(entity_type, entity_id, language, chunk_idx, vec_half)
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language, chunk_idx) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			updated_at = now()
+	`, s.schema, embeddingVectorsExactTable)
+	prune := func(table string) string {
+		return fmt.Sprintf(`
+			DELETE FROM %s.%s ev
+			USING unnest($2::text[], $3::text[], $4::text[], $5::int[]) AS k(entity_type, entity_id, language, keep)
+			WHERE ev.tenant_id = $6 AND ev.entity_type = k.entity_type AND ev.entity_id = k.entity_id AND ev.model = $1
+				AND ev.language = k.language AND ev.chunk_idx >= k.keep
+		`, s.schema, table)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tenant := TenantFromContext(ctx)
+	if _, err := tx.Exec(ctx, qUpsert, model, tenant, types, ids, langs, chunkIdx, halves, fulls, bits, hashes); err != nil {
+		return err
+	}
+	if mode == StorageBit {
+		if _, err := tx.Exec(ctx, qUpsertExact, model, types, ids, langs, chunkIdx, halves, tenant); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, prune(embeddingVectorsTable), model, keyTypes, keyIDs, keyLangs, keyCounts, tenant); err != nil {
+		return err
+	}
+	exactKeep := keyCounts
+	if mode != StorageBit {
+		exactKeep = make([]int, len(keyCounts))
+	}
+	if _, err := tx.Exec(ctx, prune(embeddingVectorsExactTable), model, keyTypes, keyIDs, keyLangs, exactKeep, tenant); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,57 @@
+package pg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StorageMode selects how a model's vectors are stored and indexed.
+type StorageMode string
+
+const (
+	// StorageHalfvec stores fp16 vectors in embedding_vectors.embedding (default).
+	StorageHalfvec StorageMode = "halfvec"
+	// StorageVector stores fp32 vectors in embedding_vectors.embedding_vec.
+	StorageVector StorageMode = "vector"
+	// StorageBit stores only binary-quantized vectors in
+	// embedding_vectors.embedding_bits; exact halfvec vectors used for rescoring
+	// live in embedding_vectors_exact.
+	StorageBit StorageMode = "bit"
+	// StorageSparse stores learned sparse vectors (SPLADE, BM42) in
+	// embedding_vectors.embedding_sparse; it is the storage of sparse models
+	// and not selectable for dense ones.
+	StorageSparse StorageMode = "sparsevec"
+)
+
+// OrDefault returns StorageHalfvec for the zero value.
+func (m StorageMode) OrDefault() StorageMode {
+	if m == "" {
+		return StorageHalfvec
+	}
+	return m
+}
+
+// Validate reports whether m is a known storage mode (the zero value is valid).
+func (m StorageMode) Validate() error {
+	switch m.OrDefault() {
+	case StorageHalfvec, StorageVector, StorageBit, StorageSparse:
+		return nil
+	default:
+		return fmt.Errorf("invalid storage mode %q", m)
+	}
+}
+
+// BinaryQuantize returns the bit string literal (e.g. "1010") matching
+// pgvector's binary_quantize: 1 for positive components, 0 otherwise.
+func BinaryQuantize(vec []float32) string {
+	var b strings.Builder
+	b.Grow(len(vec))
+	for _, x := range vec {
+		if x > 0 {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type tenantKey struct{}
+
+// WithTenant scopes searchkit writes and reads made with ctx (lexical
+// documents, embeddings, tasks, searches, admin reports) to tenantID. The
+// worker derives it from search_dirty and embedding_tasks rows; hosts calling
+// the runtime, search or pg helpers directly set it themselves. The empty
+// tenant is the default, single-tenant dataset; reads that span tenants say so
+// with an AllTenants option.
+//
+// tenant_id leads the row keys, so tenants may use the same entity IDs.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, strings.TrimSpace(tenantID))
+}
+
+// TenantFromContext returns the tenant set by WithTenant ("" if none).
+func TenantFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(string)
+	return t
+}
+
+// tenantArg is the tenant filter argument of reads and admin calls, matched
+// with "($N::text IS NULL OR tenant_id = $N)": ctx's tenant, or nil (every
+// tenant) with allTenants.
+func tenantArg(ctx context.Context, allTenants bool) any {
+	if allTenants {
+		return nil
+	}
+	return TenantFromContext(ctx)
+}
+
+// EnsureTenantIndexes creates per-tenant partial HNSW indexes for each model, so
+// tenant-filtered semantic search does not scan past other tenants' neighbours
+// in the shared per-model index. Use it for large tenants; small tenants are
+// served well enough by the shared index plus the tenant filter.
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func EnsureTenantIndexes(ctx context.Context, pool Querier, schema string, tenantID string, models []ModelSpec) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	tenantID = strings.TrimSpace(tenantID)
+	if tenantID == "" {
+		return fmt.Errorf("tenantID is required")
+	}
+	for _, m := range models {
+		name := strings.TrimSpace(m.Name)
+		if name == "" {
+			return fmt.Errorf("model name is required")
+		}
+		dims := m.IndexDims()
+		if dims <= 0 {
+			return fmt.Errorf("model %q dims must be > 0", name)
+		}
+		mode := m.Storage.OrDefault()
+		if err := mode.Validate(); err != nil {
+			return err
+		}
+		if m.Modality == "vl" {
+			idx := fmt.Sprintf("idx_embedding_vectors_vl_hnsw_tenant__%s", indexSuffix(name+"/"+tenantID, dims))
+			using := fmt.Sprintf("(embedding::halfvec(%d)) halfvec_cosine_ops", dims)
+			pred := ""
+			if mode == StorageVector {
+				idx = fmt.Sprintf("idx_embedding_vectors_vl_hnsw_tenant__%s", indexSuffix(name+"/"+string(mode)+"/"+tenantID, dims))
+				using = fmt.Sprintf("(embedding_vec::vector(%d)) vector_cosine_ops", dims)
+				pred = " AND embedding_vec IS NOT NULL"
+			}
+			q := fmt.Sprintf(`
+				CREATE INDEX CONCURRENTLY IF NOT EXISTS %s
+				ON %s.%s
+				USING hnsw (%s)
+				WHERE model = %s AND tenant_id = %s%s
+			`, idx, qs, embeddingVectorsVLTable, using, quoteLiteral(name), quoteLiteral(tenantID), pred)
+			if _, err := pool.Exec(ctx, q); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var expr, ops, col string
+		switch mode {
+		case StorageBit:
+			col = "embedding_bits"
+			expr, ops = fmt.Sprintf("(embedding_bits::bit(%d))", dims), "bit_hamming_ops"
+		case StorageSparse:
+			col = "embedding_sparse"
+			expr, ops = fmt.Sprintf("(embedding_sparse::sparsevec(%d))", dims), "sparsevec_ip_ops"
+		case StorageVector:
+			col = "embedding_vec"
+			expr, ops = fmt.Sprintf("(embedding_vec::vector(%d))", dims), "vector_cosine_ops"
+		default:
+			col = "embedding"
+			expr, ops = fmt.Sprintf("(embedding::halfvec(%d))", dims), "halfvec_cosine_ops"
+		}
+		idx := fmt.Sprintf("idx_embedding_vectors_hnsw_tenant__%s", indexSuffix(name+"/"+string(mode)+"/"+tenantID, dims))
+		q := fmt.Sprintf(`
+			CREATE INDEX CONCURRENTLY IF NOT EXISTS %s
+			ON %s.embedding_vectors
+			USING hnsw (%s %s)
+			WHERE model = %s AND tenant_id = %s AND %s IS NOT NULL
+		`, idx, qs, expr, ops, quoteLiteral(name), quoteLiteral(tenantID), col)
+		if _, err := pool.Exec(ctx, q); err != nil {
+			return err
+		}
+		if m.SparseDims > 0 {
+			idx := fmt.Sprintf("idx_embedding_vectors_hnsw_tenant__%s", indexSuffix(name+"/hybrid/"+tenantID, m.SparseDims))
+			q := fmt.Sprintf(`
+				CREATE INDEX CONCURRENTLY IF NOT EXISTS %s
+				ON %s.embedding_vectors
+				USING hnsw ((embedding_sparse::sparsevec(%d)) sparsevec_ip_ops)
+				WHERE model = %s AND tenant_id = %s AND embedding_sparse IS NOT NULL
+			`, idx, qs, m.SparseDims, quoteLiteral(name), quoteLiteral(tenantID))
+			if _, err := pool.Exec(ctx, q); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
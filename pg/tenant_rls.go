@@ -0,0 +1,185 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TenantSetting is the GUC the tenant RLS policies (see EnableTenantRLS)
+// compare tenant_id against.
+const TenantSetting = "searchkit.tenant"
+
+// tenantPolicy is the name of the policy EnableTenantRLS creates on each table.
+const tenantPolicy = "searchkit_tenant_isolation"
+
+// tenantRLSTables are the searchkit tables with a tenant_id column.
+var tenantRLSTables = []string{
+	searchDocumentsTable,
+	"search_dirty",
+	"embedding_tasks",
+	embeddingVectorsTable,
+	embeddingVectorsExactTable,
+	embeddingVectorsVLTable,
+	embeddingVectorAssetsTable,
+	assetCaptionsTable,
+	"embedding_dead_letters",
+}
+
+// EnableTenantRLS enables row-level security on the searchkit tables that
+// carry tenant_id and (re)creates a policy limiting reads and writes to rows of
+// the tenant in TenantSetting. Without the setting, only the default (empty)
+// tenant is visible.
+//
+// Policies do not apply to the tables' owner or roles with BYPASSRLS, so the
+// worker and migrations keep running as the owner across tenants, while
+// request-serving code connects as a separate role and scopes each statement
+// with SetTenant, BeginTenant or TenantScoped. It is idempotent.
+func EnableTenantRLS(ctx context.Context, db Execer, schema string) error {
+	if db == nil {
+		return fmt.Errorf("db is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	for _, table := range tenantRLSTables {
+		q := fmt.Sprintf(`
+			ALTER TABLE %[1]s.%[2]s ENABLE ROW LEVEL SECURITY;
+			DROP POLICY IF EXISTS %[3]s ON %[1]s.%[2]s;
+			CREATE POLICY %[3]s ON %[1]s.%[2]s
+				USING (tenant_id = COALESCE(current_setting(%[4]s, true), ''))
+				WITH CHECK (tenant_id = COALESCE(current_setting(%[4]s, true), ''))
+		`, qs, table, tenantPolicy, quoteLiteral(TenantSetting))
+		if _, err := db.Exec(ctx, q); err != nil {
+			return fmt.Errorf("enable tenant rls on %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// DisableTenantRLS drops the EnableTenantRLS policies and disables row-level
+// security on the tenant tables.
+func DisableTenantRLS(ctx context.Context, db Execer, schema string) error {
+	if db == nil {
+		return fmt.Errorf("db is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	for _, table := range tenantRLSTables {
+		q := fmt.Sprintf(`
+			DROP POLICY IF EXISTS %[3]s ON %[1]s.%[2]s;
+			ALTER TABLE %[1]s.%[2]s DISABLE ROW LEVEL SECURITY;
+		`, qs, table, tenantPolicy)
+		if _, err := db.Exec(ctx, q); err != nil {
+			return fmt.Errorf("disable tenant rls on %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// SetTenant sets TenantSetting to tenantID for the rest of tx's transaction
+// (set_config(..., true)), so tenant RLS policies only expose that tenant.
+func SetTenant(ctx context.Context, tx Execer, tenantID string) error {
+	if tx == nil {
+		return fmt.Errorf("tx is required")
+	}
+	_, err := tx.Exec(ctx, `SELECT set_config($1, $2, true)`, TenantSetting, strings.TrimSpace(tenantID))
+	return err
+}
+
+// BeginTenant begins a transaction on db scoped to tenantID (see SetTenant).
+func BeginTenant(ctx context.Context, db Querier, tenantID string) (pgx.Tx, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db is required")
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetTenant(ctx, tx, tenantID); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+// TenantScoped wraps db so every statement runs in its own transaction scoped
+// to the tenant of the statement's context (see WithTenant), for passing to
+// the search functions, NewPostgresStorage or tasks.NewRepo under tenant RLS.
+// Each statement costs extra round trips; hosts running several statements for
+// one tenant should pass a BeginTenant transaction instead.
+func TenantScoped(db Querier) Querier {
+	return tenantQuerier{db: db}
+}
+
+type tenantQuerier struct {
+	db Querier
+}
+
+func (q tenantQuerier) Begin(ctx context.Context) (pgx.Tx, error) {
+	return BeginTenant(ctx, q.db, TenantFromContext(ctx))
+}
+
+func (q tenantQuerier) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return tag, err
+	}
+	return tag, tx.Commit(ctx)
+}
+
+func (q tenantQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return TxRows(ctx, tx, rows), nil
+}
+
+func (q tenantQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	tx, err := q.Begin(ctx)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return tenantRow{row: tx.QueryRow(ctx, sql, args...), ctx: ctx, tx: tx}
+}
+
+type tenantRow struct {
+	row pgx.Row
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (r tenantRow) Scan(dest ...any) error {
+	if err := r.row.Scan(dest...); err != nil {
+		_ = r.tx.Rollback(r.ctx)
+		return err
+	}
+	return r.tx.Commit(r.ctx)
+}
+
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
+var _ Querier = tenantQuerier{}
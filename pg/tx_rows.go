@@ -0,0 +1,52 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TxRows wraps rows queried in tx so that closing them (or reading past the
+// last row) ends tx: committed if the rows were read without error, rolled
+// back otherwise. A commit failure is reported by Err. It lets a function that
+// opened tx for one query (e.g. to scope a setting with SET LOCAL) return the
+// rows to its caller.
+func TxRows(ctx context.Context, tx pgx.Tx, rows pgx.Rows) pgx.Rows {
+	return &txRows{Rows: rows, ctx: ctx, tx: tx}
+}
+
+type txRows struct {
+	pgx.Rows
+	ctx    context.Context
+	tx     pgx.Tx
+	closed bool
+	err    error
+}
+
+func (r *txRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+func (r *txRows) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.Rows.Close()
+	if r.Rows.Err() != nil {
+		_ = r.tx.Rollback(r.ctx)
+		return
+	}
+	r.err = r.tx.Commit(r.ctx)
+}
+
+func (r *txRows) Err() error {
+	if err := r.Rows.Err(); err != nil {
+		return err
+	}
+	return r.err
+}
@@ -0,0 +1,172 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+const embeddingVectorAssetsTable = "embedding_vector_assets"
+
+// AssetEmbedding is one asset's vector for an entity, stored in
+// embedding_vector_assets.
+type AssetEmbedding struct {
+	// Key is the host's stable asset id (vl.AssetURL.StorageKey).
+	Key      string
+	FrameIdx int
+	Kind     string // vl.AssetKind
+	URL      string
+	Vector   []float32
+
+	// Start and End are a video segment's offsets (Kind "segment"; see
+	// vl.SegmentPolicy), stored as start_ms/end_ms. Both are zero for other
+	// assets.
+	Start, End time.Duration
+}
+
+func (a AssetEmbedding) validate() error {
+	if strings.TrimSpace(a.Key) == "" {
+		return fmt.Errorf("asset key is required")
+	}
+	if len(a.Vector) == 0 {
+		return fmt.Errorf("embedding is empty")
+	}
+	if a.Start < 0 || a.End < a.Start {
+		return fmt.Errorf("invalid segment bounds %s-%s", a.Start, a.End)
+	}
+	return nil
+}
+
+// bounds returns the segment offsets in milliseconds, or NULLs for assets
+// that aren't segments.
+func (a AssetEmbedding) bounds() (*int64, *int64) {
+	if a.End == 0 {
+		return nil, nil
+	}
+	start, end := a.Start.Milliseconds(), a.End.Milliseconds()
+	return &start, &end
+}
+
+func (s *PostgresStorage) assetArgs(entityType string, entityID string, model string, language string) error {
+	if s.schema == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if entityType == "" || model == "" {
+		return fmt.Errorf("entityType and model are required")
+	}
+	if strings.TrimSpace(language) == "" {
+		return fmt.Errorf("language is required")
+	}
+	if strings.TrimSpace(entityID) == "" {
+		return fmt.Errorf("entityID is required")
+	}
+	return nil
+}
+
+func (s *PostgresStorage) upsertAssetQuery() string {
+	return fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, asset_key, frame_idx, asset_kind, asset_url, embedding, start_ms, end_ms, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, now(), now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language, asset_key, frame_idx) DO UPDATE SET
+			asset_kind = EXCLUDED.asset_kind,
+			asset_url = EXCLUDED.asset_url,
+			embedding = EXCLUDED.embedding,
+			start_ms = EXCLUDED.start_ms,
+			end_ms = EXCLUDED.end_ms,
+			deleted_at = NULL,
+			updated_at = now()
+	`, s.schema, embeddingVectorAssetsTable)
+}
+
+// UpsertVLEmbeddingAsset stores (or replaces) one asset vector for an
+// entity+model+language, leaving the entity's other assets untouched. Rows are
+// tagged with ctx's tenant (see WithTenant).
+func (s *PostgresStorage) UpsertVLEmbeddingAsset(ctx context.Context, entityType string, entityID string, model string, language string, asset AssetEmbedding) error {
+	if err := s.assetArgs(entityType, entityID, model, language); err != nil {
+		return err
+	}
+	if err := asset.validate(); err != nil {
+		return err
+	}
+	start, end := asset.bounds()
+	_, err := s.pool.Exec(ctx, s.upsertAssetQuery(), entityType, entityID, model, language, asset.Key, asset.FrameIdx, asset.Kind, asset.URL, pgvector.NewHalfVector(asset.Vector), start, end, TenantFromContext(ctx))
+	return err
+}
+
+// UpsertVLEmbeddingAssets replaces an entity+model+language's asset vectors
+// with assets, removing assets that are no longer listed.
+func (s *PostgresStorage) UpsertVLEmbeddingAssets(ctx context.Context, entityType string, entityID string, model string, language string, assets []AssetEmbedding) error {
+	if err := s.assetArgs(entityType, entityID, model, language); err != nil {
+		return err
+	}
+	keys := make([]string, len(assets))
+	frames := make([]int32, len(assets))
+	for i, a := range assets {
+		if err := a.validate(); err != nil {
+			return err
+		}
+		keys[i] = a.Key
+		frames[i] = int32(a.FrameIdx)
+	}
+	tenant := TenantFromContext(ctx)
+
+	qPrune := fmt.Sprintf(`
+		DELETE FROM %s.%s
+		WHERE tenant_id = $7 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4
+		  AND (asset_key, frame_idx) NOT IN (SELECT * FROM unnest($5::text[], $6::int[]))
+	`, s.schema, embeddingVectorAssetsTable)
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	q := s.upsertAssetQuery()
+	for _, a := range assets {
+		start, end := a.bounds()
+		if _, err := tx.Exec(ctx, q, entityType, entityID, model, language, a.Key, a.FrameIdx, a.Kind, a.URL, pgvector.NewHalfVector(a.Vector), start, end, tenant); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, qPrune, entityType, entityID, model, language, keys, frames, tenant); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// EnsureAssetIndexes creates the per-model cosine HNSW index on
+// embedding_vector_assets used by search.AssetSearch.
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func EnsureAssetIndexes(ctx context.Context, pool Querier, schema string, model string, dims int) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if dims <= 0 {
+		return fmt.Errorf("dims must be > 0")
+	}
+	idx := assetModelIndex(model, dims)
+	_, err = pool.Exec(ctx, idx.createSQL(qs, idx.name, HNSWParams{}))
+	return err
+}
+
+// assetModelIndex is the index EnsureAssetIndexes creates.
+func assetModelIndex(model string, dims int) modelIndex {
+	return modelIndex{
+		name:  "idx_embedding_vector_assets_hnsw__" + indexSuffix(model, dims),
+		table: embeddingVectorAssetsTable,
+		using: fmt.Sprintf("(embedding::halfvec(%d)) halfvec_cosine_ops", dims),
+		where: "model = " + quoteLiteral(model),
+	}
+}
@@ -0,0 +1,168 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pgvector "github.com/pgvector/pgvector-go"
+)
+
+const embeddingVectorsVLTable = "embedding_vectors_vl"
+
+// UpsertVLEmbedding stores an entity's fused VL vector in embedding_vectors_vl
+// (never chunked): halfvec in embedding, or fp32 in embedding_vec for
+// StorageVector models. contentHash records the document and asset set that
+// produced it. Rows are tagged with ctx's tenant (see WithTenant).
+func (s *PostgresStorage) UpsertVLEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, embedding []float32, contentHash string) error {
+	if err := s.assetArgs(entityType, entityID, model, language); err != nil {
+		return err
+	}
+	if len(embedding) == 0 {
+		return fmt.Errorf("embedding is empty")
+	}
+	mode := s.storageMode(model)
+	if mode != StorageHalfvec && mode != StorageVector {
+		return fmt.Errorf("model %q: vl vectors can't be stored as %s", model, mode)
+	}
+	if err := s.checkDims(ctx, model, [][]float32{embedding}); err != nil {
+		return err
+	}
+	// The column not used by the storage mode is cleared.
+	var half, full any
+	if mode == StorageVector {
+		full = pgvector.NewVector(embedding)
+	} else {
+		half = pgvector.NewHalfVector(embedding)
+	}
+	q := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, embedding, embedding_vec, content_hash, tenant_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, now(), now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language) DO UPDATE SET
+			embedding = EXCLUDED.embedding,
+			embedding_vec = EXCLUDED.embedding_vec,
+			content_hash = EXCLUDED.content_hash,
+			deleted_at = NULL,
+			updated_at = now()
+	`, s.schema, embeddingVectorsVLTable)
+	_, err := s.pool.Exec(ctx, q, entityType, entityID, model, language, half, full, contentHash, TenantFromContext(ctx))
+	return err
+}
+
+// VLContentHashes is ContentHashes for the fused VL vectors in
+// embedding_vectors_vl.
+func (s *PostgresStorage) VLContentHashes(ctx context.Context, model string, keys []EmbeddingKey) (map[EmbeddingKey]string, error) {
+	if s.schema == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	out := make(map[EmbeddingKey]string, len(keys))
+	if len(keys) == 0 {
+		return out, nil
+	}
+	types := make([]string, len(keys))
+	ids := make([]string, len(keys))
+	langs := make([]string, len(keys))
+	for i, k := range keys {
+		types[i] = k.EntityType
+		ids[i] = k.EntityID
+		langs[i] = k.Language
+	}
+
+	q := fmt.Sprintf(`
+		SELECT ev.entity_type, ev.entity_id, ev.language, ev.content_hash
+		FROM unnest($2::text[], $3::text[], $4::text[]) AS keys (entity_type, entity_id, language)
+		JOIN %s.%s ev
+			ON ev.entity_type = keys.entity_type
+			AND ev.entity_id = keys.entity_id
+			AND ev.language = keys.language
+			AND ev.model = $1
+			AND ev.tenant_id = $5
+		WHERE ev.content_hash IS NOT NULL
+	`, s.schema, embeddingVectorsVLTable)
+	rows, err := s.pool.Query(ctx, q, model, types, ids, langs, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k EmbeddingKey
+		var h string
+		if err := rows.Scan(&k.EntityType, &k.EntityID, &k.Language, &h); err != nil {
+			return nil, err
+		}
+		out[k] = h
+	}
+	return out, rows.Err()
+}
+
+// EnsureVLIndexes creates the per-model cosine and binary (two-stage) HNSW
+// indexes on embedding_vectors_vl, like EnsureModelIndexes does for text
+// models.
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func EnsureVLIndexes(ctx context.Context, pool Querier, schema string, model string, dims int) error {
+	return EnsureVLIndexesWithStorage(ctx, pool, schema, model, dims, StorageHalfvec)
+}
+
+// EnsureVLIndexesWithStorage is EnsureVLIndexes for a specific storage mode
+// (StorageHalfvec or StorageVector).
+//
+// This must NOT run inside a transaction because it uses CREATE INDEX CONCURRENTLY.
+func EnsureVLIndexesWithStorage(ctx context.Context, pool Querier, schema string, model string, dims int, mode StorageMode) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	qs, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if dims <= 0 {
+		return fmt.Errorf("dims must be > 0")
+	}
+	mode = mode.OrDefault()
+	if mode != StorageHalfvec && mode != StorageVector {
+		return fmt.Errorf("model %q: vl vectors can't be stored as %s", model, mode)
+	}
+	for _, idx := range vlModelIndexes(model, dims, mode) {
+		if _, err := pool.Exec(ctx, idx.createSQL(qs, idx.name, HNSWParams{})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vlModelIndexes lists the indexes EnsureVLIndexesWithStorage creates for a
+// halfvec or vector VL model.
+func vlModelIndexes(model string, dims int, mode StorageMode) []modelIndex {
+	// Halfvec index names and predicates predate storage modes and stay
+	// unchanged so existing indexes are reused.
+	suffix := indexSuffix(model, dims)
+	col, typ, ops := "embedding", fmt.Sprintf("halfvec(%d)", dims), "halfvec_cosine_ops"
+	pred := "model = " + quoteLiteral(model)
+	if mode == StorageVector {
+		suffix = indexSuffix(model+"/"+string(mode), dims)
+		col, typ, ops = "embedding_vec", fmt.Sprintf("vector(%d)", dims), "vector_cosine_ops"
+		pred += " AND embedding_vec IS NOT NULL"
+	}
+	return []modelIndex{
+		{
+			name:  "idx_embedding_vectors_vl_hnsw_cosine__" + suffix,
+			table: embeddingVectorsVLTable,
+			using: fmt.Sprintf("(%s::%s) %s", col, typ, ops),
+			where: pred,
+		},
+		{
+			name:  "idx_embedding_vectors_vl_hnsw_binary__" + suffix,
+			table: embeddingVectorsVLTable,
+			using: fmt.Sprintf("(binary_quantize(%s::%s)::bit(%d)) bit_hamming_ops", col, typ, dims),
+			where: pred,
+		},
+	}
+}
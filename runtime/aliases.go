@@ -0,0 +1,28 @@
+package runtime
+
+import (
+	"sort"
+	"strings"
+)
+
+// CanonicalModel resolves a model alias (see Options.ModelAliases) to the
+// canonical model name. Unknown names are returned unchanged (trimmed).
+func (r *Runtime) CanonicalModel(model string) string {
+	model = strings.TrimSpace(model)
+	if c, ok := r.aliases[model]; ok {
+		return c
+	}
+	return model
+}
+
+// aliasesOf returns the aliases configured for a canonical model, sorted.
+func (r *Runtime) aliasesOf(model string) []string {
+	var out []string
+	for alias, c := range r.aliases {
+		if c == model {
+			out = append(out, alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
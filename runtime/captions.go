@@ -0,0 +1,109 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/vl"
+)
+
+// CaptionAssets generates short captions for assets (e.g. with a VLM), in
+// language; the result aligns with assets by index ("" for no caption). It is
+// only called for assets without a stored caption.
+type CaptionAssets func(ctx context.Context, language string, assets []vl.AssetURL) ([]string, error)
+
+// CaptionStorage is implemented by storages that can hold generated asset
+// captions; it is required when Options.CaptionAssets is set.
+// pg.PostgresStorage and MemoryStorage implement it.
+type CaptionStorage interface {
+	AssetCaptions(ctx context.Context, entityType string, language string, entityIDs []string) (map[string][]pg.AssetCaption, error)
+	ReplaceAssetCaptions(ctx context.Context, entityType string, entityID string, language string, captions []pg.AssetCaption) error
+}
+
+var _ CaptionStorage = (*pg.PostgresStorage)(nil)
+
+type captionKey struct {
+	key   string
+	frame int
+}
+
+// captions returns each entity's captions (newline-joined, in asset order),
+// generating and storing captions for assets that have none yet.
+func (r *Runtime) captions(ctx context.Context, entityType string, language string, assets map[string][]vl.AssetURL) (map[string]string, error) {
+	store := r.storage.(CaptionStorage)
+	ids := make([]string, 0, len(assets))
+	for id := range assets {
+		ids = append(ids, id)
+	}
+	stored, err := store.AssetCaptions(ctx, entityType, language, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	type ref struct {
+		id string
+		i  int
+	}
+	var (
+		missing []vl.AssetURL
+		refs    []ref
+	)
+	current := make(map[string][]pg.AssetCaption, len(assets))
+	changed := map[string]bool{}
+	for id, list := range assets {
+		have := make(map[captionKey]string, len(stored[id]))
+		for _, c := range stored[id] {
+			have[captionKey{c.Key, c.FrameIdx}] = c.Caption
+		}
+		seen := map[captionKey]bool{}
+		var caps []pg.AssetCaption
+		for _, a := range list {
+			k := captionKey{a.StorageKey(), a.FrameIdx}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			c, ok := have[k]
+			if !ok {
+				missing = append(missing, a)
+				refs = append(refs, ref{id: id, i: len(caps)})
+				changed[id] = true
+			}
+			caps = append(caps, pg.AssetCaption{Key: k.key, FrameIdx: k.frame, Caption: c})
+		}
+		if len(caps) != len(stored[id]) {
+			changed[id] = true
+		}
+		current[id] = caps
+	}
+
+	if len(missing) > 0 {
+		generated, err := r.captionAssets(ctx, language, missing)
+		if err != nil {
+			return nil, fmt.Errorf("CaptionAssets: %w", err)
+		}
+		if len(generated) != len(missing) {
+			return nil, fmt.Errorf("CaptionAssets: expected %d captions, got %d", len(missing), len(generated))
+		}
+		for k, rf := range refs {
+			current[rf.id][rf.i].Caption = strings.TrimSpace(generated[k])
+		}
+	}
+
+	out := make(map[string]string, len(current))
+	for id, caps := range current {
+		if changed[id] {
+			if err := store.ReplaceAssetCaptions(ctx, entityType, id, language, caps); err != nil {
+				return nil, err
+			}
+		}
+		lines := make([]string, len(caps))
+		for i, c := range caps {
+			lines[i] = c.Caption
+		}
+		out[id] = joinLines(lines...)
+	}
+	return out, nil
+}
@@ -0,0 +1,104 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ChunkOptions configures how long semantic documents are split before
+// embedding. Each chunk is embedded separately and stored as its own
+// embedding_vectors row (chunk_idx), so long descriptions are not truncated or
+// diluted into a single vector.
+type ChunkOptions struct {
+	// MaxChars is the maximum chunk length in characters. Documents that fit are
+	// embedded as a single vector. Required.
+	MaxChars int
+	// OverlapChars is how many characters of a chunk are repeated at the start
+	// of the next one. Defaults to MaxChars/8; negative disables overlap.
+	OverlapChars int
+	// MaxChunks caps the number of chunks per document (0 means unlimited).
+	MaxChunks int
+}
+
+func (o ChunkOptions) withDefaults() ChunkOptions {
+	out := o
+	if out.OverlapChars == 0 {
+		out.OverlapChars = out.MaxChars / 8
+	}
+	if out.OverlapChars < 0 {
+		out.OverlapChars = 0
+	}
+	return out
+}
+
+func (o ChunkOptions) validate() error {
+	if o.MaxChars <= 0 {
+		return fmt.Errorf("MaxChars must be > 0")
+	}
+	if o.OverlapChars >= o.MaxChars {
+		return fmt.Errorf("OverlapChars must be < MaxChars")
+	}
+	if o.MaxChunks < 0 {
+		return fmt.Errorf("MaxChunks must be >= 0")
+	}
+	return nil
+}
+
+// splitChunks splits doc into overlapping chunks of at most MaxChars
+// characters, preferring whitespace boundaries.
+func splitChunks(doc string, o ChunkOptions) []string {
+	o = o.withDefaults()
+	r := []rune(doc)
+	if o.MaxChars <= 0 || len(r) <= o.MaxChars {
+		return []string{doc}
+	}
+
+	var out []string
+	start := 0
+	for start < len(r) {
+		end := start + o.MaxChars
+		if end >= len(r) {
+			end = len(r)
+		} else {
+			// Back off to the last whitespace in the second half of the window so
+			// words are not cut in half.
+			for i := end; i > start+o.MaxChars/2; i-- {
+				if unicode.IsSpace(r[i]) {
+					end = i
+					break
+				}
+			}
+		}
+		if chunk := strings.TrimSpace(string(r[start:end])); chunk != "" {
+			out = append(out, chunk)
+		}
+		if end == len(r) || (o.MaxChunks > 0 && len(out) >= o.MaxChunks) {
+			break
+		}
+
+		next := end - o.OverlapChars
+		if next <= start {
+			next = end
+		}
+		// Start the next chunk on a word boundary.
+		for next < end && !unicode.IsSpace(r[next-1]) {
+			next++
+		}
+		start = next
+	}
+	if len(out) == 0 {
+		return []string{doc}
+	}
+	return out
+}
+
+// documentChunks returns the texts to embed for doc under model's chunking
+// settings (a single element when chunking is not configured).
+func (r *Runtime) documentChunks(model string, doc string) []string {
+	o, ok := r.chunking[model]
+	if !ok {
+		return []string{doc}
+	}
+	return splitChunks(doc, o)
+}
@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitChunks_ShortDocument(t *testing.T) {
+	got := splitChunks("short doc", ChunkOptions{MaxChars: 100})
+	if len(got) != 1 || got[0] != "short doc" {
+		t.Fatalf("expected single chunk, got %q", got)
+	}
+}
+
+func TestSplitChunks_OverlapAndWordBoundaries(t *testing.T) {
+	words := make([]string, 80)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%02d", i)
+	}
+	doc := strings.Join(words, " ")
+	o := ChunkOptions{MaxChars: 60, OverlapChars: 12}
+	got := splitChunks(doc, o)
+	if len(got) < 2 {
+		t.Fatalf("expected multiple chunks, got %d", len(got))
+	}
+	for i, c := range got {
+		if len([]rune(c)) > o.MaxChars {
+			t.Fatalf("chunk %d exceeds MaxChars: %q", i, c)
+		}
+		for _, w := range strings.Fields(c) {
+			if len(w) != 3 {
+				t.Fatalf("chunk %d has a split word %q", i, w)
+			}
+		}
+	}
+	// Consecutive chunks share text when overlap is enabled.
+	first := strings.Fields(got[1])[0]
+	if !strings.Contains(got[0], first) {
+		t.Fatalf("expected chunk 1 to start with overlap from chunk 0, got %q / %q", got[0], got[1])
+	}
+	if got[len(got)-1] == got[0] || !strings.HasSuffix(got[len(got)-1], "w79") {
+		t.Fatalf("expected last chunk to end the document, got %q", got[len(got)-1])
+	}
+}
+
+func TestSplitChunks_MaxChunks(t *testing.T) {
+	doc := strings.Repeat("word ", 200)
+	got := splitChunks(doc, ChunkOptions{MaxChars: 50, OverlapChars: -1, MaxChunks: 3})
+	if len(got) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(got))
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%02d", i)
+	}
+	doc := strings.Join(words, " ")
+
+	head := TokenLimit{MaxTokens: 10}.withDefaults()
+	got := truncateToTokens(doc, head.MaxTokens, head)
+	if EstimateTokens(got) > 10 || !strings.HasPrefix(doc, got) {
+		t.Fatalf("head: unexpected %q", got)
+	}
+
+	ht := TokenLimit{MaxTokens: 20, Strategy: TruncateHeadTail}.withDefaults()
+	got = truncateToTokens(doc, ht.MaxTokens, ht)
+	if EstimateTokens(got) > 20 {
+		t.Fatalf("head_tail: %q exceeds budget (%d tokens)", got, EstimateTokens(got))
+	}
+	if !strings.HasPrefix(got, "w00") || !strings.HasSuffix(got, "w99") {
+		t.Fatalf("head_tail: expected head and tail kept, got %q", got)
+	}
+}
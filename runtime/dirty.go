@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// MarkDirty records that an entity changed (or was deleted) in the given
+// languages. The worker (worker.SyncOnce) rebuilds lexical documents and
+// enqueues embeddings for it.
+func (r *Runtime) MarkDirty(ctx context.Context, entityType string, entityID string, languages []string, deleted bool, reason string) error {
+	marks := make([]pg.DirtyMark, 0, len(languages))
+	for _, lang := range languages {
+		marks = append(marks, pg.DirtyMark{EntityType: entityType, EntityID: entityID, Language: lang, Deleted: deleted, Reason: reason})
+	}
+	return r.MarkDirtyMany(ctx, marks)
+}
+
+// MarkAssetsChanged records that an entity's assets changed in the given
+// languages (pg.ReasonAssetsChanged), so the worker re-embeds only
+// AssetDependentModels rather than the whole entity.
+func (r *Runtime) MarkAssetsChanged(ctx context.Context, entityType string, entityID string, languages []string) error {
+	return r.MarkDirty(ctx, entityType, entityID, languages, false, pg.ReasonAssetsChanged)
+}
+
+// AssetDependentModels returns the active models whose inputs depend on an
+// entity's assets: VL models, or every model when asset text
+// (Options.ExtractAssetText, CaptionAssets) is added to documents.
+func (r *Runtime) AssetDependentModels() []string {
+	models := r.ActiveModels()
+	if r.extractText != nil || r.captionAssets != nil {
+		return models
+	}
+	return slices.DeleteFunc(models, func(m string) bool { return !r.IsVLModel(m) })
+}
+
+// LexicalUsesAssets reports whether lexical documents include asset text
+// (Options.AssetTextInLexical), so asset changes also rebuild them.
+func (r *Runtime) LexicalUsesAssets() bool {
+	return r.lexicalText
+}
+
+// MarkDirtyMany records several dirty marks in one statement.
+func (r *Runtime) MarkDirtyMany(ctx context.Context, marks []pg.DirtyMark) error {
+	return pg.MarkDirty(ctx, r.pool, r.schema, marks)
+}
+
+// MarkDirtyTx records dirty marks inside the host's transaction, so they are
+// committed (or rolled back) together with the change they describe.
+func (r *Runtime) MarkDirtyTx(ctx context.Context, tx pgx.Tx, marks []pg.DirtyMark) error {
+	return pg.MarkDirty(ctx, tx, r.schema, marks)
+}
+
+// DeleteEntity immediately removes all searchkit state for an entity in every
+// language (see pg.DeleteEntity). Prefer MarkDirty with deleted=true when the
+// removal can wait for the worker (and should honour soft deletes).
+func (r *Runtime) DeleteEntity(ctx context.Context, entityType string, entityID string) error {
+	return pg.DeleteEntity(ctx, r.pool, r.schema, entityType, entityID)
+}
@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-rails/searchkit/embedder"
+	"github.com/open-rails/searchkit/pg"
+)
+
+// EmbeddingCache stores provider outputs keyed by model and a hash of the exact
+// provider input (see pg.ContentHash), so identical documents across entities
+// reuse a vector. pg.EmbeddingCache is the Postgres implementation.
+//
+// The cache is best-effort: lookup and store errors are counted in
+// EmbeddingCacheStats.Errors and otherwise ignored.
+type EmbeddingCache interface {
+	GetEmbeddings(ctx context.Context, model string, hashes []string) (map[string][]float32, error)
+	PutEmbeddings(ctx context.Context, model string, entries map[string][]float32) error
+}
+
+var _ EmbeddingCache = (*pg.EmbeddingCache)(nil)
+
+// EmbeddingCacheStats counts embedding cache lookups since the runtime started.
+type EmbeddingCacheStats struct {
+	Hits   uint64
+	Misses uint64
+	Errors uint64
+}
+
+// HitRate returns Hits / (Hits + Misses), or 0 before any lookups.
+func (s EmbeddingCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type cacheCounters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// EmbeddingCacheStats returns embedding cache counters (zero when no cache is
+// configured).
+func (r *Runtime) EmbeddingCacheStats() EmbeddingCacheStats {
+	return EmbeddingCacheStats{
+		Hits:   r.cacheStats.hits.Load(),
+		Misses: r.cacheStats.misses.Load(),
+		Errors: r.cacheStats.errors.Load(),
+	}
+}
+
+// embedInputs returns raw provider vectors for inputs (aligned by index),
+// serving repeated inputs and cache hits without a provider call.
+func (r *Runtime) embedInputs(ctx context.Context, model string, emb embedder.Embedder, inputs []string) ([][]float32, error) {
+	hashes := make([]string, len(inputs))
+	for i, in := range inputs {
+		hashes[i] = pg.ContentHash(in)
+	}
+
+	found := map[string][]float32{}
+	if r.cache != nil && !isReembed(ctx) {
+		got, err := r.cache.GetEmbeddings(ctx, model, uniqueStrings(hashes))
+		if err != nil {
+			r.cacheStats.errors.Add(1)
+		} else {
+			found = got
+		}
+	}
+
+	// Embed each distinct missing input once.
+	var (
+		missHashes []string
+		missInputs []string
+	)
+	seen := make(map[string]struct{}, len(inputs))
+	for i, h := range hashes {
+		if _, ok := found[h]; ok {
+			continue
+		}
+		if _, ok := seen[h]; ok {
+			continue
+		}
+		seen[h] = struct{}{}
+		missHashes = append(missHashes, h)
+		missInputs = append(missInputs, inputs[i])
+	}
+	if r.cache != nil {
+		r.cacheStats.hits.Add(uint64(len(inputs) - len(missInputs)))
+		r.cacheStats.misses.Add(uint64(len(missInputs)))
+	}
+
+	if len(missInputs) > 0 {
+		var (
+			vecs [][]float32
+			err  error
+		)
+		var usage embedder.Usage
+		started := time.Now()
+		if len(missInputs) == 1 {
+			var vec []float32
+			vec, usage, err = embedder.EmbedTextWithUsage(ctx, emb, missInputs[0])
+			vecs = [][]float32{vec}
+		} else {
+			vecs, usage, err = embedder.EmbedTextsWithUsage(ctx, emb, missInputs)
+		}
+		r.metrics.ProviderCall(model, len(missInputs), time.Since(started), err)
+		r.tokensUsed(model, usage)
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != len(missInputs) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(missInputs), len(vecs))
+		}
+		fresh := make(map[string][]float32, len(vecs))
+		for k, vec := range vecs {
+			fresh[missHashes[k]] = vec
+			found[missHashes[k]] = vec
+		}
+		if r.cache != nil {
+			if err := r.cache.PutEmbeddings(ctx, model, fresh); err != nil {
+				r.cacheStats.errors.Add(1)
+			}
+		}
+	}
+
+	// Copy so that per-input post-processing (truncation, in-place
+	// normalization) never aliases a shared vector.
+	out := make([][]float32, len(inputs))
+	for i, h := range hashes {
+		out[i] = append([]float32(nil), found[h]...)
+	}
+	return out, nil
+}
+
+func uniqueStrings(in []string) []string {
+	seen := make(map[string]struct{}, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	return out
+}
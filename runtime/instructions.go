@@ -0,0 +1,164 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/open-rails/searchkit/embedder"
+	"github.com/open-rails/searchkit/internal/normalize"
+	"github.com/open-rails/searchkit/pg"
+)
+
+// Instructions are per-model templates applied to text before it is sent to the
+// provider. Instruction-tuned models (Qwen3-Embedding, E5, BGE, ...) expect
+// different prefixes for queries and documents, e.g. E5:
+//
+//	Instructions{Query: "query: ", Document: "passage: "}
+//
+// A template containing "{text}" has it replaced by the input; otherwise the
+// template is used as a prefix. "{language}" is replaced by the language code.
+// Empty templates leave text unchanged.
+type Instructions struct {
+	Query    string
+	Document string
+}
+
+const (
+	instructionText     = "{text}"
+	instructionLanguage = "{language}"
+)
+
+// retrievalTask is the web-search retrieval instruction used by the
+// instruct-style presets (E5-instruct, GTE-Qwen, Qwen3-Embedding).
+const retrievalTask = "Instruct: Given a web search query, retrieve relevant passages that answer the query\nQuery: "
+
+// instructionPresets are the query/document prefixes published with common
+// model families, selectable by name via Options.InstructionPresets.
+var instructionPresets = map[string]Instructions{
+	// intfloat/e5-* and multilingual-e5-*.
+	"e5": {Query: "query: ", Document: "passage: "},
+	// intfloat/e5-mistral-7b-instruct, multilingual-e5-large-instruct.
+	"e5-instruct": {Query: retrievalTask},
+	// Alibaba-NLP/gte-Qwen2-*-instruct.
+	"gte-instruct": {Query: retrievalTask},
+	// Qwen/Qwen3-Embedding-*.
+	"qwen3": {Query: retrievalTask},
+	// BAAI/bge-*-en-v1.5 (documents take no prefix).
+	"bge": {Query: "Represent this sentence for searching relevant passages: "},
+	// BAAI/bge-*-zh-v1.5.
+	"bge-zh": {Query: "为这个句子生成表示以用于检索相关文章："},
+	// nomic-ai/nomic-embed-text-*.
+	"nomic": {Query: "search_query: ", Document: "search_document: "},
+	// mixedbread-ai/mxbai-embed-large-v1.
+	"mxbai": {Query: "Represent this sentence for searching relevant passages: "},
+	// Snowflake/snowflake-arctic-embed-*.
+	"arctic": {Query: "Represent this sentence for searching relevant passages: "},
+}
+
+// InstructionPreset returns the built-in instructions named name (see
+// InstructionPresetNames).
+func InstructionPreset(name string) (Instructions, error) {
+	in, ok := instructionPresets[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return Instructions{}, fmt.Errorf("unknown instruction preset %q (known: %s)", name, strings.Join(InstructionPresetNames(), ", "))
+	}
+	return in, nil
+}
+
+// InstructionPresetNames returns the built-in preset names, sorted.
+func InstructionPresetNames() []string {
+	return slices.Sorted(maps.Keys(instructionPresets))
+}
+
+// presetPatterns map model-name substrings to presets, most specific first.
+var presetPatterns = []struct{ substr, preset string }{
+	{"e5-mistral", "e5-instruct"},
+	{"e5-large-instruct", "e5-instruct"},
+	{"e5-", "e5"},
+	{"gte-qwen", "gte-instruct"},
+	{"qwen3-embedding", "qwen3"},
+	{"qwen-3-embedding", "qwen3"},
+	{"bge-m3", ""}, // no instructions needed
+	{"-zh-v1.5", "bge-zh"},
+	{"bge-", "bge"},
+	{"nomic-embed-text", "nomic"},
+	{"mxbai-embed", "mxbai"},
+	{"arctic-embed", "arctic"},
+}
+
+// DetectInstructionPreset guesses the preset for a model (canonical or
+// provider) name, e.g. "intfloat/multilingual-e5-large" -> "e5". It returns ""
+// for models that take no prefixes or aren't recognized.
+func DetectInstructionPreset(model string) string {
+	m := strings.ToLower(model)
+	for _, p := range presetPatterns {
+		if strings.Contains(m, p.substr) {
+			return p.preset
+		}
+	}
+	return ""
+}
+
+func applyInstruction(tmpl string, language string, text string) string {
+	if tmpl == "" {
+		return text
+	}
+	if !strings.Contains(tmpl, instructionText) {
+		tmpl += instructionText
+	}
+	return strings.NewReplacer(instructionText, text, instructionLanguage, language).Replace(tmpl)
+}
+
+// documentInputs fits each chunk of an entity's document to model's token
+// limit and applies the document template, returning the provider inputs.
+func (r *Runtime) documentInputs(model string, key pg.EmbeddingKey, chunks []string) []string {
+	tmpl := r.instructions[model].Document
+	out := make([]string, len(chunks))
+	for i, c := range chunks {
+		c = r.fitTokens(model, tmpl, c, TruncationEvent{
+			EntityType: key.EntityType,
+			EntityID:   key.EntityID,
+			Language:   key.Language,
+			ChunkIdx:   i,
+		})
+		out[i] = applyInstruction(tmpl, key.Language, c)
+	}
+	return out
+}
+
+// EmbedQuery returns a vector ready for search.Query.QueryVec: the query text is
+// normalized the same way searchkit.Client does, the model's query instruction
+// template is applied, and the result is truncated (see Options.TruncateDims)
+// and normalized (see Options.Normalization).
+//
+// It returns a nil vector when the text has nothing to embed (e.g. only
+// punctuation); callers should treat that as "no semantic results".
+func (r *Runtime) EmbedQuery(ctx context.Context, model string, language string, text string) ([]float32, error) {
+	q := normalize.QueryForEmbedding(text)
+	if q == "" {
+		return nil, nil
+	}
+	return r.embedQuery(ctx, model, language, q)
+}
+
+func (r *Runtime) embedQuery(ctx context.Context, model string, language string, text string) ([]float32, error) {
+	model = r.CanonicalModel(model)
+	emb, ok := r.textEmbedders[model]
+	if !ok {
+		if vlEmb, ok := r.vlEmbedders[model]; ok {
+			return r.embedVLQuery(ctx, model, vlEmb, text)
+		}
+		return nil, fmt.Errorf("model %q is not configured for text embeddings", model)
+	}
+	tmpl := r.instructions[model].Query
+	text = r.fitTokens(model, tmpl, text, TruncationEvent{Language: language})
+	vec, usage, err := embedder.EmbedTextWithUsage(embedder.WithInputType(ctx, embedder.InputQuery), emb, applyInstruction(tmpl, language, text))
+	r.tokensUsed(model, usage)
+	if err != nil {
+		return nil, err
+	}
+	return r.finishVector(model, vec), nil
+}
@@ -0,0 +1,161 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+type memoryKey struct {
+	model string
+	key   pg.EmbeddingKey
+}
+
+type memoryEntry struct {
+	chunks [][]float32
+	sparse pgvector.SparseVector
+	assets []pg.AssetEmbedding
+	hash   string
+}
+
+// MemoryStorage is an in-process Storage, intended for tests.
+type MemoryStorage struct {
+	mu       sync.Mutex
+	entries  map[memoryKey]memoryEntry
+	captions map[pg.EmbeddingKey][]pg.AssetCaption
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{entries: map[memoryKey]memoryEntry{}, captions: map[pg.EmbeddingKey][]pg.AssetCaption{}}
+}
+
+func (s *MemoryStorage) UpsertTextEmbeddingChunks(ctx context.Context, entityType string, entityID string, model string, language string, dim int, chunks [][]float32, contentHash string) error {
+	cp := make([][]float32, len(chunks))
+	for i, c := range chunks {
+		cp[i] = append([]float32(nil), c...)
+	}
+	k := memoryKey{model: model, key: pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: language}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Asset vectors live in their own table in Postgres; keep them.
+	s.entries[k] = memoryEntry{chunks: cp, assets: s.entries[k].assets, hash: contentHash}
+	return nil
+}
+
+func (s *MemoryStorage) UpsertSparseEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, vec pgvector.SparseVector, contentHash string) error {
+	k := memoryKey{model: model, key: pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: language}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[k] = memoryEntry{sparse: vec, hash: contentHash}
+	return nil
+}
+
+// UpsertHybridEmbedding stores an entity's dense chunks and sparse vector
+// together (asset vectors are kept).
+func (s *MemoryStorage) UpsertHybridEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, chunks [][]float32, sparse pgvector.SparseVector, contentHash string) error {
+	if err := s.UpsertTextEmbeddingChunks(ctx, entityType, entityID, model, language, len(chunks[0]), chunks, contentHash); err != nil {
+		return err
+	}
+	k := memoryKey{model: model, key: pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: language}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[k]
+	e.sparse = sparse
+	s.entries[k] = e
+	return nil
+}
+
+// UpsertVLEmbeddingAssets replaces an entity's asset vectors (the fused
+// vector and hash are kept).
+func (s *MemoryStorage) UpsertVLEmbeddingAssets(ctx context.Context, entityType string, entityID string, model string, language string, assets []pg.AssetEmbedding) error {
+	cp := make([]pg.AssetEmbedding, len(assets))
+	for i, a := range assets {
+		a.Vector = append([]float32(nil), a.Vector...)
+		cp[i] = a
+	}
+	k := memoryKey{model: model, key: pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: language}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[k]
+	e.assets = cp
+	s.entries[k] = e
+	return nil
+}
+
+// UpsertVLEmbedding stores an entity's fused VL vector as its single chunk
+// (asset vectors are kept).
+func (s *MemoryStorage) UpsertVLEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, embedding []float32, contentHash string) error {
+	return s.UpsertTextEmbeddingChunks(ctx, entityType, entityID, model, language, len(embedding), [][]float32{embedding}, contentHash)
+}
+
+// VLContentHashes is ContentHashes; model names keep text and VL entries apart.
+func (s *MemoryStorage) VLContentHashes(ctx context.Context, model string, keys []pg.EmbeddingKey) (map[pg.EmbeddingKey]string, error) {
+	return s.ContentHashes(ctx, model, keys)
+}
+
+func (s *MemoryStorage) AssetCaptions(ctx context.Context, entityType string, language string, entityIDs []string) (map[string][]pg.AssetCaption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := map[string][]pg.AssetCaption{}
+	for _, id := range entityIDs {
+		if c, ok := s.captions[pg.EmbeddingKey{EntityType: entityType, EntityID: id, Language: language}]; ok {
+			out[id] = append([]pg.AssetCaption(nil), c...)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStorage) ReplaceAssetCaptions(ctx context.Context, entityType string, entityID string, language string, captions []pg.AssetCaption) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.captions[pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: language}] = append([]pg.AssetCaption(nil), captions...)
+	return nil
+}
+
+func (s *MemoryStorage) ContentHashes(ctx context.Context, model string, keys []pg.EmbeddingKey) (map[pg.EmbeddingKey]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[pg.EmbeddingKey]string, len(keys))
+	for _, k := range keys {
+		if e, ok := s.entries[memoryKey{model: model, key: k}]; ok && e.hash != "" {
+			out[k] = e.hash
+		}
+	}
+	return out, nil
+}
+
+// Vectors returns the stored chunk vectors for an entity, or nil.
+func (s *MemoryStorage) Vectors(model string, key pg.EmbeddingKey) [][]float32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[memoryKey{model: model, key: key}].chunks
+}
+
+// SparseVector returns the stored sparse vector for an entity (zero value when
+// none).
+func (s *MemoryStorage) SparseVector(model string, key pg.EmbeddingKey) pgvector.SparseVector {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[memoryKey{model: model, key: key}].sparse
+}
+
+// Len returns the number of stored entity+model+language entries.
+func (s *MemoryStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Assets returns the stored asset vectors for an entity, or nil.
+func (s *MemoryStorage) Assets(model string, key pg.EmbeddingKey) []pg.AssetEmbedding {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[memoryKey{model: model, key: key}].assets
+}
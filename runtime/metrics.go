@@ -0,0 +1,51 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/open-rails/searchkit/embedder"
+)
+
+// MetricsSink receives runtime events so hosts can export searchkit throughput
+// to their own metrics system. Implementations must be safe for concurrent use
+// and should return quickly. Embed NopMetricsSink to implement a subset.
+type MetricsSink interface {
+	// ProviderCall is reported after each embedding provider request.
+	ProviderCall(model string, inputs int, latency time.Duration, err error)
+	// EmbeddingsGenerated counts entities whose embedding was generated and
+	// stored (including cache hits; excluding unchanged documents).
+	EmbeddingsGenerated(model string, count int)
+	// VectorsUpserted is reported after each entity's vectors are written.
+	VectorsUpserted(model string, chunks int, latency time.Duration, err error)
+	// NotFound counts tasks completed because the entity no longer exists.
+	NotFound(model string)
+}
+
+// TokenUsageSink is optionally implemented by a MetricsSink to receive the
+// provider-reported prompt tokens of each embedding call, for spend tracking
+// (providers that do not report usage are not counted).
+type TokenUsageSink interface {
+	TokensUsed(model string, promptTokens int)
+}
+
+// NopMetricsSink ignores all events.
+type NopMetricsSink struct{}
+
+func (NopMetricsSink) ProviderCall(string, int, time.Duration, error)    {}
+func (NopMetricsSink) EmbeddingsGenerated(string, int)                   {}
+func (NopMetricsSink) VectorsUpserted(string, int, time.Duration, error) {}
+func (NopMetricsSink) NotFound(string)                                   {}
+func (NopMetricsSink) TokensUsed(string, int)                            {}
+
+// tokensUsed reports provider token usage to sinks implementing TokenUsageSink.
+func (r *Runtime) tokensUsed(model string, u embedder.Usage) {
+	if s, ok := r.metrics.(TokenUsageSink); ok && u.PromptTokens > 0 {
+		s.TokensUsed(model, u.PromptTokens)
+	}
+}
+
+// Metrics returns the configured MetricsSink (a no-op sink when none is set).
+// Worker implementations use it to report task-level events.
+func (r *Runtime) Metrics() MetricsSink {
+	return r.metrics
+}
@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/vl"
+)
+
+// ExtractAssetText extracts text (OCR) from entities' assets, e.g. scanned
+// pages or typeset panels, so that content becomes searchable. assets holds
+// each existing entity's ListAssetURLs result; the returned map holds text per
+// entity ID (entities without text may be omitted). Hosts call their OCR
+// provider here and should cache results per asset, since documents are
+// rebuilt on every (re-)embed.
+type ExtractAssetText func(ctx context.Context, entityType string, language string, assets map[string][]vl.AssetURL) (map[string]string, error)
+
+// entityAssetText is the text derived from one entity's assets.
+type entityAssetText struct {
+	ocr      string
+	captions string
+}
+
+// assetText runs the OCR and caption hooks for the entities in ids (nil when
+// neither is configured), listing their assets once.
+func (r *Runtime) assetText(ctx context.Context, entityType string, language string, ids []string) (map[string]entityAssetText, error) {
+	if (r.extractText == nil && r.captionAssets == nil) || len(ids) == 0 {
+		return nil, nil
+	}
+	assets, err := r.listAssetURLs(ctx, entityType, ids)
+	if err != nil {
+		return nil, fmt.Errorf("ListAssetURLs: %w", err)
+	}
+	if len(assets) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]entityAssetText, len(assets))
+	if r.extractText != nil {
+		text, err := r.extractText(ctx, entityType, language, assets)
+		if err != nil {
+			return nil, fmt.Errorf("ExtractAssetText: %w", err)
+		}
+		for id, t := range text {
+			e := out[id]
+			e.ocr = strings.TrimSpace(t)
+			out[id] = e
+		}
+	}
+	if r.captionAssets != nil {
+		captions, err := r.captions(ctx, entityType, language, assets)
+		if err != nil {
+			return nil, err
+		}
+		for id, c := range captions {
+			e := out[id]
+			e.captions = c
+			out[id] = e
+		}
+	}
+	return out, nil
+}
+
+// appendAssetText appends each entity's asset text to its plain document.
+// Captions also stand in for an empty document, so image-only entities become
+// searchable; OCR text alone does not.
+func appendAssetText(docs map[string]string, text map[string]entityAssetText) {
+	for id, t := range text {
+		doc, ok := docs[id]
+		if !ok {
+			continue
+		}
+		if strings.TrimSpace(doc) == "" {
+			if t.captions != "" {
+				docs[id] = t.captions
+			}
+			continue
+		}
+		docs[id] = joinLines(doc, t.ocr, t.captions)
+	}
+}
+
+// joinLines joins the non-empty parts with newlines.
+func joinLines(parts ...string) string {
+	kept := parts[:0:0]
+	for _, p := range parts {
+		if strings.TrimSpace(p) != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
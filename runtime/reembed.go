@@ -0,0 +1,103 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReasonReembed is the task reason used by Reembed. Workers run such tasks with
+// WithReembed so the content-hash and cache checks are bypassed.
+const ReasonReembed = "reembed"
+
+// ReembedPriority is the task priority used by Reembed; tasks with a higher
+// priority are leased before older ordinary tasks.
+const ReembedPriority = 100
+
+type reembedKey struct{}
+
+// WithReembed makes GenerateAndStore* calls with ctx re-embed even when the
+// stored content hash matches, and skip embedding-cache reads.
+func WithReembed(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reembedKey{}, true)
+}
+
+func isReembed(ctx context.Context) bool {
+	v, _ := ctx.Value(reembedKey{}).(bool)
+	return v
+}
+
+// Reembed enqueues high-priority tasks that re-embed an entity for every active
+// model and the given languages, bypassing the unchanged-document check (e.g.
+// for support tooling when an item's results look stale). Language-agnostic
+// models are re-embedded once.
+func (r *Runtime) Reembed(ctx context.Context, entityType string, entityID string, languages ...string) error {
+	languages, err := reembedLanguages(entityType, entityID, languages)
+	if err != nil {
+		return err
+	}
+	seen := make(map[[2]string]struct{})
+	for _, l := range languages {
+		for _, model := range r.ActiveModels() {
+			lang := r.EmbeddingLanguage(model, l)
+			if _, ok := seen[[2]string{model, lang}]; ok {
+				continue
+			}
+			seen[[2]string{model, lang}] = struct{}{}
+			if err := r.taskRepo.EnqueuePriority(ctx, entityType, entityID, model, lang, ReasonReembed, ReembedPriority); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReembedNow re-embeds an entity synchronously for every active model and the
+// given languages, bypassing the unchanged-document check. Models that fail are
+// enqueued as Reembed tasks and reported in the returned (joined) error.
+func (r *Runtime) ReembedNow(ctx context.Context, entityType string, entityID string, languages ...string) error {
+	languages, err := reembedLanguages(entityType, entityID, languages)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	seen := make(map[[2]string]struct{})
+	for _, l := range languages {
+		for _, model := range r.ActiveModels() {
+			lang := r.EmbeddingLanguage(model, l)
+			if _, ok := seen[[2]string{model, lang}]; ok {
+				continue
+			}
+			seen[[2]string{model, lang}] = struct{}{}
+			err := r.GenerateAndStoreEmbedding(WithReembed(ctx), entityType, entityID, model, lang)
+			if err == nil {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("model %q language %q: %w", model, lang, err))
+			if errors.Is(err, ErrEntityNotFound) {
+				continue
+			}
+			if err := r.taskRepo.EnqueuePriority(ctx, entityType, entityID, model, lang, ReasonReembed, ReembedPriority); err != nil {
+				return errors.Join(append(errs, err)...)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func reembedLanguages(entityType string, entityID string, languages []string) ([]string, error) {
+	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" {
+		return nil, fmt.Errorf("entityType and entityID are required")
+	}
+	out := make([]string, 0, len(languages))
+	for _, l := range languages {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one language is required")
+	}
+	return out, nil
+}
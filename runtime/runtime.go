@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -36,15 +39,50 @@ type BuildSemanticDocument func(ctx context.Context, entityType string, language
 type BuildLexicalString func(ctx context.Context, entityType string, language string, entityIDs []string) (map[string]string, error)
 
 type Runtime struct {
-	textEmbedders map[string]embedder.Embedder
-	vlEmbedders   map[string]vl.Embedder
+	pool   *pgxpool.Pool
+	schema string
+
+	textEmbedders   map[string]embedder.Embedder
+	vlEmbedders     map[string]vl.Embedder
+	sparseEmbedders map[string]embedder.SparseEmbedder
+	hybridSparse    map[string]embedder.SparseEmbedder
 
 	taskRepo *tasks.Repo
-	storage  *pg.PostgresStorage
+	storage  Storage
+
+	buildSemantic   BuildSemanticDocument
+	buildStructured BuildStructuredDocument
+	buildLexical    BuildLexicalString
+	listAssetURLs   vl.ListAssetURLs
+	extractText     ExtractAssetText
+	captionAssets   CaptionAssets
+	lexicalText     bool
+
+	chunking          map[string]ChunkOptions
+	instructions      map[string]Instructions
+	documentTemplates map[string]DocumentTemplate
+	truncateDims      map[string]int
+	storageModes      map[string]pg.StorageMode
+	aliases           map[string]string // alias -> canonical model
+
+	cache      EmbeddingCache
+	cacheStats cacheCounters
+
+	tokenLimits map[string]TokenLimit
+	onTruncate  func(TruncationEvent)
+
+	metrics MetricsSink
+
+	shadow      map[string]struct{}
+	anyLanguage map[string]struct{}
+
+	normalization map[string]pg.Normalization
 
-	buildSemantic BuildSemanticDocument
-	buildLexical  BuildLexicalString
-	listAssetURLs vl.ListAssetURLs
+	vlAssets      map[string]struct{}
+	framePolicies map[string]vl.FramePolicy
+	assetLimits   map[string]vl.AssetSelection
+	assetKinds    map[string][]vl.AssetKind
+	segments      map[string]vl.SegmentPolicy
 }
 
 type Options struct {
@@ -56,9 +94,31 @@ type Options struct {
 	TextEmbedders []embedder.Embedder
 	VLEmbedders   []vl.Embedder
 
-	// Required.
+	// Optional: learned sparse models (SPLADE, BM42) embedded from the same
+	// semantic documents and stored as sparsevec (pg.StorageSparse). Search them
+	// with search.SparseSearch and EmbedQuerySparse. Instructions,
+	// DocumentTemplates, TokenLimits, ShadowModels and LanguageAgnosticModels
+	// apply to them; the dense-only options do not.
+	SparseEmbedders []embedder.SparseEmbedder
+
+	// Optional: hybrid dense+sparse models (e.g. BGE-M3), keyed by text model.
+	// Each entity also gets the sparse embedder's vector of the same document,
+	// stored next to its dense vectors instead of under a separate sparse model
+	// (see pg.ModelSpec.SparseDims). Search them with search.HybridSearch,
+	// EmbedQuery and EmbedQuerySparse. The text model's Instructions and
+	// TokenLimits apply to the sparse inputs too; Storage must implement
+	// HybridStorage.
+	HybridSparseEmbedders map[string]embedder.SparseEmbedder
+
+	// Required (or BuildStructuredDocument).
 	BuildSemanticDocument BuildSemanticDocument
 
+	// Optional: structured alternative to BuildSemanticDocument; documents are
+	// rendered per model with DocumentTemplates (default
+	// DefaultDocumentTemplate).
+	BuildStructuredDocument BuildStructuredDocument
+	DocumentTemplates       map[string]DocumentTemplate
+
 	// Optional: only needed if you want searchkit-managed lexical (trigram)
 	// document storage/backfill.
 	BuildLexicalString BuildLexicalString
@@ -66,9 +126,131 @@ type Options struct {
 	// Required if VLEmbedders is non-empty.
 	ListAssetURLs vl.ListAssetURLs
 
+	// Optional: OCR hook whose text is appended to every semantic document
+	// (structured documents: SemanticDocument.AssetText), and to lexical
+	// documents when AssetTextInLexical is set. Requires ListAssetURLs.
+	ExtractAssetText ExtractAssetText
+	// Optional: captioning hook; captions are stored per asset (Storage must
+	// implement CaptionStorage) and added to documents like ExtractAssetText
+	// text, also standing in for empty documents of image-only entities.
+	// Requires ListAssetURLs.
+	CaptionAssets      CaptionAssets
+	AssetTextInLexical bool
+
+	// Optional: VL models that also store one vector per asset
+	// (embedding_vector_assets) so search.AssetSearch can report which
+	// page/image matched. Their embedders must implement
+	// vl.AssetVectorEmbedder and Storage must implement VLAssetStorage.
+	VLAssetModels []string
+
+	// Optional: sample video assets into frame URLs before embedding for these
+	// VL models (keyed by model name), instead of the host pre-sampling frames
+	// in ListAssetURLs. See vl.FramePolicy.
+	FrameSampling map[string]vl.FramePolicy
+	// Optional: cap and prioritize the assets embedded per entity for VL
+	// models (keyed by model name), applied after frame sampling. See
+	// vl.AssetSelection.
+	AssetSelection map[string]vl.AssetSelection
+	// Optional: the asset kinds each VL model accepts (keyed by model name),
+	// e.g. images and frames only for an image-only provider. Other assets are
+	// dropped before embedding; an entity left without assets fails with
+	// vl.ErrUnsupportedAssetKind. Defaults to the embedder's own kinds
+	// (vl.AssetKindsEmbedder), else all kinds.
+	AssetKinds map[string][]vl.AssetKind
+	// Optional: split long videos into time segments for these VL models
+	// (keyed by model name) and store one vector per segment, with its
+	// offsets, in embedding_vector_assets next to the entity vector, so
+	// search.AssetSearch can jump to the matching moment. Storage must
+	// implement VLAssetStorage. See vl.SegmentPolicy.
+	VideoSegments map[string]vl.SegmentPolicy
+
+	// Optional: split long semantic documents into chunks for these text models
+	// (keyed by model name). Search with search.Options.ChunkAggregate to rank
+	// entities by their chunk similarities.
+	Chunking map[string]ChunkOptions
+
+	// Optional: query/document instruction templates for text models (keyed by
+	// model name).
+	Instructions map[string]Instructions
+	// Optional: built-in instruction presets by name for text models (keyed by
+	// model name), e.g. {"multilingual-e5-large": "e5"}; see
+	// InstructionPresetNames. Instructions entries take precedence.
+	InstructionPresets map[string]string
+	// Optional: apply DetectInstructionPreset to text models that have neither
+	// Instructions nor InstructionPresets, so e5/bge/gte/... models don't
+	// silently run without their required prefixes.
+	AutoInstructionPresets bool
+
+	// Optional: Matryoshka truncation per model (keyed by model name). Vectors
+	// are cut to the first N dims and re-normalized before storage and at query
+	// time; indexes are built at N dims. Only for MRL-trained models.
+	TruncateDims map[string]int
+
+	// Optional: vector normalization per model (keyed by model name; defaults
+	// to pg.NormalizeL2). It is applied to stored and query vectors alike and
+	// recorded in embedding_models. Changing it re-embeds documents.
+	Normalization map[string]pg.Normalization
+
+	// Optional: vector storage representation per model (keyed by model name;
+	// defaults to pg.StorageHalfvec). Use pg.StorageVector for models sensitive
+	// to fp16 precision; VL models support only those two, and their
+	// per-asset vectors stay halfvec. Search resolves the mode from
+	// embedding_models.
+	StorageModes map[string]pg.StorageMode
+
+	// Optional: maps alternate model names to the canonical model name that
+	// vectors and tasks are stored under (alias -> canonical). Use it to rename a
+	// model, or to follow a provider model id change, without orphaning vectors:
+	// e.g. {"text-embedding-3-small-v2": "text-embedding-3-small"}. Embedders
+	// whose Model() is an alias are registered under the canonical name, and
+	// other per-model options may be keyed by either name.
+	ModelAliases map[string]string
+
+	// Optional: per-model input token limits (keyed by model name). Inputs over
+	// the limit are truncated before the provider call and reported to
+	// OnTruncate.
+	TokenLimits map[string]TokenLimit
+	OnTruncate  func(TruncationEvent)
+
+	// Optional: candidate models that are embedded and stored like any other
+	// but excluded from search unless a query opts in (IncludeShadow). Use it to
+	// compare a new model against production before switching over.
+	ShadowModels []string
+
+	// Optional: multilingual models that embed each entity once under
+	// pg.AnyLanguage instead of once per language. The semantic document
+	// builder is called with language "*" for them.
+	LanguageAgnosticModels []string
+
+	// Optional: what NewWithContext does with registered models missing from
+	// this config (default pg.PruneMarkInactive; see pg.PruneInactiveModels).
+	PruneMode pg.PruneMode
+
+	// Optional: NewWithContext runs Validate before registering models, so a
+	// changed embedder dimension fails startup instead of search queries.
+	ValidateModels bool
+
+	// Optional: NewWithContext runs ProbeDimensions before registering models,
+	// embedding a tiny input per model so a misconfigured Dimensions() fails
+	// startup instead of storing wrong-width vectors. Costs one provider call per
+	// model.
+	ProbeDimensions bool
+	// Optional: NewWithContext runs ProbeVL with this public image URL before
+	// registering models, so VL credentials, dimensions and the provider's
+	// ability to fetch URLs are checked at startup rather than by failing
+	// tasks. Costs one provider call per VL model.
+	VLProbeImageURL string
+
+	// Optional: receives embedding/provider/storage events.
+	Metrics MetricsSink
+
+	// Optional: reuse vectors across entities with identical provider inputs
+	// (e.g. pg.NewEmbeddingCache(pool, schema)).
+	EmbeddingCache EmbeddingCache
+
 	// Optional overrides (primarily for tests).
 	TaskRepo *tasks.Repo
-	Storage  *pg.PostgresStorage
+	Storage  Storage
 }
 
 func New(opts Options) (*Runtime, error) {
@@ -80,14 +262,35 @@ func New(opts Options) (*Runtime, error) {
 	}
 	// Embedders are optional: hosts may want lexical-only operation (FTS/trigram/PGroonga)
 	// while deferring semantic embeddings until a provider is configured/available.
-	hasEmbedders := len(opts.TextEmbedders) > 0 || len(opts.VLEmbedders) > 0
-	if hasEmbedders && opts.BuildSemanticDocument == nil {
+	hasEmbedders := len(opts.TextEmbedders) > 0 || len(opts.VLEmbedders) > 0 || len(opts.SparseEmbedders) > 0
+	if hasEmbedders && opts.BuildSemanticDocument == nil && opts.BuildStructuredDocument == nil {
 		return nil, fmt.Errorf("BuildSemanticDocument is required when embedders are configured")
 	}
+	if opts.BuildSemanticDocument != nil && opts.BuildStructuredDocument != nil {
+		return nil, fmt.Errorf("set only one of BuildSemanticDocument and BuildStructuredDocument")
+	}
 	if !hasEmbedders && opts.BuildLexicalString == nil {
 		return nil, fmt.Errorf("at least one embedder or BuildLexicalString is required")
 	}
 
+	aliases := make(map[string]string, len(opts.ModelAliases))
+	for alias, canonical := range opts.ModelAliases {
+		alias, canonical = strings.TrimSpace(alias), strings.TrimSpace(canonical)
+		if alias == "" || canonical == "" {
+			return nil, fmt.Errorf("ModelAliases entries must have non-empty names")
+		}
+		if alias != canonical {
+			aliases[alias] = canonical
+		}
+	}
+	canonical := func(model string) string {
+		model = strings.TrimSpace(model)
+		if c, ok := aliases[model]; ok {
+			return c
+		}
+		return model
+	}
+
 	textMap := make(map[string]embedder.Embedder, len(opts.TextEmbedders))
 	for _, e := range opts.TextEmbedders {
 		if e == nil {
@@ -97,7 +300,7 @@ func New(opts Options) (*Runtime, error) {
 		if m == "" {
 			return nil, fmt.Errorf("text embedder has empty model name")
 		}
-		textMap[m] = e
+		textMap[canonical(m)] = e
 	}
 
 	vlMap := make(map[string]vl.Embedder, len(opts.VLEmbedders))
@@ -109,12 +312,299 @@ func New(opts Options) (*Runtime, error) {
 		if m == "" {
 			return nil, fmt.Errorf("vl embedder has empty model name")
 		}
-		vlMap[m] = e
+		vlMap[canonical(m)] = e
 	}
 
 	if len(vlMap) > 0 && opts.ListAssetURLs == nil {
 		return nil, fmt.Errorf("vl embedder provided but ListAssetURLs missing")
 	}
+	if opts.ExtractAssetText != nil && opts.ListAssetURLs == nil {
+		return nil, fmt.Errorf("ExtractAssetText requires ListAssetURLs")
+	}
+	if opts.CaptionAssets != nil && opts.ListAssetURLs == nil {
+		return nil, fmt.Errorf("CaptionAssets requires ListAssetURLs")
+	}
+
+	sparseMap := make(map[string]embedder.SparseEmbedder, len(opts.SparseEmbedders))
+	for _, e := range opts.SparseEmbedders {
+		if e == nil {
+			continue
+		}
+		m := strings.TrimSpace(e.Model())
+		if m == "" {
+			return nil, fmt.Errorf("sparse embedder has empty model name")
+		}
+		m = canonical(m)
+		_, isText := textMap[m]
+		_, isVL := vlMap[m]
+		if isText || isVL {
+			return nil, fmt.Errorf("model %q is configured as both a sparse and a dense embedder", m)
+		}
+		sparseMap[m] = e
+	}
+
+	hybridMap := make(map[string]embedder.SparseEmbedder, len(opts.HybridSparseEmbedders))
+	for model, e := range opts.HybridSparseEmbedders {
+		model = canonical(model)
+		if _, ok := textMap[model]; !ok {
+			return nil, fmt.Errorf("HybridSparseEmbedders configured for model %q which is not a text embedder", model)
+		}
+		if e == nil {
+			return nil, fmt.Errorf("HybridSparseEmbedders for model %q is nil", model)
+		}
+		if e.Dimensions() <= 0 {
+			return nil, fmt.Errorf("HybridSparseEmbedders for model %q: sparse embedder has no dimensions", model)
+		}
+		hybridMap[model] = e
+	}
+
+	for alias := range aliases {
+		_, isText := textMap[alias]
+		_, isVL := vlMap[alias]
+		_, isSparse := sparseMap[alias]
+		if isText || isVL || isSparse {
+			return nil, fmt.Errorf("alias %q is also a configured model name", alias)
+		}
+	}
+
+	chunking := make(map[string]ChunkOptions, len(opts.Chunking))
+	for model, co := range opts.Chunking {
+		model = canonical(model)
+		if _, ok := textMap[model]; !ok {
+			return nil, fmt.Errorf("chunking configured for model %q which is not a text embedder", model)
+		}
+		if err := co.withDefaults().validate(); err != nil {
+			return nil, fmt.Errorf("chunking for model %q: %w", model, err)
+		}
+		chunking[model] = co
+	}
+
+	instructions := make(map[string]Instructions, len(opts.Instructions))
+	for model, in := range opts.Instructions {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isSparse := sparseMap[model]
+		if !isText && !isSparse {
+			return nil, fmt.Errorf("instructions configured for model %q which is not a text embedder", model)
+		}
+		instructions[model] = in
+	}
+	for model, name := range opts.InstructionPresets {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isSparse := sparseMap[model]
+		if !isText && !isSparse {
+			return nil, fmt.Errorf("instruction preset configured for model %q which is not a text embedder", model)
+		}
+		in, err := InstructionPreset(name)
+		if err != nil {
+			return nil, fmt.Errorf("model %q: %w", model, err)
+		}
+		if _, ok := instructions[model]; !ok {
+			instructions[model] = in
+		}
+	}
+	if opts.AutoInstructionPresets {
+		for model := range textMap {
+			if _, ok := instructions[model]; ok {
+				continue
+			}
+			names := []string{model}
+			for alias, c := range aliases {
+				if c == model {
+					names = append(names, alias)
+				}
+			}
+			for _, n := range names {
+				if name := DetectInstructionPreset(n); name != "" {
+					instructions[model] = instructionPresets[name]
+					break
+				}
+			}
+		}
+	}
+
+	documentTemplates := make(map[string]DocumentTemplate, len(opts.DocumentTemplates))
+	for model, t := range opts.DocumentTemplates {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isVL := vlMap[model]
+		_, isSparse := sparseMap[model]
+		if !isText && !isVL && !isSparse {
+			return nil, fmt.Errorf("DocumentTemplates configured for unknown model %q", model)
+		}
+		documentTemplates[model] = t
+	}
+
+	truncateDims := make(map[string]int, len(opts.TruncateDims))
+	for model, d := range opts.TruncateDims {
+		model = canonical(model)
+		var full int
+		if e, ok := textMap[model]; ok {
+			full = e.Dimensions()
+		} else if e, ok := vlMap[model]; ok {
+			full = e.Dimensions()
+		} else {
+			return nil, fmt.Errorf("TruncateDims configured for unknown model %q", model)
+		}
+		if d <= 0 || d > full {
+			return nil, fmt.Errorf("TruncateDims for model %q must be in [1..%d]", model, full)
+		}
+		if d < full {
+			truncateDims[model] = d
+		}
+	}
+
+	if err := opts.PruneMode.Validate(); err != nil {
+		return nil, err
+	}
+
+	storageModes := make(map[string]pg.StorageMode, len(opts.StorageModes))
+	for model, mode := range opts.StorageModes {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isVL := vlMap[model]
+		if !isText && !isVL {
+			return nil, fmt.Errorf("StorageModes configured for unknown model %q", model)
+		}
+		if err := mode.Validate(); err != nil {
+			return nil, fmt.Errorf("model %q: %w", model, err)
+		}
+		if isVL && mode.OrDefault() != pg.StorageHalfvec && mode.OrDefault() != pg.StorageVector {
+			return nil, fmt.Errorf("model %q: vl vectors are stored as %s or %s", model, pg.StorageHalfvec, pg.StorageVector)
+		}
+		if _, ok := hybridMap[model]; ok && mode.OrDefault() != pg.StorageHalfvec && mode.OrDefault() != pg.StorageVector {
+			return nil, fmt.Errorf("model %q: hybrid models store dense vectors as %s or %s", model, pg.StorageHalfvec, pg.StorageVector)
+		}
+		storageModes[model] = mode.OrDefault()
+	}
+
+	normalization := make(map[string]pg.Normalization, len(opts.Normalization))
+	for model, n := range opts.Normalization {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isVL := vlMap[model]
+		if !isText && !isVL {
+			return nil, fmt.Errorf("Normalization configured for unknown model %q", model)
+		}
+		if err := n.Validate(); err != nil {
+			return nil, fmt.Errorf("model %q: %w", model, err)
+		}
+		normalization[model] = n.OrDefault()
+	}
+
+	tokenLimits := make(map[string]TokenLimit, len(opts.TokenLimits))
+	for model, l := range opts.TokenLimits {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isSparse := sparseMap[model]
+		if !isText && !isSparse {
+			return nil, fmt.Errorf("TokenLimits configured for model %q which is not a text embedder", model)
+		}
+		l = l.withDefaults()
+		if err := l.validate(); err != nil {
+			return nil, fmt.Errorf("TokenLimits for model %q: %w", model, err)
+		}
+		tokenLimits[model] = l
+	}
+
+	shadow := make(map[string]struct{}, len(opts.ShadowModels))
+	for _, model := range opts.ShadowModels {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isVL := vlMap[model]
+		_, isSparse := sparseMap[model]
+		if !isText && !isVL && !isSparse {
+			return nil, fmt.Errorf("ShadowModels contains unknown model %q", model)
+		}
+		shadow[model] = struct{}{}
+	}
+
+	anyLanguage := make(map[string]struct{}, len(opts.LanguageAgnosticModels))
+	for _, model := range opts.LanguageAgnosticModels {
+		model = canonical(model)
+		_, isText := textMap[model]
+		_, isVL := vlMap[model]
+		_, isSparse := sparseMap[model]
+		if !isText && !isVL && !isSparse {
+			return nil, fmt.Errorf("LanguageAgnosticModels contains unknown model %q", model)
+		}
+		anyLanguage[model] = struct{}{}
+	}
+
+	vlAssets := make(map[string]struct{}, len(opts.VLAssetModels))
+	for _, model := range opts.VLAssetModels {
+		model = canonical(model)
+		e, ok := vlMap[model]
+		if !ok {
+			return nil, fmt.Errorf("VLAssetModels contains model %q which is not a vl embedder", model)
+		}
+		if _, ok := e.(vl.AssetVectorEmbedder); !ok {
+			return nil, fmt.Errorf("vl embedder for model %q does not implement vl.AssetVectorEmbedder", model)
+		}
+		vlAssets[model] = struct{}{}
+	}
+
+	framePolicies := make(map[string]vl.FramePolicy, len(opts.FrameSampling))
+	for model, p := range opts.FrameSampling {
+		model = canonical(model)
+		if _, ok := vlMap[model]; !ok {
+			return nil, fmt.Errorf("FrameSampling contains model %q which is not a vl embedder", model)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("FrameSampling for model %q: %w", model, err)
+		}
+		framePolicies[model] = p
+	}
+
+	assetLimits := make(map[string]vl.AssetSelection, len(opts.AssetSelection))
+	for model, sel := range opts.AssetSelection {
+		model = canonical(model)
+		if _, ok := vlMap[model]; !ok {
+			return nil, fmt.Errorf("AssetSelection contains model %q which is not a vl embedder", model)
+		}
+		if err := sel.Validate(); err != nil {
+			return nil, fmt.Errorf("AssetSelection for model %q: %w", model, err)
+		}
+		assetLimits[model] = sel
+	}
+
+	assetKinds := make(map[string][]vl.AssetKind, len(opts.AssetKinds))
+	for model, e := range vlMap {
+		if k, ok := e.(vl.AssetKindsEmbedder); ok {
+			assetKinds[model] = k.AssetKinds()
+		}
+	}
+	for model, kinds := range opts.AssetKinds {
+		model = canonical(model)
+		if _, ok := vlMap[model]; !ok {
+			return nil, fmt.Errorf("AssetKinds contains model %q which is not a vl embedder", model)
+		}
+		if err := vl.ValidateKinds(kinds); err != nil {
+			return nil, fmt.Errorf("AssetKinds for model %q: %w", model, err)
+		}
+		assetKinds[model] = kinds
+	}
+
+	segments := make(map[string]vl.SegmentPolicy, len(opts.VideoSegments))
+	for model, p := range opts.VideoSegments {
+		model = canonical(model)
+		if _, ok := vlMap[model]; !ok {
+			return nil, fmt.Errorf("VideoSegments contains model %q which is not a vl embedder", model)
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("VideoSegments for model %q: %w", model, err)
+		}
+		if kinds := assetKinds[model]; p.ClipURL != nil && len(kinds) > 0 && !slices.Contains(kinds, vl.AssetKindVideo) {
+			return nil, fmt.Errorf("VideoSegments for model %q: ClipURL is set but the model does not accept video assets", model)
+		}
+		segments[model] = p
+	}
+
+	metrics := opts.Metrics
+	if metrics == nil {
+		metrics = NopMetricsSink{}
+	}
 
 	repo := opts.TaskRepo
 	if repo == nil {
@@ -124,15 +614,77 @@ func New(opts Options) (*Runtime, error) {
 	if store == nil {
 		store = pg.NewPostgresStorage(opts.Pool, opts.Schema)
 	}
+	if len(sparseMap) > 0 {
+		if _, ok := store.(SparseStorage); !ok {
+			return nil, fmt.Errorf("sparse embedders configured but Storage does not implement SparseStorage")
+		}
+		for model := range sparseMap {
+			storageModes[model] = pg.StorageSparse
+		}
+	}
+	if len(hybridMap) > 0 {
+		if _, ok := store.(HybridStorage); !ok {
+			return nil, fmt.Errorf("hybrid sparse embedders configured but Storage does not implement HybridStorage")
+		}
+	}
+	if opts.CaptionAssets != nil {
+		if _, ok := store.(CaptionStorage); !ok {
+			return nil, fmt.Errorf("CaptionAssets configured but Storage does not implement CaptionStorage")
+		}
+	}
+	if len(vlMap) > 0 {
+		if _, ok := store.(VLStorage); !ok {
+			return nil, fmt.Errorf("vl embedders configured but Storage does not implement VLStorage")
+		}
+	}
+	if len(vlAssets) > 0 {
+		if _, ok := store.(VLAssetStorage); !ok {
+			return nil, fmt.Errorf("VLAssetModels configured but Storage does not implement VLAssetStorage")
+		}
+	}
+	if len(segments) > 0 {
+		if _, ok := store.(VLAssetStorage); !ok {
+			return nil, fmt.Errorf("VideoSegments configured but Storage does not implement VLAssetStorage")
+		}
+	}
+	if s, ok := store.(storageModeSetter); ok {
+		s.SetStorageModes(storageModes)
+	}
 
 	return &Runtime{
-		textEmbedders: textMap,
-		vlEmbedders:   vlMap,
-		taskRepo:      repo,
-		storage:       store,
-		buildSemantic: opts.BuildSemanticDocument,
-		buildLexical:  opts.BuildLexicalString,
-		listAssetURLs: opts.ListAssetURLs,
+		pool:              opts.Pool,
+		schema:            opts.Schema,
+		textEmbedders:     textMap,
+		vlEmbedders:       vlMap,
+		sparseEmbedders:   sparseMap,
+		hybridSparse:      hybridMap,
+		taskRepo:          repo,
+		storage:           store,
+		buildSemantic:     opts.BuildSemanticDocument,
+		buildStructured:   opts.BuildStructuredDocument,
+		buildLexical:      opts.BuildLexicalString,
+		listAssetURLs:     opts.ListAssetURLs,
+		extractText:       opts.ExtractAssetText,
+		captionAssets:     opts.CaptionAssets,
+		lexicalText:       opts.AssetTextInLexical,
+		chunking:          chunking,
+		instructions:      instructions,
+		documentTemplates: documentTemplates,
+		truncateDims:      truncateDims,
+		storageModes:      storageModes,
+		aliases:           aliases,
+		cache:             opts.EmbeddingCache,
+		tokenLimits:       tokenLimits,
+		onTruncate:        opts.OnTruncate,
+		metrics:           metrics,
+		shadow:            shadow,
+		anyLanguage:       anyLanguage,
+		normalization:     normalization,
+		vlAssets:          vlAssets,
+		framePolicies:     framePolicies,
+		assetLimits:       assetLimits,
+		assetKinds:        assetKinds,
+		segments:          segments,
 	}, nil
 }
 
@@ -155,7 +707,22 @@ func NewWithContext(ctx context.Context, opts Options) (*Runtime, error) {
 	if len(models) == 0 {
 		return rt, nil
 	}
-	if err := pg.UpsertModels(ctx, opts.Pool, opts.Schema, models); err != nil {
+	if opts.ProbeDimensions {
+		if err := rt.ProbeDimensions(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if opts.VLProbeImageURL != "" && len(rt.vlEmbedders) > 0 {
+		if err := rt.ProbeVL(ctx, opts.VLProbeImageURL); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ValidateModels {
+		if err := rt.Validate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := pg.UpsertModelsWithPrune(ctx, opts.Pool, opts.Schema, models, opts.PruneMode); err != nil {
 		return nil, err
 	}
 	if err := pg.EnsureIndexesForModels(ctx, opts.Pool, opts.Schema, models); err != nil {
@@ -165,6 +732,89 @@ func NewWithContext(ctx context.Context, opts Options) (*Runtime, error) {
 	return rt, nil
 }
 
+// Validate checks the configured embedders (dims, after TruncateDims, and
+// modality) against the registered embedding_models rows and existing per-model
+// indexes, returning an error per mismatch. Models not yet registered pass.
+//
+// Call it before NewWithContext registers models (or set
+// Options.ValidateModels), since registration overwrites the stored dims.
+func (r *Runtime) Validate(ctx context.Context) error {
+	models := r.modelSpecs()
+	if len(models) == 0 {
+		return nil
+	}
+	if err := pg.CheckModels(ctx, r.pool, r.schema, models); err != nil {
+		return fmt.Errorf("searchkit model check failed:\n%w", err)
+	}
+	return nil
+}
+
+// dimensionProbeText is the input ProbeDimensions embeds.
+const dimensionProbeText = "searchkit dimension probe"
+
+// ProbeDimensions embeds a short text with every configured embedder (VL
+// embedders get text only, no assets; hybrid models also their sparse
+// embedder) and checks the returned vector width (sparse: largest index)
+// against Dimensions(), returning an error per mismatch
+// or failed call.
+func (r *Runtime) ProbeDimensions(ctx context.Context) error {
+	ctx = embedder.WithInputType(ctx, embedder.InputQuery)
+	var errs []error
+	check := func(model string, declared int, vec []float32, err error) {
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("model %s: dimension probe failed: %w", model, err))
+		case len(vec) != declared:
+			errs = append(errs, fmt.Errorf("model %s: embedder declares %d dims but provider returned %d", model, declared, len(vec)))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.textEmbedders)) {
+		e := r.textEmbedders[name]
+		vec, err := e.EmbedText(ctx, dimensionProbeText)
+		check(name, e.Dimensions(), vec, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.vlEmbedders)) {
+		e := r.vlEmbedders[name]
+		vec, err := e.EmbedTextAndAssetURLs(ctx, dimensionProbeText, nil)
+		check(name, e.Dimensions(), vec, err)
+	}
+	// Sparse models have no fixed width; their indices must fit the vocabulary.
+	checkSparse := func(name string, e embedder.SparseEmbedder) {
+		vecs, err := e.EmbedSparseTexts(ctx, []string{dimensionProbeText})
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("model %s: dimension probe failed: %w", name, err))
+		case len(vecs) != 1:
+			errs = append(errs, fmt.Errorf("model %s: dimension probe returned %d vectors", name, len(vecs)))
+		case len(vecs[0].Indices) > 0 && int(slices.Max(vecs[0].Indices)) >= e.Dimensions():
+			errs = append(errs, fmt.Errorf("model %s: embedder declares a vocabulary of %d but provider returned index %d", name, e.Dimensions(), slices.Max(vecs[0].Indices)))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.sparseEmbedders)) {
+		checkSparse(name, r.sparseEmbedders[name])
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.hybridSparse)) {
+		checkSparse(name+" (sparse)", r.hybridSparse[name])
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("searchkit dimension probe failed:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// EnsureTenantIndexes creates per-tenant partial HNSW indexes for every
+// configured model (see pg.EnsureTenantIndexes). Writes are tagged with the
+// tenant carried by ctx (pg.WithTenant); the worker sets it from task rows.
+func (r *Runtime) EnsureTenantIndexes(ctx context.Context, tenantID string) error {
+	return pg.EnsureTenantIndexes(ctx, r.pool, r.schema, tenantID, r.modelSpecs())
+}
+
+// ModelSpecs returns the specs of every configured model, as registered in
+// embedding_models (see pg.Diagnose).
+func (r *Runtime) ModelSpecs() []pg.ModelSpec {
+	return r.modelSpecs()
+}
+
 func (r *Runtime) modelSpecs() []pg.ModelSpec {
 	seen := make(map[string]struct{})
 	var out []pg.ModelSpec
@@ -173,19 +823,50 @@ func (r *Runtime) modelSpecs() []pg.ModelSpec {
 			continue
 		}
 		seen[name] = struct{}{}
-		out = append(out, pg.ModelSpec{Name: name, Dims: e.Dimensions(), Modality: "text"})
+		var sparseDims int
+		if s, ok := r.hybridSparse[name]; ok {
+			sparseDims = s.Dimensions()
+		}
+		out = append(out, pg.ModelSpec{Name: name, Dims: e.Dimensions(), Modality: "text", StoredDims: r.truncateDims[name], Storage: r.storageModes[name], Aliases: r.aliasesOf(name), Shadow: r.IsShadowModel(name), LanguageAgnostic: r.IsLanguageAgnostic(name), Normalization: r.normalization[name], SparseDims: sparseDims})
 	}
 	for name, e := range r.vlEmbedders {
 		if _, ok := seen[name]; ok {
 			continue
 		}
 		seen[name] = struct{}{}
-		out = append(out, pg.ModelSpec{Name: name, Dims: e.Dimensions(), Modality: "vl"})
+		out = append(out, pg.ModelSpec{Name: name, Dims: e.Dimensions(), Modality: "vl", StoredDims: r.truncateDims[name], Storage: r.storageModes[name], Aliases: r.aliasesOf(name), Shadow: r.IsShadowModel(name), LanguageAgnostic: r.IsLanguageAgnostic(name), Normalization: r.normalization[name]})
+	}
+	for name, e := range r.sparseEmbedders {
+		// Sparse weights are stored as returned (inner-product scoring).
+		out = append(out, pg.ModelSpec{Name: name, Dims: e.Dimensions(), Modality: "sparse", Storage: pg.StorageSparse, Aliases: r.aliasesOf(name), Shadow: r.IsShadowModel(name), LanguageAgnostic: r.IsLanguageAgnostic(name), Normalization: pg.NormalizeNone})
 	}
 	return out
 }
 
-// ActiveModels returns the configured embedding model names.
+// IsShadowModel reports whether model (or its alias) is a shadow model.
+func (r *Runtime) IsShadowModel(model string) bool {
+	_, ok := r.shadow[r.CanonicalModel(model)]
+	return ok
+}
+
+// IsLanguageAgnostic reports whether model (or its alias) embeds entities once
+// under pg.AnyLanguage.
+func (r *Runtime) IsLanguageAgnostic(model string) bool {
+	_, ok := r.anyLanguage[r.CanonicalModel(model)]
+	return ok
+}
+
+// EmbeddingLanguage returns the language model's vectors and tasks are stored
+// under: pg.AnyLanguage for language-agnostic models, otherwise language.
+func (r *Runtime) EmbeddingLanguage(model string, language string) string {
+	if r.IsLanguageAgnostic(model) {
+		return pg.AnyLanguage
+	}
+	return language
+}
+
+// ActiveModels returns the configured embedding model names (including shadow
+// models, which are embedded like any other).
 func (r *Runtime) ActiveModels() []string {
 	seen := make(map[string]struct{})
 	var out []string
@@ -203,21 +884,25 @@ func (r *Runtime) ActiveModels() []string {
 		seen[name] = struct{}{}
 		out = append(out, name)
 	}
+	for name := range r.sparseEmbedders {
+		out = append(out, name)
+	}
 	return out
 }
 
 // EnqueueEmbedding enqueues an embedding task for an entity+model+language (text or VL).
 func (r *Runtime) EnqueueEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, reason string) error {
-	return r.taskRepo.Enqueue(ctx, entityType, entityID, model, language, reason)
+	model = r.CanonicalModel(model)
+	return r.taskRepo.Enqueue(ctx, entityType, entityID, model, r.EmbeddingLanguage(model, language), reason)
 }
 
 // BuildSemanticDocument is exposed for worker implementations that want to batch
 // hydration. The returned map contains text for entities that exist.
 func (r *Runtime) BuildSemanticDocument(ctx context.Context, entityType string, language string, entityIDs []string) (map[string]string, error) {
-	if r.buildSemantic == nil {
+	if r.buildSemantic == nil && r.buildStructured == nil {
 		return nil, fmt.Errorf("BuildSemanticDocument not configured")
 	}
-	return r.buildSemantic(ctx, entityType, language, entityIDs)
+	return r.buildDocuments(ctx, entityType, language, entityIDs)
 }
 
 // BuildLexicalString is exposed for worker implementations that want to batch
@@ -227,7 +912,16 @@ func (r *Runtime) BuildLexicalString(ctx context.Context, entityType string, lan
 	if r.buildLexical == nil {
 		return nil, fmt.Errorf("BuildLexicalString not configured")
 	}
-	return r.buildLexical(ctx, entityType, language, entityIDs)
+	docs, err := r.buildLexical(ctx, entityType, language, entityIDs)
+	if err != nil || !r.lexicalText {
+		return docs, err
+	}
+	text, err := r.assetText(ctx, entityType, language, slices.Collect(maps.Keys(docs)))
+	if err != nil {
+		return nil, err
+	}
+	appendAssetText(docs, text)
+	return docs, nil
 }
 
 // ListAssetURLs is exposed for worker implementations that want to batch
@@ -239,26 +933,88 @@ func (r *Runtime) ListAssetURLs(ctx context.Context, entityType string, entityID
 	return r.listAssetURLs(ctx, entityType, entityIDs)
 }
 
+// MaxBatch returns the embedder's per-request input limit for model (see
+// embedder.MaxBatcher and vl.BatchEmbedder), or 0 when unknown. VL models
+// whose embedder can't batch report 1.
+func (r *Runtime) MaxBatch(model string) int {
+	model = r.CanonicalModel(model)
+	if emb, ok := r.textEmbedders[model]; ok {
+		return embedder.MaxBatch(emb)
+	}
+	if emb, ok := r.vlEmbedders[model]; ok {
+		if b, ok := emb.(vl.BatchEmbedder); ok {
+			return b.MaxBatch()
+		}
+		return 1
+	}
+	if b, ok := r.sparseEmbedders[model].(embedder.MaxBatcher); ok {
+		return b.MaxBatch()
+	}
+	return 0
+}
+
 func (r *Runtime) IsVLModel(model string) bool {
+	model = r.CanonicalModel(model)
 	_, ok := r.vlEmbedders[model]
 	return ok
 }
 
-// EmbedQueryText returns a normalized embedding vector for arbitrary query text
-// using a configured text embedder.
-//
-// This is intended for host apps calling SemanticSearch at request time.
+// EmbedQueryText returns a normalized embedding vector for already-normalized
+// query text using a configured text embedder. It implements searchkit.Embedder;
+// the query template is applied without a language. Prefer EmbedQuery when
+// calling SemanticSearch directly.
 func (r *Runtime) EmbedQueryText(ctx context.Context, model string, text string) ([]float32, error) {
-	emb, ok := r.textEmbedders[strings.TrimSpace(model)]
-	if !ok {
-		return nil, fmt.Errorf("model %q is not configured for text embeddings", model)
+	return r.embedQuery(ctx, model, "", text)
+}
+
+// finishVector applies model's Matryoshka truncation (if any) and its
+// normalization policy.
+func (r *Runtime) finishVector(model string, vec []float32) []float32 {
+	if d := r.truncateDims[model]; d > 0 && len(vec) > d {
+		vec = vec[:d]
 	}
-	vec, err := emb.EmbedText(ctx, text)
-	if err != nil {
-		return nil, err
+	if r.normalization[model] != pg.NormalizeNone {
+		normalize.L2NormalizeInPlace(vec)
 	}
-	normalize.L2NormalizeInPlace(vec)
-	return vec, nil
+	return vec
+}
+
+// documentHash is the content hash stored alongside model's vectors for doc.
+// Settings that change the stored vectors (chunking, document instructions,
+// truncation, token limits, normalization, the hybrid sparse model) are part of
+// the hash so changing them re-embeds documents.
+func (r *Runtime) documentHash(model string, doc string) string {
+	var b strings.Builder
+	if o, ok := r.chunking[model]; ok {
+		o = o.withDefaults()
+		fmt.Fprintf(&b, "chunk:%d:%d:%d\n", o.MaxChars, o.OverlapChars, o.MaxChunks)
+	}
+	if tmpl := r.instructions[model].Document; tmpl != "" {
+		fmt.Fprintf(&b, "instruction:%q\n", tmpl)
+	}
+	if d := r.truncateDims[model]; d > 0 {
+		fmt.Fprintf(&b, "dims:%d\n", d)
+	}
+	if l, ok := r.tokenLimits[model]; ok {
+		fmt.Fprintf(&b, "tokens:%d:%s\n", l.MaxTokens, l.Strategy)
+	}
+	if n := r.normalization[model]; n == pg.NormalizeNone {
+		fmt.Fprintf(&b, "norm:%s\n", n)
+	}
+	if s, ok := r.hybridSparse[model]; ok {
+		fmt.Fprintf(&b, "sparse:%s:%d\n", s.Model(), s.Dimensions())
+	}
+	// Each mode stores vectors in its own column, so a mode change re-embeds.
+	// Halfvec (the default) and sparsevec (fixed for sparse models) keep the
+	// hashes stored before modes existed valid.
+	if m := r.storageModes[model]; m != "" && m != pg.StorageHalfvec && m != pg.StorageSparse {
+		fmt.Fprintf(&b, "storage:%s\n", m)
+	}
+	if b.Len() == 0 {
+		return pg.ContentHash(doc)
+	}
+	b.WriteString(doc)
+	return pg.ContentHash(b.String())
 }
 
 type TextEmbeddingItem struct {
@@ -269,28 +1025,71 @@ type TextEmbeddingItem struct {
 }
 
 func (r *Runtime) GenerateAndStoreTextEmbeddingWithDocument(ctx context.Context, entityType string, entityID string, model string, language string, doc string) error {
+	model = r.CanonicalModel(model)
+	_, isSparse := r.sparseEmbedders[model]
+	_, isHybrid := r.hybridSparse[model]
+	if isSparse || isHybrid {
+		errs, err := r.GenerateAndStoreTextEmbeddingsWithDocuments(ctx, model, []TextEmbeddingItem{{EntityType: entityType, EntityID: entityID, Language: language, Document: doc}})
+		if err != nil {
+			return err
+		}
+		return errs[0]
+	}
 	emb, ok := r.textEmbedders[model]
 	if !ok {
 		return fmt.Errorf("model %q is not configured for text embeddings", model)
 	}
+	language = r.EmbeddingLanguage(model, language)
+	doc = r.renderDocument(model, language, doc)
 	if strings.TrimSpace(doc) == "" {
 		return ErrEntityNotFound
 	}
-	vec, err := emb.EmbedText(ctx, doc)
+	hash := r.documentHash(model, doc)
+	key := pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: language}
+	stored, err := r.storage.ContentHashes(ctx, model, []pg.EmbeddingKey{key})
 	if err != nil {
 		return err
 	}
-	normalize.L2NormalizeInPlace(vec)
-	return r.storage.UpsertTextEmbedding(ctx, entityType, entityID, model, language, len(vec), vec)
+	if stored[key] == hash && !isReembed(ctx) {
+		// Document unchanged since the stored embedding was generated.
+		return nil
+	}
+	chunks := r.documentInputs(model, key, r.documentChunks(model, doc))
+	vecs, err := r.embedInputs(ctx, model, emb, chunks)
+	if err != nil {
+		return err
+	}
+	for i, vec := range vecs {
+		vecs[i] = r.finishVector(model, vec)
+	}
+	return r.upsert(ctx, entityType, entityID, model, language, vecs, hash)
+}
+
+// upsert stores an entity's vectors and reports storage metrics.
+func (r *Runtime) upsert(ctx context.Context, entityType string, entityID string, model string, language string, vecs [][]float32, hash string) error {
+	started := time.Now()
+	err := r.storage.UpsertTextEmbeddingChunks(ctx, entityType, entityID, model, language, len(vecs[0]), vecs, hash)
+	r.metrics.VectorsUpserted(model, len(vecs), time.Since(started), err)
+	if err == nil {
+		r.metrics.EmbeddingsGenerated(model, 1)
+	}
+	return err
 }
 
 // GenerateAndStoreTextEmbeddingsWithDocuments generates embeddings in a batch (provider call)
-// and stores them in the database (one upsert per item).
+// and stores them in the database (one bulk write when the storage implements BulkStorage,
+// otherwise one upsert per item; chunked documents contribute one provider input per chunk).
+// Items whose document hash matches the stored embedding are skipped without a provider
+// call and report a nil error.
 //
 // Returned per-item errors align with items by index. If the provider call fails, the
 // returned error is non-nil and per-item errors are only set for inputs we can classify
 // locally (e.g. ErrEntityNotFound for empty docs).
 func (r *Runtime) GenerateAndStoreTextEmbeddingsWithDocuments(ctx context.Context, model string, items []TextEmbeddingItem) ([]error, error) {
+	model = r.CanonicalModel(model)
+	if _, ok := r.sparseEmbedders[model]; ok {
+		return r.generateAndStoreSparse(ctx, model, items)
+	}
 	emb, ok := r.textEmbedders[model]
 	if !ok {
 		return nil, fmt.Errorf("model %q is not configured for text embeddings", model)
@@ -300,34 +1099,94 @@ func (r *Runtime) GenerateAndStoreTextEmbeddingsWithDocuments(ctx context.Contex
 	if len(items) == 0 {
 		return errs, nil
 	}
+	if r.IsLanguageAgnostic(model) {
+		items = append([]TextEmbeddingItem(nil), items...)
+		for i := range items {
+			items[i].Language = pg.AnyLanguage
+		}
+	}
+
+	rendered := make([]string, len(items))
+	hashes := make([]string, len(items))
+	keys := make([]pg.EmbeddingKey, 0, len(items))
+	for i, it := range items {
+		rendered[i] = r.renderDocument(model, it.Language, it.Document)
+		if strings.TrimSpace(rendered[i]) == "" {
+			errs[i] = ErrEntityNotFound
+			continue
+		}
+		hashes[i] = r.documentHash(model, rendered[i])
+		keys = append(keys, pg.EmbeddingKey{EntityType: it.EntityType, EntityID: it.EntityID, Language: it.Language})
+	}
+	stored, err := r.storage.ContentHashes(ctx, model, keys)
+	if err != nil {
+		return errs, err
+	}
 
+	// spans[k] covers docs[offset:offset+count] for items[idx[k]].
+	type span struct{ offset, count int }
 	idx := make([]int, 0, len(items))
+	spans := make([]span, 0, len(items))
 	docs := make([]string, 0, len(items))
 	for i, it := range items {
-		if strings.TrimSpace(it.Document) == "" {
-			errs[i] = ErrEntityNotFound
+		if errs[i] != nil {
+			continue
+		}
+		key := pg.EmbeddingKey{EntityType: it.EntityType, EntityID: it.EntityID, Language: it.Language}
+		if stored[key] == hashes[i] && !isReembed(ctx) {
 			continue
 		}
+		chunks := r.documentInputs(model, key, r.documentChunks(model, rendered[i]))
 		idx = append(idx, i)
-		docs = append(docs, it.Document)
+		spans = append(spans, span{offset: len(docs), count: len(chunks)})
+		docs = append(docs, chunks...)
 	}
 	if len(docs) == 0 {
 		return errs, nil
 	}
 
-	vecs, err := emb.EmbedTexts(ctx, docs)
+	vecs, err := r.embedInputs(ctx, model, emb, docs)
 	if err != nil {
 		return errs, err
 	}
-	if len(vecs) != len(docs) {
-		return errs, fmt.Errorf("expected %d embeddings, got %d", len(docs), len(vecs))
+	for i, vec := range vecs {
+		vecs[i] = r.finishVector(model, vec)
+	}
+
+	if sparse, ok := r.hybridSparse[model]; ok {
+		chunkVecs := make([][][]float32, len(spans))
+		for k, sp := range spans {
+			chunkVecs[k] = vecs[sp.offset : sp.offset+sp.count]
+		}
+		return r.storeHybrid(ctx, model, sparse, items, idx, rendered, chunkVecs, hashes, errs)
 	}
 
-	for k, vec := range vecs {
+	if bulk, ok := r.storage.(BulkStorage); ok && len(spans) > 1 {
+		writes := make([]pg.TextEmbeddingWrite, len(spans))
+		for k, sp := range spans {
+			it := items[idx[k]]
+			writes[k] = pg.TextEmbeddingWrite{
+				EntityType:  it.EntityType,
+				EntityID:    it.EntityID,
+				Language:    it.Language,
+				Chunks:      vecs[sp.offset : sp.offset+sp.count],
+				ContentHash: hashes[idx[k]],
+			}
+		}
+		started := time.Now()
+		err := bulk.UpsertTextEmbeddingsBulk(ctx, model, writes)
+		r.metrics.VectorsUpserted(model, len(vecs), time.Since(started), err)
+		if err == nil {
+			r.metrics.EmbeddingsGenerated(model, len(spans))
+			return errs, nil
+		}
+		// Retry per item so one bad write doesn't fail the whole batch.
+	}
+	for k, sp := range spans {
 		i := idx[k]
-		normalize.L2NormalizeInPlace(vec)
 		it := items[i]
-		if err := r.storage.UpsertTextEmbedding(ctx, it.EntityType, it.EntityID, model, it.Language, len(vec), vec); err != nil {
+		chunkVecs := vecs[sp.offset : sp.offset+sp.count]
+		if err := r.upsert(ctx, it.EntityType, it.EntityID, model, it.Language, chunkVecs, hashes[i]); err != nil {
 			errs[i] = err
 		}
 	}
@@ -335,23 +1194,30 @@ func (r *Runtime) GenerateAndStoreTextEmbeddingsWithDocuments(ctx context.Contex
 }
 
 func (r *Runtime) GenerateAndStoreVLEmbeddingWithInputs(ctx context.Context, entityType string, entityID string, model string, language string, doc string, assets []vl.AssetURL) error {
-	emb, ok := r.vlEmbedders[model]
-	if !ok {
+	model = r.CanonicalModel(model)
+	if _, ok := r.vlEmbedders[model]; !ok {
 		return fmt.Errorf("model %q is not configured for vl embeddings", model)
 	}
-	if strings.TrimSpace(doc) == "" || len(assets) == 0 {
-		return ErrEntityNotFound
+	in, err := r.prepareVL(ctx, model, language, doc, assets)
+	if err != nil {
+		return err
 	}
-	vec, err := emb.EmbedTextAndAssetURLs(ctx, doc, assets)
+	key := pg.EmbeddingKey{EntityType: entityType, EntityID: entityID, Language: in.language}
+	stored, err := r.storage.(VLStorage).VLContentHashes(ctx, model, []pg.EmbeddingKey{key})
 	if err != nil {
 		return err
 	}
-	normalize.L2NormalizeInPlace(vec)
-	return r.storage.UpsertTextEmbedding(ctx, entityType, entityID, model, language, len(vec), vec)
+	if stored[key] == in.hash && !isReembed(ctx) {
+		// Document and asset set unchanged since the stored embedding.
+		return nil
+	}
+	return r.embedAndStoreVL(ctx, entityType, entityID, model, in)
 }
 
 func (r *Runtime) GenerateAndStoreTextEmbedding(ctx context.Context, entityType string, entityID string, model string, language string) error {
-	docs, err := r.buildSemantic(ctx, entityType, language, []string{entityID})
+	model = r.CanonicalModel(model)
+	language = r.EmbeddingLanguage(model, language)
+	docs, err := r.buildDocuments(ctx, entityType, language, []string{entityID})
 	if err != nil {
 		return err
 	}
@@ -363,11 +1229,13 @@ func (r *Runtime) GenerateAndStoreTextEmbedding(ctx context.Context, entityType
 }
 
 func (r *Runtime) GenerateAndStoreVLEmbedding(ctx context.Context, entityType string, entityID string, model string, language string) error {
+	model = r.CanonicalModel(model)
 	if r.listAssetURLs == nil {
 		return fmt.Errorf("ListAssetURLs not configured")
 	}
+	language = r.EmbeddingLanguage(model, language)
 
-	docs, err := r.buildSemantic(ctx, entityType, language, []string{entityID})
+	docs, err := r.buildDocuments(ctx, entityType, language, []string{entityID})
 	if err != nil {
 		return err
 	}
@@ -389,6 +1257,7 @@ func (r *Runtime) GenerateAndStoreVLEmbedding(ctx context.Context, entityType st
 
 // GenerateAndStoreEmbedding routes to text vs VL based on which embedder is configured.
 func (r *Runtime) GenerateAndStoreEmbedding(ctx context.Context, entityType string, entityID string, model string, language string) error {
+	model = r.CanonicalModel(model)
 	if _, ok := r.vlEmbedders[model]; ok {
 		return r.GenerateAndStoreVLEmbedding(ctx, entityType, entityID, model, language)
 	}
@@ -0,0 +1,143 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// SemanticDocument is a structured semantic document. searchkit serializes it
+// with the model's DocumentTemplate, so every deployment (and every model)
+// renders the same fields the same way.
+type SemanticDocument struct {
+	Title       string
+	Tags        []string
+	Description string
+	// Hints are extra search terms (synonyms, alternate names, ...).
+	Hints []string
+	// AssetText is text derived from the entity's assets; searchkit fills it
+	// from Options.ExtractAssetText and Options.CaptionAssets.
+	AssetText string
+}
+
+// BuildStructuredDocument is the structured alternative to
+// BuildSemanticDocument. The returned map should contain entries only for
+// entities that exist.
+type BuildStructuredDocument func(ctx context.Context, entityType string, language string, entityIDs []string) (map[string]SemanticDocument, error)
+
+// DocumentTemplate renders a SemanticDocument. "{title}", "{tags}",
+// "{description}", "{hints}", "{asset_text}" and "{language}" are replaced by
+// the field values (tags and hints comma-separated); lines left blank are
+// dropped. Repeating a placeholder weights that field more heavily, e.g.
+//
+//	"{title}\n{title}\n{tags}\n{description}"
+//
+// Templates without "{asset_text}" get it as a final line.
+type DocumentTemplate string
+
+// DefaultDocumentTemplate is used for models without a DocumentTemplates entry.
+const DefaultDocumentTemplate DocumentTemplate = "{title}\n{tags}\n{description}\n{hints}\n{asset_text}"
+
+// Render serializes doc for language.
+func (t DocumentTemplate) Render(doc SemanticDocument, language string) string {
+	if t == "" {
+		t = DefaultDocumentTemplate
+	}
+	if !strings.Contains(string(t), "{asset_text}") {
+		t += "\n{asset_text}"
+	}
+	out := strings.NewReplacer(
+		"{title}", strings.TrimSpace(doc.Title),
+		"{tags}", joinNonEmpty(doc.Tags),
+		"{description}", strings.TrimSpace(doc.Description),
+		"{hints}", joinNonEmpty(doc.Hints),
+		"{asset_text}", strings.TrimSpace(doc.AssetText),
+		"{language}", language,
+	).Replace(string(t))
+
+	lines := strings.Split(out, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSpace(l); l != "" {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func joinNonEmpty(in []string) string {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// Structured documents travel through the string-based document pipeline
+// (worker hydration, GenerateAndStore*WithDocument) encoded behind this marker,
+// and are rendered with the target model's template right before embedding.
+const structuredDocMarker = "\x00searchkit:structured\x00"
+
+func encodeStructuredDocument(doc SemanticDocument) string {
+	if DefaultDocumentTemplate.Render(doc, "") == "" {
+		// Nothing to embed: behave like a missing document.
+		return ""
+	}
+	b, _ := json.Marshal(doc)
+	return structuredDocMarker + string(b)
+}
+
+// renderDocument returns doc as model's provider text, rendering structured
+// documents with the model's DocumentTemplate. Plain documents are unchanged.
+func (r *Runtime) renderDocument(model string, language string, doc string) string {
+	enc, ok := strings.CutPrefix(doc, structuredDocMarker)
+	if !ok {
+		return doc
+	}
+	var sd SemanticDocument
+	if err := json.Unmarshal([]byte(enc), &sd); err != nil {
+		return ""
+	}
+	return r.documentTemplates[model].Render(sd, language)
+}
+
+// buildDocuments calls the configured document builder, adding asset text
+// (Options.ExtractAssetText) and encoding structured documents.
+func (r *Runtime) buildDocuments(ctx context.Context, entityType string, language string, entityIDs []string) (map[string]string, error) {
+	if r.buildStructured == nil {
+		docs, err := r.buildSemantic(ctx, entityType, language, entityIDs)
+		if err != nil {
+			return nil, err
+		}
+		text, err := r.assetText(ctx, entityType, language, slices.Collect(maps.Keys(docs)))
+		if err != nil {
+			return nil, err
+		}
+		appendAssetText(docs, text)
+		return docs, nil
+	}
+	docs, err := r.buildStructured(ctx, entityType, language, entityIDs)
+	if err != nil {
+		return nil, err
+	}
+	text, err := r.assetText(ctx, entityType, language, slices.Collect(maps.Keys(docs)))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(docs))
+	for id, d := range docs {
+		t := text[id]
+		if DefaultDocumentTemplate.Render(d, "") != "" {
+			d.AssetText = joinLines(t.ocr, t.captions)
+		} else {
+			// Captions stand in for image-only entities (see appendAssetText).
+			d.AssetText = t.captions
+		}
+		out[id] = encodeStructuredDocument(d)
+	}
+	return out, nil
+}
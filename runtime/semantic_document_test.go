@@ -0,0 +1,128 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-rails/searchkit/vl"
+)
+
+func TestDocumentTemplate_Render(t *testing.T) {
+	doc := SemanticDocument{
+		Title: " Blue Bike ",
+		Tags:  []string{"bike", "", "blue"},
+		Hints: nil,
+	}
+	if got, want := DefaultDocumentTemplate.Render(doc, "en"), "Blue Bike\nbike, blue"; got != want {
+		t.Fatalf("default: got %q want %q", got, want)
+	}
+	weighted := DocumentTemplate("[{language}] {title}\n{title}\n{description}")
+	if got, want := weighted.Render(doc, "en"), "[en] Blue Bike\nBlue Bike"; got != want {
+		t.Fatalf("weighted: got %q want %q", got, want)
+	}
+}
+
+func TestRuntime_RendersStructuredDocumentsPerModel(t *testing.T) {
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		DocumentTemplates: map[string]DocumentTemplate{"test-model": "title: {title}"},
+	})
+	enc := encodeStructuredDocument(SemanticDocument{Title: "Blue Bike", Description: "fast"})
+	if got, want := rt.renderDocument("test-model", "en", enc), "title: Blue Bike"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if got := rt.renderDocument("test-model", "en", "plain"); got != "plain" {
+		t.Fatalf("plain documents must pass through, got %q", got)
+	}
+	if encodeStructuredDocument(SemanticDocument{}) != "" {
+		t.Fatalf("empty structured documents must encode as missing")
+	}
+}
+
+func TestRuntime_AppendsAssetTextToDocuments(t *testing.T) {
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		BuildStructuredDocument: func(_ context.Context, _ string, _ string, ids []string) (map[string]SemanticDocument, error) {
+			return map[string]SemanticDocument{"1": {Title: "Issue 1"}, "2": {}}, nil
+		},
+		ListAssetURLs: func(_ context.Context, _ string, ids []string) (map[string][]vl.AssetURL, error) {
+			out := map[string][]vl.AssetURL{}
+			for _, id := range ids {
+				out[id] = []vl.AssetURL{{Kind: vl.AssetKindImage, URL: "https://cdn/" + id + ".jpg"}}
+			}
+			return out, nil
+		},
+		ExtractAssetText: func(_ context.Context, _ string, _ string, assets map[string][]vl.AssetURL) (map[string]string, error) {
+			out := map[string]string{}
+			for id := range assets {
+				out[id] = "scanned text " + id
+			}
+			return out, nil
+		},
+		DocumentTemplates: map[string]DocumentTemplate{"test-model": "{title}"},
+	})
+	docs, err := rt.BuildSemanticDocument(context.Background(), "comic", "en", []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("BuildSemanticDocument: %v", err)
+	}
+	if got, want := rt.renderDocument("test-model", "en", docs["1"]), "Issue 1\nscanned text 1"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if docs["2"] != "" {
+		t.Fatalf("asset text alone must not make a missing document embeddable, got %q", docs["2"])
+	}
+}
+
+func TestRuntime_CaptionsAreStoredAndFillImageOnlyDocuments(t *testing.T) {
+	store := NewMemoryStorage()
+	var captioned []string
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{
+		BuildSemanticDocument: func(_ context.Context, _ string, _ string, ids []string) (map[string]string, error) {
+			return map[string]string{"1": "Issue 1", "2": ""}, nil
+		},
+		ListAssetURLs: func(_ context.Context, _ string, ids []string) (map[string][]vl.AssetURL, error) {
+			out := map[string][]vl.AssetURL{}
+			for _, id := range ids {
+				out[id] = []vl.AssetURL{{Kind: vl.AssetKindImage, URL: "https://cdn/" + id + ".jpg", Key: "img-" + id}}
+			}
+			return out, nil
+		},
+		CaptionAssets: func(_ context.Context, _ string, assets []vl.AssetURL) ([]string, error) {
+			out := make([]string, len(assets))
+			for i, a := range assets {
+				captioned = append(captioned, a.Key)
+				out[i] = "a cat on " + a.Key
+			}
+			return out, nil
+		},
+	})
+	for range 2 {
+		docs, err := rt.BuildSemanticDocument(context.Background(), "post", "en", []string{"1", "2"})
+		if err != nil {
+			t.Fatalf("BuildSemanticDocument: %v", err)
+		}
+		if docs["1"] != "Issue 1\na cat on img-1" || docs["2"] != "a cat on img-2" {
+			t.Fatalf("unexpected documents %q", docs)
+		}
+	}
+	if len(captioned) != 2 {
+		t.Fatalf("expected stored captions to be reused, captioned %v", captioned)
+	}
+}
+
+func TestDetectInstructionPreset(t *testing.T) {
+	for model, want := range map[string]string{
+		"intfloat/multilingual-e5-large":          "e5",
+		"intfloat/multilingual-e5-large-instruct": "e5-instruct",
+		"BAAI/bge-base-en-v1.5":                   "bge",
+		"BAAI/bge-large-zh-v1.5":                  "bge-zh",
+		"BAAI/bge-m3":                             "",
+		"qwen-3-embedding-4b":                     "qwen3",
+		"text-embedding-3-small":                  "",
+	} {
+		if got := DetectInstructionPreset(model); got != want {
+			t.Errorf("DetectInstructionPreset(%q) = %q, want %q", model, got, want)
+		}
+	}
+	if _, err := InstructionPreset("e6"); err == nil {
+		t.Errorf("expected an error for an unknown preset")
+	}
+}
@@ -0,0 +1,206 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/embedder"
+	"github.com/open-rails/searchkit/internal/normalize"
+	"github.com/open-rails/searchkit/pg"
+)
+
+// SparseStorage is implemented by storages that can hold sparse vectors; it is
+// required when Options.SparseEmbedders is set. pg.PostgresStorage and
+// MemoryStorage implement it.
+type SparseStorage interface {
+	UpsertSparseEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, vec pgvector.SparseVector, contentHash string) error
+}
+
+var _ SparseStorage = (*pg.PostgresStorage)(nil)
+
+// HybridStorage is implemented by storages that can hold a hybrid model's
+// dense chunks and sparse vector together; it is required when
+// Options.HybridSparseEmbedders is set. pg.PostgresStorage and MemoryStorage
+// implement it.
+type HybridStorage interface {
+	UpsertHybridEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, chunks [][]float32, sparse pgvector.SparseVector, contentHash string) error
+}
+
+var _ HybridStorage = (*pg.PostgresStorage)(nil)
+
+// IsSparseModel reports whether model is a configured sparse embedder.
+func (r *Runtime) IsSparseModel(model string) bool {
+	_, ok := r.sparseEmbedders[r.CanonicalModel(model)]
+	return ok
+}
+
+// IsHybridModel reports whether model is a text model with a hybrid sparse
+// embedder (see Options.HybridSparseEmbedders).
+func (r *Runtime) IsHybridModel(model string) bool {
+	_, ok := r.hybridSparse[r.CanonicalModel(model)]
+	return ok
+}
+
+// EmbedQuerySparse returns a sparse query vector ready for
+// search.SparseQuery.QueryVec (or search.HybridQuery.SparseVec for hybrid
+// models): the text is normalized like EmbedQuery and the model's query
+// instruction template is applied. It returns an empty vector when the text
+// has nothing to embed.
+func (r *Runtime) EmbedQuerySparse(ctx context.Context, model string, language string, text string) (pgvector.SparseVector, error) {
+	model = r.CanonicalModel(model)
+	emb, ok := r.sparseEmbedders[model]
+	if !ok {
+		emb, ok = r.hybridSparse[model]
+	}
+	if !ok {
+		return pgvector.SparseVector{}, fmt.Errorf("model %q is not configured for sparse embeddings", model)
+	}
+	q := normalize.QueryForEmbedding(text)
+	if q == "" {
+		return pgvector.SparseVector{}, nil
+	}
+	tmpl := r.instructions[model].Query
+	q = r.fitTokens(model, tmpl, q, TruncationEvent{Language: language})
+
+	started := time.Now()
+	vecs, err := emb.EmbedSparseTexts(embedder.WithInputType(ctx, embedder.InputQuery), []string{applyInstruction(tmpl, language, q)})
+	r.metrics.ProviderCall(model, 1, time.Since(started), err)
+	if err != nil {
+		return pgvector.SparseVector{}, err
+	}
+	if len(vecs) != 1 {
+		return pgvector.SparseVector{}, fmt.Errorf("expected 1 sparse embedding, got %d", len(vecs))
+	}
+	return toPGSparse(vecs[0], emb.Dimensions()), nil
+}
+
+// toPGSparse converts a provider sparse vector to pgvector's representation.
+func toPGSparse(v embedder.SparseVector, dims int) pgvector.SparseVector {
+	elements := make(map[int32]float32, len(v.Indices))
+	for i, idx := range v.Indices {
+		if v.Values[i] != 0 {
+			elements[idx] = v.Values[i]
+		}
+	}
+	return pgvector.NewSparseVectorFromMap(elements, int32(dims))
+}
+
+// generateAndStoreSparse is GenerateAndStoreTextEmbeddingsWithDocuments for a
+// sparse model: one unchunked vector per item, pruned to pg.MaxSparseNonZero
+// weights, skipping items whose document hash is unchanged.
+func (r *Runtime) generateAndStoreSparse(ctx context.Context, model string, items []TextEmbeddingItem) ([]error, error) {
+	emb := r.sparseEmbedders[model]
+	store := r.storage.(SparseStorage)
+
+	errs := make([]error, len(items))
+	if len(items) == 0 {
+		return errs, nil
+	}
+	items = append([]TextEmbeddingItem(nil), items...)
+	for i := range items {
+		items[i].Language = r.EmbeddingLanguage(model, items[i].Language)
+	}
+
+	tmpl := r.instructions[model].Document
+	rendered := make([]string, len(items))
+	hashes := make([]string, len(items))
+	keys := make([]pg.EmbeddingKey, 0, len(items))
+	for i, it := range items {
+		rendered[i] = r.renderDocument(model, it.Language, it.Document)
+		if strings.TrimSpace(rendered[i]) == "" {
+			errs[i] = ErrEntityNotFound
+			continue
+		}
+		hashes[i] = r.documentHash(model, rendered[i])
+		keys = append(keys, pg.EmbeddingKey{EntityType: it.EntityType, EntityID: it.EntityID, Language: it.Language})
+	}
+	stored, err := r.storage.ContentHashes(ctx, model, keys)
+	if err != nil {
+		return errs, err
+	}
+
+	var idx []int
+	var inputs []string
+	for i, it := range items {
+		if errs[i] != nil {
+			continue
+		}
+		key := pg.EmbeddingKey{EntityType: it.EntityType, EntityID: it.EntityID, Language: it.Language}
+		if stored[key] == hashes[i] && !isReembed(ctx) {
+			continue
+		}
+		doc := r.fitTokens(model, tmpl, rendered[i], TruncationEvent{EntityType: it.EntityType, EntityID: it.EntityID, Language: it.Language})
+		idx = append(idx, i)
+		inputs = append(inputs, applyInstruction(tmpl, it.Language, doc))
+	}
+	if len(inputs) == 0 {
+		return errs, nil
+	}
+
+	started := time.Now()
+	vecs, err := emb.EmbedSparseTexts(ctx, inputs)
+	r.metrics.ProviderCall(model, len(inputs), time.Since(started), err)
+	if err != nil {
+		return errs, err
+	}
+	if len(vecs) != len(inputs) {
+		return errs, fmt.Errorf("expected %d sparse embeddings, got %d", len(inputs), len(vecs))
+	}
+
+	for k, i := range idx {
+		it := items[i]
+		vec := toPGSparse(vecs[k].TopK(pg.MaxSparseNonZero), emb.Dimensions())
+		started := time.Now()
+		err := store.UpsertSparseEmbedding(ctx, it.EntityType, it.EntityID, model, it.Language, vec, hashes[i])
+		r.metrics.VectorsUpserted(model, 1, time.Since(started), err)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		r.metrics.EmbeddingsGenerated(model, 1)
+	}
+	return errs, nil
+}
+
+// storeHybrid finishes GenerateAndStoreTextEmbeddingsWithDocuments for a
+// hybrid model: it embeds the sparse vectors of items[idx[k]] from the same
+// rendered documents (whole, not chunked) and stores them with their dense
+// chunkVecs[k], one write per item.
+func (r *Runtime) storeHybrid(ctx context.Context, model string, emb embedder.SparseEmbedder, items []TextEmbeddingItem, idx []int, rendered []string, chunkVecs [][][]float32, hashes []string, errs []error) ([]error, error) {
+	store := r.storage.(HybridStorage)
+
+	tmpl := r.instructions[model].Document
+	inputs := make([]string, len(idx))
+	for k, i := range idx {
+		it := items[i]
+		doc := r.fitTokens(model, tmpl, rendered[i], TruncationEvent{EntityType: it.EntityType, EntityID: it.EntityID, Language: it.Language})
+		inputs[k] = applyInstruction(tmpl, it.Language, doc)
+	}
+	started := time.Now()
+	vecs, err := emb.EmbedSparseTexts(ctx, inputs)
+	r.metrics.ProviderCall(model, len(inputs), time.Since(started), err)
+	if err != nil {
+		return errs, err
+	}
+	if len(vecs) != len(inputs) {
+		return errs, fmt.Errorf("expected %d sparse embeddings, got %d", len(inputs), len(vecs))
+	}
+
+	for k, i := range idx {
+		it := items[i]
+		sparse := toPGSparse(vecs[k].TopK(pg.MaxSparseNonZero), emb.Dimensions())
+		started := time.Now()
+		err := store.UpsertHybridEmbedding(ctx, it.EntityType, it.EntityID, model, it.Language, chunkVecs[k], sparse, hashes[i])
+		r.metrics.VectorsUpserted(model, len(chunkVecs[k]), time.Since(started), err)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		r.metrics.EmbeddingsGenerated(model, 1)
+	}
+	return errs, nil
+}
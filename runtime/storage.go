@@ -0,0 +1,198 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// Storage persists generated embeddings. pg.PostgresStorage is the default
+// implementation; MemoryStorage and ShadowStorage are provided for tests and
+// backend migrations.
+type Storage interface {
+	// UpsertTextEmbeddingChunks stores one vector per chunk (chunk index = slice
+	// index) for an entity+model+language, replacing any previously stored
+	// chunks. contentHash identifies the document the vectors were built from
+	// ("" when not applicable).
+	UpsertTextEmbeddingChunks(ctx context.Context, entityType string, entityID string, model string, language string, dim int, chunks [][]float32, contentHash string) error
+
+	// ContentHashes returns the stored content hash for each key that has one
+	// for model. Keys without a stored hash are omitted.
+	ContentHashes(ctx context.Context, model string, keys []pg.EmbeddingKey) (map[pg.EmbeddingKey]string, error)
+}
+
+// storageModeSetter is implemented by storages that honor per-model storage
+// modes (see Options.StorageModes).
+type storageModeSetter interface {
+	SetStorageModes(modes map[string]pg.StorageMode)
+}
+
+// BulkStorage is implemented by storages that can write many entities' vectors
+// at once (pg.PostgresStorage does so with a fixed number of statements).
+// GenerateAndStoreTextEmbeddingsWithDocuments uses it for batches of more than
+// one entity, falling back to per-entity upserts when the bulk write fails so
+// errors are attributed to single items.
+type BulkStorage interface {
+	UpsertTextEmbeddingsBulk(ctx context.Context, model string, writes []pg.TextEmbeddingWrite) error
+}
+
+var (
+	_ Storage     = (*pg.PostgresStorage)(nil)
+	_ BulkStorage = (*pg.PostgresStorage)(nil)
+)
+
+// ShadowStorage writes to Primary and mirrors writes to Shadow, e.g. while
+// migrating to a new backend. Reads are served by Primary only.
+//
+// Shadow failures do not fail the write; they are reported to OnShadowError
+// (when set) so the shadow can be backfilled later.
+type ShadowStorage struct {
+	Primary       Storage
+	Shadow        Storage
+	OnShadowError func(err error)
+}
+
+func (s *ShadowStorage) UpsertTextEmbeddingChunks(ctx context.Context, entityType string, entityID string, model string, language string, dim int, chunks [][]float32, contentHash string) error {
+	if s.Primary == nil {
+		return errors.New("ShadowStorage.Primary is required")
+	}
+	if err := s.Primary.UpsertTextEmbeddingChunks(ctx, entityType, entityID, model, language, dim, chunks, contentHash); err != nil {
+		return err
+	}
+	if s.Shadow != nil {
+		if err := s.Shadow.UpsertTextEmbeddingChunks(ctx, entityType, entityID, model, language, dim, chunks, contentHash); err != nil && s.OnShadowError != nil {
+			s.OnShadowError(err)
+		}
+	}
+	return nil
+}
+
+// UpsertSparseEmbedding writes to Primary and mirrors to Shadow like
+// UpsertTextEmbeddingChunks; both must implement SparseStorage (a shadow that
+// doesn't is skipped).
+func (s *ShadowStorage) UpsertSparseEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, vec pgvector.SparseVector, contentHash string) error {
+	primary, ok := s.Primary.(SparseStorage)
+	if !ok {
+		return errors.New("ShadowStorage.Primary does not implement SparseStorage")
+	}
+	if err := primary.UpsertSparseEmbedding(ctx, entityType, entityID, model, language, vec, contentHash); err != nil {
+		return err
+	}
+	if shadow, ok := s.Shadow.(SparseStorage); ok {
+		if err := shadow.UpsertSparseEmbedding(ctx, entityType, entityID, model, language, vec, contentHash); err != nil && s.OnShadowError != nil {
+			s.OnShadowError(err)
+		}
+	}
+	return nil
+}
+
+// UpsertHybridEmbedding writes to Primary and mirrors to Shadow like
+// UpsertSparseEmbedding; both must implement HybridStorage (a shadow that
+// doesn't is skipped).
+func (s *ShadowStorage) UpsertHybridEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, chunks [][]float32, sparse pgvector.SparseVector, contentHash string) error {
+	primary, ok := s.Primary.(HybridStorage)
+	if !ok {
+		return errors.New("ShadowStorage.Primary does not implement HybridStorage")
+	}
+	if err := primary.UpsertHybridEmbedding(ctx, entityType, entityID, model, language, chunks, sparse, contentHash); err != nil {
+		return err
+	}
+	if shadow, ok := s.Shadow.(HybridStorage); ok {
+		if err := shadow.UpsertHybridEmbedding(ctx, entityType, entityID, model, language, chunks, sparse, contentHash); err != nil && s.OnShadowError != nil {
+			s.OnShadowError(err)
+		}
+	}
+	return nil
+}
+
+// UpsertVLEmbeddingAssets writes to Primary and mirrors to Shadow like
+// UpsertSparseEmbedding; both must implement VLAssetStorage (a shadow that
+// doesn't is skipped).
+func (s *ShadowStorage) UpsertVLEmbeddingAssets(ctx context.Context, entityType string, entityID string, model string, language string, assets []pg.AssetEmbedding) error {
+	primary, ok := s.Primary.(VLAssetStorage)
+	if !ok {
+		return errors.New("ShadowStorage.Primary does not implement VLAssetStorage")
+	}
+	if err := primary.UpsertVLEmbeddingAssets(ctx, entityType, entityID, model, language, assets); err != nil {
+		return err
+	}
+	if shadow, ok := s.Shadow.(VLAssetStorage); ok {
+		if err := shadow.UpsertVLEmbeddingAssets(ctx, entityType, entityID, model, language, assets); err != nil && s.OnShadowError != nil {
+			s.OnShadowError(err)
+		}
+	}
+	return nil
+}
+
+// UpsertVLEmbedding writes to Primary and mirrors to Shadow like
+// UpsertSparseEmbedding; both must implement VLStorage (a shadow that doesn't
+// is skipped).
+func (s *ShadowStorage) UpsertVLEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, embedding []float32, contentHash string) error {
+	primary, ok := s.Primary.(VLStorage)
+	if !ok {
+		return errors.New("ShadowStorage.Primary does not implement VLStorage")
+	}
+	if err := primary.UpsertVLEmbedding(ctx, entityType, entityID, model, language, embedding, contentHash); err != nil {
+		return err
+	}
+	if shadow, ok := s.Shadow.(VLStorage); ok {
+		if err := shadow.UpsertVLEmbedding(ctx, entityType, entityID, model, language, embedding, contentHash); err != nil && s.OnShadowError != nil {
+			s.OnShadowError(err)
+		}
+	}
+	return nil
+}
+
+// ReplaceAssetCaptions writes to Primary and mirrors to Shadow like
+// UpsertSparseEmbedding; both must implement CaptionStorage (a shadow that
+// doesn't is skipped).
+func (s *ShadowStorage) ReplaceAssetCaptions(ctx context.Context, entityType string, entityID string, language string, captions []pg.AssetCaption) error {
+	primary, ok := s.Primary.(CaptionStorage)
+	if !ok {
+		return errors.New("ShadowStorage.Primary does not implement CaptionStorage")
+	}
+	if err := primary.ReplaceAssetCaptions(ctx, entityType, entityID, language, captions); err != nil {
+		return err
+	}
+	if shadow, ok := s.Shadow.(CaptionStorage); ok {
+		if err := shadow.ReplaceAssetCaptions(ctx, entityType, entityID, language, captions); err != nil && s.OnShadowError != nil {
+			s.OnShadowError(err)
+		}
+	}
+	return nil
+}
+
+func (s *ShadowStorage) AssetCaptions(ctx context.Context, entityType string, language string, entityIDs []string) (map[string][]pg.AssetCaption, error) {
+	primary, ok := s.Primary.(CaptionStorage)
+	if !ok {
+		return nil, errors.New("ShadowStorage.Primary does not implement CaptionStorage")
+	}
+	return primary.AssetCaptions(ctx, entityType, language, entityIDs)
+}
+
+func (s *ShadowStorage) VLContentHashes(ctx context.Context, model string, keys []pg.EmbeddingKey) (map[pg.EmbeddingKey]string, error) {
+	primary, ok := s.Primary.(VLStorage)
+	if !ok {
+		return nil, errors.New("ShadowStorage.Primary does not implement VLStorage")
+	}
+	return primary.VLContentHashes(ctx, model, keys)
+}
+
+func (s *ShadowStorage) ContentHashes(ctx context.Context, model string, keys []pg.EmbeddingKey) (map[pg.EmbeddingKey]string, error) {
+	if s.Primary == nil {
+		return nil, errors.New("ShadowStorage.Primary is required")
+	}
+	return s.Primary.ContentHashes(ctx, model, keys)
+}
+
+// SetStorageModes forwards storage modes to both backends.
+func (s *ShadowStorage) SetStorageModes(modes map[string]pg.StorageMode) {
+	for _, st := range []Storage{s.Primary, s.Shadow} {
+		if setter, ok := st.(storageModeSetter); ok {
+			setter.SetStorageModes(modes)
+		}
+	}
+}
@@ -0,0 +1,780 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/open-rails/searchkit/embedder"
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/vl"
+)
+
+type countingEmbedder struct {
+	calls int
+}
+
+func (e *countingEmbedder) Model() string   { return "test-model" }
+func (e *countingEmbedder) Dimensions() int { return 3 }
+
+func (e *countingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	e.calls++
+	return []float32{float32(len(text)), 1, 0}, nil
+}
+
+func (e *countingEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = []float32{float32(len(t)), 1, 0}
+	}
+	return out, nil
+}
+
+func newTestRuntime(t *testing.T, emb *countingEmbedder, store Storage, opts Options) *Runtime {
+	t.Helper()
+	// The pool is never used: storage is in-memory and no tasks are enqueued.
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/unused")
+	if err != nil {
+		t.Fatalf("pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	opts.Pool = pool
+	opts.Schema = "app"
+	opts.TextEmbedders = append(opts.TextEmbedders, emb)
+	if opts.BuildSemanticDocument == nil && opts.BuildStructuredDocument == nil {
+		opts.BuildSemanticDocument = func(context.Context, string, string, []string) (map[string]string, error) {
+			return nil, nil
+		}
+	}
+	opts.Storage = store
+	rt, err := New(opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return rt
+}
+
+func TestRuntime_SkipsUnchangedDocuments(t *testing.T) {
+	emb := &countingEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, emb, store, Options{})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", "en", "hello"); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+	}
+	if emb.calls != 1 {
+		t.Fatalf("expected 1 provider call for an unchanged document, got %d", emb.calls)
+	}
+
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", "en", "hello again"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if emb.calls != 2 {
+		t.Fatalf("expected re-embed after change, got %d calls", emb.calls)
+	}
+}
+
+func TestRuntime_StoresChunks(t *testing.T) {
+	emb := &countingEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, emb, store, Options{
+		Chunking: map[string]ChunkOptions{"test-model": {MaxChars: 20, OverlapChars: -1}},
+	})
+
+	doc := strings.Repeat("word ", 20)
+	errs, err := rt.GenerateAndStoreTextEmbeddingsWithDocuments(context.Background(), "test-model", []TextEmbeddingItem{
+		{EntityType: "post", EntityID: "1", Language: "en", Document: doc},
+		{EntityType: "post", EntityID: "2", Language: "en", Document: "short"},
+	})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	for i, e := range errs {
+		if e != nil {
+			t.Fatalf("item %d: %v", i, e)
+		}
+	}
+	if n := len(store.Vectors("test-model", pg.EmbeddingKey{EntityType: "post", EntityID: "1", Language: "en"})); n < 2 {
+		t.Fatalf("expected multiple chunks for long document, got %d", n)
+	}
+	if n := len(store.Vectors("test-model", pg.EmbeddingKey{EntityType: "post", EntityID: "2", Language: "en"})); n != 1 {
+		t.Fatalf("expected 1 chunk for short document, got %d", n)
+	}
+}
+
+// bulkStorage records bulk writes, failing them when fail is set.
+type bulkStorage struct {
+	*MemoryStorage
+	fail  bool
+	calls int
+}
+
+func (s *bulkStorage) UpsertTextEmbeddingsBulk(ctx context.Context, model string, writes []pg.TextEmbeddingWrite) error {
+	s.calls++
+	if s.fail {
+		return errors.New("bulk write failed")
+	}
+	for _, w := range writes {
+		if err := s.UpsertTextEmbeddingChunks(ctx, w.EntityType, w.EntityID, model, w.Language, len(w.Chunks[0]), w.Chunks, w.ContentHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRuntime_BulkStoresBatches(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		store := &bulkStorage{MemoryStorage: NewMemoryStorage(), fail: fail}
+		rt := newTestRuntime(t, &countingEmbedder{}, store, Options{})
+
+		errs, err := rt.GenerateAndStoreTextEmbeddingsWithDocuments(context.Background(), "test-model", []TextEmbeddingItem{
+			{EntityType: "post", EntityID: "1", Language: "en", Document: "one"},
+			{EntityType: "post", EntityID: "2", Language: "en", Document: "two"},
+		})
+		if err != nil || errs[0] != nil || errs[1] != nil {
+			t.Fatalf("fail=%v: generate: %v %v", fail, err, errs)
+		}
+		if store.calls != 1 {
+			t.Fatalf("fail=%v: expected 1 bulk write, got %d", fail, store.calls)
+		}
+		// A failed bulk write falls back to per-item upserts.
+		for _, id := range []string{"1", "2"} {
+			if n := len(store.Vectors("test-model", pg.EmbeddingKey{EntityType: "post", EntityID: id, Language: "en"})); n != 1 {
+				t.Fatalf("fail=%v: expected post %s stored, got %d vectors", fail, id, n)
+			}
+		}
+	}
+}
+
+type failingStorage struct{ Storage }
+
+func (failingStorage) UpsertTextEmbeddingChunks(context.Context, string, string, string, string, int, [][]float32, string) error {
+	return errors.New("shadow down")
+}
+
+func TestShadowStorage_IgnoresShadowErrors(t *testing.T) {
+	primary := NewMemoryStorage()
+	var shadowErr error
+	s := &ShadowStorage{
+		Primary:       primary,
+		Shadow:        failingStorage{},
+		OnShadowError: func(err error) { shadowErr = err },
+	}
+	if err := s.UpsertTextEmbeddingChunks(context.Background(), "post", "1", "m", "en", 2, [][]float32{{1, 0}}, "h"); err != nil {
+		t.Fatalf("expected primary write to succeed, got %v", err)
+	}
+	if shadowErr == nil {
+		t.Fatalf("expected shadow error to be reported")
+	}
+	if primary.Len() != 1 {
+		t.Fatalf("expected primary to hold 1 entry, got %d", primary.Len())
+	}
+}
+
+type mapCache map[string][]float32
+
+func (c mapCache) GetEmbeddings(_ context.Context, model string, hashes []string) (map[string][]float32, error) {
+	out := map[string][]float32{}
+	for _, h := range hashes {
+		if v, ok := c[model+"/"+h]; ok {
+			out[h] = v
+		}
+	}
+	return out, nil
+}
+
+func (c mapCache) PutEmbeddings(_ context.Context, model string, entries map[string][]float32) error {
+	for h, v := range entries {
+		c[model+"/"+h] = v
+	}
+	return nil
+}
+
+func TestRuntime_EmbeddingCacheReusesVectors(t *testing.T) {
+	emb := &countingEmbedder{}
+	rt := newTestRuntime(t, emb, NewMemoryStorage(), Options{EmbeddingCache: mapCache{}})
+	ctx := context.Background()
+
+	// Two entities with the same document in one batch: one provider input.
+	if _, err := rt.GenerateAndStoreTextEmbeddingsWithDocuments(ctx, "test-model", []TextEmbeddingItem{
+		{EntityType: "post", EntityID: "1", Language: "en", Document: "same title"},
+		{EntityType: "post", EntityID: "2", Language: "en", Document: "same title"},
+	}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	// A third entity later is served from the cache.
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "3", "test-model", "en", "same title"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if emb.calls != 1 {
+		t.Fatalf("expected 1 provider call, got %d", emb.calls)
+	}
+	stats := rt.EmbeddingCacheStats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatalf("unexpected cache stats: %+v", stats)
+	}
+}
+
+type recordingMetrics struct {
+	NopMetricsSink
+	providerInputs int
+	generated      int
+	upsertedChunks int
+}
+
+func (m *recordingMetrics) ProviderCall(model string, inputs int, _ time.Duration, _ error) {
+	m.providerInputs += inputs
+}
+func (m *recordingMetrics) EmbeddingsGenerated(model string, n int) { m.generated += n }
+func (m *recordingMetrics) VectorsUpserted(model string, chunks int, _ time.Duration, _ error) {
+	m.upsertedChunks += chunks
+}
+
+func TestRuntime_ReportsMetrics(t *testing.T) {
+	emb := &countingEmbedder{}
+	metrics := &recordingMetrics{}
+	rt := newTestRuntime(t, emb, NewMemoryStorage(), Options{
+		Metrics:  metrics,
+		Chunking: map[string]ChunkOptions{"test-model": {MaxChars: 12, OverlapChars: -1}},
+	})
+	ctx := context.Background()
+
+	items := []TextEmbeddingItem{
+		{EntityType: "post", EntityID: "1", Language: "en", Document: "alpha beta gamma delta"},
+		{EntityType: "post", EntityID: "2", Language: "en", Document: "short"},
+	}
+	if _, err := rt.GenerateAndStoreTextEmbeddingsWithDocuments(ctx, "test-model", items); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if metrics.generated != 2 {
+		t.Fatalf("expected 2 generated embeddings, got %d", metrics.generated)
+	}
+	if metrics.upsertedChunks != 3 || metrics.providerInputs != 3 {
+		t.Fatalf("expected 3 chunks embedded and upserted, got %d/%d", metrics.providerInputs, metrics.upsertedChunks)
+	}
+}
+
+func TestRuntime_WithReembedBypassesHashCheck(t *testing.T) {
+	emb := &countingEmbedder{}
+	rt := newTestRuntime(t, emb, NewMemoryStorage(), Options{})
+	ctx := WithReembed(context.Background())
+
+	for i := 0; i < 2; i++ {
+		if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", "en", "hello"); err != nil {
+			t.Fatalf("generate: %v", err)
+		}
+	}
+	if emb.calls != 2 {
+		t.Fatalf("expected a provider call per forced re-embed, got %d", emb.calls)
+	}
+}
+
+func TestRuntime_LanguageAgnosticModelEmbedsOnce(t *testing.T) {
+	emb := &countingEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, emb, store, Options{LanguageAgnosticModels: []string{"test-model"}})
+	ctx := context.Background()
+
+	for _, lang := range []string{"en", "de"} {
+		if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", lang, "hello"); err != nil {
+			t.Fatalf("generate %s: %v", lang, err)
+		}
+	}
+	if emb.calls != 1 {
+		t.Fatalf("expected 1 provider call across languages, got %d", emb.calls)
+	}
+	if store.Len() != 1 || store.Vectors("test-model", pg.EmbeddingKey{EntityType: "post", EntityID: "1", Language: pg.AnyLanguage}) == nil {
+		t.Fatalf("expected a single %q entry, got %d entries", pg.AnyLanguage, store.Len())
+	}
+}
+
+func TestRuntime_NormalizeNoneStoresRawVectors(t *testing.T) {
+	emb := &countingEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, emb, store, Options{Normalization: map[string]pg.Normalization{"test-model": pg.NormalizeNone}})
+	ctx := context.Background()
+
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", "en", "hello"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	got := store.Vectors("test-model", pg.EmbeddingKey{EntityType: "post", EntityID: "1", Language: "en"})
+	if len(got) != 1 || got[0][0] != 5 || got[0][1] != 1 {
+		t.Fatalf("expected the raw provider vector, got %v", got)
+	}
+	q, err := rt.EmbedQuery(ctx, "test-model", "en", "hello")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if q[0] != 5 {
+		t.Fatalf("expected the raw query vector, got %v", q)
+	}
+}
+
+func TestRuntime_NormalizeNoneKeepsProviderVectors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","embedding":[3,4,0],"index":0}]}`))
+	}))
+	defer srv.Close()
+	oa, err := embedder.NewOpenAICompatible(embedder.OpenAICompatibleConfig{BaseURL: srv.URL, Model: "oa", Dimensions: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{
+		TextEmbedders: []embedder.Embedder{oa},
+		Normalization: map[string]pg.Normalization{"oa": pg.NormalizeNone},
+	})
+	ctx := context.Background()
+
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "oa", "en", "hello"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	got := store.Vectors("oa", pg.EmbeddingKey{EntityType: "post", EntityID: "1", Language: "en"})
+	if len(got) != 1 || got[0][0] != 3 || got[0][1] != 4 {
+		t.Fatalf("expected the raw provider vector, got %v", got)
+	}
+	q, err := rt.EmbedQuery(ctx, "oa", "en", "hello")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if q[0] != 3 || q[1] != 4 {
+		t.Fatalf("expected the raw query vector, got %v", q)
+	}
+}
+
+func TestRuntime_StorageModeChangeReembeds(t *testing.T) {
+	emb := &countingEmbedder{}
+	store := NewMemoryStorage()
+	ctx := context.Background()
+
+	rt := newTestRuntime(t, emb, store, Options{})
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", "en", "hello"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	rt = newTestRuntime(t, emb, store, Options{StorageModes: map[string]pg.StorageMode{"test-model": pg.StorageVector}})
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(ctx, "post", "1", "test-model", "en", "hello"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if emb.calls != 2 {
+		t.Fatalf("expected a re-embed after switching to vector storage, got %d calls", emb.calls)
+	}
+}
+
+type misdeclaredEmbedder struct{ countingEmbedder }
+
+func (e *misdeclaredEmbedder) Dimensions() int { return 1024 }
+
+func TestRuntime_ProbeDimensionsCatchesMisdeclaredWidth(t *testing.T) {
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{})
+	if err := rt.ProbeDimensions(context.Background()); err != nil {
+		t.Fatalf("expected matching dims to pass, got %v", err)
+	}
+
+	rt.textEmbedders["test-model"] = &misdeclaredEmbedder{}
+	err := rt.ProbeDimensions(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "declares 1024 dims but provider returned 3") {
+		t.Fatalf("expected a dimension mismatch error, got %v", err)
+	}
+}
+
+func TestRuntime_ProbeVLReportsUnfetchableURLs(t *testing.T) {
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		VLEmbedders:   []vl.Embedder{fakeAssetEmbedder{}},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+		VLAssetModels: []string{"vl"},
+	})
+	if err := rt.ProbeVL(context.Background(), "https://example.com/probe.jpg"); err != nil {
+		t.Fatalf("expected a working embedder to pass, got %v", err)
+	}
+
+	rt.vlEmbedders["vl"] = &unreachableVLEmbedder{}
+	delete(rt.vlAssets, "vl")
+	err := rt.ProbeVL(context.Background(), "https://example.com/probe.jpg")
+	if !errors.Is(err, vl.ErrAssetUnreachable) || !strings.Contains(err.Error(), "model vl: provider cannot fetch asset URLs") {
+		t.Fatalf("expected an unreachable-URL error, got %v", err)
+	}
+}
+
+type fakeSparseEmbedder struct{ calls int }
+
+func (e *fakeSparseEmbedder) Model() string   { return "splade" }
+func (e *fakeSparseEmbedder) Dimensions() int { return 100 }
+
+func (e *fakeSparseEmbedder) EmbedSparseTexts(ctx context.Context, texts []string) ([]embedder.SparseVector, error) {
+	e.calls++
+	out := make([]embedder.SparseVector, len(texts))
+	for i, t := range texts {
+		out[i] = embedder.SparseVector{Indices: []int32{1, int32(len(t))}, Values: []float32{0.5, 2}}
+	}
+	return out, nil
+}
+
+func TestRuntime_SparseModelStoresSparseVectors(t *testing.T) {
+	sparse := &fakeSparseEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{SparseEmbedders: []embedder.SparseEmbedder{sparse}})
+	if !rt.IsSparseModel("splade") || rt.IsVLModel("splade") {
+		t.Fatalf("expected splade to be a sparse model")
+	}
+
+	items := []TextEmbeddingItem{{EntityType: "game", EntityID: "1", Language: "en", Document: "space opera"}}
+	errs, err := rt.GenerateAndStoreTextEmbeddingsWithDocuments(context.Background(), "splade", items)
+	if err != nil || errs[0] != nil {
+		t.Fatalf("store: %v %v", err, errs)
+	}
+	vec := store.SparseVector("splade", pg.EmbeddingKey{EntityType: "game", EntityID: "1", Language: "en"})
+	if vec.Dimensions() != 100 || len(vec.Indices()) != 2 || vec.Indices()[1] != int32(len("space opera")) {
+		t.Fatalf("unexpected stored sparse vector %v", vec)
+	}
+
+	// Unchanged documents are skipped like dense ones.
+	if _, err := rt.GenerateAndStoreTextEmbeddingsWithDocuments(context.Background(), "splade", items); err != nil {
+		t.Fatal(err)
+	}
+	if sparse.calls != 1 {
+		t.Fatalf("expected 1 provider call, got %d", sparse.calls)
+	}
+}
+
+func TestRuntime_HybridModelStoresDenseAndSparse(t *testing.T) {
+	sparse := &fakeSparseEmbedder{}
+	emb := &countingEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, emb, store, Options{HybridSparseEmbedders: map[string]embedder.SparseEmbedder{"test-model": sparse}})
+	if !rt.IsHybridModel("test-model") || rt.IsSparseModel("test-model") {
+		t.Fatalf("expected test-model to be a hybrid model")
+	}
+	if specs := rt.ModelSpecs(); len(specs) != 1 || specs[0].SparseDims != 100 {
+		t.Fatalf("unexpected model specs %+v", specs)
+	}
+
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(context.Background(), "game", "1", "test-model", "en", "space opera"); err != nil {
+		t.Fatal(err)
+	}
+	key := pg.EmbeddingKey{EntityType: "game", EntityID: "1", Language: "en"}
+	if len(store.Vectors("test-model", key)) != 1 {
+		t.Fatalf("expected a dense vector")
+	}
+	if vec := store.SparseVector("test-model", key); vec.Dimensions() != 100 || len(vec.Indices()) != 2 {
+		t.Fatalf("unexpected stored sparse vector %v", vec)
+	}
+
+	// Unchanged documents skip both providers.
+	if err := rt.GenerateAndStoreTextEmbeddingWithDocument(context.Background(), "game", "1", "test-model", "en", "space opera"); err != nil {
+		t.Fatal(err)
+	}
+	if emb.calls != 1 || sparse.calls != 1 {
+		t.Fatalf("expected 1 call per provider, got %d dense and %d sparse", emb.calls, sparse.calls)
+	}
+}
+
+type fakeAssetEmbedder struct{}
+
+func (fakeAssetEmbedder) Model() string   { return "vl" }
+func (fakeAssetEmbedder) Dimensions() int { return 2 }
+
+func (e fakeAssetEmbedder) EmbedTextAndAssetURLs(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, error) {
+	fused, _, err := e.EmbedAssetVectors(ctx, text, assets)
+	return fused, err
+}
+
+func (fakeAssetEmbedder) EmbedAssetVectors(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, [][]float32, error) {
+	per := make([][]float32, len(assets))
+	for i := range assets {
+		per[i] = []float32{float32(i + 1), 1}
+	}
+	return []float32{1, 1}, per, nil
+}
+
+func TestRuntime_VLAssetModelStoresPerAssetVectors(t *testing.T) {
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{
+		VLEmbedders:   []vl.Embedder{fakeAssetEmbedder{}},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+		VLAssetModels: []string{"vl"},
+	})
+	assets := []vl.AssetURL{
+		{Kind: vl.AssetKindImage, URL: "https://cdn/cover.jpg?sig=1", Key: "cover"},
+		{Kind: vl.AssetKindFrame, URL: "https://cdn/f2.jpg", FrameIdx: 2},
+	}
+	if err := rt.GenerateAndStoreVLEmbeddingWithInputs(context.Background(), "video", "1", "vl", "en", "doc", assets); err != nil {
+		t.Fatal(err)
+	}
+	key := pg.EmbeddingKey{EntityType: "video", EntityID: "1", Language: "en"}
+	if len(store.Vectors("vl", key)) != 1 {
+		t.Fatalf("expected the fused vector to be stored")
+	}
+	got := store.Assets("vl", key)
+	if len(got) != 2 || got[0].Key != "cover" || got[1].Key != "https://cdn/f2.jpg" || got[1].FrameIdx != 2 || got[1].Kind != "frame" {
+		t.Fatalf("unexpected stored assets %+v", got)
+	}
+	if v := got[1].Vector; math.Abs(float64(v[0]*v[0]+v[1]*v[1])-1) > 1e-5 {
+		t.Fatalf("expected normalized asset vectors, got %v", v)
+	}
+}
+
+type countingVLEmbedder struct {
+	fakeAssetEmbedder
+	calls int
+}
+
+func (e *countingVLEmbedder) EmbedTextAndAssetURLs(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, error) {
+	e.calls++
+	return e.fakeAssetEmbedder.EmbedTextAndAssetURLs(ctx, text, assets)
+}
+
+func TestRuntime_VLSkipsUnchangedAssetSet(t *testing.T) {
+	emb := &countingVLEmbedder{}
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		VLEmbedders:   []vl.Embedder{emb},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+	})
+	ctx := context.Background()
+	embed := func(doc string, assets ...vl.AssetURL) {
+		t.Helper()
+		if err := rt.GenerateAndStoreVLEmbeddingWithInputs(ctx, "gallery", "1", "vl", "en", doc, assets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cover := func(sig string) vl.AssetURL {
+		return vl.AssetURL{Kind: vl.AssetKindImage, URL: "https://cdn/cover.jpg?sig=" + sig, Key: "cover"}
+	}
+
+	embed("doc", cover("1"))
+	// A fresh presigned URL for the same asset key is not a change.
+	embed("doc", cover("2"))
+	if emb.calls != 1 {
+		t.Fatalf("expected unchanged inputs to be skipped, got %d calls", emb.calls)
+	}
+	embed("doc", cover("2"), vl.AssetURL{Kind: vl.AssetKindImage, URL: "https://cdn/p1.jpg"})
+	embed("doc v2", cover("2"), vl.AssetURL{Kind: vl.AssetKindImage, URL: "https://cdn/p1.jpg"})
+	if emb.calls != 3 {
+		t.Fatalf("expected asset and document changes to re-embed, got %d calls", emb.calls)
+	}
+}
+
+func TestRuntime_GenerateAndStoreEmbeddingResolvesAliases(t *testing.T) {
+	emb := &countingVLEmbedder{}
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		VLEmbedders:  []vl.Embedder{emb},
+		ModelAliases: map[string]string{"vl-legacy": "vl"},
+		BuildSemanticDocument: func(_ context.Context, _ string, _ string, ids []string) (map[string]string, error) {
+			return map[string]string{ids[0]: "doc"}, nil
+		},
+		ListAssetURLs: func(_ context.Context, _ string, ids []string) (map[string][]vl.AssetURL, error) {
+			return map[string][]vl.AssetURL{ids[0]: {{Kind: vl.AssetKindImage, URL: "https://cdn/cover.jpg"}}}, nil
+		},
+	})
+	if err := rt.GenerateAndStoreEmbedding(context.Background(), "gallery", "1", "vl-legacy", "en"); err != nil {
+		t.Fatal(err)
+	}
+	if emb.calls != 1 {
+		t.Fatalf("expected the alias to route to the vl embedder, got %d calls", emb.calls)
+	}
+}
+
+type unreachableVLEmbedder struct {
+	fakeAssetEmbedder
+	uploaded []vl.AssetBytes
+}
+
+func (*unreachableVLEmbedder) EmbedTextAndAssetURLs(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, error) {
+	return nil, fmt.Errorf("fetch %s: %w", assets[0].URL, vl.ErrAssetUnreachable)
+}
+
+func (e *unreachableVLEmbedder) EmbedTextAndAssetBytes(ctx context.Context, text string, assets []vl.AssetBytes) ([]float32, [][]float32, error) {
+	e.uploaded = assets
+	return []float32{1, 0}, nil, nil
+}
+
+func TestRuntime_VLUploadsBytesWhenProviderCannotFetch(t *testing.T) {
+	emb := &unreachableVLEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{
+		VLEmbedders:   []vl.Embedder{emb},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+	})
+	assets := []vl.AssetURL{
+		{Kind: vl.AssetKindImage, URL: "https://private/cover.png"},
+		{Kind: vl.AssetKindVideo, URL: "https://private/v.mp4"},
+	}
+	if err := rt.GenerateAndStoreVLEmbeddingWithInputs(context.Background(), "video", "1", "vl", "en", "doc", assets); !errors.Is(err, vl.ErrAssetUnreachable) {
+		t.Fatalf("expected the provider error without a fetcher, got %v", err)
+	}
+
+	fetch := func(_ context.Context, a vl.AssetURL) ([]byte, string, error) {
+		return []byte("\x89PNG\r\n\x1a\n"), "", nil
+	}
+	ctx := WithAssetFetcher(context.Background(), fetch, false)
+	if err := rt.GenerateAndStoreVLEmbeddingWithInputs(ctx, "video", "1", "vl", "en", "doc", assets); err != nil {
+		t.Fatal(err)
+	}
+	if len(emb.uploaded) != 2 || emb.uploaded[0].MIMEType != "image/png" || emb.uploaded[1].Data != nil {
+		t.Fatalf("expected the image uploaded and the video left as a URL, got %+v", emb.uploaded)
+	}
+	if len(store.Vectors("vl", pg.EmbeddingKey{EntityType: "video", EntityID: "1", Language: "en"})) != 1 {
+		t.Fatalf("expected the uploaded embedding to be stored")
+	}
+}
+
+type batchVLEmbedder struct {
+	fakeAssetEmbedder
+	batches [][]vl.Input
+}
+
+func (*batchVLEmbedder) MaxBatch() int { return 4 }
+
+func (e *batchVLEmbedder) EmbedBatch(ctx context.Context, inputs []vl.Input) ([]vl.BatchResult, error) {
+	e.batches = append(e.batches, inputs)
+	out := make([]vl.BatchResult, len(inputs))
+	for i := range inputs {
+		out[i].Fused = []float32{float32(i + 1), 1}
+	}
+	return out, nil
+}
+
+func TestRuntime_VLBatchSharesProviderCalls(t *testing.T) {
+	emb := &batchVLEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{
+		VLEmbedders:   []vl.Embedder{emb},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+	})
+	cover := []vl.AssetURL{{Kind: vl.AssetKindImage, URL: "https://cdn/c.jpg"}}
+	items := []VLEmbeddingItem{
+		{EntityType: "gallery", EntityID: "1", Language: "en", Document: "one", Assets: cover},
+		{EntityType: "gallery", EntityID: "2", Language: "en", Document: "", Assets: cover},
+		{EntityType: "gallery", EntityID: "3", Language: "en", Document: "three", Assets: cover},
+	}
+	errs, err := rt.GenerateAndStoreVLEmbeddingsWithInputs(context.Background(), "vl", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], ErrEntityNotFound) || errs[2] != nil {
+		t.Fatalf("unexpected per-item errors %v", errs)
+	}
+	if len(emb.batches) != 1 || len(emb.batches[0]) != 2 || rt.MaxBatch("vl") != 4 {
+		t.Fatalf("expected one shared provider call, got %v", emb.batches)
+	}
+	if v := store.Vectors("vl", pg.EmbeddingKey{EntityType: "gallery", EntityID: "3", Language: "en"}); len(v) != 1 || v[0][0] <= v[0][1] {
+		t.Fatalf("expected results stored by item, got %v", v)
+	}
+
+	// Unchanged items are skipped without a provider call.
+	if _, err := rt.GenerateAndStoreVLEmbeddingsWithInputs(context.Background(), "vl", items[:1]); err != nil || len(emb.batches) != 1 {
+		t.Fatalf("expected unchanged items to be skipped (err %v, batches %d)", err, len(emb.batches))
+	}
+}
+
+func TestRuntime_VideoSegmentsStoreTimedVectors(t *testing.T) {
+	emb := &batchVLEmbedder{}
+	store := NewMemoryStorage()
+	rt := newTestRuntime(t, &countingEmbedder{}, store, Options{
+		VLEmbedders:   []vl.Embedder{emb},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+		VideoSegments: map[string]vl.SegmentPolicy{"vl": {
+			Length:   time.Minute,
+			Frames:   1,
+			Duration: func(context.Context, vl.AssetURL) (time.Duration, error) { return 90 * time.Second, nil },
+			FrameURL: func(_ context.Context, v vl.AssetURL, at time.Duration) (string, error) {
+				return fmt.Sprintf("%s?t=%d", v.URL, int(at.Seconds())), nil
+			},
+		}},
+	})
+	assets := []vl.AssetURL{{Kind: vl.AssetKindVideo, URL: "https://cdn/v.mp4", Key: "v"}}
+	if err := rt.GenerateAndStoreVLEmbeddingWithInputs(context.Background(), "video", "1", "vl", "en", "doc", assets); err != nil {
+		t.Fatal(err)
+	}
+	if len(emb.batches) != 1 || len(emb.batches[0]) != 2 || emb.batches[0][1].Assets[0].URL != "https://cdn/v.mp4?t=75" {
+		t.Fatalf("expected both segments embedded in one call, got %+v", emb.batches)
+	}
+	got := store.Assets("vl", pg.EmbeddingKey{EntityType: "video", EntityID: "1", Language: "en"})
+	if len(got) != 2 || got[1].Key != "v#t=60,90" || got[1].Kind != "segment" || got[1].Start != time.Minute || got[1].End != 90*time.Second || got[1].URL != "https://cdn/v.mp4" {
+		t.Fatalf("unexpected segment rows %+v", got)
+	}
+}
+
+type fakeDualEncoder struct {
+	countingVLEmbedder
+}
+
+func (*fakeDualEncoder) EmbedQueryText(ctx context.Context, text string) ([]float32, error) {
+	return []float32{3, 4}, nil
+}
+
+func TestRuntime_DualEncoderStoresImageVectorsAndEmbedsTextQueries(t *testing.T) {
+	emb := &fakeDualEncoder{}
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		VLEmbedders:   []vl.Embedder{emb},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+	})
+	assets := []vl.AssetURL{{Kind: vl.AssetKindImage, URL: "https://cdn/c.jpg"}}
+	for _, doc := range []string{"title v1", "title v2"} {
+		if err := rt.GenerateAndStoreVLEmbeddingWithInputs(context.Background(), "gallery", "1", "vl", "en", doc, assets); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if emb.calls != 1 {
+		t.Fatalf("expected document edits not to re-embed image vectors, got %d calls", emb.calls)
+	}
+	vec, err := rt.EmbedQueryText(context.Background(), "vl", "red dress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vec) != 2 || math.Abs(float64(vec[0])-0.6) > 1e-6 {
+		t.Fatalf("expected the normalized text-encoder vector, got %v", vec)
+	}
+}
+
+func TestRuntime_EmbedEntityTextEmbedsTheDocumentInVLSpace(t *testing.T) {
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), Options{
+		VLEmbedders:   []vl.Embedder{&fakeDualEncoder{}},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+		BuildSemanticDocument: func(_ context.Context, _ string, _ string, ids []string) (map[string]string, error) {
+			return map[string]string{"1": "red dress"}, nil
+		},
+	})
+	vec, err := rt.EmbedEntityText(context.Background(), "gallery", "1", "vl", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vec) != 2 || math.Abs(float64(vec[1])-0.8) > 1e-6 {
+		t.Fatalf("expected the normalized text-encoder vector, got %v", vec)
+	}
+	if vec, err := rt.EmbedEntityText(context.Background(), "gallery", "2", "vl", "en"); err != nil || vec != nil {
+		t.Fatalf("expected no vector for an entity without a document, got %v, %v", vec, err)
+	}
+}
+
+func TestRuntime_AssetDependentModels(t *testing.T) {
+	opts := Options{
+		VLEmbedders:   []vl.Embedder{fakeAssetEmbedder{}},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+	}
+	rt := newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), opts)
+	if got := rt.AssetDependentModels(); !slices.Equal(got, []string{"vl"}) {
+		t.Fatalf("expected only the VL model, got %v", got)
+	}
+
+	// Asset text feeds every document, so every model depends on assets.
+	opts.ExtractAssetText = func(context.Context, string, string, map[string][]vl.AssetURL) (map[string]string, error) {
+		return nil, nil
+	}
+	rt = newTestRuntime(t, &countingEmbedder{}, NewMemoryStorage(), opts)
+	if got := rt.AssetDependentModels(); len(got) != 2 || rt.LexicalUsesAssets() {
+		t.Fatalf("expected both models and no lexical asset text, got %v", got)
+	}
+}
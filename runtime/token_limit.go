@@ -0,0 +1,154 @@
+package runtime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/internal/tokens"
+)
+
+// TruncateStrategy selects which part of an over-long input is kept.
+type TruncateStrategy string
+
+const (
+	// TruncateHead keeps the beginning of the text (default).
+	TruncateHead TruncateStrategy = "head"
+	// TruncateHeadTail keeps the beginning and the end, dropping the middle.
+	TruncateHeadTail TruncateStrategy = "head_tail"
+)
+
+// TokenLimit caps provider inputs for a model so a single long document does
+// not make the provider reject a whole batch.
+type TokenLimit struct {
+	// MaxTokens is the model's input limit, including instruction templates.
+	MaxTokens int
+	// Strategy defaults to TruncateHead.
+	Strategy TruncateStrategy
+	// CountTokens returns the token count of text. Defaults to EstimateTokens;
+	// set it to the model's tokenizer for exact limits.
+	CountTokens func(text string) int
+}
+
+func (l TokenLimit) withDefaults() TokenLimit {
+	out := l
+	if out.Strategy == "" {
+		out.Strategy = TruncateHead
+	}
+	if out.CountTokens == nil {
+		out.CountTokens = EstimateTokens
+	}
+	return out
+}
+
+func (l TokenLimit) validate() error {
+	if l.MaxTokens <= 0 {
+		return fmt.Errorf("MaxTokens must be > 0")
+	}
+	switch l.Strategy {
+	case TruncateHead, TruncateHeadTail:
+		return nil
+	default:
+		return fmt.Errorf("invalid Strategy %q", l.Strategy)
+	}
+}
+
+// TruncationEvent reports an input that was cut to fit a model's TokenLimit.
+// EntityType/EntityID are empty for queries.
+type TruncationEvent struct {
+	Model      string
+	EntityType string
+	EntityID   string
+	Language   string
+	ChunkIdx   int
+	Tokens     int // estimated tokens before truncation
+	MaxTokens  int
+}
+
+// EstimateTokens is a tokenizer-free estimate: one token per CJK character and
+// one per ~4 other characters. It errs high for typical English text.
+func EstimateTokens(text string) int {
+	return tokens.Estimate(text)
+}
+
+const truncationSeparator = " … "
+
+// truncateToTokens cuts text so that count(text) <= budget.
+func truncateToTokens(text string, budget int, l TokenLimit) string {
+	if budget <= 0 {
+		return ""
+	}
+	if l.Strategy == TruncateHeadTail {
+		headBudget := budget / 2
+		head := fitPrefix(text, headBudget, l.CountTokens)
+		rest := text[len(head):]
+		tailBudget := budget - l.CountTokens(head) - l.CountTokens(truncationSeparator)
+		tail := fitSuffix(rest, tailBudget, l.CountTokens)
+		if tail == "" {
+			return fitPrefix(text, budget, l.CountTokens)
+		}
+		return strings.TrimSpace(head) + truncationSeparator + strings.TrimSpace(tail)
+	}
+	return fitPrefix(text, budget, l.CountTokens)
+}
+
+// fitPrefix returns the longest prefix of text (in runes) within budget.
+func fitPrefix(text string, budget int, count func(string) int) string {
+	if budget <= 0 {
+		return ""
+	}
+	r := []rune(text)
+	lo, hi := 0, len(r)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(string(r[:mid])) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(r[:lo])
+}
+
+// fitSuffix returns the longest suffix of text (in runes) within budget.
+func fitSuffix(text string, budget int, count func(string) int) string {
+	if budget <= 0 {
+		return ""
+	}
+	r := []rune(text)
+	lo, hi := 0, len(r)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if count(string(r[len(r)-mid:])) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(r[len(r)-lo:])
+}
+
+// fitTokens truncates text to model's TokenLimit, reserving room for tmpl (the
+// instruction template applied afterwards). It reports truncation via
+// Options.OnTruncate.
+func (r *Runtime) fitTokens(model string, tmpl string, text string, ev TruncationEvent) string {
+	l, ok := r.tokenLimits[model]
+	if !ok {
+		return text
+	}
+	reserve := 0
+	if tmpl != "" {
+		reserve = l.CountTokens(strings.NewReplacer(instructionText, "", instructionLanguage, ev.Language).Replace(tmpl))
+	}
+	budget := l.MaxTokens - reserve
+	n := l.CountTokens(text)
+	if n <= budget {
+		return text
+	}
+	if r.onTruncate != nil {
+		ev.Model = model
+		ev.Tokens = n + reserve
+		ev.MaxTokens = l.MaxTokens
+		r.onTruncate(ev)
+	}
+	return truncateToTokens(text, budget, l)
+}
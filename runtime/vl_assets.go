@@ -0,0 +1,301 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/vl"
+)
+
+// VLAssetStorage is implemented by storages that can hold per-asset VL
+// vectors; it is required when Options.VLAssetModels is set.
+// pg.PostgresStorage and MemoryStorage implement it.
+type VLAssetStorage interface {
+	UpsertVLEmbeddingAssets(ctx context.Context, entityType string, entityID string, model string, language string, assets []pg.AssetEmbedding) error
+}
+
+var _ VLAssetStorage = (*pg.PostgresStorage)(nil)
+
+// VLStorage is implemented by storages that keep fused VL vectors apart from
+// text vectors (embedding_vectors_vl); it is required when VLEmbedders are
+// configured. pg.PostgresStorage and MemoryStorage implement it.
+type VLStorage interface {
+	UpsertVLEmbedding(ctx context.Context, entityType string, entityID string, model string, language string, embedding []float32, contentHash string) error
+	// VLContentHashes is Storage.ContentHashes for VL vectors.
+	VLContentHashes(ctx context.Context, model string, keys []pg.EmbeddingKey) (map[pg.EmbeddingKey]string, error)
+}
+
+var _ VLStorage = (*pg.PostgresStorage)(nil)
+
+// IsVLAssetModel reports whether model stores per-asset vectors (see
+// Options.VLAssetModels).
+func (r *Runtime) IsVLAssetModel(model string) bool {
+	_, ok := r.vlAssets[r.CanonicalModel(model)]
+	return ok
+}
+
+// vlHash is the content hash stored with a VL vector: the document hash plus
+// the asset set (kind, storage key and frame, order-insensitive), including
+// video segments' assets. Presigned URL churn doesn't re-embed assets with a
+// stable Key, so hosts should change Key when an asset's content changes.
+func (r *Runtime) vlHash(model string, doc string, assets []vl.AssetURL, segments []vl.Segment) string {
+	set := make([]string, 0, len(assets)+len(segments))
+	for _, a := range assets {
+		set = append(set, fmt.Sprintf("%s:%d:%s", a.Kind, a.FrameIdx, a.StorageKey()))
+	}
+	for _, seg := range segments {
+		for _, a := range seg.Assets {
+			set = append(set, fmt.Sprintf("%s/%s:%d:%s", vl.AssetKindSegment, a.Kind, a.FrameIdx, a.StorageKey()))
+		}
+	}
+	sort.Strings(set)
+	var b strings.Builder
+	if _, ok := r.vlAssets[model]; ok {
+		b.WriteString("per-asset\n")
+	}
+	for _, s := range set {
+		fmt.Fprintf(&b, "asset:%q\n", s)
+	}
+	b.WriteString(r.documentHash(model, doc))
+	return pg.ContentHash(b.String())
+}
+
+// vlInput is a VL item resolved for embedding: language, rendered document,
+// assets after frame sampling, video segments, and content hash.
+type vlInput struct {
+	language string
+	doc      string
+	assets   []vl.AssetURL
+	segments []vl.Segment
+	hash     string
+}
+
+// FilterAssets drops assets of kinds model doesn't accept (see
+// Options.AssetKinds), so hydration can fail early with
+// vl.ErrUnsupportedAssetKind. Videos count as accepted when the model samples
+// them into frames (Options.FrameSampling).
+func (r *Runtime) FilterAssets(model string, assets []vl.AssetURL) ([]vl.AssetURL, error) {
+	model = r.CanonicalModel(model)
+	_, sampled := r.framePolicies[model]
+	return r.filterAssetKinds(model, assets, sampled)
+}
+
+func (r *Runtime) filterAssetKinds(model string, assets []vl.AssetURL, framesForVideos bool) ([]vl.AssetURL, error) {
+	kinds := r.assetKinds[model]
+	if len(kinds) == 0 {
+		return assets, nil
+	}
+	if framesForVideos && slices.Contains(kinds, vl.AssetKindFrame) && !slices.Contains(kinds, vl.AssetKindVideo) {
+		kinds = append(slices.Clone(kinds), vl.AssetKindVideo)
+	}
+	out, err := vl.FilterKinds(assets, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("model %q: %w", model, err)
+	}
+	return out, nil
+}
+
+// prepareVL resolves a VL item's language, document, video segments, frames,
+// selected assets and hash.
+func (r *Runtime) prepareVL(ctx context.Context, model string, language string, doc string, assets []vl.AssetURL) (vlInput, error) {
+	language = r.EmbeddingLanguage(model, language)
+	doc = r.renderDocument(model, language, doc)
+	if strings.TrimSpace(doc) == "" || len(assets) == 0 {
+		return vlInput{}, ErrEntityNotFound
+	}
+	if _, ok := r.vlEmbedders[model].(vl.DualEncoder); ok {
+		// Dual encoders store image vectors only, so document edits don't
+		// change (or re-embed) them.
+		doc = ""
+	}
+	var segments []vl.Segment
+	if p, ok := r.segments[model]; ok {
+		var err error
+		if segments, err = p.Segments(ctx, assets); err != nil {
+			return vlInput{}, err
+		}
+	}
+	if p, ok := r.framePolicies[model]; ok {
+		sampled, err := p.Expand(ctx, assets)
+		if err != nil {
+			return vlInput{}, err
+		}
+		if len(sampled) == 0 {
+			return vlInput{}, ErrEntityNotFound
+		}
+		assets = sampled
+	}
+	assets, err := r.filterAssetKinds(model, assets, false)
+	if err != nil {
+		return vlInput{}, err
+	}
+	if sel, ok := r.assetLimits[model]; ok {
+		assets = sel.Select(assets)
+	}
+	return vlInput{language: language, doc: doc, assets: assets, segments: segments, hash: r.vlHash(model, doc, assets, segments)}, nil
+}
+
+// embedSegments embeds each video segment of a prepared VL item on its own,
+// in one provider request when the embedder implements vl.BatchEmbedder.
+func (r *Runtime) embedSegments(ctx context.Context, model string, segments []vl.Segment) ([][]float32, error) {
+	if len(segments) == 0 {
+		return nil, nil
+	}
+	emb := r.vlEmbedders[model]
+	upload, _ := ctx.Value(assetUploadKey{}).(assetUpload)
+	_, uploads := emb.(vl.BytesEmbedder)
+	if batcher, ok := emb.(vl.BatchEmbedder); ok && !(upload.always && uploads) {
+		inputs := make([]vl.Input, len(segments))
+		for i, seg := range segments {
+			inputs[i] = vl.Input{Assets: seg.Assets}
+		}
+		started := time.Now()
+		results, err := batcher.EmbedBatch(ctx, inputs)
+		r.metrics.ProviderCall(model, len(inputs), time.Since(started), err)
+		if err == nil {
+			if len(results) != len(inputs) {
+				return nil, fmt.Errorf("vl embedder for model %q returned %d results for %d segments", model, len(results), len(inputs))
+			}
+			out := make([][]float32, len(results))
+			for i, res := range results {
+				out[i] = res.Fused
+			}
+			return out, nil
+		}
+		if !errors.Is(err, vl.ErrAssetUnreachable) {
+			return nil, err
+		}
+		// Retry segment by segment so the upload fallback applies.
+	}
+	out := make([][]float32, len(segments))
+	for i, seg := range segments {
+		vec, _, err := r.embedVL(ctx, model, "", seg.Assets, false)
+		if err != nil {
+			return nil, fmt.Errorf("segment %q: %w", seg.Key(), err)
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+// storeVL stores an entity's fused VL vector and, for VLAssetModels, each
+// asset's vector, plus video segment vectors (segmentVecs align with
+// in.segments) for models with VideoSegments. Asset rows are written before
+// the hashed fused vector so a failed asset write is retried rather than
+// skipped as unchanged.
+func (r *Runtime) storeVL(ctx context.Context, entityType string, entityID string, model string, in vlInput, fused []float32, perAsset [][]float32, segmentVecs [][]float32) error {
+	_, assetModel := r.vlAssets[model]
+	_, segmented := r.segments[model]
+	if assetModel || segmented {
+		rows := make([]pg.AssetEmbedding, 0, len(in.assets)+len(in.segments))
+		for i, a := range in.assets {
+			if !assetModel || i >= len(perAsset) || len(perAsset[i]) == 0 {
+				continue
+			}
+			rows = append(rows, pg.AssetEmbedding{
+				Key:      a.StorageKey(),
+				FrameIdx: a.FrameIdx,
+				Kind:     string(a.Kind),
+				URL:      a.URL,
+				Vector:   r.finishVector(model, perAsset[i]),
+			})
+		}
+		for i, seg := range in.segments {
+			if i >= len(segmentVecs) || len(segmentVecs[i]) == 0 {
+				continue
+			}
+			rows = append(rows, pg.AssetEmbedding{
+				Key:      seg.Key(),
+				FrameIdx: seg.Index,
+				Kind:     string(vl.AssetKindSegment),
+				URL:      seg.Video.URL,
+				Vector:   r.finishVector(model, segmentVecs[i]),
+				Start:    seg.Start,
+				End:      seg.End,
+			})
+		}
+		started := time.Now()
+		err := r.storage.(VLAssetStorage).UpsertVLEmbeddingAssets(ctx, entityType, entityID, model, in.language, rows)
+		r.metrics.VectorsUpserted(model, len(rows), time.Since(started), err)
+		if err != nil {
+			return err
+		}
+	}
+	started := time.Now()
+	err := r.storage.(VLStorage).UpsertVLEmbedding(ctx, entityType, entityID, model, in.language, r.finishVector(model, fused), in.hash)
+	r.metrics.VectorsUpserted(model, 1, time.Since(started), err)
+	if err == nil {
+		r.metrics.EmbeddingsGenerated(model, 1)
+	}
+	return err
+}
+
+// EmbedQueryImage embeds a query image (URL, or a data: URL for uploaded
+// bytes) with model's VL embedder for search-by-image; the vector is
+// truncated and normalized like stored VL vectors.
+func (r *Runtime) EmbedQueryImage(ctx context.Context, model string, image vl.AssetURL) ([]float32, error) {
+	model = r.CanonicalModel(model)
+	emb, ok := r.vlEmbedders[model]
+	if !ok {
+		return nil, fmt.Errorf("model %q is not configured for vl embeddings", model)
+	}
+	if strings.TrimSpace(image.URL) == "" {
+		return nil, fmt.Errorf("image URL is required")
+	}
+	if image.Kind == "" {
+		image.Kind = vl.AssetKindImage
+	}
+	started := time.Now()
+	vec, err := emb.EmbedTextAndAssetURLs(ctx, "", []vl.AssetURL{image})
+	r.metrics.ProviderCall(model, 1, time.Since(started), err)
+	if err != nil {
+		return nil, err
+	}
+	return r.finishVector(model, vec), nil
+}
+
+// EmbedEntityText embeds an entity's own semantic document, text only, with
+// model's VL embedder (like a text query), e.g. to pick the entity's asset
+// that best matches its description. Like EmbedQuery, it returns a nil vector
+// when the entity has no document.
+func (r *Runtime) EmbedEntityText(ctx context.Context, entityType string, entityID string, model string, language string) ([]float32, error) {
+	model = r.CanonicalModel(model)
+	emb, ok := r.vlEmbedders[model]
+	if !ok {
+		return nil, fmt.Errorf("model %q is not configured for vl embeddings", model)
+	}
+	docs, err := r.BuildSemanticDocument(ctx, entityType, language, []string{entityID})
+	if err != nil {
+		return nil, err
+	}
+	doc := r.renderDocument(model, language, docs[entityID])
+	if strings.TrimSpace(doc) == "" {
+		return nil, nil
+	}
+	return r.embedVLQuery(ctx, model, emb, doc)
+}
+
+// embedVLQuery embeds a text query for a VL model: with a dual encoder's text
+// encoder, or as a text-only input to a fused model, into the space of its
+// stored entity vectors.
+func (r *Runtime) embedVLQuery(ctx context.Context, model string, emb vl.Embedder, text string) ([]float32, error) {
+	started := time.Now()
+	var vec []float32
+	var err error
+	if dual, ok := emb.(vl.DualEncoder); ok {
+		vec, err = dual.EmbedQueryText(ctx, text)
+	} else {
+		vec, err = emb.EmbedTextAndAssetURLs(ctx, text, nil)
+	}
+	r.metrics.ProviderCall(model, 1, time.Since(started), err)
+	if err != nil {
+		return nil, err
+	}
+	return r.finishVector(model, vec), nil
+}
@@ -0,0 +1,128 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/vl"
+)
+
+// VLEmbeddingItem is one entity's inputs for
+// GenerateAndStoreVLEmbeddingsWithInputs.
+type VLEmbeddingItem struct {
+	EntityType string
+	EntityID   string
+	Language   string
+	Document   string
+	Assets     []vl.AssetURL
+}
+
+// embedAndStoreVL embeds one prepared VL item and stores its vectors.
+func (r *Runtime) embedAndStoreVL(ctx context.Context, entityType string, entityID string, model string, in vlInput) error {
+	fused, perAsset, err := r.embedVL(ctx, model, in.doc, in.assets, r.IsVLAssetModel(model))
+	if err != nil {
+		return err
+	}
+	segments, err := r.embedSegments(ctx, model, in.segments)
+	if err != nil {
+		return err
+	}
+	return r.storeVL(ctx, entityType, entityID, model, in, fused, perAsset, segments)
+}
+
+// GenerateAndStoreVLEmbeddingsWithInputs embeds several entities for a VL
+// model in shared provider requests when its embedder implements
+// vl.BatchEmbedder (one call per item otherwise). Items whose document and
+// asset set are unchanged are skipped and report a nil error.
+//
+// Returned per-item errors align with items by index. If the provider call
+// fails, the returned error is non-nil and per-item errors are only set for
+// inputs classified locally. A batch failing with vl.ErrAssetUnreachable is
+// retried item by item instead, so one unreachable URL doesn't fail the rest
+// and the upload fallback (WithAssetFetcher) applies per item.
+func (r *Runtime) GenerateAndStoreVLEmbeddingsWithInputs(ctx context.Context, model string, items []VLEmbeddingItem) ([]error, error) {
+	model = r.CanonicalModel(model)
+	emb, ok := r.vlEmbedders[model]
+	if !ok {
+		return nil, fmt.Errorf("model %q is not configured for vl embeddings", model)
+	}
+	errs := make([]error, len(items))
+	batcher, ok := emb.(vl.BatchEmbedder)
+	if !ok {
+		for i, it := range items {
+			errs[i] = r.GenerateAndStoreVLEmbeddingWithInputs(ctx, it.EntityType, it.EntityID, model, it.Language, it.Document, it.Assets)
+		}
+		return errs, nil
+	}
+
+	prepared := make([]vlInput, len(items))
+	keys := make([]pg.EmbeddingKey, 0, len(items))
+	for i, it := range items {
+		in, err := r.prepareVL(ctx, model, it.Language, it.Document, it.Assets)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		prepared[i] = in
+		keys = append(keys, pg.EmbeddingKey{EntityType: it.EntityType, EntityID: it.EntityID, Language: in.language})
+	}
+	stored, err := r.storage.(VLStorage).VLContentHashes(ctx, model, keys)
+	if err != nil {
+		return errs, err
+	}
+
+	var idx []int
+	var inputs []vl.Input
+	for i, it := range items {
+		if errs[i] != nil {
+			continue
+		}
+		in := prepared[i]
+		key := pg.EmbeddingKey{EntityType: it.EntityType, EntityID: it.EntityID, Language: in.language}
+		if stored[key] == in.hash && !isReembed(ctx) {
+			continue
+		}
+		idx = append(idx, i)
+		inputs = append(inputs, vl.Input{Text: in.doc, Assets: in.assets})
+	}
+	if len(inputs) == 0 {
+		return errs, nil
+	}
+
+	perItem := func() ([]error, error) {
+		for _, i := range idx {
+			errs[i] = r.embedAndStoreVL(ctx, items[i].EntityType, items[i].EntityID, model, prepared[i])
+		}
+		return errs, nil
+	}
+	if upload, _ := ctx.Value(assetUploadKey{}).(assetUpload); upload.always {
+		if _, ok := emb.(vl.BytesEmbedder); ok {
+			return perItem()
+		}
+	}
+
+	started := time.Now()
+	results, err := batcher.EmbedBatch(ctx, inputs)
+	r.metrics.ProviderCall(model, len(inputs), time.Since(started), err)
+	if errors.Is(err, vl.ErrAssetUnreachable) {
+		return perItem()
+	}
+	if err != nil {
+		return errs, err
+	}
+	if len(results) != len(inputs) {
+		return errs, fmt.Errorf("vl embedder for model %q returned %d results for %d inputs", model, len(results), len(inputs))
+	}
+	for k, i := range idx {
+		segments, err := r.embedSegments(ctx, model, prepared[i].segments)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		errs[i] = r.storeVL(ctx, items[i].EntityType, items[i].EntityID, model, prepared[i], results[k].Fused, results[k].PerAsset, segments)
+	}
+	return errs, nil
+}
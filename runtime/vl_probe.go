@@ -0,0 +1,74 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/open-rails/searchkit/vl"
+)
+
+// vlProbeText is the text ProbeVL embeds with the probe image.
+const vlProbeText = "searchkit vl probe"
+
+// ProbeVL embeds vlProbeText plus the public image at imageURL with every VL
+// embedder, checking credentials, the returned widths (fused, per-asset for
+// VLAssetModels, and a dual encoder's text vectors) against Dimensions(), and
+// that the provider can fetch asset URLs. It returns an error per failing
+// model, so configuration problems surface before tasks start failing.
+func (r *Runtime) ProbeVL(ctx context.Context, imageURL string) error {
+	if strings.TrimSpace(imageURL) == "" {
+		return fmt.Errorf("probe image URL is required")
+	}
+	image := []vl.AssetURL{{Kind: vl.AssetKindImage, URL: imageURL}}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(r.vlEmbedders)) {
+		if err := r.probeVL(ctx, name, image); err != nil {
+			errs = append(errs, fmt.Errorf("model %s: %w", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("searchkit vl probe failed:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (r *Runtime) probeVL(ctx context.Context, model string, image []vl.AssetURL) error {
+	e := r.vlEmbedders[model]
+	dims := e.Dimensions()
+	var (
+		fused    []float32
+		perAsset [][]float32
+		err      error
+	)
+	if r.IsVLAssetModel(model) {
+		fused, perAsset, err = e.(vl.AssetVectorEmbedder).EmbedAssetVectors(ctx, vlProbeText, image)
+	} else {
+		fused, err = e.EmbedTextAndAssetURLs(ctx, vlProbeText, image)
+	}
+	switch {
+	case errors.Is(err, vl.ErrAssetUnreachable):
+		return fmt.Errorf("provider cannot fetch asset URLs (configure the worker's AssetFetcher with a vl.BytesEmbedder): %w", err)
+	case err != nil:
+		return fmt.Errorf("probe embedding failed: %w", err)
+	case len(fused) != dims:
+		return fmt.Errorf("embedder declares %d dims but provider returned %d", dims, len(fused))
+	case r.IsVLAssetModel(model) && len(perAsset) != 1:
+		return fmt.Errorf("expected 1 asset vector, provider returned %d", len(perAsset))
+	case r.IsVLAssetModel(model) && len(perAsset[0]) != dims:
+		return fmt.Errorf("embedder declares %d dims but provider returned %d-dim asset vectors", dims, len(perAsset[0]))
+	}
+	if dual, ok := e.(vl.DualEncoder); ok {
+		vec, err := dual.EmbedQueryText(ctx, vlProbeText)
+		if err != nil {
+			return fmt.Errorf("text encoder probe failed: %w", err)
+		}
+		if len(vec) != dims {
+			return fmt.Errorf("embedder declares %d dims but text encoder returned %d", dims, len(vec))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/open-rails/searchkit/vl"
+)
+
+type assetUploadKey struct{}
+
+type assetUpload struct {
+	fetch  vl.AssetFetcher
+	always bool
+}
+
+// WithAssetFetcher makes VL GenerateAndStore* calls with ctx download assets
+// with fetch and upload the bytes when the provider cannot fetch the URLs
+// (vl.ErrAssetUnreachable), or without trying the URLs first when always is
+// set. Only embedders implementing vl.BytesEmbedder can upload; others keep
+// the URL path.
+func WithAssetFetcher(ctx context.Context, fetch vl.AssetFetcher, always bool) context.Context {
+	if fetch == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, assetUploadKey{}, assetUpload{fetch: fetch, always: always})
+}
+
+// embedVL embeds doc+assets with model's VL embedder, returning per-asset
+// vectors too when perAsset is set, and falls back to uploading bytes (see
+// WithAssetFetcher).
+func (r *Runtime) embedVL(ctx context.Context, model string, doc string, assets []vl.AssetURL, perAsset bool) ([]float32, [][]float32, error) {
+	emb := r.vlEmbedders[model]
+	upload, _ := ctx.Value(assetUploadKey{}).(assetUpload)
+	bytesEmb, canUpload := emb.(vl.BytesEmbedder)
+	canUpload = canUpload && upload.fetch != nil
+
+	if !canUpload || !upload.always {
+		var (
+			fused    []float32
+			assetVec [][]float32
+			err      error
+		)
+		started := time.Now()
+		if perAsset {
+			fused, assetVec, err = emb.(vl.AssetVectorEmbedder).EmbedAssetVectors(ctx, doc, assets)
+		} else {
+			fused, err = emb.EmbedTextAndAssetURLs(ctx, doc, assets)
+		}
+		r.metrics.ProviderCall(model, 1, time.Since(started), err)
+		if err == nil || !canUpload || !errors.Is(err, vl.ErrAssetUnreachable) {
+			return fused, assetVec, err
+		}
+	}
+
+	data, err := vl.FetchAssets(ctx, upload.fetch, assets)
+	if err != nil {
+		return nil, nil, err
+	}
+	started := time.Now()
+	fused, assetVec, err := bytesEmb.EmbedTextAndAssetBytes(ctx, doc, data)
+	r.metrics.ProviderCall(model, 1, time.Since(started), err)
+	if err != nil {
+		return nil, nil, err
+	}
+	if perAsset && len(assetVec) != len(assets) {
+		return nil, nil, fmt.Errorf("vl embedder for model %q returned %d asset vectors for %d uploaded assets", model, len(assetVec), len(assets))
+	}
+	return fused, assetVec, nil
+}
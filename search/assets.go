@@ -0,0 +1,350 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// AssetHit is a match on one stored asset vector (see runtime
+// Options.VLAssetModels): the entity plus which asset matched.
+type AssetHit struct {
+	Hit
+	AssetKey  string
+	FrameIdx  int
+	AssetKind string
+	AssetURL  string // the URL at embedding time (presigned URLs may have expired)
+
+	// Start and End locate a matched video segment (AssetKind "segment", see
+	// runtime Options.VideoSegments) within the video at AssetURL, to jump to
+	// the matching moment; zero for other assets.
+	Start, End time.Duration
+}
+
+// segmentBounds converts nullable start_ms/end_ms columns.
+func segmentBounds(start, end *int64) (time.Duration, time.Duration) {
+	if start == nil || end == nil {
+		return 0, 0
+	}
+	return time.Duration(*start) * time.Millisecond, time.Duration(*end) * time.Millisecond
+}
+
+type AssetQuery struct {
+	Schema   string
+	Model    string
+	Language string
+	QueryVec []float32
+	Limit    int
+
+	// Options apply as for SemanticSearch (FilterSQL may reference the
+	// entity_type, entity_id, language and tenant_id columns via "ev.").
+	// TwoStage and the chunk options are ignored.
+	Options Options
+
+	// BestPerEntity returns only each entity's best-matching asset, so Limit
+	// counts entities rather than assets.
+	BestPerEntity bool
+
+	// AssetKinds restricts hits to these asset kinds, e.g. {"segment"} to
+	// search video segments only. Empty means all kinds.
+	AssetKinds []string
+
+	// IncludeShadow allows searching a shadow model (see Query.IncludeShadow).
+	IncludeShadow bool
+}
+
+// AssetSearch runs a cosine KNN search over a VL model's per-asset vectors
+// (embedding_vector_assets), reporting which page/image/frame matched rather
+// than only the entity. The query vector is compared as halfvec, like the
+// stored asset vectors.
+func AssetSearch(ctx context.Context, pool pg.Querier, q AssetQuery) ([]AssetHit, error) {
+	return assetSearch(ctx, pool, q, "", "")
+}
+
+// assetSearch runs AssetSearch, excluding ctx's tenant's entity
+// excludeType/excludeID when set.
+func assetSearch(ctx context.Context, pool pg.Querier, q AssetQuery, excludeType string, excludeID string) ([]AssetHit, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(q.Schema) == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(q.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(q.Language) == "" {
+		return nil, fmt.Errorf("language is required")
+	}
+	if q.Limit <= 0 || len(q.QueryVec) == 0 {
+		return []AssetHit{}, nil
+	}
+	dim := len(q.QueryVec)
+
+	quotedSchema, err := quoteIdent(q.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	resolved, err := resolveModel(ctx, pool, quotedSchema, q.Model, "")
+	if err != nil {
+		return nil, err
+	}
+	if resolved.shadow && !q.IncludeShadow {
+		return nil, fmt.Errorf("model %q is a shadow model; set IncludeShadow to search it", resolved.name)
+	}
+
+	opts := q.Options
+	where := "WHERE ev.model = @model AND ev.language = @language AND ev.deleted_at IS NULL"
+	args := pgx.NamedArgs{
+		"model":    resolved.name,
+		"language": resolved.language(q.Language),
+		"qvec":     pgvector.NewHalfVector(q.QueryVec),
+		"limit":    q.Limit,
+	}
+	if excludeType != "" {
+		where += " AND NOT (ev.tenant_id = @exclude_tenant AND ev.entity_type = @exclude_type AND ev.entity_id = @exclude_id)"
+		args["exclude_tenant"] = pg.TenantFromContext(ctx)
+		args["exclude_type"] = excludeType
+		args["exclude_id"] = excludeID
+	}
+	if len(q.AssetKinds) > 0 {
+		where += " AND ev.asset_kind = ANY(@asset_kinds::text[])"
+		args["asset_kinds"] = q.AssetKinds
+	}
+	if len(opts.EntityTypes) > 0 {
+		where += " AND ev.entity_type = ANY(@entity_types::text[])"
+		args["entity_types"] = opts.EntityTypes
+	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND ev.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
+	if len(opts.ExcludeIDs) > 0 {
+		where += " AND ev.entity_id <> ALL(@exclude_ids::text[])"
+		args["exclude_ids"] = opts.ExcludeIDs
+	}
+	if strings.TrimSpace(opts.FilterSQL) != "" {
+		where += " AND (" + opts.FilterSQL + ")"
+		if err := mergeNamedArgs(args, opts.FilterArgs); err != nil {
+			return nil, err
+		}
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT
+			ev.entity_type,
+			ev.entity_id,
+			ev.model,
+			ev.language,
+			(1 - (ev.embedding::halfvec(%[1]d) <=> @qvec::halfvec(%[1]d)))::float4 AS similarity,
+			ev.asset_key,
+			ev.frame_idx,
+			ev.asset_kind,
+			ev.asset_url,
+			ev.start_ms,
+			ev.end_ms
+		FROM %[2]s.embedding_vector_assets ev
+		%[3]s
+		ORDER BY ev.embedding::halfvec(%[1]d) <=> @qvec::halfvec(%[1]d)
+		LIMIT @limit
+	`, dim, quotedSchema, where)
+	if q.BestPerEntity {
+		// Oversample assets, then keep each entity's best one.
+		args["limit"] = q.Limit * defaultChunkOversample
+		args["entity_limit"] = q.Limit
+		sql = fmt.Sprintf(`
+			SELECT * FROM (
+				SELECT DISTINCT ON (entity_type, entity_id) *
+				FROM (%s) asset_hits
+				ORDER BY entity_type, entity_id, similarity DESC
+			) best
+			ORDER BY similarity DESC
+			LIMIT @entity_limit
+		`, sql)
+	}
+
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AssetHit
+	for rows.Next() {
+		var h AssetHit
+		var start, end *int64
+		if err := rows.Scan(&h.EntityType, &h.EntityID, &h.Model, &h.Language, &h.Similarity, &h.AssetKey, &h.FrameIdx, &h.AssetKind, &h.AssetURL, &start, &end); err != nil {
+			return nil, err
+		}
+		h.Start, h.End = segmentBounds(start, end)
+		if opts.MinSimilarity > 0 && h.Similarity < opts.MinSimilarity {
+			continue
+		}
+		if resolved.anyLanguage {
+			h.Language = q.Language
+		}
+		if opts.BestAsset {
+			h.BestAsset = &AssetMatch{Key: h.AssetKey, FrameIdx: h.FrameIdx, Kind: h.AssetKind, URL: h.AssetURL, Similarity: h.Similarity, Start: h.Start, End: h.End}
+		}
+		out = append(out, h)
+	}
+	return out, rows.Err()
+}
+
+// SimilarToAsset returns the assets of other entities nearest to one stored
+// asset of the source entity (its lowest frame when the key has several), for
+// "more like this page/panel". Results are one best asset per entity, like
+// AssetQuery.BestPerEntity. The source is ctx's tenant's entity.
+func SimilarToAsset(ctx context.Context, pool pg.Querier, schema string, entityType string, entityID string, assetKey string, model string, language string, limit int, opts Options) ([]AssetHit, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" {
+		return nil, fmt.Errorf("entityType and entityID are required")
+	}
+	if strings.TrimSpace(assetKey) == "" {
+		return nil, fmt.Errorf("assetKey is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(language) == "" {
+		return nil, fmt.Errorf("language is required")
+	}
+	if limit <= 0 {
+		return []AssetHit{}, nil
+	}
+	quotedSchema, err := quoteIdent(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	resolved, err := resolveModel(ctx, pool, quotedSchema, model, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var source pgvector.HalfVector
+	err = pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT embedding
+		FROM %s.embedding_vector_assets
+		WHERE entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4 AND asset_key = $5 AND deleted_at IS NULL
+		  AND tenant_id = $6
+		ORDER BY frame_idx
+		LIMIT 1
+	`, quotedSchema), entityType, entityID, resolved.name, resolved.language(language), assetKey, pg.TenantFromContext(ctx)).Scan(&source)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []AssetHit{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assetSearch(ctx, pool, AssetQuery{
+		Schema:        schema,
+		Model:         model,
+		Language:      language,
+		QueryVec:      source.Slice(),
+		Limit:         limit,
+		Options:       opts,
+		BestPerEntity: true,
+		IncludeShadow: true,
+	}, entityType, entityID)
+}
+
+// BestAssets sets each hit's BestAsset to the entity's stored asset of VL
+// model nearest to queryVec (nil when the entity has none), for contextual
+// thumbnails on result pages whose hits come from any search (lexical,
+// fused, another model). kinds restricts the candidate assets, e.g. to images
+// and frames so video segments aren't picked; empty means all kinds. Only the
+// assets of ctx's tenant (see pg.WithTenant) are considered.
+func BestAssets(ctx context.Context, pool pg.Querier, schema string, model string, language string, queryVec []float32, kinds []string, hits []Hit) error {
+	if pool == nil {
+		return fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(model) == "" {
+		return fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(language) == "" {
+		return fmt.Errorf("language is required")
+	}
+	if len(queryVec) == 0 || len(hits) == 0 {
+		return nil
+	}
+	return annotateBestAssets(ctx, pool, schema, model, language, queryVec, kinds, hits, false)
+}
+
+// annotateBestAssets sets each hit's BestAsset to its entity's stored asset
+// (of kinds, when set) nearest to queryVec, among ctx's tenant's assets unless
+// allTenants.
+func annotateBestAssets(ctx context.Context, pool pg.Querier, schema string, model string, language string, queryVec []float32, kinds []string, hits []Hit, allTenants bool) error {
+	quotedSchema, err := quoteIdent(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	resolved, err := resolveModel(ctx, pool, quotedSchema, model, "")
+	if err != nil {
+		return err
+	}
+	types := make([]string, len(hits))
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		types[i], ids[i] = h.EntityType, h.EntityID
+	}
+	rows, err := pool.Query(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (entity_type, entity_id)
+			entity_type,
+			entity_id,
+			asset_key,
+			frame_idx,
+			asset_kind,
+			asset_url,
+			start_ms,
+			end_ms,
+			(1 - (embedding::halfvec(%[1]d) <=> $5::halfvec(%[1]d)))::float4 AS similarity
+		FROM %[2]s.embedding_vector_assets
+		WHERE model = $1 AND language = $2 AND deleted_at IS NULL
+		  AND (entity_type, entity_id) IN (SELECT * FROM unnest($3::text[], $4::text[]))
+		  AND (COALESCE(cardinality($6::text[]), 0) = 0 OR asset_kind = ANY($6::text[]))
+		  AND ($8 OR tenant_id = $7)
+		ORDER BY entity_type, entity_id, embedding::halfvec(%[1]d) <=> $5::halfvec(%[1]d)
+	`, len(queryVec), quotedSchema), resolved.name, resolved.language(language), types, ids, pgvector.NewHalfVector(queryVec), kinds, pg.TenantFromContext(ctx), allTenants)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	best := make(map[[2]string]*AssetMatch, len(hits))
+	for rows.Next() {
+		var et, id string
+		var start, end *int64
+		m := &AssetMatch{}
+		if err := rows.Scan(&et, &id, &m.Key, &m.FrameIdx, &m.Kind, &m.URL, &start, &end, &m.Similarity); err != nil {
+			return err
+		}
+		m.Start, m.End = segmentBounds(start, end)
+		best[[2]string{et, id}] = m
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range hits {
+		hits[i].BestAsset = best[[2]string{hits[i].EntityType, hits[i].EntityID}]
+	}
+	return nil
+}
+
+// AssetHits returns the entity-level hits of asset hits, in order (e.g. for
+// HitKeys and FuseRRF).
+func AssetHits(hits []AssetHit) []Hit {
+	out := make([]Hit, len(hits))
+	for i, h := range hits {
+		out[i] = h.Hit
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package search
+
+import "fmt"
+
+// ChunkAggregate controls how chunked (multi-row) embeddings are collapsed into
+// one hit per entity.
+type ChunkAggregate string
+
+const (
+	// ChunkAggregateMax scores an entity by its best-matching chunk.
+	ChunkAggregateMax ChunkAggregate = "max"
+	// ChunkAggregateSum scores an entity by the sum of its top ChunkTopK chunk
+	// similarities, favoring entities that match in several places.
+	ChunkAggregateSum ChunkAggregate = "sum"
+)
+
+const (
+	defaultChunkTopK       = 3
+	defaultChunkOversample = 4
+)
+
+// wrapChunkAggregate wraps a per-row candidate query (returning entity_type,
+// entity_id, model, language, similarity) so that rows are grouped per entity.
+// The wrapped query binds @chunk_top_k and @chunk_limit.
+func wrapChunkAggregate(inner string, agg ChunkAggregate) (string, error) {
+	var fn string
+	switch agg {
+	case ChunkAggregateMax:
+		fn = "max"
+	case ChunkAggregateSum:
+		fn = "sum"
+	default:
+		return "", fmt.Errorf("invalid ChunkAggregate %q", agg)
+	}
+	return fmt.Sprintf(`
+		WITH chunk_hits AS (
+			%s
+		), ranked AS (
+			SELECT
+				entity_type,
+				entity_id,
+				model,
+				language,
+				similarity,
+				row_number() OVER (PARTITION BY entity_type, entity_id ORDER BY similarity DESC) AS rn
+			FROM chunk_hits
+		)
+		SELECT
+			entity_type,
+			entity_id,
+			model,
+			language,
+			%s(similarity)::float4 AS similarity
+		FROM ranked
+		WHERE rn <= @chunk_top_k
+		GROUP BY entity_type, entity_id, model, language
+		ORDER BY 5 DESC
+		LIMIT @chunk_limit
+	`, inner, fn), nil
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	querynorm "github.com/open-rails/searchkit/internal/normalize"
+	"github.com/open-rails/searchkit/pg"
 )
 
 type FTSHit struct {
@@ -23,6 +24,9 @@ type FTSOptions struct {
 	EntityTypes []string
 	Limit       int
 
+	// AllTenants searches every tenant instead of ctx's (see Options.AllTenants).
+	AllTenants bool
+
 	// FilterSQL is an optional additional WHERE fragment appended to the query as:
 	//   ... AND (<FilterSQL>)
 	//
@@ -35,6 +39,10 @@ type FTSOptions struct {
 	// FilterArgs are named args referenced by FilterSQL using pgx '@name'
 	// placeholders (e.g. "... language = @lang").
 	FilterArgs map[string]any
+
+	// StatementTimeout cancels the query once it runs longer (see
+	// Options.StatementTimeout). Zero uses the session's statement_timeout.
+	StatementTimeout time.Duration
 }
 
 // NormalizeFTSScore maps Postgres `ts_rank_cd` scores into a bounded [0..1] range.
@@ -51,7 +59,7 @@ func NormalizeFTSScore(raw float32) float32 {
 }
 
 // FTSSearchNormalized runs FTSSearch and normalizes the returned score into [0..1].
-func FTSSearchNormalized(ctx context.Context, pool *pgxpool.Pool, query string, opts FTSOptions) ([]FTSHit, error) {
+func FTSSearchNormalized(ctx context.Context, pool pg.Querier, query string, opts FTSOptions) ([]FTSHit, error) {
 	hits, err := FTSSearch(ctx, pool, query, opts)
 	if err != nil {
 		return nil, err
@@ -69,7 +77,7 @@ func FTSSearchNormalized(ctx context.Context, pool *pgxpool.Pool, query string,
 //   - This is language-aware via `searchkit_regconfig_for_language(language)`.
 //   - The stored `tsv` is derived from `raw_document`, while trigram/typeahead
 //     uses the heavy-normalized `document`.
-func FTSSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts FTSOptions) ([]FTSHit, error) {
+func FTSSearch(ctx context.Context, pool pg.Querier, query string, opts FTSOptions) ([]FTSHit, error) {
 	if strings.TrimSpace(opts.Schema) == "" {
 		return nil, fmt.Errorf("schema is required")
 	}
@@ -94,7 +102,7 @@ func FTSSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts FTSOp
 	}
 	table := quotedSchema + ".search_documents"
 
-	where := "WHERE sd.language = @language AND sd.tsv IS NOT NULL"
+	where := "WHERE sd.language = @language AND sd.tsv IS NOT NULL AND sd.deleted_at IS NULL"
 	args := pgx.NamedArgs{
 		"language": opts.Language,
 		"q":        q,
@@ -104,6 +112,10 @@ func FTSSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts FTSOp
 		where += " AND sd.entity_type = ANY(@entity_types::text[])"
 		args["entity_types"] = opts.EntityTypes
 	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND sd.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
 	if strings.TrimSpace(opts.FilterSQL) != "" {
 		where += " AND (" + opts.FilterSQL + ")"
 		if err := mergeNamedArgs(args, opts.FilterArgs); err != nil {
@@ -131,7 +143,7 @@ func FTSSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts FTSOp
 			LIMIT @limit
 		`, fn, quotedSchema, table, where)
 
-		rows, err := pool.Query(ctx, sql, args)
+		rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
 		if err != nil {
 			return nil, err
 		}
@@ -149,8 +161,8 @@ func FTSSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts FTSOp
 	}
 
 	out, err := run("websearch_to_tsquery")
-	if err == nil {
-		return out, nil
+	if err == nil || IsStatementTimeout(err) {
+		return out, err
 	}
 	return run("plainto_tsquery")
 }
@@ -0,0 +1,198 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+type HybridQuery struct {
+	Schema   string
+	Model    string
+	Language string
+	// QueryVec is the dense query embedding and SparseVec the sparse one of a
+	// hybrid model (see runtime.EmbedQuery and runtime.EmbedQuerySparse).
+	// SparseVec's dimensions must match the model's pg.ModelSpec.SparseDims;
+	// a sparse vector without terms fuses the dense list alone.
+	QueryVec  []float32
+	SparseVec pgvector.SparseVector
+	Limit     int
+
+	// Candidates is how many entities each of the dense and sparse KNN stages
+	// retrieves before fusion. Defaults to 4 * Limit.
+	Candidates int
+
+	// RRF configures the fusion; Weights are {dense, sparse}.
+	RRF RRFOptions
+
+	// Options filter both stages as for SemanticSearch. MinSimilarity,
+	// TwoStage, BestAsset and the chunk options are ignored.
+	Options Options
+
+	// Storage is the dense storage mode (see Query.Storage).
+	Storage pg.StorageMode
+
+	// IncludeShadow allows searching a shadow model (see Query.IncludeShadow).
+	IncludeShadow bool
+}
+
+// HybridSearch searches a hybrid dense+sparse model (pg.ModelSpec.SparseDims)
+// in one query: a cosine KNN over the dense vectors (best chunk per entity)
+// and an inner-product KNN over the sparse vectors stored next to them, fused
+// with RRF in SQL. Hit.Similarity is the fused RRF score, so it is only
+// comparable within one result list.
+func HybridSearch(ctx context.Context, pool pg.Querier, q HybridQuery) ([]Hit, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(q.Schema) == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(q.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(q.Language) == "" {
+		return nil, fmt.Errorf("language is required")
+	}
+	if q.Limit <= 0 || len(q.QueryVec) == 0 {
+		return []Hit{}, nil
+	}
+	sparseDim := int(q.SparseVec.Dimensions())
+	if sparseDim <= 0 {
+		return nil, fmt.Errorf("sparse vector dimensions are required")
+	}
+
+	quotedSchema, err := quoteIdent(q.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	resolved, err := resolveModel(ctx, pool, quotedSchema, q.Model, q.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.shadow && !q.IncludeShadow {
+		return nil, fmt.Errorf("model %q is a shadow model; set IncludeShadow to search it", resolved.name)
+	}
+	mode := resolved.storage
+	if resolved.vl || (mode != pg.StorageHalfvec && mode != pg.StorageVector) {
+		return nil, fmt.Errorf("model %q: hybrid search needs a halfvec or vector text model", resolved.name)
+	}
+	col, typ := vectorColumn(mode, len(q.QueryVec))
+	_, sparseTyp := vectorColumn(pg.StorageSparse, sparseDim)
+
+	candidates := q.Candidates
+	if candidates <= 0 {
+		candidates = 4 * q.Limit
+	}
+	sparseCandidates := candidates
+	if len(q.SparseVec.Indices()) == 0 {
+		sparseCandidates = 0
+	}
+	k := q.RRF.K
+	if k <= 0 {
+		k = 60
+	}
+	weight := func(i int) float32 {
+		if i < len(q.RRF.Weights) && q.RRF.Weights[i] > 0 {
+			return q.RRF.Weights[i]
+		}
+		return 1
+	}
+
+	opts := q.Options
+	where := "WHERE ev.model = @model AND ev.language = @language AND ev.deleted_at IS NULL"
+	args := pgx.NamedArgs{
+		"model":             resolved.name,
+		"language":          resolved.language(q.Language),
+		"qvec":              queryVectorArg(mode, q.QueryVec),
+		"svec":              q.SparseVec.String(),
+		"candidates":        candidates,
+		"chunk_candidates":  candidates * defaultChunkOversample,
+		"sparse_candidates": sparseCandidates,
+		"k":                 k,
+		"dense_weight":      weight(0),
+		"sparse_weight":     weight(1),
+		"limit":             q.Limit,
+	}
+	if len(opts.EntityTypes) > 0 {
+		where += " AND ev.entity_type = ANY(@entity_types::text[])"
+		args["entity_types"] = opts.EntityTypes
+	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND ev.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
+	if len(opts.ExcludeIDs) > 0 {
+		where += " AND ev.entity_id <> ALL(@exclude_ids::text[])"
+		args["exclude_ids"] = opts.ExcludeIDs
+	}
+	if strings.TrimSpace(opts.FilterSQL) != "" {
+		where += " AND (" + opts.FilterSQL + ")"
+		if err := mergeNamedArgs(args, opts.FilterArgs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each KNN stage is a plain ORDER BY distance LIMIT so it can use the
+	// model's HNSW index; ranks are assigned afterwards. Dense chunk rows are
+	// oversampled and reduced to each entity's best chunk. Sparse vectors are
+	// only stored on chunk 0.
+	sql := fmt.Sprintf(`
+		WITH dense_knn AS (
+			SELECT ev.tenant_id, ev.entity_type, ev.entity_id, ev.language, ev.%[1]s::%[2]s <=> (@qvec::%[2]s) AS distance
+			FROM %[3]s.embedding_vectors ev
+			%[4]s AND ev.%[1]s IS NOT NULL
+			ORDER BY ev.%[1]s::%[2]s <=> (@qvec::%[2]s)
+			LIMIT @chunk_candidates
+		),
+		dense AS (
+			SELECT tenant_id, entity_type, entity_id, language, row_number() OVER (ORDER BY min(distance), entity_type, entity_id) AS rank
+			FROM dense_knn
+			GROUP BY tenant_id, entity_type, entity_id, language
+			ORDER BY rank
+			LIMIT @candidates
+		),
+		sparse_knn AS (
+			SELECT ev.tenant_id, ev.entity_type, ev.entity_id, ev.language, ev.embedding_sparse::%[5]s <#> (@svec::text::%[5]s) AS distance
+			FROM %[3]s.embedding_vectors ev
+			%[4]s AND ev.embedding_sparse IS NOT NULL
+			ORDER BY ev.embedding_sparse::%[5]s <#> (@svec::text::%[5]s)
+			LIMIT @sparse_candidates
+		),
+		sparse AS (
+			SELECT tenant_id, entity_type, entity_id, language, row_number() OVER (ORDER BY distance, entity_type, entity_id) AS rank
+			FROM sparse_knn
+		)
+		SELECT
+			entity_type,
+			entity_id,
+			language,
+			(COALESCE(@dense_weight::float8 / (@k + d.rank), 0) + COALESCE(@sparse_weight::float8 / (@k + s.rank), 0))::float4 AS score
+		FROM dense d
+		FULL OUTER JOIN sparse s USING (tenant_id, entity_type, entity_id, language)
+		ORDER BY score DESC, entity_type, entity_id
+		LIMIT @limit
+	`, col, typ, quotedSchema, where, sparseTyp)
+
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Hit
+	for rows.Next() {
+		h := Hit{Model: resolved.name}
+		if err := rows.Scan(&h.EntityType, &h.EntityID, &h.Language, &h.Similarity); err != nil {
+			return nil, err
+		}
+		out = append(out, h)
+	}
+	resolved.hitLanguage(out, q.Language)
+	return out, rows.Err()
+}
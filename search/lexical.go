@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/open-rails/searchkit/internal/textnormalize"
+	"github.com/open-rails/searchkit/pg"
 )
 
 type LexicalHit struct {
@@ -25,6 +26,9 @@ type LexicalOptions struct {
 	Limit         int
 	MinSimilarity float32
 
+	// AllTenants searches every tenant instead of ctx's (see Options.AllTenants).
+	AllTenants bool
+
 	// FilterSQL is an optional additional WHERE fragment appended to the query as:
 	//   ... AND (<FilterSQL>)
 	//
@@ -37,13 +41,17 @@ type LexicalOptions struct {
 	// FilterArgs are named args referenced by FilterSQL using pgx '@name'
 	// placeholders (e.g. "... language = @lang").
 	FilterArgs map[string]any
+
+	// StatementTimeout cancels the query once it runs longer (see
+	// Options.StatementTimeout). Zero uses the session's statement_timeout.
+	StatementTimeout time.Duration
 }
 
 // LexicalSearch runs a trigram similarity search against `<schema>.search_documents`.
 //
 // searchkit heavy-normalizes the query (and expects stored documents to be heavy-normalized
 // at write time).
-func LexicalSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts LexicalOptions) ([]LexicalHit, error) {
+func LexicalSearch(ctx context.Context, pool pg.Querier, query string, opts LexicalOptions) ([]LexicalHit, error) {
 	if pool == nil {
 		return nil, fmt.Errorf("pool is required")
 	}
@@ -68,7 +76,7 @@ func LexicalSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts L
 	}
 	table := quotedSchema + ".search_documents"
 
-	where := "WHERE sd.language = @language"
+	where := "WHERE sd.language = @language AND sd.deleted_at IS NULL"
 	args := pgx.NamedArgs{
 		"language": opts.Language,
 		"q":        q,
@@ -78,6 +86,10 @@ func LexicalSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts L
 		where += " AND sd.entity_type = ANY(@entity_types::text[])"
 		args["entity_types"] = opts.EntityTypes
 	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND sd.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
 	if strings.TrimSpace(opts.FilterSQL) != "" {
 		where += " AND (" + opts.FilterSQL + ")"
 		if err := mergeNamedArgs(args, opts.FilterArgs); err != nil {
@@ -112,7 +124,7 @@ func LexicalSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts L
 		LIMIT @limit
 	`, table, where)
 
-	rows, err := pool.Query(ctx, sql, args)
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
 	if err != nil {
 		return nil, err
 	}
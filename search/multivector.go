@@ -0,0 +1,62 @@
+package search
+
+import "fmt"
+
+// multiVectorSQL builds a late-interaction (MaxSim) query over chunk rows.
+//
+// Index strategy:
+//   - candidate generation runs one KNN per query vector (LATERAL), served by
+//     the per-model HNSW cosine index on the model's vector column;
+//   - exact MaxSim rescoring reads every chunk of each candidate entity through
+//     the (tenant_id, entity_type, entity_id, model, language, chunk_idx)
+//     primary key.
+//
+// Bindings: @qvecs (text[] of halfvec literals), @candidates (per query vector),
+// @limit, plus the filters referenced by where. col/typ are the vector column
+// and its fixed-dimension type (see vectorColumn).
+func multiVectorSQL(table string, where string, col string, typ string) string {
+	return fmt.Sprintf(`
+		WITH qv AS (
+			SELECT ord, v::%[1]s AS v
+			FROM unnest(@qvecs::text[]) WITH ORDINALITY AS t(v, ord)
+		), candidates AS (
+			SELECT DISTINCT c.tenant_id, c.entity_type, c.entity_id
+			FROM qv
+			CROSS JOIN LATERAL (
+				SELECT ev.tenant_id, ev.entity_type, ev.entity_id
+				FROM %[2]s ev
+				%[3]s
+				ORDER BY ev.%[4]s::%[1]s <=> qv.v
+				LIMIT @candidates
+			) c
+		), maxsim AS (
+			SELECT
+				ev.entity_type,
+				ev.entity_id,
+				ev.model,
+				ev.language,
+				qv.ord,
+				max(1 - (ev.%[4]s::%[1]s <=> qv.v)) AS sim
+			FROM candidates c
+			JOIN %[2]s ev
+				ON ev.tenant_id = c.tenant_id
+				AND ev.entity_type = c.entity_type
+				AND ev.entity_id = c.entity_id
+				AND ev.model = @model
+				AND ev.language = @language
+				AND ev.%[4]s IS NOT NULL
+			CROSS JOIN qv
+			GROUP BY ev.entity_type, ev.entity_id, ev.model, ev.language, qv.ord
+		)
+		SELECT
+			entity_type,
+			entity_id,
+			model,
+			language,
+			(sum(sim) / (SELECT count(*) FROM qv))::float4 AS similarity
+		FROM maxsim
+		GROUP BY entity_type, entity_id, model, language
+		ORDER BY 5 DESC
+		LIMIT @limit
+	`, typ, table, where, col)
+}
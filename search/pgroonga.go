@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/open-rails/searchkit/pg"
 )
 
 var pgroongaExtensionSchema struct {
@@ -17,7 +19,7 @@ var pgroongaExtensionSchema struct {
 	err    error
 }
 
-func getPGroongaExtensionSchema(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+func getPGroongaExtensionSchema(ctx context.Context, pool pg.Querier) (string, error) {
 	if pool == nil {
 		return "", fmt.Errorf("pool is required")
 	}
@@ -65,6 +67,9 @@ type PGroongaOptions struct {
 	// Defaults to 1.
 	ScoreK float32
 
+	// AllTenants searches every tenant instead of ctx's (see Options.AllTenants).
+	AllTenants bool
+
 	// FilterSQL is an optional additional WHERE fragment appended to the query as:
 	//   ... AND (<FilterSQL>)
 	//
@@ -77,6 +82,10 @@ type PGroongaOptions struct {
 	// FilterArgs are named args referenced by FilterSQL using pgx '@name'
 	// placeholders (e.g. "... language = @lang").
 	FilterArgs map[string]any
+
+	// StatementTimeout cancels the query once it runs longer (see
+	// Options.StatementTimeout). Zero uses the session's statement_timeout.
+	StatementTimeout time.Duration
 }
 
 // NormalizePGroongaScore converts a raw PGroonga score into a [0..1] range
@@ -147,7 +156,7 @@ func buildPGroongaTypeaheadQuery(q string) string {
 	return strings.Join(toks, " ")
 }
 
-func buildPGroongaSQL(docSchema string, extSchema string, entityTypes []string, filterSQL string, filterArgs map[string]any) (string, pgx.NamedArgs, string, error) {
+func buildPGroongaSQL(docSchema string, extSchema string, entityTypes []string, tenant string, allTenants bool, filterSQL string, filterArgs map[string]any) (string, pgx.NamedArgs, string, error) {
 	qs, err := quoteIdent(docSchema)
 	if err != nil {
 		return "", nil, "", fmt.Errorf("invalid schema: %w", err)
@@ -159,7 +168,7 @@ func buildPGroongaSQL(docSchema string, extSchema string, entityTypes []string,
 		return "", nil, "", fmt.Errorf("invalid pgroonga schema: %w", err)
 	}
 
-	where := "WHERE sd.language = @language AND sd.raw_document IS NOT NULL AND btrim(sd.raw_document) <> ''"
+	where := "WHERE sd.language = @language AND sd.raw_document IS NOT NULL AND btrim(sd.raw_document) <> '' AND sd.deleted_at IS NULL"
 	args := pgx.NamedArgs{
 		"language": "",
 		"q":        "",
@@ -169,6 +178,10 @@ func buildPGroongaSQL(docSchema string, extSchema string, entityTypes []string,
 		where += " AND sd.entity_type = ANY(@entity_types::text[])"
 		args["entity_types"] = entityTypes
 	}
+	if !allTenants {
+		where += " AND sd.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
 	if strings.TrimSpace(filterSQL) != "" {
 		where += " AND (" + filterSQL + ")"
 		if err := mergeNamedArgs(args, filterArgs); err != nil {
@@ -199,7 +212,7 @@ func buildPGroongaSQL(docSchema string, extSchema string, entityTypes []string,
 //
 // This is intended for languages like ja/zh/ko where Postgres FTS tokenization
 // is insufficient and trigram transliteration is lossy.
-func PGroongaSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts PGroongaOptions) ([]PGroongaHit, error) {
+func PGroongaSearch(ctx context.Context, pool pg.Querier, query string, opts PGroongaOptions) ([]PGroongaHit, error) {
 	if pool == nil {
 		return nil, fmt.Errorf("pool is required")
 	}
@@ -235,7 +248,7 @@ func PGroongaSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts
 		return nil, err
 	}
 
-	sql, args, _, err := buildPGroongaSQL(opts.Schema, extSchema, opts.EntityTypes, opts.FilterSQL, opts.FilterArgs)
+	sql, args, _, err := buildPGroongaSQL(opts.Schema, extSchema, opts.EntityTypes, pg.TenantFromContext(ctx), opts.AllTenants, opts.FilterSQL, opts.FilterArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -243,7 +256,7 @@ func PGroongaSearch(ctx context.Context, pool *pgxpool.Pool, query string, opts
 	args["q"] = q
 	args["limit"] = opts.Limit
 
-	rows, err := pool.Query(ctx, sql, args)
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
 	if err != nil {
 		return nil, err
 	}
@@ -35,7 +35,7 @@ func TestNormalizePGroongaScore(t *testing.T) {
 }
 
 func TestBuildPGroongaSQL(t *testing.T) {
-	sql, args, _, err := buildPGroongaSQL("doujins", "doujins", []string{"gallery"}, "", nil)
+	sql, args, _, err := buildPGroongaSQL("doujins", "doujins", []string{"gallery"}, "", false, "", nil)
 	if err != nil {
 		t.Fatalf("unexpected err: %v", err)
 	}
@@ -54,4 +54,7 @@ func TestBuildPGroongaSQL(t *testing.T) {
 	if _, ok := args["limit"]; !ok {
 		t.Fatalf("expected limit arg placeholder")
 	}
+	if tenant, ok := args["tenant_id"]; !ok || tenant != "" {
+		t.Fatalf("expected the default tenant filter, got %v", args["tenant_id"])
+	}
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
 )
 
 type Hit struct {
@@ -16,6 +18,23 @@ type Hit struct {
 	Model      string
 	Language   string
 	Similarity float32
+
+	// BestAsset is the entity's stored asset closest to the query, set for
+	// VL searches with Options.BestAsset (nil when the entity has no asset
+	// vectors).
+	BestAsset *AssetMatch
+}
+
+// AssetMatch identifies one stored VL asset (see runtime Options.VLAssetModels).
+type AssetMatch struct {
+	Key        string
+	FrameIdx   int
+	Kind       string
+	URL        string // the URL at embedding time (presigned URLs may have expired)
+	Similarity float32
+	// Start and End locate a matched video segment (Kind "segment") within
+	// its video; zero for other assets.
+	Start, End time.Duration
 }
 
 type Options struct {
@@ -35,6 +54,12 @@ type Options struct {
 	// Only used when TwoStage=true. Defaults to 5.
 	OversampleFactor int
 
+	// AllTenants searches every tenant. Otherwise results are restricted to
+	// ctx's tenant (see pg.WithTenant; the default tenant when ctx has none),
+	// the tenant writes use. Hits don't carry their tenant, so cross-tenant
+	// results can't tell tenants' equal entity IDs apart.
+	AllTenants bool
+
 	// FilterSQL is an optional additional WHERE fragment appended to the query as:
 	//   ... AND (<FilterSQL>)
 	//
@@ -47,6 +72,30 @@ type Options struct {
 	// FilterArgs are named args referenced by FilterSQL using pgx '@name'
 	// placeholders (e.g. "... language = @lang").
 	FilterArgs map[string]any
+
+	// ChunkAggregate returns one hit per entity for models stored as multiple
+	// chunk rows (see runtime.ChunkOptions). Empty returns rows as-is, which is
+	// correct for unchunked models.
+	ChunkAggregate ChunkAggregate
+	// ChunkTopK is how many chunks are summed per entity for ChunkAggregateSum.
+	// Defaults to 3.
+	ChunkTopK int
+	// ChunkOversample controls how many chunk rows are retrieved per requested
+	// hit before aggregation (per query vector for QueryVecs). Defaults to 4.
+	ChunkOversample int
+
+	// BestAsset annotates each hit with the entity's best-matching stored
+	// asset (Hit.BestAsset), e.g. to show the matching image as the result
+	// thumbnail. Only per-asset VL models have asset vectors; costs one extra
+	// query. Ignored for QueryVecs.
+	BestAsset bool
+
+	// StatementTimeout cancels the query on the server once it runs longer
+	// (SET LOCAL statement_timeout in a short transaction), so a runaway scan
+	// fails with an IsStatementTimeout error instead of holding a pooled
+	// connection. Zero uses the session's statement_timeout. Not applied when
+	// querying a caller's pgx.Tx.
+	StatementTimeout time.Duration
 }
 
 type Query struct {
@@ -57,6 +106,22 @@ type Query struct {
 	Limit      int
 	Dimensions int // required for TwoStage; defaults to len(QueryVec) when 0
 	Options    Options
+
+	// Storage is the model's vector storage mode. Empty resolves it from
+	// embedding_models (cached per process). Model may be an alias of the
+	// canonical model either way. VL models ignore it and always use their
+	// registered mode.
+	Storage pg.StorageMode
+
+	// QueryVecs enables multi-vector (late interaction) scoring: each entity is
+	// scored by the mean over query vectors of the max similarity across its
+	// chunk vectors. When set, QueryVec, TwoStage and ChunkAggregate are ignored.
+	QueryVecs [][]float32
+
+	// IncludeShadow allows searching a shadow model (embedding_models.shadow),
+	// e.g. for offline evaluation. Without it, SemanticSearch rejects shadow
+	// models so a candidate never serves production traffic by accident.
+	IncludeShadow bool
 }
 
 func quoteIdent(ident string) (string, error) {
@@ -90,12 +155,33 @@ func mergeNamedArgs(dst pgx.NamedArgs, extra map[string]any) error {
 	return nil
 }
 
+// tenantFilter returns the tenant a read is restricted to: ctx's (see
+// pg.TenantFromContext), or none (ok false) with allTenants.
+func tenantFilter(ctx context.Context, allTenants bool) (tenant string, ok bool) {
+	if allTenants {
+		return "", false
+	}
+	return pg.TenantFromContext(ctx), true
+}
+
 // SemanticSearch runs a semantic KNN search against the searchkit-owned
-// `<schema>.embedding_vectors` table and returns only candidate IDs + scores.
+// `<schema>.embedding_vectors` table (`embedding_vectors_vl` for VL models) and
+// returns only candidate IDs + scores.
 //
 // This function intentionally does not hydrate domain rows or apply business
 // logic beyond basic filtering options.
-func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, error) {
+func SemanticSearch(ctx context.Context, pool pg.Querier, q Query) ([]Hit, error) {
+	hits, err := semanticSearch(ctx, pool, q)
+	if err != nil || !q.Options.BestAsset || len(q.QueryVecs) > 0 || len(hits) == 0 {
+		return hits, err
+	}
+	if err := annotateBestAssets(ctx, pool, q.Schema, q.Model, q.Language, q.QueryVec, nil, hits, q.Options.AllTenants); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+func semanticSearch(ctx context.Context, pool pg.Querier, q Query) ([]Hit, error) {
 	if pool == nil {
 		return nil, fmt.Errorf("pool is required")
 	}
@@ -111,13 +197,23 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 	if q.Limit <= 0 {
 		return []Hit{}, nil
 	}
-	if len(q.QueryVec) == 0 {
+	multi := len(q.QueryVecs) > 0
+	if len(q.QueryVec) == 0 && !multi {
 		return []Hit{}, nil
 	}
 
 	dim := q.Dimensions
 	if dim <= 0 {
-		dim = len(q.QueryVec)
+		if multi {
+			dim = len(q.QueryVecs[0])
+		} else {
+			dim = len(q.QueryVec)
+		}
+	}
+	for _, v := range q.QueryVecs {
+		if len(v) != dim {
+			return nil, fmt.Errorf("QueryVecs must all have %d dimensions", dim)
+		}
 	}
 
 	quotedSchema, err := quoteIdent(q.Schema)
@@ -125,27 +221,56 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 		return nil, fmt.Errorf("invalid schema: %w", err)
 	}
 
+	resolved, err := resolveModel(ctx, pool, quotedSchema, q.Model, q.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.shadow && !q.IncludeShadow {
+		return nil, fmt.Errorf("model %q is a shadow model; set IncludeShadow to search it", resolved.name)
+	}
+	mode := resolved.storage
+	if mode == pg.StorageSparse {
+		return nil, fmt.Errorf("model %q stores sparse vectors; use SparseSearch", resolved.name)
+	}
+	col, typ := vectorColumn(mode, dim)
 	half := fmt.Sprintf("halfvec(%d)", dim)
-	table := quotedSchema + ".embedding_vectors"
+	table := resolved.table(quotedSchema)
 
 	opts := q.Options
 	if opts.OversampleFactor <= 1 {
 		opts.OversampleFactor = 5
 	}
+	if opts.ChunkTopK <= 0 {
+		opts.ChunkTopK = defaultChunkTopK
+	}
+	if opts.ChunkOversample <= 1 {
+		opts.ChunkOversample = defaultChunkOversample
+	}
 
-	vec := pgvector.NewHalfVector(q.QueryVec)
+	// With chunk aggregation, the KNN stage retrieves chunk rows and @limit
+	// bounds candidates rather than entities.
+	limit := q.Limit
+	if opts.ChunkAggregate != "" && !multi {
+		limit = q.Limit * opts.ChunkOversample
+	}
+
+	vec := queryVectorArg(mode, q.QueryVec)
 
 	var sql string
 	args := pgx.NamedArgs{}
 
 	// Common WHERE filters.
-	where := "WHERE ev.model = @model AND ev.language = @language AND ev.embedding IS NOT NULL"
-	args["model"] = q.Model
-	args["language"] = q.Language
+	where := "WHERE ev.model = @model AND ev.language = @language AND ev." + col + " IS NOT NULL AND ev.deleted_at IS NULL"
+	args["model"] = resolved.name
+	args["language"] = resolved.language(q.Language)
 	if len(opts.EntityTypes) > 0 {
 		where += " AND ev.entity_type = ANY(@entity_types::text[])"
 		args["entity_types"] = opts.EntityTypes
 	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND ev.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
 	if len(opts.ExcludeIDs) > 0 {
 		where += " AND ev.entity_id <> ALL(@exclude_ids::text[])"
 		args["exclude_ids"] = opts.ExcludeIDs
@@ -157,7 +282,61 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 		}
 	}
 
-	if !opts.TwoStage {
+	if multi {
+		if mode == pg.StorageBit {
+			return nil, fmt.Errorf("multi-vector search is not supported for %s storage", mode)
+		}
+		sql = multiVectorSQL(table, where, col, typ)
+		qvecs := make([]string, len(q.QueryVecs))
+		for i, v := range q.QueryVecs {
+			qvecs[i] = pgvector.NewHalfVector(v).String()
+		}
+		args["qvecs"] = qvecs
+		args["candidates"] = q.Limit * opts.ChunkOversample
+		args["limit"] = q.Limit
+	} else if mode == pg.StorageBit {
+		// Bit-only storage is always 2-stage:
+		//  - stage 1: Hamming KNN over embedding_bits
+		//  - stage 2: rescore with exact vectors from embedding_vectors_exact
+		sql = fmt.Sprintf(`
+			WITH candidates AS (
+				SELECT
+					ev.tenant_id,
+					ev.entity_type,
+					ev.entity_id,
+					ev.model,
+					ev.language,
+					ev.chunk_idx
+				FROM %s ev
+				%s
+				ORDER BY ev.embedding_bits::%s <~> (@qbits::text::%s)
+				LIMIT @oversample
+			)
+			SELECT
+				c.entity_type,
+				c.entity_id,
+				c.model,
+				c.language,
+				(1 - (ex.embedding::%s <=> (@qvec::%s)))::float4 AS similarity
+			FROM candidates c
+			JOIN %s.embedding_vectors_exact ex
+				ON ex.tenant_id = c.tenant_id
+				AND ex.entity_type = c.entity_type
+				AND ex.entity_id = c.entity_id
+				AND ex.model = c.model
+				AND ex.language = c.language
+				AND ex.chunk_idx = c.chunk_idx
+			WHERE (1 - (ex.embedding::%s <=> (@qvec::%s))) >= @min_similarity
+			ORDER BY ex.embedding::%s <=> (@qvec::%s)
+			LIMIT @limit
+		`, table, where, typ, typ, half, half, quotedSchema, half, half, half, half)
+
+		args["qvec"] = vec
+		args["qbits"] = pg.BinaryQuantize(q.QueryVec)
+		args["oversample"] = limit * opts.OversampleFactor
+		args["min_similarity"] = opts.MinSimilarity
+		args["limit"] = limit
+	} else if !opts.TwoStage {
 		// 1-stage cosine KNN:
 		// similarity = 1 - cosine_distance
 		// order by cosine_distance
@@ -167,17 +346,17 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 				ev.entity_id,
 				ev.model,
 				ev.language,
-				(1 - (ev.embedding::%s <=> (@qvec::%s)))::float4 AS similarity
+				(1 - (ev.%s::%s <=> (@qvec::%s)))::float4 AS similarity
 			FROM %s ev
 			%s
-			ORDER BY ev.embedding::%s <=> (@qvec::%s)
+			ORDER BY ev.%s::%s <=> (@qvec::%s)
 			LIMIT @limit
-		`, half, half, table, where, half, half)
+		`, col, typ, typ, table, where, col, typ, typ)
 
 		args["qvec"] = vec
-		args["limit"] = q.Limit
+		args["limit"] = limit
 	} else {
-		oversample := q.Limit * opts.OversampleFactor
+		oversample := limit * opts.OversampleFactor
 
 		// 2-stage:
 		//  - stage 1: approx retrieval using binary quantize (Hamming distance)
@@ -189,10 +368,10 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 						ev.entity_id,
 						ev.model,
 						ev.language,
-						ev.embedding
+						ev.%s AS embedding
 					FROM %s ev
 					%s
-					ORDER BY (binary_quantize(embedding::%s)::bit(%d)) <~> (binary_quantize(@qvec::%s)::bit(%d))
+					ORDER BY (binary_quantize(ev.%s::%s)::bit(%d)) <~> (binary_quantize(@qvec::%s)::bit(%d))
 					LIMIT @oversample
 				)
 				SELECT
@@ -205,15 +384,28 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 				WHERE (1 - (embedding::%s <=> (@qvec::%s))) >= @min_similarity
 				ORDER BY embedding::%s <=> (@qvec::%s)
 				LIMIT @limit
-			`, table, where, half, dim, half, dim, half, half, half, half, half, half)
+			`, col, table, where, col, typ, dim, typ, dim, typ, typ, typ, typ, typ, typ)
 
 		args["qvec"] = vec
 		args["oversample"] = oversample
 		args["min_similarity"] = opts.MinSimilarity
-		args["limit"] = q.Limit
+		args["limit"] = limit
 	}
 
-	rows, err := pool.Query(ctx, sql, args)
+	if opts.ChunkAggregate != "" && !multi {
+		sql, err = wrapChunkAggregate(sql, opts.ChunkAggregate)
+		if err != nil {
+			return nil, err
+		}
+		topK := opts.ChunkTopK
+		if opts.ChunkAggregate == ChunkAggregateMax {
+			topK = 1
+		}
+		args["chunk_top_k"] = topK
+		args["chunk_limit"] = q.Limit
+	}
+
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
 	if err != nil {
 		return nil, err
 	}
@@ -230,17 +422,19 @@ func SemanticSearch(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, er
 		}
 		out = append(out, h)
 	}
+	resolved.hitLanguage(out, q.Language)
 	return out, rows.Err()
 }
 
 // SearchVectors is a legacy alias for SemanticSearch.
-func SearchVectors(ctx context.Context, pool *pgxpool.Pool, q Query) ([]Hit, error) {
+func SearchVectors(ctx context.Context, pool pg.Querier, q Query) ([]Hit, error) {
 	return SemanticSearch(ctx, pool, q)
 }
 
 // SimilarTo returns nearest neighbors to an existing stored vector for the same
-// model, excluding the source entity itself.
-func SimilarTo(ctx context.Context, pool *pgxpool.Pool, schema string, entityType string, entityID string, model string, language string, limit int, opts Options) ([]Hit, error) {
+// model, excluding the source entity itself. The source is ctx's tenant's
+// entity (see pg.WithTenant), also with Options.AllTenants.
+func SimilarTo(ctx context.Context, pool pg.Querier, schema string, entityType string, entityID string, model string, language string, limit int, opts Options) ([]Hit, error) {
 	if pool == nil {
 		return nil, fmt.Errorf("pool is required")
 	}
@@ -265,26 +459,45 @@ func SimilarTo(ctx context.Context, pool *pgxpool.Pool, schema string, entityTyp
 		return nil, fmt.Errorf("invalid schema: %w", err)
 	}
 
-	table := quotedSchema + ".embedding_vectors"
+	resolved, err := resolveModel(ctx, pool, quotedSchema, model, "")
+	if err != nil {
+		return nil, err
+	}
+	model = resolved.name
+	queryLanguage := language
+	language = resolved.language(language)
+	mode := resolved.storage
+	if mode == pg.StorageBit || mode == pg.StorageSparse {
+		return nil, fmt.Errorf("SimilarTo is not supported for %s storage", mode)
+	}
+	col, _ := vectorColumn(mode, 0)
+
+	table := resolved.table(quotedSchema)
 
 	where := `
 		WHERE ev.model = @model
 		  AND ev.language = @language
-		  AND ev.embedding IS NOT NULL
-		  AND NOT (ev.entity_type = @entity_type AND ev.entity_id = @entity_id)
+		  AND ev.` + col + ` IS NOT NULL
+		  AND ev.deleted_at IS NULL
+		  AND NOT (ev.tenant_id = @source_tenant AND ev.entity_type = @entity_type AND ev.entity_id = @entity_id)
 	`
 	args := pgx.NamedArgs{
-		"entity_type": entityType,
-		"entity_id":   entityID,
-		"model":       model,
-		"language":    language,
-		"limit":       limit,
+		"entity_type":   entityType,
+		"entity_id":     entityID,
+		"model":         model,
+		"language":      language,
+		"limit":         limit,
+		"source_tenant": pg.TenantFromContext(ctx),
 	}
 
 	if len(opts.EntityTypes) > 0 {
 		where += " AND ev.entity_type = ANY(@entity_types::text[])\n"
 		args["entity_types"] = opts.EntityTypes
 	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND ev.tenant_id = @tenant_id\n"
+		args["tenant_id"] = tenant
+	}
 	if len(opts.ExcludeIDs) > 0 {
 		where += " AND ev.entity_id <> ALL(@exclude_ids::text[])\n"
 		args["exclude_ids"] = opts.ExcludeIDs
@@ -298,11 +511,17 @@ func SimilarTo(ctx context.Context, pool *pgxpool.Pool, schema string, entityTyp
 
 	// NOTE: SimilarTo always runs 1-stage cosine KNN. Callers can run TwoStage by
 	// fetching the source vector and calling SearchVectors with TwoStage=true.
+	// For chunked models the source is the entity's first chunk.
+	firstChunk := " AND chunk_idx = 0"
+	if resolved.vl {
+		firstChunk = ""
+	}
 	sql := fmt.Sprintf(`
 		WITH source AS (
-			SELECT embedding
-			FROM %s
-			WHERE entity_type = @entity_type AND entity_id = @entity_id AND model = @model AND language = @language AND embedding IS NOT NULL
+			SELECT %[1]s AS embedding
+			FROM %[2]s
+			WHERE entity_type = @entity_type AND entity_id = @entity_id AND model = @model AND language = @language%[4]s AND %[1]s IS NOT NULL
+				AND tenant_id = @source_tenant
 			LIMIT 1
 		)
 		SELECT
@@ -310,14 +529,14 @@ func SimilarTo(ctx context.Context, pool *pgxpool.Pool, schema string, entityTyp
 			ev.entity_id,
 			ev.model,
 			ev.language,
-			(1 - (ev.embedding <=> s.embedding))::float4 AS similarity
-		FROM %s ev, source s
-		%s
-		ORDER BY ev.embedding <=> s.embedding
+			(1 - (ev.%[1]s <=> s.embedding))::float4 AS similarity
+		FROM %[2]s ev, source s
+		%[3]s
+		ORDER BY ev.%[1]s <=> s.embedding
 		LIMIT @limit
-	`, table, table, where)
+	`, col, table, where, firstChunk)
 
-	rows, err := pool.Query(ctx, sql, args)
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
 	if err != nil {
 		return nil, err
 	}
@@ -334,5 +553,6 @@ func SimilarTo(ctx context.Context, pool *pgxpool.Pool, schema string, entityTyp
 		}
 		out = append(out, h)
 	}
+	resolved.hitLanguage(out, queryLanguage)
 	return out, rows.Err()
 }
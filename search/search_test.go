@@ -1,9 +1,14 @@
 package search
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func TestMergeNamedArgs_Conflict(t *testing.T) {
@@ -40,3 +45,39 @@ func TestFuseRRF_Basic(t *testing.T) {
 		t.Fatalf("expected top entity_id=2, got %q", out[0].EntityID)
 	}
 }
+
+func TestIsStatementTimeout(t *testing.T) {
+	if !IsStatementTimeout(fmt.Errorf("search: %w", &pgconn.PgError{Code: "57014"})) {
+		t.Fatalf("expected a wrapped 57014 error to be a statement timeout")
+	}
+	if IsStatementTimeout(&pgconn.PgError{Code: "42P01"}) || IsStatementTimeout(errors.New("boom")) {
+		t.Fatalf("expected other errors not to be statement timeouts")
+	}
+}
+
+// callerTx is a caller's transaction; only Begin and Query are expected.
+type callerTx struct {
+	pgx.Tx
+	began bool
+}
+
+var errQueried = errors.New("queried")
+
+func (tx *callerTx) Begin(context.Context) (pgx.Tx, error) {
+	tx.began = true
+	return nil, errors.New("unexpected savepoint")
+}
+
+func (tx *callerTx) Query(context.Context, string, ...any) (pgx.Rows, error) {
+	return nil, errQueried
+}
+
+func TestQueryRows_CallerTxSkipsTimeout(t *testing.T) {
+	tx := &callerTx{}
+	if _, err := queryRows(context.Background(), tx, time.Second, "SELECT 1"); !errors.Is(err, errQueried) {
+		t.Fatalf("expected the query to run on the caller's tx, got %v", err)
+	}
+	if tx.began {
+		t.Fatalf("expected no savepoint (its SET LOCAL would outlast the query)")
+	}
+}
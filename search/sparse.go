@@ -0,0 +1,136 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+type SparseQuery struct {
+	Schema   string
+	Model    string
+	Language string
+	// QueryVec is the sparse query embedding (see runtime.EmbedQuerySparse);
+	// its dimensions must match the model's vocabulary size.
+	QueryVec pgvector.SparseVector
+	Limit    int
+
+	// Options apply as for SemanticSearch; MinSimilarity is a minimum inner
+	// product. TwoStage and the chunk options are ignored.
+	Options Options
+
+	// IncludeShadow allows searching a shadow model (see Query.IncludeShadow).
+	IncludeShadow bool
+}
+
+// SparseSearch runs an inner-product KNN search over a sparse (or hybrid)
+// model's vectors (embedding_vectors.embedding_sparse). Hit.Similarity is the inner product of
+// the query and document term weights. Fuse the results with dense and lexical
+// lists via FuseRRF (see HitKeys).
+func SparseSearch(ctx context.Context, pool pg.Querier, q SparseQuery) ([]Hit, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("pool is required")
+	}
+	if strings.TrimSpace(q.Schema) == "" {
+		return nil, fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(q.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(q.Language) == "" {
+		return nil, fmt.Errorf("language is required")
+	}
+	if q.Limit <= 0 || len(q.QueryVec.Indices()) == 0 {
+		return []Hit{}, nil
+	}
+	dim := int(q.QueryVec.Dimensions())
+
+	quotedSchema, err := quoteIdent(q.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	resolved, err := resolveModel(ctx, pool, quotedSchema, q.Model, pg.StorageSparse)
+	if err != nil {
+		return nil, err
+	}
+	if resolved.shadow && !q.IncludeShadow {
+		return nil, fmt.Errorf("model %q is a shadow model; set IncludeShadow to search it", resolved.name)
+	}
+	col, typ := vectorColumn(pg.StorageSparse, dim)
+
+	opts := q.Options
+	where := "WHERE ev.model = @model AND ev.language = @language AND ev." + col + " IS NOT NULL AND ev.deleted_at IS NULL"
+	args := pgx.NamedArgs{
+		"model":    resolved.name,
+		"language": resolved.language(q.Language),
+		"qvec":     q.QueryVec.String(),
+		"limit":    q.Limit,
+	}
+	if len(opts.EntityTypes) > 0 {
+		where += " AND ev.entity_type = ANY(@entity_types::text[])"
+		args["entity_types"] = opts.EntityTypes
+	}
+	if tenant, ok := tenantFilter(ctx, opts.AllTenants); ok {
+		where += " AND ev.tenant_id = @tenant_id"
+		args["tenant_id"] = tenant
+	}
+	if len(opts.ExcludeIDs) > 0 {
+		where += " AND ev.entity_id <> ALL(@exclude_ids::text[])"
+		args["exclude_ids"] = opts.ExcludeIDs
+	}
+	if strings.TrimSpace(opts.FilterSQL) != "" {
+		where += " AND (" + opts.FilterSQL + ")"
+		if err := mergeNamedArgs(args, opts.FilterArgs); err != nil {
+			return nil, err
+		}
+	}
+
+	// <#> is the negative inner product, matching the sparsevec_ip_ops index.
+	sql := fmt.Sprintf(`
+		SELECT
+			ev.entity_type,
+			ev.entity_id,
+			ev.model,
+			ev.language,
+			(-(ev.%[1]s::%[2]s <#> (@qvec::text::%[2]s)))::float4 AS similarity
+		FROM %[3]s.embedding_vectors ev
+		%[4]s
+		ORDER BY ev.%[1]s::%[2]s <#> (@qvec::text::%[2]s)
+		LIMIT @limit
+	`, col, typ, quotedSchema, where)
+
+	rows, err := queryRows(ctx, pool, opts.StatementTimeout, sql, args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Hit
+	for rows.Next() {
+		var h Hit
+		if err := rows.Scan(&h.EntityType, &h.EntityID, &h.Model, &h.Language, &h.Similarity); err != nil {
+			return nil, err
+		}
+		if opts.MinSimilarity > 0 && h.Similarity < opts.MinSimilarity {
+			continue
+		}
+		out = append(out, h)
+	}
+	resolved.hitLanguage(out, q.Language)
+	return out, rows.Err()
+}
+
+// HitKeys returns the RRF keys of hits, in order, for FuseRRF. Model is left
+// empty so the same entity matched by several models fuses into one result.
+func HitKeys(hits []Hit) []RRFKey {
+	keys := make([]RRFKey, len(hits))
+	for i, h := range hits {
+		keys[i] = RRFKey{EntityType: h.EntityType, EntityID: h.EntityID, Language: h.Language}
+	}
+	return keys
+}
@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	pgvector "github.com/pgvector/pgvector-go"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// resolvedModel is a model name resolved against embedding_models.
+type resolvedModel struct {
+	name    string // canonical model name (embedding_vectors.model)
+	storage pg.StorageMode
+	shadow  bool
+	// anyLanguage models store vectors under pg.AnyLanguage.
+	anyLanguage bool
+	// vl models store their vectors in embedding_vectors_vl.
+	vl bool
+}
+
+// resolvedModels caches model resolution per (schema, model) for the lifetime
+// of the process; changing a model's aliases, storage mode or flags
+// requires a restart (or an explicit Query.Storage).
+var resolvedModels sync.Map
+
+// resolveModel resolves model (or one of its aliases) to the canonical model
+// name and storage mode from embedding_models. Unregistered models resolve to
+// themselves with StorageHalfvec. explicit, when set, overrides the storage
+// mode.
+func resolveModel(ctx context.Context, pool pg.Querier, quotedSchema string, model string, explicit pg.StorageMode) (resolvedModel, error) {
+	if err := explicit.Validate(); err != nil {
+		return resolvedModel{}, err
+	}
+	withExplicit := func(m resolvedModel) resolvedModel {
+		if explicit != "" && !m.vl {
+			m.storage = explicit
+		}
+		return m
+	}
+
+	key := quotedSchema + "\x00" + model
+	if v, ok := resolvedModels.Load(key); ok {
+		return withExplicit(v.(resolvedModel)), nil
+	}
+
+	var (
+		name, mode, modality string
+		shadow               bool
+		anyLang              bool
+	)
+	err := pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT model, storage, shadow, language_agnostic, modality
+		FROM %s.embedding_models
+		WHERE model = $1 OR $1 = ANY(aliases)
+		ORDER BY (model = $1) DESC
+		LIMIT 1
+	`, quotedSchema), model).Scan(&name, &mode, &shadow, &anyLang, &modality)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return withExplicit(resolvedModel{name: model, storage: pg.StorageHalfvec}), nil
+	}
+	if err != nil {
+		return resolvedModel{}, fmt.Errorf("resolve model %q: %w", model, err)
+	}
+	m := resolvedModel{name: name, storage: pg.StorageMode(mode).OrDefault(), shadow: shadow, anyLanguage: anyLang, vl: modality == "vl"}
+	if m.vl && m.storage != pg.StorageVector {
+		m.storage = pg.StorageHalfvec
+	}
+	if err := m.storage.Validate(); err != nil {
+		return resolvedModel{}, err
+	}
+	resolvedModels.Store(key, m)
+	return withExplicit(m), nil
+}
+
+// table returns the table holding m's vectors.
+func (m resolvedModel) table(quotedSchema string) string {
+	if m.vl {
+		return quotedSchema + ".embedding_vectors_vl"
+	}
+	return quotedSchema + ".embedding_vectors"
+}
+
+// language returns the embedding_vectors language to search for m.
+func (m resolvedModel) language(queryLanguage string) string {
+	if m.anyLanguage {
+		return pg.AnyLanguage
+	}
+	return queryLanguage
+}
+
+// hitLanguage reports hits of language-agnostic models under the query
+// language, so they fuse (FuseRRF) with per-language lexical results.
+func (m resolvedModel) hitLanguage(hits []Hit, queryLanguage string) {
+	if !m.anyLanguage {
+		return
+	}
+	for i := range hits {
+		hits[i].Language = queryLanguage
+	}
+}
+
+// vectorColumn returns the embedding_vectors column and fixed-dimension SQL type
+// holding vectors for mode.
+func vectorColumn(mode pg.StorageMode, dim int) (col string, typ string) {
+	switch mode {
+	case pg.StorageVector:
+		return "embedding_vec", fmt.Sprintf("vector(%d)", dim)
+	case pg.StorageBit:
+		return "embedding_bits", fmt.Sprintf("bit(%d)", dim)
+	case pg.StorageSparse:
+		return "embedding_sparse", fmt.Sprintf("sparsevec(%d)", dim)
+	default:
+		return "embedding", fmt.Sprintf("halfvec(%d)", dim)
+	}
+}
+
+// queryVectorArg binds a query vector for comparison against mode's exact
+// vectors (StorageBit rescoring uses halfvec).
+func queryVectorArg(mode pg.StorageMode, vec []float32) any {
+	if mode == pg.StorageVector {
+		return pgvector.NewVector(vec)
+	}
+	return pgvector.NewHalfVector(vec)
+}
@@ -0,0 +1,53 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/open-rails/searchkit/pg"
+)
+
+// queryCanceled is the SQLSTATE of statements canceled by statement_timeout
+// (or a cancel request).
+const queryCanceled = "57014"
+
+// IsStatementTimeout reports whether err is a search query canceled by its
+// StatementTimeout.
+func IsStatementTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == queryCanceled
+}
+
+// queryRows runs sql on pool with statement_timeout set to timeout (SET LOCAL in a
+// transaction that ends when the rows are closed), so a runaway KNN or trigram
+// scan is canceled by the server instead of holding its connection. A zero
+// timeout queries pool directly.
+//
+// On a caller's pgx.Tx the timeout is not applied: Begin would open a
+// savepoint, and the setting would outlast it for the rest of the caller's
+// transaction.
+func queryRows(ctx context.Context, pool pg.Querier, timeout time.Duration, sql string, args ...any) (pgx.Rows, error) {
+	if _, ok := pool.(pgx.Tx); ok || timeout <= 0 {
+		return pool.Query(ctx, sql, args...)
+	}
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ms := max(timeout.Milliseconds(), 1)
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", ms)); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return pg.TxRows(ctx, tx, rows), nil
+}
@@ -7,10 +7,18 @@ type Task struct {
 	EntityID   string
 	Model      string
 	Language   string
+	TenantID   string // "" for the default tenant; see pg.WithTenant
 	Reason     string
+	Priority   int
 	Attempts   int
 	NextRunAt  time.Time
 	StartedAt  *time.Time
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+
+	// WorkerID and LeaseExpiresAt identify the lease held by the worker that
+	// fetched the task. Complete/Fail/DeadLetter only apply while that lease is
+	// still held.
+	WorkerID       string
+	LeaseExpiresAt time.Time
 }
@@ -2,26 +2,62 @@ package tasks
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/open-rails/searchkit/pg"
 )
 
 type Repo struct {
-	pool   *pgxpool.Pool
-	schema string
+	pool     pg.Querier
+	schema   string
+	workerID string
 }
 
 const embeddingTasksTable = "embedding_tasks"
 const embeddingDeadLettersTable = "embedding_dead_letters"
 
-func NewRepo(pool *pgxpool.Pool, schema string) *Repo {
-	return &Repo{pool: pool, schema: schema}
+func NewRepo(pool pg.Querier, schema string) *Repo {
+	return &Repo{pool: pool, schema: schema, workerID: defaultWorkerID()}
 }
 
+// WithWorkerID returns a copy of the repo that leases tasks as workerID
+// (defaults to "<hostname>-<pid>-<random>").
+func (r *Repo) WithWorkerID(workerID string) *Repo {
+	out := *r
+	if id := strings.TrimSpace(workerID); id != "" {
+		out.workerID = id
+	}
+	return &out
+}
+
+// WorkerID returns the lease owner id used by this repo.
+func (r *Repo) WorkerID() string { return r.workerID }
+
+func defaultWorkerID() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "worker"
+	}
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
+
+// Enqueue schedules an embedding task. The task belongs to ctx's tenant (see
+// pg.WithTenant).
 func (r *Repo) Enqueue(ctx context.Context, entityType string, entityID string, model string, language string, reason string) error {
+	return r.EnqueuePriority(ctx, entityType, entityID, model, language, reason, 0)
+}
+
+// EnqueuePriority is Enqueue with a priority: ready tasks are leased in
+// descending priority, then oldest first. Re-enqueueing keeps the higher of the
+// existing and new priority (and the reason that goes with it).
+func (r *Repo) EnqueuePriority(ctx context.Context, entityType string, entityID string, model string, language string, reason string, priority int) error {
 	if entityType == "" || model == "" {
 		return fmt.Errorf("entityType and model are required")
 	}
@@ -35,14 +71,19 @@ func (r *Repo) Enqueue(ctx context.Context, entityType string, entityID string,
 		return fmt.Errorf("schema is required")
 	}
 	q := fmt.Sprintf(`
-		INSERT INTO %s.%s (entity_type, entity_id, model, language, reason)
-		VALUES ($1, $2, $3, $4, COALESCE($5, 'unknown'))
-		ON CONFLICT (entity_type, entity_id, model, language) DO UPDATE SET
-			reason = EXCLUDED.reason,
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, reason, tenant_id, priority)
+		VALUES ($1, $2, $3, $4, COALESCE($5, 'unknown'), $6, $7)
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language) DO UPDATE SET
+			reason = CASE WHEN EXCLUDED.priority >= %s.%s.priority THEN EXCLUDED.reason ELSE %s.%s.reason END,
+			priority = GREATEST(%s.%s.priority, EXCLUDED.priority),
 			next_run_at = LEAST(%s.%s.next_run_at, now()),
+			worker_id = NULL,
+			lease_expires_at = NULL,
 			updated_at = now()
-	`, r.schema, embeddingTasksTable, r.schema, embeddingTasksTable)
-	_, err := r.pool.Exec(ctx, q, entityType, entityID, model, language, reason)
+	`, r.schema, embeddingTasksTable,
+		r.schema, embeddingTasksTable, r.schema, embeddingTasksTable, r.schema, embeddingTasksTable,
+		r.schema, embeddingTasksTable)
+	_, err := r.pool.Exec(ctx, q, entityType, entityID, model, language, reason, pg.TenantFromContext(ctx), priority)
 	return err
 }
 
@@ -64,19 +105,25 @@ func (r *Repo) EnqueueMany(ctx context.Context, entityType string, entityIDs []s
 		WITH ids AS (
 			SELECT unnest($2::text[]) AS entity_id
 		)
-		INSERT INTO %s.%s (entity_type, entity_id, model, language, reason)
-		SELECT $1, ids.entity_id, $3, $4, COALESCE($5, 'unknown')
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, reason, tenant_id)
+		SELECT $1, ids.entity_id, $3, $4, COALESCE($5, 'unknown'), $6
 		FROM ids
 		WHERE ids.entity_id IS NOT NULL AND btrim(ids.entity_id) <> ''
-		ON CONFLICT (entity_type, entity_id, model, language) DO UPDATE SET
-			reason = EXCLUDED.reason,
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language) DO UPDATE SET
+			reason = CASE WHEN %s.%s.priority > 0 THEN %s.%s.reason ELSE EXCLUDED.reason END,
 			next_run_at = LEAST(%s.%s.next_run_at, now()),
+			worker_id = NULL,
+			lease_expires_at = NULL,
 			updated_at = now()
-	`, r.schema, embeddingTasksTable, r.schema, embeddingTasksTable)
-	_, err := r.pool.Exec(ctx, q, entityType, entityIDs, model, language, reason)
+	`, r.schema, embeddingTasksTable,
+		r.schema, embeddingTasksTable, r.schema, embeddingTasksTable,
+		r.schema, embeddingTasksTable)
+	_, err := r.pool.Exec(ctx, q, entityType, entityIDs, model, language, reason, pg.TenantFromContext(ctx))
 	return err
 }
 
+// DeleteAllForEntity deletes an entity+language's tasks (all models) of ctx's
+// tenant.
 func (r *Repo) DeleteAllForEntity(ctx context.Context, entityType string, entityID string, language string) error {
 	if r.schema == "" {
 		return fmt.Errorf("schema is required")
@@ -86,15 +133,48 @@ func (r *Repo) DeleteAllForEntity(ctx context.Context, entityType string, entity
 	}
 	q := fmt.Sprintf(`
 		DELETE FROM %s.%s
-		WHERE entity_type = $1 AND entity_id = $2 AND language = $3
+		WHERE tenant_id = $4 AND entity_type = $1 AND entity_id = $2 AND language = $3
 	`, r.schema, embeddingTasksTable)
-	_, err := r.pool.Exec(ctx, q, entityType, entityID, language)
+	_, err := r.pool.Exec(ctx, q, entityType, entityID, language, pg.TenantFromContext(ctx))
 	return err
 }
 
-// FetchReady returns up to limit tasks ready to run now, and bumps next_run_at
-// forward by lockAhead to reduce duplicate work across workers.
+// OldestReadyAt returns the next_run_at of the oldest task that is ready to run
+// now, optionally restricted to models. ok is false when no task is ready.
+func (r *Repo) OldestReadyAt(ctx context.Context, models ...string) (at time.Time, ok bool, err error) {
+	if r.schema == "" {
+		return time.Time{}, false, fmt.Errorf("schema is required")
+	}
+	q := fmt.Sprintf(`
+		SELECT min(next_run_at)
+		FROM %s.%s
+		WHERE next_run_at <= now()
+		  AND (lease_expires_at IS NULL OR lease_expires_at <= now())
+		  AND (cardinality($1::text[]) = 0 OR model = ANY($1::text[]))
+	`, r.schema, embeddingTasksTable)
+	var oldest *time.Time
+	if err := r.pool.QueryRow(ctx, q, nonNilStrings(models)).Scan(&oldest); err != nil {
+		return time.Time{}, false, err
+	}
+	if oldest == nil {
+		return time.Time{}, false, nil
+	}
+	return *oldest, true, nil
+}
+
+// FetchReady returns up to limit tasks ready to run now and leases them to this
+// repo's worker for lockAhead, to avoid duplicate work across workers.
+//
+// Tasks whose lease has expired (e.g. a crashed worker) are taken over. Re-enqueueing
+// a leased task (Enqueue/EnqueueMany) clears its lease so the newer request is not
+// lost when the current holder completes.
 func (r *Repo) FetchReady(ctx context.Context, limit int, lockAhead time.Duration) ([]Task, error) {
+	return r.FetchReadyForModels(ctx, nil, limit, lockAhead)
+}
+
+// FetchReadyForModels is like FetchReady but only leases tasks for the given
+// models. An empty models list means all models.
+func (r *Repo) FetchReadyForModels(ctx context.Context, models []string, limit int, lockAhead time.Duration) ([]Task, error) {
 	if limit <= 0 {
 		return nil, nil
 	}
@@ -105,32 +185,34 @@ func (r *Repo) FetchReady(ctx context.Context, limit int, lockAhead time.Duratio
 		return nil, fmt.Errorf("schema is required")
 	}
 
-	now := time.Now().UTC()
-	next := now.Add(lockAhead)
-
 	q := fmt.Sprintf(`
 		WITH picked AS (
-			SELECT entity_type, entity_id, model, language
+			SELECT tenant_id, entity_type, entity_id, model, language
 			FROM %s.%s
-			WHERE next_run_at <= $1
-			ORDER BY next_run_at ASC, entity_type ASC, entity_id ASC, model ASC, language ASC
-			LIMIT $2
+			WHERE next_run_at <= now()
+			  AND (lease_expires_at IS NULL OR lease_expires_at <= now())
+			  AND (cardinality($3::text[]) = 0 OR model = ANY($3::text[]))
+			ORDER BY priority DESC, next_run_at ASC, entity_type ASC, entity_id ASC, model ASC, language ASC
+			LIMIT $1
 			FOR UPDATE SKIP LOCKED
 		)
 		UPDATE %s.%s t
-		SET next_run_at = $3,
-		    started_at = COALESCE(t.started_at, $1),
-		    updated_at = $1
+		SET worker_id = $2,
+		    lease_expires_at = now() + make_interval(secs => $4),
+		    started_at = COALESCE(t.started_at, now()),
+		    updated_at = now()
 		FROM picked p
-		WHERE t.entity_type = p.entity_type
+		WHERE t.tenant_id = p.tenant_id
+		  AND t.entity_type = p.entity_type
 		  AND t.entity_id = p.entity_id
 		  AND t.model = p.model
 		  AND t.language = p.language
 		RETURNING
-			t.entity_type, t.entity_id, t.model, t.language, t.reason, t.attempts, t.next_run_at, t.started_at, t.created_at, t.updated_at
+			t.entity_type, t.entity_id, t.model, t.language, t.tenant_id, t.reason, t.priority, t.attempts, t.next_run_at, t.started_at, t.created_at, t.updated_at,
+			t.worker_id, t.lease_expires_at
 	`, r.schema, embeddingTasksTable, r.schema, embeddingTasksTable)
 
-	rows, err := r.pool.Query(ctx, q, now, limit, next)
+	rows, err := r.pool.Query(ctx, q, limit, r.workerID, nonNilStrings(models), lockAhead.Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -144,12 +226,16 @@ func (r *Repo) FetchReady(ctx context.Context, limit int, lockAhead time.Duratio
 			&t.EntityID,
 			&t.Model,
 			&t.Language,
+			&t.TenantID,
 			&t.Reason,
+			&t.Priority,
 			&t.Attempts,
 			&t.NextRunAt,
 			&t.StartedAt,
 			&t.CreatedAt,
 			&t.UpdatedAt,
+			&t.WorkerID,
+			&t.LeaseExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -158,29 +244,34 @@ func (r *Repo) FetchReady(ctx context.Context, limit int, lockAhead time.Duratio
 	return out, rows.Err()
 }
 
-func (r *Repo) Complete(ctx context.Context, entityType string, entityID string, model string, language string, leaseUntil time.Time) error {
+// Complete deletes a finished task. It is lease-safe: the row is deleted only if
+// t's lease is still held (same worker_id and lease_expires_at).
+func (r *Repo) Complete(ctx context.Context, t Task) error {
 	if r.schema == "" {
 		return fmt.Errorf("schema is required")
 	}
-	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" || strings.TrimSpace(model) == "" || strings.TrimSpace(language) == "" {
+	if strings.TrimSpace(t.EntityType) == "" || strings.TrimSpace(t.EntityID) == "" || strings.TrimSpace(t.Model) == "" || strings.TrimSpace(t.Language) == "" {
 		return nil
 	}
 	q := fmt.Sprintf(`
 		DELETE FROM %s.%s
-		WHERE entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4 AND next_run_at = $5
+		WHERE tenant_id = $7 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4
+		  AND worker_id = $5 AND lease_expires_at = $6
 	`, r.schema, embeddingTasksTable)
-	_, err := r.pool.Exec(ctx, q, entityType, entityID, model, language, leaseUntil.UTC())
+	_, err := r.pool.Exec(ctx, q, t.EntityType, t.EntityID, t.Model, t.Language, t.WorkerID, t.LeaseExpiresAt, t.TenantID)
 	return err
 }
 
-func (r *Repo) Fail(ctx context.Context, entityType string, entityID string, model string, language string, leaseUntil time.Time, backoff time.Duration) error {
+// Fail records a failed attempt, releases the lease, and schedules the next run
+// after backoff. It is lease-safe like Complete.
+func (r *Repo) Fail(ctx context.Context, t Task, backoff time.Duration) error {
 	if backoff <= 0 {
 		backoff = 30 * time.Second
 	}
 	if r.schema == "" {
 		return fmt.Errorf("schema is required")
 	}
-	if strings.TrimSpace(entityType) == "" || strings.TrimSpace(entityID) == "" || strings.TrimSpace(model) == "" || strings.TrimSpace(language) == "" {
+	if strings.TrimSpace(t.EntityType) == "" || strings.TrimSpace(t.EntityID) == "" || strings.TrimSpace(t.Model) == "" || strings.TrimSpace(t.Language) == "" {
 		return nil
 	}
 	secs := int64(backoff / time.Second)
@@ -191,18 +282,44 @@ func (r *Repo) Fail(ctx context.Context, entityType string, entityID string, mod
 		UPDATE %s.%s
 		SET attempts = attempts + 1,
 		    next_run_at = now() + make_interval(secs => $1),
+		    worker_id = NULL,
+		    lease_expires_at = NULL,
+		    updated_at = now()
+		WHERE tenant_id = $8 AND entity_type = $2 AND entity_id = $3 AND model = $4 AND language = $5
+		  AND worker_id = $6 AND lease_expires_at = $7
+	`, r.schema, embeddingTasksTable)
+	_, err := r.pool.Exec(ctx, q, secs, t.EntityType, t.EntityID, t.Model, t.Language, t.WorkerID, t.LeaseExpiresAt, t.TenantID)
+	return err
+}
+
+// Defer releases the lease and reschedules the task for until without counting
+// an attempt (e.g. when the model's spend budget is exhausted). It is
+// lease-safe like Complete.
+func (r *Repo) Defer(ctx context.Context, t Task, until time.Time) error {
+	if r.schema == "" {
+		return fmt.Errorf("schema is required")
+	}
+	if strings.TrimSpace(t.EntityType) == "" || strings.TrimSpace(t.EntityID) == "" || strings.TrimSpace(t.Model) == "" || strings.TrimSpace(t.Language) == "" {
+		return nil
+	}
+	q := fmt.Sprintf(`
+		UPDATE %s.%s
+		SET next_run_at = GREATEST($1::timestamptz, now()),
+		    worker_id = NULL,
+		    lease_expires_at = NULL,
 		    updated_at = now()
-		WHERE entity_type = $2 AND entity_id = $3 AND model = $4 AND language = $5 AND next_run_at = $6
+		WHERE tenant_id = $8 AND entity_type = $2 AND entity_id = $3 AND model = $4 AND language = $5
+		  AND worker_id = $6 AND lease_expires_at = $7
 	`, r.schema, embeddingTasksTable)
-	_, err := r.pool.Exec(ctx, q, secs, entityType, entityID, model, language, leaseUntil.UTC())
+	_, err := r.pool.Exec(ctx, q, until, t.EntityType, t.EntityID, t.Model, t.Language, t.WorkerID, t.LeaseExpiresAt, t.TenantID)
 	return err
 }
 
 // DeadLetter moves a task into the dead-letter table and deletes it from
 // embedding_tasks so the runnable queue stays small.
 //
-// This is lease-safe: the task is deleted only if next_run_at matches leaseUntil.
-func (r *Repo) DeadLetter(ctx context.Context, t Task, leaseUntil time.Time, err error) error {
+// This is lease-safe: nothing is written unless t's lease is still held.
+func (r *Repo) DeadLetter(ctx context.Context, t Task, err error) error {
 	if r.schema == "" {
 		return fmt.Errorf("schema is required")
 	}
@@ -220,9 +337,23 @@ func (r *Repo) DeadLetter(ctx context.Context, t Task, leaseUntil time.Time, err
 	defer func() { _ = tx.Rollback(ctx) }()
 
 	q1 := fmt.Sprintf(`
-		INSERT INTO %s.%s (entity_type, entity_id, model, language, reason, error, attempts, failed_at, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now(), now())
-		ON CONFLICT (entity_type, entity_id, model, language) DO UPDATE SET
+		DELETE FROM %s.%s
+		WHERE tenant_id = $7 AND entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4
+		  AND worker_id = $5 AND lease_expires_at = $6
+	`, r.schema, embeddingTasksTable)
+	tag, execErr := tx.Exec(ctx, q1, t.EntityType, t.EntityID, t.Model, t.Language, t.WorkerID, t.LeaseExpiresAt, t.TenantID)
+	if execErr != nil {
+		return execErr
+	}
+	if tag.RowsAffected() == 0 {
+		// Lease lost (taken over or re-enqueued); leave the task to its new owner.
+		return nil
+	}
+
+	q2 := fmt.Sprintf(`
+		INSERT INTO %s.%s (entity_type, entity_id, model, language, reason, error, attempts, tenant_id, failed_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now(), now(), now())
+		ON CONFLICT (tenant_id, entity_type, entity_id, model, language) DO UPDATE SET
 			reason = EXCLUDED.reason,
 			error = EXCLUDED.error,
 			attempts = EXCLUDED.attempts,
@@ -233,17 +364,18 @@ func (r *Repo) DeadLetter(ctx context.Context, t Task, leaseUntil time.Time, err
 	if attempts < 0 {
 		attempts = 0
 	}
-	if _, execErr := tx.Exec(ctx, q1, t.EntityType, t.EntityID, t.Model, t.Language, t.Reason, err.Error(), attempts); execErr != nil {
-		return execErr
-	}
-
-	q2 := fmt.Sprintf(`
-		DELETE FROM %s.%s
-		WHERE entity_type = $1 AND entity_id = $2 AND model = $3 AND language = $4 AND next_run_at = $5
-	`, r.schema, embeddingTasksTable)
-	if _, execErr := tx.Exec(ctx, q2, t.EntityType, t.EntityID, t.Model, t.Language, leaseUntil.UTC()); execErr != nil {
+	if _, execErr := tx.Exec(ctx, q2, t.EntityType, t.EntityID, t.Model, t.Language, t.Reason, err.Error(), attempts, t.TenantID); execErr != nil {
 		return execErr
 	}
 
 	return tx.Commit(ctx)
 }
+
+// nonNilStrings returns an empty (non-nil) slice for nil so it binds as an
+// empty text[] rather than NULL.
+func nonNilStrings(in []string) []string {
+	if in == nil {
+		return []string{}
+	}
+	return in
+}
@@ -0,0 +1,84 @@
+package searchkit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/search"
+	"github.com/open-rails/searchkit/vl"
+)
+
+// ThumbnailEmbedder embeds queries and entity documents for VL models
+// (implemented by runtime.Runtime).
+type ThumbnailEmbedder interface {
+	EmbedQuery(ctx context.Context, model string, language string, text string) ([]float32, error)
+	EmbedEntityText(ctx context.Context, entityType string, entityID string, model string, language string) ([]float32, error)
+}
+
+type ThumbnailRequest struct {
+	Schema string
+	// Model is the VL model whose stored asset vectors are compared; it must
+	// store per-asset vectors (runtime Options.VLAssetModels).
+	Model    string
+	Language string // defaults to "en"
+
+	EntityType string
+	EntityID   string
+
+	// Query picks the asset matching a search query; QueryVec is used as is
+	// when set. With neither, the entity's own semantic document is embedded
+	// (runtime.Runtime.EmbedEntityText), for a thumbnail matching its
+	// description.
+	Query    string
+	QueryVec []float32
+
+	// AssetKinds restricts the candidate assets; defaults to images and
+	// frames, so video segments aren't picked.
+	AssetKinds []string
+}
+
+// RepresentativeAsset returns the entity's stored asset whose VL vector best
+// matches the request's query (or the entity's own text), for contextual
+// thumbnails on search result pages. It returns nil when the entity has no
+// matching asset vectors or nothing to embed. To annotate a page of hits at
+// once, embed the query and call search.BestAssets.
+func RepresentativeAsset(ctx context.Context, pool pg.Querier, emb ThumbnailEmbedder, req ThumbnailRequest) (*search.AssetMatch, error) {
+	if emb == nil {
+		return nil, fmt.Errorf("ThumbnailEmbedder is required")
+	}
+	if strings.TrimSpace(req.Model) == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	if strings.TrimSpace(req.EntityType) == "" || strings.TrimSpace(req.EntityID) == "" {
+		return nil, fmt.Errorf("entityType and entityID are required")
+	}
+	language := strings.TrimSpace(req.Language)
+	if language == "" {
+		language = "en"
+	}
+	kinds := req.AssetKinds
+	if len(kinds) == 0 {
+		kinds = []string{string(vl.AssetKindImage), string(vl.AssetKindFrame)}
+	}
+
+	vec := req.QueryVec
+	var err error
+	switch {
+	case len(vec) > 0:
+	case strings.TrimSpace(req.Query) != "":
+		vec, err = emb.EmbedQuery(ctx, req.Model, language, req.Query)
+	default:
+		vec, err = emb.EmbedEntityText(ctx, req.EntityType, req.EntityID, req.Model, language)
+	}
+	if err != nil || len(vec) == 0 {
+		return nil, err
+	}
+
+	hits := []search.Hit{{EntityType: req.EntityType, EntityID: req.EntityID}}
+	if err := search.BestAssets(ctx, pool, req.Schema, req.Model, language, vec, kinds, hits); err != nil {
+		return nil, err
+	}
+	return hits[0].BestAsset, nil
+}
@@ -0,0 +1,72 @@
+package vl
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrAssetUnreachable is wrapped by embedders when the provider could not
+// download an asset URL (private network, expired presigned URL). Callers with
+// an AssetFetcher retry such failures by uploading the bytes.
+var ErrAssetUnreachable = errors.New("vl: provider could not fetch asset")
+
+// AssetBytes is an asset whose content was downloaded by searchkit. Data nil
+// means the asset is passed by URL (e.g. videos, which are not uploaded).
+type AssetBytes struct {
+	AssetURL
+	Data     []byte
+	MIMEType string
+}
+
+// BytesEmbedder is implemented by embedders that can take asset bytes instead
+// of URLs. perAsset may be nil when the embedder doesn't report per-asset
+// vectors (see AssetVectorEmbedder).
+type BytesEmbedder interface {
+	Embedder
+	EmbedTextAndAssetBytes(ctx context.Context, text string, assets []AssetBytes) (fused []float32, perAsset [][]float32, err error)
+}
+
+// AssetFetcher downloads an asset's content (used when the provider cannot
+// fetch the URL itself). mimeType may be empty; it is then sniffed.
+type AssetFetcher func(ctx context.Context, asset AssetURL) (data []byte, mimeType string, err error)
+
+// HTTPFetcher returns an AssetFetcher that GETs asset URLs with client
+// (http.DefaultClient when nil) once, rejecting bodies over maxBytes (0 = no
+// limit). Use HTTPFetchPolicy for timeouts, retries and content-type checks.
+func HTTPFetcher(client *http.Client, maxBytes int64) AssetFetcher {
+	if maxBytes <= 0 {
+		maxBytes = -1
+	}
+	return HTTPFetchPolicy{Client: client, MaxBytes: maxBytes, Timeout: -1, Attempts: 1, AnyContentType: true}.Fetcher()
+}
+
+// FetchAssets downloads every image and frame with fetch; videos stay URLs.
+func FetchAssets(ctx context.Context, fetch AssetFetcher, assets []AssetURL) ([]AssetBytes, error) {
+	out := make([]AssetBytes, len(assets))
+	for i, a := range assets {
+		out[i] = AssetBytes{AssetURL: a}
+		if a.Kind == AssetKindVideo {
+			continue
+		}
+		data, mime, err := fetch(ctx, a)
+		if err != nil {
+			return nil, fmt.Errorf("fetch asset %q: %w", a.StorageKey(), err)
+		}
+		if strings.TrimSpace(mime) == "" || mime == "application/octet-stream" {
+			mime = http.DetectContentType(data)
+		}
+		out[i].Data = data
+		out[i].MIMEType = mime
+	}
+	return out, nil
+}
+
+// DataURL encodes data as a base64 data: URL, the form providers accept for
+// inline images.
+func DataURL(mimeType string, data []byte) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,159 @@
+package vl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrAssetRejected is returned by HTTPFetchPolicy fetchers for assets that
+// exceed MaxBytes or aren't of an allowed content type. The worker
+// dead-letters such tasks instead of retrying them.
+var ErrAssetRejected = errors.New("vl: asset rejected")
+
+const (
+	defaultFetchMaxBytes = 20 << 20
+	defaultFetchTimeout  = 30 * time.Second
+	defaultFetchAttempts = 3
+	defaultFetchBackoff  = 500 * time.Millisecond
+)
+
+// HTTPFetchPolicy configures a downloading AssetFetcher (see Fetcher) for the
+// bytes upload path: bounded size and time per download, retries of transient
+// failures, and content-type validation.
+type HTTPFetchPolicy struct {
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+	// MaxBytes rejects larger assets (default 20 MiB; < 0 = no limit).
+	MaxBytes int64
+	// Timeout bounds each download attempt (default 30s; < 0 = none).
+	Timeout time.Duration
+	// Attempts is the number of tries for transport errors and HTTP 408, 429
+	// and 5xx responses (default 3); other responses fail at once.
+	Attempts int
+	// Backoff is the wait before the second attempt, doubling after each
+	// further failure (default 500ms).
+	Backoff time.Duration
+	// ContentTypes are the accepted media types or "type/" prefixes, checked
+	// against the response's Content-Type, sniffed when missing or generic
+	// (default {"image/"}). Set AnyContentType to skip the check.
+	ContentTypes   []string
+	AnyContentType bool
+}
+
+// Fetcher returns an AssetFetcher that GETs asset URLs under the policy. The
+// returned MIME type is the validated media type.
+func (p HTTPFetchPolicy) Fetcher() AssetFetcher {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	maxBytes := p.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultFetchMaxBytes
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = defaultFetchTimeout
+	}
+	attempts := p.Attempts
+	if attempts <= 0 {
+		attempts = defaultFetchAttempts
+	}
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = defaultFetchBackoff
+	}
+	types := p.ContentTypes
+	if len(types) == 0 {
+		types = []string{"image/"}
+	}
+
+	return func(ctx context.Context, asset AssetURL) ([]byte, string, error) {
+		var lastErr error
+		for attempt := range attempts {
+			if attempt > 0 {
+				select {
+				case <-ctx.Done():
+					return nil, "", ctx.Err()
+				case <-time.After(backoff << (attempt - 1)):
+				}
+			}
+			data, mimeType, retry, err := fetchOnce(ctx, client, timeout, maxBytes, asset)
+			if err == nil {
+				if !p.AnyContentType && !contentTypeAllowed(mimeType, types) {
+					return nil, "", fmt.Errorf("fetch %q: %w: content type %q", asset.StorageKey(), ErrAssetRejected, mimeType)
+				}
+				return data, mimeType, nil
+			}
+			if !retry || ctx.Err() != nil {
+				return nil, "", err
+			}
+			lastErr = err
+		}
+		return nil, "", fmt.Errorf("%w (after %d attempts)", lastErr, attempts)
+	}
+}
+
+// fetchOnce downloads asset once, reporting whether a failure is worth
+// retrying.
+func fetchOnce(ctx context.Context, client *http.Client, timeout time.Duration, maxBytes int64, asset AssetURL) ([]byte, string, bool, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.URL, nil)
+	if err != nil {
+		return nil, "", false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("fetch %q: %w", asset.StorageKey(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		code := resp.StatusCode
+		retry := code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+		return nil, "", retry, fmt.Errorf("fetch %q: http %d", asset.StorageKey(), code)
+	}
+	if maxBytes > 0 && resp.ContentLength > maxBytes {
+		return nil, "", false, fmt.Errorf("fetch %q: %w: %d bytes exceeds %d", asset.StorageKey(), ErrAssetRejected, resp.ContentLength, maxBytes)
+	}
+	body := io.Reader(resp.Body)
+	if maxBytes > 0 {
+		body = io.LimitReader(resp.Body, maxBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", true, fmt.Errorf("fetch %q: %w", asset.StorageKey(), err)
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return nil, "", false, fmt.Errorf("fetch %q: %w: asset exceeds %d bytes", asset.StorageKey(), ErrAssetRejected, maxBytes)
+	}
+	return data, mediaType(resp.Header.Get("Content-Type"), data), false, nil
+}
+
+// mediaType returns header's media type without parameters, sniffing data
+// when the header is missing or generic.
+func mediaType(header string, data []byte) string {
+	mt, _, err := mime.ParseMediaType(header)
+	if err != nil || mt == "" || mt == "application/octet-stream" || mt == "binary/octet-stream" {
+		mt, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	return mt
+}
+
+func contentTypeAllowed(mediaType string, allowed []string) bool {
+	for _, t := range allowed {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package vl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPFetchPolicy_RetriesAndValidates(t *testing.T) {
+	t.Parallel()
+
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	var flaky atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky.png":
+			if flaky.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write(png)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>login</html>"))
+		case "/big.png":
+			_, _ = w.Write(make([]byte, 64))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	fetch := HTTPFetchPolicy{Client: srv.Client(), MaxBytes: 32, Backoff: time.Millisecond}.Fetcher()
+	get := func(path string) ([]byte, string, error) {
+		return fetch(context.Background(), AssetURL{Kind: AssetKindImage, URL: srv.URL + path})
+	}
+
+	data, mime, err := get("/flaky.png")
+	if err != nil || string(data) != string(png) || mime != "image/png" || flaky.Load() != 2 {
+		t.Fatalf("expected a sniffed PNG after one retry, got %q %q %v (%d requests)", data, mime, err, flaky.Load())
+	}
+	if _, _, err := get("/page.html"); !errors.Is(err, ErrAssetRejected) {
+		t.Fatalf("expected an HTML page to be rejected, got %v", err)
+	}
+	if _, _, err := get("/big.png"); !errors.Is(err, ErrAssetRejected) {
+		t.Fatalf("expected an oversized asset to be rejected, got %v", err)
+	}
+	if _, _, err := get("/gone.png"); err == nil || errors.Is(err, ErrAssetRejected) {
+		t.Fatalf("expected a plain 404 error, got %v", err)
+	}
+}
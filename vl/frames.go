@@ -0,0 +1,153 @@
+package vl
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const defaultSampledFrames = 8
+
+// FramePolicy converts AssetKindVideo assets into AssetKindFrame URLs before
+// embedding, so hosts don't have to pre-sample videos. searchkit never decodes
+// video: the host supplies FrameURL (e.g. a CDN or transcoder thumbnail URL
+// for an offset) and either Duration (uniform sampling) or SceneChanges.
+//
+// Frames keep the video's StorageKey as their Key and are numbered by
+// FrameIdx, so per-asset vectors are stored per (video, frame).
+type FramePolicy struct {
+	// Frames is the number of frames sampled per video (default 8).
+	Frames int
+	// MaxDuration only samples the first MaxDuration of each video (0 = the
+	// whole video).
+	MaxDuration time.Duration
+
+	// Duration returns a video's length; required unless SceneChanges is set.
+	Duration func(ctx context.Context, video AssetURL) (time.Duration, error)
+	// SceneChanges optionally returns a video's scene-change offsets. Frames
+	// are taken at scene changes (evenly thinned to Frames); a video without
+	// scene changes falls back to uniform sampling when Duration is set.
+	SceneChanges func(ctx context.Context, video AssetURL) ([]time.Duration, error)
+	// FrameURL returns the URL of the frame at offset at (required).
+	FrameURL func(ctx context.Context, video AssetURL, at time.Duration) (string, error)
+
+	// KeepVideo keeps each video asset after its frames (for providers that
+	// also accept whole videos).
+	KeepVideo bool
+}
+
+func (p FramePolicy) Validate() error {
+	if p.Frames < 0 {
+		return fmt.Errorf("frames must be >= 0")
+	}
+	if p.MaxDuration < 0 {
+		return fmt.Errorf("max duration must be >= 0")
+	}
+	if p.FrameURL == nil {
+		return fmt.Errorf("FrameURL is required")
+	}
+	if p.Duration == nil && p.SceneChanges == nil {
+		return fmt.Errorf("Duration or SceneChanges is required")
+	}
+	return nil
+}
+
+// Expand returns assets with each video replaced by its sampled frames; other
+// assets are kept in place.
+func (p FramePolicy) Expand(ctx context.Context, assets []AssetURL) ([]AssetURL, error) {
+	out := make([]AssetURL, 0, len(assets))
+	for _, a := range assets {
+		if a.Kind != AssetKindVideo {
+			out = append(out, a)
+			continue
+		}
+		frames, err := p.SampleFrames(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, frames...)
+		if p.KeepVideo {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// SampleFrames returns the frame assets for one video.
+func (p FramePolicy) SampleFrames(ctx context.Context, video AssetURL) ([]AssetURL, error) {
+	offsets, err := p.offsets(ctx, video)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]AssetURL, 0, len(offsets))
+	for i, at := range offsets {
+		url, err := p.FrameURL(ctx, video, at)
+		if err != nil {
+			return nil, fmt.Errorf("frame url for %q at %s: %w", video.StorageKey(), at, err)
+		}
+		frames = append(frames, AssetURL{
+			Kind:     AssetKindFrame,
+			URL:      url,
+			Key:      video.StorageKey(),
+			FrameIdx: i,
+		})
+	}
+	return frames, nil
+}
+
+func (p FramePolicy) offsets(ctx context.Context, video AssetURL) ([]time.Duration, error) {
+	n := p.Frames
+	if n <= 0 {
+		n = defaultSampledFrames
+	}
+	if p.SceneChanges != nil {
+		scenes, err := p.SceneChanges(ctx, video)
+		if err != nil {
+			return nil, fmt.Errorf("scene changes for %q: %w", video.StorageKey(), err)
+		}
+		var kept []time.Duration
+		for _, at := range scenes {
+			if at >= 0 && (p.MaxDuration == 0 || at <= p.MaxDuration) {
+				kept = append(kept, at)
+			}
+		}
+		if len(kept) > 0 {
+			sort.Slice(kept, func(i, j int) bool { return kept[i] < kept[j] })
+			return thin(kept, n), nil
+		}
+		if p.Duration == nil {
+			return nil, nil
+		}
+	}
+
+	d, err := p.Duration(ctx, video)
+	if err != nil {
+		return nil, fmt.Errorf("duration of %q: %w", video.StorageKey(), err)
+	}
+	if p.MaxDuration > 0 && d > p.MaxDuration {
+		d = p.MaxDuration
+	}
+	if d <= 0 {
+		return []time.Duration{0}, nil
+	}
+	// Frame centers of n equal segments, so the first and last frames avoid
+	// intros/credits at the very edges.
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = d * time.Duration(2*i+1) / time.Duration(2*n)
+	}
+	return out, nil
+}
+
+// thin keeps n evenly spaced offsets (including the first).
+func thin(offsets []time.Duration, n int) []time.Duration {
+	if len(offsets) <= n {
+		return offsets
+	}
+	out := make([]time.Duration, n)
+	for i := range out {
+		out[i] = offsets[i*len(offsets)/n]
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package vl
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFramePolicy_ExpandsVideos(t *testing.T) {
+	t.Parallel()
+
+	frameURL := func(_ context.Context, v AssetURL, at time.Duration) (string, error) {
+		return fmt.Sprintf("%s#t=%d", v.URL, int(at.Seconds())), nil
+	}
+	p := FramePolicy{
+		Frames:      4,
+		MaxDuration: 80 * time.Second,
+		Duration:    func(context.Context, AssetURL) (time.Duration, error) { return 10 * time.Minute, nil },
+		FrameURL:    frameURL,
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.Expand(context.Background(), []AssetURL{
+		{Kind: AssetKindImage, URL: "cover.jpg"},
+		{Kind: AssetKindVideo, URL: "v.mp4", Key: "trailer"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cover.jpg", "v.mp4#t=10", "v.mp4#t=30", "v.mp4#t=50", "v.mp4#t=70"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d assets, got %+v", len(want), got)
+	}
+	for i, a := range got {
+		if a.URL != want[i] {
+			t.Fatalf("asset %d: expected %q, got %q", i, want[i], a.URL)
+		}
+		if i > 0 && (a.Kind != AssetKindFrame || a.Key != "trailer" || a.FrameIdx != i-1) {
+			t.Fatalf("unexpected frame %+v", a)
+		}
+	}
+
+	// Scene changes past MaxDuration are dropped and the rest thinned to Frames.
+	p.Frames = 2
+	p.SceneChanges = func(context.Context, AssetURL) ([]time.Duration, error) {
+		return []time.Duration{5 * time.Second, 20 * time.Second, 40 * time.Second, 60 * time.Second, 90 * time.Second}, nil
+	}
+	frames, err := p.SampleFrames(context.Background(), AssetURL{Kind: AssetKindVideo, URL: "v.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || frames[0].URL != "v.mp4#t=5" || frames[1].URL != "v.mp4#t=40" {
+		t.Fatalf("unexpected scene frames %+v", frames)
+	}
+}
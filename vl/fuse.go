@@ -28,3 +28,111 @@ func FuseAverageL2(vectors [][]float32) []float32 {
 	normalize.L2NormalizeInPlace(sum)
 	return sum
 }
+
+// FusionPart is one embedded input of a VL embedding: the text or one asset.
+type FusionPart struct {
+	Kind   AssetKind // empty for the text
+	Asset  int       // index into the assets argument; -1 for the text
+	Vector []float32
+}
+
+// Fusion combines an entity's embedded inputs (text first, then assets in
+// order) into one vector. Returns nil if parts is empty or dimensions
+// mismatch. FuseAverage is the default; embedders that embed inputs
+// separately (embedder.DashScopeVLConfig.Fusion) take one per model.
+type Fusion func(parts []FusionPart) []float32
+
+// FuseAverage averages every part (FuseAverageL2).
+func FuseAverage(parts []FusionPart) []float32 {
+	vecs := make([][]float32, len(parts))
+	for i, p := range parts {
+		vecs[i] = p.Vector
+	}
+	return FuseAverageL2(vecs)
+}
+
+// FuseWeighted returns a weighted average: the text weighs textWeight and each
+// asset its kind's weight (1 for kinds missing from kindWeights), e.g. to let
+// images outweigh sampled frames.
+func FuseWeighted(textWeight float32, kindWeights map[AssetKind]float32) Fusion {
+	return func(parts []FusionPart) []float32 {
+		return weightedAverage(parts, func(p FusionPart) float32 {
+			if p.Asset < 0 {
+				return textWeight
+			}
+			if w, ok := kindWeights[p.Kind]; ok {
+				return w
+			}
+			return 1
+		})
+	}
+}
+
+// FuseFirstImages weighs the first k image assets (e.g. the cover) weight and
+// every other part 1.
+func FuseFirstImages(k int, weight float32) Fusion {
+	return func(parts []FusionPart) []float32 {
+		images := 0
+		return weightedAverage(parts, func(p FusionPart) float32 {
+			if p.Kind != AssetKindImage {
+				return 1
+			}
+			images++
+			if images <= k {
+				return weight
+			}
+			return 1
+		})
+	}
+}
+
+// FuseMaxPool takes the elementwise maximum over the parts and L2-normalizes
+// it, so a strong signal from any single input survives fusion.
+func FuseMaxPool(parts []FusionPart) []float32 {
+	if len(parts) == 0 || len(parts[0].Vector) == 0 {
+		return nil
+	}
+	dim := len(parts[0].Vector)
+	out := append([]float32(nil), parts[0].Vector...)
+	for _, p := range parts[1:] {
+		if len(p.Vector) != dim {
+			return nil
+		}
+		for i, x := range p.Vector {
+			if x > out[i] {
+				out[i] = x
+			}
+		}
+	}
+	normalize.L2NormalizeInPlace(out)
+	return out
+}
+
+// weightedAverage averages parts by weight(part) and L2-normalizes the
+// result. Returns nil if no part has a positive weight.
+func weightedAverage(parts []FusionPart, weight func(p FusionPart) float32) []float32 {
+	if len(parts) == 0 || len(parts[0].Vector) == 0 {
+		return nil
+	}
+	dim := len(parts[0].Vector)
+	sum := make([]float32, dim)
+	var total float32
+	for _, p := range parts {
+		if len(p.Vector) != dim {
+			return nil
+		}
+		w := weight(p)
+		if w <= 0 {
+			continue
+		}
+		total += w
+		for j, x := range p.Vector {
+			sum[j] += w * x
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	normalize.L2NormalizeInPlace(sum)
+	return sum
+}
@@ -0,0 +1,36 @@
+package vl
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFusionStrategies(t *testing.T) {
+	t.Parallel()
+
+	parts := []FusionPart{
+		{Asset: -1, Vector: []float32{1, 0, 0}},
+		{Kind: AssetKindImage, Asset: 0, Vector: []float32{0, 1, 0}},
+		{Kind: AssetKindFrame, Asset: 1, Vector: []float32{0, 0, 1}},
+	}
+
+	avg := FuseAverage(parts)
+	if avg[0] != avg[1] || avg[1] != avg[2] {
+		t.Fatalf("expected an even average, got %v", avg)
+	}
+	cover := FuseFirstImages(1, 3)(parts)
+	if cover[1] <= cover[0] || cover[0] != cover[2] {
+		t.Fatalf("expected the first image to dominate, got %v", cover)
+	}
+	noFrames := FuseWeighted(1, map[AssetKind]float32{AssetKindFrame: 0})(parts)
+	if noFrames[2] != 0 || noFrames[0] != noFrames[1] {
+		t.Fatalf("expected frames to be dropped, got %v", noFrames)
+	}
+	maxed := FuseMaxPool([]FusionPart{{Vector: []float32{3, -1}}, {Vector: []float32{-2, 4}}})
+	if math.Abs(float64(maxed[0])-0.6) > 1e-6 || math.Abs(float64(maxed[1])-0.8) > 1e-6 {
+		t.Fatalf("expected elementwise max, got %v", maxed)
+	}
+	if FuseAverage(nil) != nil || FuseMaxPool([]FusionPart{{Vector: []float32{1}}, {Vector: []float32{1, 2}}}) != nil {
+		t.Fatalf("expected nil for empty or mismatched parts")
+	}
+}
@@ -0,0 +1,56 @@
+package vl
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedAssetKind is returned when none of an entity's assets are of
+// a kind its model accepts. The worker dead-letters such tasks instead of
+// retrying them.
+var ErrUnsupportedAssetKind = errors.New("vl: unsupported asset kind")
+
+// AssetKindsEmbedder is implemented by embedders that accept only some asset
+// kinds (e.g. image-only encoders); runtime Options.AssetKinds overrides it.
+type AssetKindsEmbedder interface {
+	Embedder
+	AssetKinds() []AssetKind
+}
+
+// ValidateKinds returns an error for unknown asset kinds.
+func ValidateKinds(kinds []AssetKind) error {
+	for _, k := range kinds {
+		switch k {
+		case AssetKindImage, AssetKindFrame, AssetKindVideo:
+		default:
+			return fmt.Errorf("unknown asset kind %q", k)
+		}
+	}
+	return nil
+}
+
+// FilterKinds returns the assets whose kind is in kinds (all assets when kinds
+// is empty). It fails with ErrUnsupportedAssetKind when assets are given but
+// none is accepted.
+func FilterKinds(assets []AssetURL, kinds []AssetKind) ([]AssetURL, error) {
+	if len(kinds) == 0 || len(assets) == 0 {
+		return assets, nil
+	}
+	accepted := make(map[AssetKind]bool, len(kinds))
+	for _, k := range kinds {
+		accepted[k] = true
+	}
+	out := make([]AssetURL, 0, len(assets))
+	var rejected []AssetKind
+	for _, a := range assets {
+		if accepted[a.Kind] {
+			out = append(out, a)
+			continue
+		}
+		rejected = append(rejected, a.Kind)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: accepts %v, got %v", ErrUnsupportedAssetKind, kinds, rejected)
+	}
+	return out, nil
+}
@@ -0,0 +1,26 @@
+package vl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFilterKinds(t *testing.T) {
+	t.Parallel()
+
+	imagesOnly := []AssetKind{AssetKindImage, AssetKindFrame}
+	got, err := FilterKinds([]AssetURL{
+		{Kind: AssetKindImage, URL: "a"},
+		{Kind: AssetKindVideo, URL: "b"},
+		{Kind: AssetKindFrame, URL: "c"},
+	}, imagesOnly)
+	if err != nil || len(got) != 2 || got[0].URL != "a" || got[1].URL != "c" {
+		t.Fatalf("expected the video to be dropped, got %+v, %v", got, err)
+	}
+	if _, err := FilterKinds([]AssetURL{{Kind: AssetKindVideo, URL: "b"}}, imagesOnly); !errors.Is(err, ErrUnsupportedAssetKind) {
+		t.Fatalf("expected ErrUnsupportedAssetKind, got %v", err)
+	}
+	if err := ValidateKinds([]AssetKind{"audio"}); err == nil {
+		t.Fatalf("expected an unknown kind to be rejected")
+	}
+}
@@ -0,0 +1,142 @@
+package vl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	// Register the stdlib decoders image.Decode understands; hosts can add
+	// more (e.g. golang.org/x/image/webp) with blank imports.
+	_ "image/gif"
+)
+
+// ErrCorruptImage is returned by ImagePreprocess for assets that don't decode
+// as an image. The worker dead-letters such tasks instead of retrying them.
+var ErrCorruptImage = errors.New("vl: corrupt or unsupported image")
+
+const defaultJPEGQuality = 85
+
+// ImagePreprocess re-encodes fetched images before upload: downscaling to
+// MaxDimension, dropping metadata (EXIF, color profiles; re-encoding never
+// carries them over) and converting to JPEG (default), PNG or a host encoder.
+// Apply it with Fetcher around the worker's AssetFetcher.
+type ImagePreprocess struct {
+	// MaxDimension caps the longest side in pixels (0 = keep the size).
+	MaxDimension int
+	// Format is "jpeg" (default) or "png"; ignored when Encode is set.
+	Format string
+	// Quality is the JPEG quality (default 85).
+	Quality int
+	// Encode optionally replaces the built-in encoders (e.g. a WebP encoder,
+	// which the standard library lacks); MIMEType is then its content type.
+	Encode   func(w io.Writer, img image.Image) error
+	MIMEType string
+}
+
+// Fetcher wraps fetch so every fetched image is preprocessed.
+func (p ImagePreprocess) Fetcher(fetch AssetFetcher) AssetFetcher {
+	return func(ctx context.Context, asset AssetURL) ([]byte, string, error) {
+		data, _, err := fetch(ctx, asset)
+		if err != nil {
+			return nil, "", err
+		}
+		out, mime, err := p.Process(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", asset.StorageKey(), err)
+		}
+		return out, mime, nil
+	}
+}
+
+// Process decodes, downscales and re-encodes one image.
+func (p ImagePreprocess) Process(data []byte) ([]byte, string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrCorruptImage, err)
+	}
+	if b := img.Bounds(); b.Dx() <= 0 || b.Dy() <= 0 {
+		return nil, "", fmt.Errorf("%w: empty image", ErrCorruptImage)
+	}
+	if p.MaxDimension > 0 {
+		img = downscale(img, p.MaxDimension)
+	}
+
+	var buf bytes.Buffer
+	switch {
+	case p.Encode != nil:
+		if p.MIMEType == "" {
+			return nil, "", fmt.Errorf("MIMEType is required with Encode")
+		}
+		err = p.Encode(&buf, img)
+		return buf.Bytes(), p.MIMEType, err
+	case p.Format == "png":
+		err = png.Encode(&buf, img)
+		return buf.Bytes(), "image/png", err
+	case p.Format == "" || p.Format == "jpeg":
+		quality := p.Quality
+		if quality <= 0 {
+			quality = defaultJPEGQuality
+		}
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: quality})
+		return buf.Bytes(), "image/jpeg", err
+	default:
+		return nil, "", fmt.Errorf("unsupported image format %q", p.Format)
+	}
+}
+
+// flatten draws img over white, since JPEG has no alpha channel.
+func flatten(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Over)
+	return out
+}
+
+// downscale shrinks img so its longest side is at most maxDim, averaging the
+// source pixels each destination pixel covers (box filter). Smaller images are
+// returned unchanged.
+func downscale(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxDim && h <= maxDim {
+		return img
+	}
+	dw, dh := maxDim, max(1, h*maxDim/w)
+	if h > w {
+		dw, dh = max(1, w*maxDim/h), maxDim
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			o := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,46 @@
+package vl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestImagePreprocess_DownscalesAndReencodes(t *testing.T) {
+	t.Parallel()
+
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	var raw bytes.Buffer
+	if err := png.Encode(&raw, src); err != nil {
+		t.Fatal(err)
+	}
+	fetch := func(context.Context, AssetURL) ([]byte, string, error) { return raw.Bytes(), "image/png", nil }
+
+	data, mime, err := ImagePreprocess{MaxDimension: 100}.Fetcher(fetch)(context.Background(), AssetURL{URL: "a.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mime != "image/jpeg" || format != "jpeg" || img.Bounds().Dx() != 100 || img.Bounds().Dy() != 25 {
+		t.Fatalf("expected a 100x25 jpeg, got %s %s %v", mime, format, img.Bounds())
+	}
+	if r, _, _, _ := img.At(50, 12).RGBA(); r>>8 < 180 {
+		t.Fatalf("expected the color to survive downscaling, got r=%d", r>>8)
+	}
+
+	if _, _, err := (ImagePreprocess{}).Process([]byte("not an image")); !errors.Is(err, ErrCorruptImage) {
+		t.Fatalf("expected ErrCorruptImage, got %v", err)
+	}
+}
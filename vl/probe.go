@@ -0,0 +1,90 @@
+package vl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// AssetProbe is the result of checking one asset URL: the HTTP status, or
+// the transport error when the request failed.
+type AssetProbe struct {
+	Key      string
+	FrameIdx int
+	Kind     AssetKind
+	Status   int
+	Err      string
+}
+
+func (p AssetProbe) String() string {
+	if p.Err != "" {
+		return fmt.Sprintf("%s %q#%d: %s", p.Kind, p.Key, p.FrameIdx, p.Err)
+	}
+	return fmt.Sprintf("%s %q#%d: http %d", p.Kind, p.Key, p.FrameIdx, p.Status)
+}
+
+// ProbeAssets checks every asset URL concurrently with a HEAD request
+// (client, or http.DefaultClient when nil). Presigned URLs are often signed
+// for GET only, so a 403 or 405 is retried as a one-byte ranged GET. Results
+// are in asset order.
+func ProbeAssets(ctx context.Context, client *http.Client, assets []AssetURL) []AssetProbe {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	out := make([]AssetProbe, len(assets))
+	var wg sync.WaitGroup
+	for i, a := range assets {
+		out[i] = AssetProbe{Key: a.StorageKey(), FrameIdx: a.FrameIdx, Kind: a.Kind}
+		wg.Add(1)
+		go func(p *AssetProbe, url string) {
+			defer wg.Done()
+			status, err := probe(ctx, client, http.MethodHead, url)
+			if err == nil && (status == http.StatusForbidden || status == http.StatusMethodNotAllowed) {
+				status, err = probe(ctx, client, http.MethodGet, url)
+			}
+			if err != nil {
+				p.Err = err.Error()
+				return
+			}
+			p.Status = status
+		}(&out[i], a.URL)
+	}
+	wg.Wait()
+	return out
+}
+
+func probe(ctx context.Context, client *http.Client, method string, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// ProbeError annotates a VL failure with asset probe results, so a failure
+// caused by broken or expired asset URLs can be told apart from a provider
+// failure. It unwraps to Err.
+type ProbeError struct {
+	Err    error
+	Probes []AssetProbe
+}
+
+func (e *ProbeError) Error() string {
+	parts := make([]string, len(e.Probes))
+	for i, p := range e.Probes {
+		parts[i] = p.String()
+	}
+	return fmt.Sprintf("%v; asset probes: %s", e.Err, strings.Join(parts, ", "))
+}
+
+func (e *ProbeError) Unwrap() error { return e.Err }
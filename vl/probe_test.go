@@ -0,0 +1,53 @@
+package vl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeAssets_ReportsStatusPerAsset(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.jpg":
+			w.WriteHeader(http.StatusOK)
+		case "/signed.jpg":
+			// Signed for GET only, like presigned object-store URLs.
+			if r.Method != http.MethodGet || r.Header.Get("Range") != "bytes=0-0" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusPartialContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	probes := ProbeAssets(context.Background(), srv.Client(), []AssetURL{
+		{Kind: AssetKindImage, URL: srv.URL + "/ok.jpg", Key: "ok"},
+		{Kind: AssetKindImage, URL: srv.URL + "/signed.jpg", Key: "signed"},
+		{Kind: AssetKindImage, URL: srv.URL + "/gone.jpg", Key: "gone"},
+		{Kind: AssetKindImage, URL: "http://127.0.0.1:0/x.jpg", Key: "down"},
+	})
+	want := []int{http.StatusOK, http.StatusPartialContent, http.StatusNotFound, 0}
+	for i, p := range probes {
+		if p.Status != want[i] {
+			t.Fatalf("probe %d: expected status %d, got %+v", i, want[i], p)
+		}
+	}
+	if probes[3].Err == "" {
+		t.Fatalf("expected a transport error for the unreachable asset")
+	}
+
+	base := errors.New("provider failed")
+	err := error(&ProbeError{Err: base, Probes: probes})
+	if !errors.Is(err, base) || !strings.Contains(err.Error(), `image "gone"#0: http 404`) {
+		t.Fatalf("unexpected probe error %q", err)
+	}
+}
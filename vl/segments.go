@@ -0,0 +1,157 @@
+package vl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSegmentLength = 30 * time.Second
+	defaultSegmentFrames = 4
+)
+
+// AssetKindSegment marks stored per-asset vectors of video time segments (see
+// SegmentPolicy). It is not an input kind: embedders never receive it.
+const AssetKindSegment AssetKind = "segment"
+
+// SegmentPolicy splits long videos into time segments that are embedded on
+// their own, in addition to the entity's vector, so search can jump to the
+// matching moment of a video. Like FramePolicy, searchkit never decodes video:
+// each segment is embedded from frames the host serves via FrameURL, or as one
+// clip via ClipURL for providers that accept videos.
+type SegmentPolicy struct {
+	// Length is the segment length (default 30s); the last segment may be
+	// shorter.
+	Length time.Duration
+	// MinDuration only segments videos at least this long (0 = every video).
+	MinDuration time.Duration
+	// MaxSegments caps the segments per video by lengthening them (0 = no
+	// cap).
+	MaxSegments int
+	// Frames is the number of frames embedded per segment (default 4);
+	// ignored when ClipURL is set.
+	Frames int
+
+	// Duration returns a video's length (required).
+	Duration func(ctx context.Context, video AssetURL) (time.Duration, error)
+	// FrameURL returns the URL of the frame at offset at; required unless
+	// ClipURL is set.
+	FrameURL func(ctx context.Context, video AssetURL, at time.Duration) (string, error)
+	// ClipURL optionally returns the URL of the clip between start and end, to
+	// embed each segment as a video rather than as frames.
+	ClipURL func(ctx context.Context, video AssetURL, start time.Duration, end time.Duration) (string, error)
+}
+
+func (p SegmentPolicy) Validate() error {
+	if p.Length < 0 {
+		return fmt.Errorf("length must be >= 0")
+	}
+	if p.MinDuration < 0 {
+		return fmt.Errorf("min duration must be >= 0")
+	}
+	if p.MaxSegments < 0 {
+		return fmt.Errorf("max segments must be >= 0")
+	}
+	if p.Frames < 0 {
+		return fmt.Errorf("frames must be >= 0")
+	}
+	if p.Duration == nil {
+		return fmt.Errorf("Duration is required")
+	}
+	if p.FrameURL == nil && p.ClipURL == nil {
+		return fmt.Errorf("FrameURL or ClipURL is required")
+	}
+	return nil
+}
+
+// Segment is one time segment of a video and the assets it is embedded from.
+type Segment struct {
+	Video      AssetURL
+	Index      int
+	Start, End time.Duration
+	Assets     []AssetURL
+}
+
+// Key is the segment's stored asset key: the video's StorageKey with a media
+// fragment, e.g. "trailer#t=30,60".
+func (s Segment) Key() string {
+	return fmt.Sprintf("%s#t=%s,%s", s.Video.StorageKey(), seconds(s.Start), seconds(s.End))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// Segments returns the segments of every video in assets; other assets are
+// ignored.
+func (p SegmentPolicy) Segments(ctx context.Context, assets []AssetURL) ([]Segment, error) {
+	var out []Segment
+	for _, a := range assets {
+		if a.Kind != AssetKindVideo {
+			continue
+		}
+		segs, err := p.Split(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, segs...)
+	}
+	return out, nil
+}
+
+// Split returns one video's segments, or none when it is shorter than
+// MinDuration.
+func (p SegmentPolicy) Split(ctx context.Context, video AssetURL) ([]Segment, error) {
+	d, err := p.Duration(ctx, video)
+	if err != nil {
+		return nil, fmt.Errorf("duration of %q: %w", video.StorageKey(), err)
+	}
+	if d <= 0 || d < p.MinDuration {
+		return nil, nil
+	}
+	length := p.Length
+	if length <= 0 {
+		length = defaultSegmentLength
+	}
+	if p.MaxSegments > 0 && (d+length-1)/length > time.Duration(p.MaxSegments) {
+		length = (d + time.Duration(p.MaxSegments) - 1) / time.Duration(p.MaxSegments)
+	}
+
+	var out []Segment
+	for start := time.Duration(0); start < d; start += length {
+		seg := Segment{Video: video, Index: len(out), Start: start, End: min(start+length, d)}
+		seg.Assets, err = p.segmentAssets(ctx, seg)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, seg)
+	}
+	return out, nil
+}
+
+func (p SegmentPolicy) segmentAssets(ctx context.Context, seg Segment) ([]AssetURL, error) {
+	if p.ClipURL != nil {
+		url, err := p.ClipURL(ctx, seg.Video, seg.Start, seg.End)
+		if err != nil {
+			return nil, fmt.Errorf("clip url for %q at %s-%s: %w", seg.Video.StorageKey(), seg.Start, seg.End, err)
+		}
+		return []AssetURL{{Kind: AssetKindVideo, URL: url, Key: seg.Key()}}, nil
+	}
+	n := p.Frames
+	if n <= 0 {
+		n = defaultSegmentFrames
+	}
+	frames := make([]AssetURL, n)
+	for i := range frames {
+		// Frame centers of n equal parts, as in FramePolicy.
+		at := seg.Start + (seg.End-seg.Start)*time.Duration(2*i+1)/time.Duration(2*n)
+		url, err := p.FrameURL(ctx, seg.Video, at)
+		if err != nil {
+			return nil, fmt.Errorf("frame url for %q at %s: %w", seg.Video.StorageKey(), at, err)
+		}
+		frames[i] = AssetURL{Kind: AssetKindFrame, URL: url, Key: seg.Key(), FrameIdx: i}
+	}
+	return frames, nil
+}
@@ -0,0 +1,64 @@
+package vl
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSegmentPolicy_SplitsLongVideos(t *testing.T) {
+	t.Parallel()
+
+	durations := map[string]time.Duration{"long.mp4": 70 * time.Second, "short.mp4": 5 * time.Second}
+	p := SegmentPolicy{
+		Frames:      2,
+		MinDuration: 10 * time.Second,
+		Duration: func(_ context.Context, v AssetURL) (time.Duration, error) {
+			return durations[v.URL], nil
+		},
+		FrameURL: func(_ context.Context, v AssetURL, at time.Duration) (string, error) {
+			return fmt.Sprintf("%s@%s", v.URL, at), nil
+		},
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	segs, err := p.Segments(context.Background(), []AssetURL{
+		{Kind: AssetKindImage, URL: "cover.jpg"},
+		{Kind: AssetKindVideo, URL: "long.mp4", Key: "trailer"},
+		{Kind: AssetKindVideo, URL: "short.mp4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKeys := []string{"trailer#t=0,30", "trailer#t=30,60", "trailer#t=60,70"}
+	if len(segs) != len(wantKeys) {
+		t.Fatalf("expected %d segments, got %+v", len(wantKeys), segs)
+	}
+	for i, s := range segs {
+		if s.Key() != wantKeys[i] || s.Index != i {
+			t.Fatalf("segment %d: expected key %q, got %q (index %d)", i, wantKeys[i], s.Key(), s.Index)
+		}
+	}
+	last := segs[2]
+	if len(last.Assets) != 2 || last.Assets[0].URL != "long.mp4@1m2.5s" || last.Assets[1].URL != "long.mp4@1m7.5s" {
+		t.Fatalf("unexpected frames %+v", last.Assets)
+	}
+
+	// MaxSegments lengthens segments instead of dropping the end of the video.
+	p.MaxSegments = 2
+	p.ClipURL = func(_ context.Context, v AssetURL, start, end time.Duration) (string, error) {
+		return fmt.Sprintf("%s?from=%d&to=%d", v.URL, int(start.Seconds()), int(end.Seconds())), nil
+	}
+	segs, err = p.Split(context.Background(), AssetURL{Kind: AssetKindVideo, URL: "long.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 || segs[1].End != 70*time.Second {
+		t.Fatalf("unexpected capped segments %+v", segs)
+	}
+	if a := segs[1].Assets; len(a) != 1 || a[0].Kind != AssetKindVideo || a[0].URL != "long.mp4?from=35&to=70" {
+		t.Fatalf("unexpected clip %+v", a)
+	}
+}
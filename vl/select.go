@@ -0,0 +1,45 @@
+package vl
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AssetSelection caps how many assets of an entity are embedded, keeping the
+// highest-priority ones (e.g. the cover, then the first pages), since
+// providers reject or bill for very large asset lists.
+type AssetSelection struct {
+	// Max is the maximum number of assets embedded per entity (0 = no limit).
+	Max int
+	// Less optionally orders assets by priority (a before b when Less(a, b));
+	// ties and a nil Less keep the host's ListAssetURLs order.
+	Less func(a, b AssetURL) bool
+}
+
+func (s AssetSelection) Validate() error {
+	if s.Max < 0 {
+		return fmt.Errorf("max must be >= 0")
+	}
+	return nil
+}
+
+// Select returns assets in priority order, truncated to Max. assets is not
+// modified.
+func (s AssetSelection) Select(assets []AssetURL) []AssetURL {
+	out := append([]AssetURL(nil), assets...)
+	if s.Less != nil {
+		sort.SliceStable(out, func(i, j int) bool { return s.Less(out[i], out[j]) })
+	}
+	if s.Max > 0 && len(out) > s.Max {
+		out = out[:s.Max]
+	}
+	return out
+}
+
+// CoverFirst is an AssetSelection.Less that puts the asset with key cover
+// first and otherwise keeps the host order.
+func CoverFirst(cover string) func(a, b AssetURL) bool {
+	return func(a, b AssetURL) bool {
+		return a.StorageKey() == cover && b.StorageKey() != cover
+	}
+}
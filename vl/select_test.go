@@ -0,0 +1,24 @@
+package vl
+
+import "testing"
+
+func TestAssetSelection_PrioritizesAndCaps(t *testing.T) {
+	t.Parallel()
+
+	assets := []AssetURL{
+		{Kind: AssetKindImage, Key: "p1"},
+		{Kind: AssetKindImage, Key: "p2"},
+		{Kind: AssetKindImage, Key: "cover"},
+		{Kind: AssetKindImage, Key: "p3"},
+	}
+	got := AssetSelection{Max: 3, Less: CoverFirst("cover")}.Select(assets)
+	if len(got) != 3 || got[0].Key != "cover" || got[1].Key != "p1" || got[2].Key != "p2" {
+		t.Fatalf("unexpected selection %+v", got)
+	}
+	if assets[2].Key != "cover" {
+		t.Fatalf("expected the input to be left unchanged")
+	}
+	if all := (AssetSelection{}).Select(assets); len(all) != len(assets) {
+		t.Fatalf("expected no limit by default, got %d assets", len(all))
+	}
+}
@@ -14,28 +14,85 @@ const (
 type AssetURL struct {
 	Kind AssetKind
 	URL  string
+	// Key is a stable asset identifier (e.g. "page-3" or an image id) under
+	// which per-asset vectors are stored; defaults to URL, which is unstable
+	// for presigned URLs. It is also part of the VL content hash, so change it
+	// (e.g. append a version) when the asset's content changes.
+	Key string
+	// FrameIdx is the frame's position within its video (AssetKindFrame).
+	FrameIdx int
+}
+
+// StorageKey returns Key, or URL when Key is empty.
+func (a AssetURL) StorageKey() string {
+	if a.Key != "" {
+		return a.Key
+	}
+	return a.URL
 }
 
 // ListAssetURLs returns the assets that should be embedded for each entity
 // (gallery/video) as presigned/public URLs.
 //
-// NOTE: searchkit's VL pipeline is URL-only: searchkit does not upload raw
-// bytes to providers.
+// Providers fetch the URLs themselves. When they can't (private networks,
+// short-lived URLs), the worker's AssetFetcher downloads the assets and
+// uploads the bytes to embedders implementing BytesEmbedder.
 //
 // The returned map should contain entries only for entities that exist. Missing
 // IDs are treated as "entity not found" by the caller (and tasks may be
 // dropped).
 type ListAssetURLs func(ctx context.Context, entityType string, entityIDs []string) (map[string][]AssetURL, error)
 
-// Embedder generates vision-language embeddings for text+assets (URL-only).
+// Embedder generates vision-language embeddings for text+assets (by URL; see
+// BytesEmbedder for uploads).
 //
 // The app supplies text + a list of URLs (images/frames and optionally a single
 // video URL) and the provider returns one fused vector.
 //
-// NOTE: Provider wiring for Qwen3-VL-Embedding is intentionally out of scope
-// here for now; searchkit defines the interface so apps can implement it.
+// embedder.NewDashScopeVL implements it for DashScope's multimodal-embedding
+// models; apps can implement it for other providers.
 type Embedder interface {
 	Model() string
 	Dimensions() int
 	EmbedTextAndAssetURLs(ctx context.Context, text string, assets []AssetURL) ([]float32, error)
 }
+
+// AssetVectorEmbedder is implemented by embedders that can also return one
+// vector per asset (in assets order) with the fused vector, so matches can be
+// reported per page/image (see runtime Options.VLAssetModels).
+type AssetVectorEmbedder interface {
+	Embedder
+	EmbedAssetVectors(ctx context.Context, text string, assets []AssetURL) (fused []float32, perAsset [][]float32, err error)
+}
+
+// Input is one entity's text and assets.
+type Input struct {
+	Text   string
+	Assets []AssetURL
+}
+
+// BatchResult is one input's vectors from EmbedBatch; PerAsset is nil unless
+// the embedder implements AssetVectorEmbedder.
+type BatchResult struct {
+	Fused    []float32
+	PerAsset [][]float32
+}
+
+// BatchEmbedder is implemented by embedders that can embed several entities'
+// inputs in shared provider requests. The worker batches VL tasks per model
+// up to MaxBatch inputs per call; results align with inputs by index.
+type BatchEmbedder interface {
+	Embedder
+	MaxBatch() int
+	EmbedBatch(ctx context.Context, inputs []Input) ([]BatchResult, error)
+}
+
+// DualEncoder is implemented by CLIP/SigLIP-style models whose text and image
+// encoders are separate but share one vector space. Entities are stored as
+// image vectors only (the document text is not embedded) and text queries are
+// embedded with EmbedQueryText, unlike fused models such as Qwen3-VL where
+// text and images go into one vector.
+type DualEncoder interface {
+	Embedder
+	EmbedQueryText(ctx context.Context, text string) ([]float32, error)
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// FailureBudget pauses a model's processing when too many of its tasks fail
+// within a window, so a misconfigured model does not burn through MaxAttempts
+// on the whole queue.
+type FailureBudget struct {
+	// MaxFailureRate is the failure fraction in (0..1] above which the model is
+	// paused (e.g. 0.5).
+	MaxFailureRate float64
+	// MinSamples is the minimum number of task outcomes in the window before
+	// the rate is evaluated. Defaults to 20.
+	MinSamples int
+	// Window is the evaluation window. Defaults to 5 minutes.
+	Window time.Duration
+	// Cooldown is how long the model stays paused. Defaults to 10 minutes.
+	Cooldown time.Duration
+
+	// OnPause is called (synchronously) when a model is paused.
+	OnPause func(model string, failureRate float64, until time.Time)
+}
+
+func (b FailureBudget) withDefaults() FailureBudget {
+	out := b
+	if out.MinSamples <= 0 {
+		out.MinSamples = 20
+	}
+	if out.Window <= 0 {
+		out.Window = 5 * time.Minute
+	}
+	if out.Cooldown <= 0 {
+		out.Cooldown = 10 * time.Minute
+	}
+	return out
+}
+
+type budgetWindow struct {
+	start       time.Time
+	total       int
+	failed      int
+	pausedUntil time.Time
+}
+
+type budgetTracker struct {
+	mu      sync.Mutex
+	byModel map[string]*budgetWindow
+}
+
+// record adds one task outcome for model and pauses the model if the budget is
+// exceeded, returning when the pause ends.
+func (t *budgetTracker) record(b *FailureBudget, model string, failed bool, now time.Time) (time.Time, bool) {
+	if b == nil || b.MaxFailureRate <= 0 {
+		return time.Time{}, false
+	}
+	cfg := b.withDefaults()
+
+	var (
+		paused bool
+		rate   float64
+		until  time.Time
+	)
+	t.mu.Lock()
+	w, ok := t.byModel[model]
+	if !ok {
+		w = &budgetWindow{start: now}
+		t.byModel[model] = w
+	}
+	if now.Sub(w.start) > cfg.Window {
+		w.start = now
+		w.total = 0
+		w.failed = 0
+	}
+	w.total++
+	if failed {
+		w.failed++
+	}
+	if w.total >= cfg.MinSamples && now.After(w.pausedUntil) {
+		rate = float64(w.failed) / float64(w.total)
+		if rate > cfg.MaxFailureRate {
+			paused = true
+			until = now.Add(cfg.Cooldown)
+			w.pausedUntil = until
+			w.start = now
+			w.total = 0
+			w.failed = 0
+		}
+	}
+	t.mu.Unlock()
+
+	if paused && cfg.OnPause != nil {
+		cfg.OnPause(model, rate, until)
+	}
+	return until, paused
+}
+
+// pausedUntil returns the models that are currently paused.
+func (t *budgetTracker) pausedUntil(now time.Time) map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := map[string]time.Time{}
+	for m, w := range t.byModel {
+		if now.Before(w.pausedUntil) {
+			out[m] = w.pausedUntil
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// HealthSnapshot is a point-in-time view of worker liveness, intended to be
+// exposed from host health endpoints so orchestrators can restart a wedged
+// worker.
+type HealthSnapshot struct {
+	// LastFetchAt is when the worker last fetched ready tasks successfully
+	// (including empty fetches). With several drain loops (e.g. a Pool) it is
+	// the least recent loop's, so one wedged loop is not hidden by the others;
+	// it is zero until every loop has fetched.
+	LastFetchAt time.Time
+	// LastFetchAtByLoop is each loop's last successful fetch, keyed by the
+	// loop's canonical models joined with "," ("" for a loop over all active
+	// models).
+	LastFetchAtByLoop map[string]time.Time
+	// LastEmbedAt is when each model last generated and stored an embedding.
+	LastEmbedAt map[string]time.Time
+	// ErrorStreak counts consecutive failures (drain errors and failed tasks)
+	// since the last success.
+	ErrorStreak int
+	// LastError is the most recent failure message, if any.
+	LastError string
+	// QueueLag is how long the oldest ready task had been waiting at the last
+	// fetch (0 when the queue was empty); the largest across loops.
+	QueueLag time.Duration
+	// PausedUntil lists models paused by a FailureBudget and when they resume.
+	PausedUntil map[string]time.Time
+}
+
+// IsLive reports whether every drain loop of the worker fetched tasks within
+// maxSinceFetch.
+func (h HealthSnapshot) IsLive(now time.Time, maxSinceFetch time.Duration) bool {
+	if h.LastFetchAt.IsZero() {
+		return false
+	}
+	return now.Sub(h.LastFetchAt) <= maxSinceFetch
+}
+
+type healthTracker struct {
+	mu          sync.Mutex
+	loops       map[string]loopFetch
+	lastEmbedAt map[string]time.Time
+	errorStreak int
+	lastError   string
+	pausedUntil map[string]time.Time
+}
+
+// loopFetch is one drain loop's last successful fetch.
+type loopFetch struct {
+	at  time.Time
+	lag time.Duration
+}
+
+// Health returns a snapshot of the liveness of the worker draining with m.
+func (m *Monitor) Health() HealthSnapshot {
+	h := &m.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	embeds := make(map[string]time.Time, len(h.lastEmbedAt))
+	for model, t := range h.lastEmbedAt {
+		embeds[model] = t
+	}
+	snap := HealthSnapshot{
+		LastFetchAtByLoop: make(map[string]time.Time, len(h.loops)),
+		LastEmbedAt:       embeds,
+		ErrorStreak:       h.errorStreak,
+		LastError:         h.lastError,
+		PausedUntil:       h.pausedAt(time.Now()),
+	}
+	first := true
+	for loop, f := range h.loops {
+		snap.LastFetchAtByLoop[loop] = f.at
+		if first || f.at.Before(snap.LastFetchAt) {
+			snap.LastFetchAt = f.at
+			first = false
+		}
+		snap.QueueLag = max(snap.QueueLag, f.lag)
+	}
+	return snap
+}
+
+// started registers a drain loop so it counts as not yet fetched until its
+// first fetch.
+func (h *healthTracker) started(loop string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.loops[loop]; !ok {
+		h.loops[loop] = loopFetch{}
+	}
+}
+
+// fetched records a successful fetch by loop; it ends the error streak.
+func (h *healthTracker) fetched(loop string, lag time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loops[loop] = loopFetch{at: time.Now(), lag: max(lag, 0)}
+	h.errorStreak = 0
+}
+
+func (h *healthTracker) embedded(model string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastEmbedAt[model] = time.Now()
+	h.errorStreak = 0
+}
+
+func (h *healthTracker) failed(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errorStreak++
+	if err != nil {
+		h.lastError = err.Error()
+	}
+}
+
+func (h *healthTracker) paused(model string, until time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pausedUntil[model] = until
+}
+
+// pausedAt returns the pauses still in effect at now. The caller holds h.mu.
+func (h *healthTracker) pausedAt(now time.Time) map[string]time.Time {
+	out := map[string]time.Time{}
+	for m, until := range h.pausedUntil {
+		if now.Before(until) {
+			out[m] = until
+		}
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/open-rails/searchkit/pg"
+)
+
+// ChangedEntity is one entity reported by ListChangedEntityIDs.
+type ChangedEntity struct {
+	ID        string
+	ChangedAt time.Time
+}
+
+// ListChangedEntityIDs lists up to limit entities of entityType in language
+// changed at or after since (zero: from the beginning), oldest first, e.g.
+// `WHERE updated_at >= $since ORDER BY updated_at, id LIMIT $limit`. Like
+// ListEntityIDsPage, it reads the tenant with pg.TenantFromContext.
+type ListChangedEntityIDs func(ctx context.Context, entityType string, language string, since time.Time, limit int) ([]ChangedEntity, error)
+
+// incrementalReason is the search_dirty reason of incremental re-sync marks.
+const incrementalReason = "incremental"
+
+// incrementalOnce marks entities changed since each (tenant, entity type,
+// language) watermark dirty and advances the watermark, in one transaction
+// per page. The dirty phase then refreshes their lexical documents and
+// enqueues their embedding tasks.
+func incrementalOnce(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	schema string,
+	entityTypes []string,
+	languages []string,
+	tenants []string,
+	list ListChangedEntityIDs,
+	pageSize int,
+	maxPages int,
+	report *SyncReport,
+) error {
+	if list == nil || maxPages <= 0 || pageSize <= 0 {
+		return nil
+	}
+	qs, err := pg.QuoteSchema(schema)
+	if err != nil {
+		return err
+	}
+	if len(tenants) == 0 {
+		tenants = []string{""}
+	}
+	pagesDone := 0
+
+	for _, tenant := range tenants {
+		tctx := pg.WithTenant(ctx, tenant)
+		for _, et := range entityTypes {
+			for _, lang := range languages {
+				if strings.TrimSpace(lang) == "" {
+					continue
+				}
+				for {
+					if pagesDone >= maxPages {
+						return nil
+					}
+					since, seen, err := ensureAndGetWatermark(ctx, pool, qs, tenant, et, lang)
+					if err != nil {
+						return err
+					}
+					changed, err := list(tctx, et, lang, since, pageSize)
+					if err == nil && len(changed) >= pageSize && !latestChange(changed).After(since) {
+						err = fmt.Errorf("more than %d entities changed at %s; raise IncrementalPageSize", pageSize, since.Format(time.RFC3339Nano))
+					}
+					if err != nil {
+						_, _ = pool.Exec(ctx, fmt.Sprintf(`
+							UPDATE %s.search_sync_watermarks
+							SET last_error = $4, updated_at = now()
+							WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+						`, qs), tenant, et, lang, err.Error())
+						return err
+					}
+
+					// Entities already marked at exactly the watermark are
+					// listed again (since is inclusive); skip them.
+					done := make(map[string]struct{}, len(seen))
+					for _, id := range seen {
+						done[id] = struct{}{}
+					}
+					next, nextSeen := since, seen
+					var marks []pg.DirtyMark
+					for _, c := range changed {
+						at := c.ChangedAt.Truncate(time.Microsecond)
+						if at.Equal(since) {
+							if _, ok := done[c.ID]; ok {
+								continue
+							}
+						}
+						marks = append(marks, pg.DirtyMark{EntityType: et, EntityID: c.ID, Language: lang, Reason: incrementalReason})
+						switch {
+						case at.After(next):
+							next, nextSeen = at, []string{c.ID}
+						case at.Equal(next):
+							nextSeen = append(nextSeen, c.ID)
+						}
+						done[c.ID] = struct{}{}
+					}
+
+					if err := advanceWatermark(tctx, pool, schema, qs, tenant, et, lang, marks, next, nextSeen); err != nil {
+						return err
+					}
+					pagesDone++
+					report.IncrementalPagesAdvanced++
+					report.ChangedEntitiesMarked += len(marks)
+					if len(changed) < pageSize {
+						break
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// latestChange returns the latest ChangedAt in changed (truncated to the
+// microsecond precision of the stored watermark).
+func latestChange(changed []ChangedEntity) time.Time {
+	var latest time.Time
+	for _, c := range changed {
+		if at := c.ChangedAt.Truncate(time.Microsecond); at.After(latest) {
+			latest = at
+		}
+	}
+	return latest
+}
+
+func ensureAndGetWatermark(ctx context.Context, pool *pgxpool.Pool, qs string, tenant string, entityType string, language string) (since time.Time, seen []string, err error) {
+	if _, err := pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s.search_sync_watermarks (tenant_id, entity_type, language)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, entity_type, language) DO NOTHING
+	`, qs), tenant, entityType, language); err != nil {
+		return time.Time{}, nil, err
+	}
+	var watermark *time.Time
+	if err := pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT watermark, watermark_ids
+		FROM %s.search_sync_watermarks
+		WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+	`, qs), tenant, entityType, language).Scan(&watermark, &seen); err != nil {
+		return time.Time{}, nil, err
+	}
+	if watermark != nil {
+		since = *watermark
+	}
+	return since, seen, nil
+}
+
+// advanceWatermark writes marks to search_dirty and the new watermark in one
+// transaction, so a crash neither loses nor skips changes.
+func advanceWatermark(ctx context.Context, pool *pgxpool.Pool, schema string, qs string, tenant string, entityType string, language string, marks []pg.DirtyMark, watermark time.Time, seen []string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := pg.MarkDirty(ctx, tx, schema, marks); err != nil {
+		return err
+	}
+	var wm *time.Time
+	if !watermark.IsZero() {
+		wm = &watermark
+	}
+	if seen == nil {
+		seen = []string{}
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s.search_sync_watermarks
+		SET watermark = $4, watermark_ids = $5, last_error = NULL, updated_at = now()
+		WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+	`, qs), tenant, entityType, language, wm, seen); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
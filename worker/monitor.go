@@ -0,0 +1,24 @@
+package worker
+
+import "time"
+
+// Monitor holds the state one worker keeps between drains: its health (see
+// Health), the FailureBudget windows and pauses and daily SpendBudget usage of
+// its models, and the rate limiters of its DrainOnce calls. Share one between
+// the DrainOnce calls or Run loops of a worker; each worker (e.g. each Pool in
+// a process) needs its own, so one's health and budgets don't mask another's.
+type Monitor struct {
+	health   healthTracker
+	budgets  budgetTracker
+	spend    spendTracker
+	limiters drainLimiters
+}
+
+// NewMonitor returns an empty Monitor.
+func NewMonitor() *Monitor {
+	return &Monitor{
+		health:  healthTracker{loops: map[string]loopFetch{}, lastEmbedAt: map[string]time.Time{}, pausedUntil: map[string]time.Time{}},
+		budgets: budgetTracker{byModel: map[string]*budgetWindow{}},
+		spend:   spendTracker{byModel: map[string]*spendDay{}},
+	}
+}
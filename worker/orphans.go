@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/open-rails/searchkit/pg"
+)
+
+// ExistingEntityIDs returns the subset of ids (of entityType) that still
+// exist in the host app. Returning an ID not in ids is harmless; an error
+// stops the cleanup without deleting the batch.
+type ExistingEntityIDs func(ctx context.Context, entityType string, ids []string) (existing []string, err error)
+
+// OrphanCleanupOptions configures CleanupOrphans.
+type OrphanCleanupOptions struct {
+	// Required.
+	Pool   *pgxpool.Pool
+	Schema string
+
+	// EntityTypes to check (required).
+	EntityTypes []string
+
+	// ExistingEntityIDs validates each batch of stored IDs (required).
+	ExistingEntityIDs ExistingEntityIDs
+
+	// BatchSize is the number of stored IDs checked and deleted at a time
+	// (default 500).
+	BatchSize int
+	// MaxBatches bounds the batches per entity type per call (0 = no limit).
+	// Pass the report's Cursors back in to continue where it stopped.
+	MaxBatches int
+	// Cursors resumes each entity type after the given entity ID.
+	Cursors map[string]string
+
+	// DryRun reports orphans without deleting them.
+	DryRun bool
+}
+
+// OrphanCleanupReport summarizes one CleanupOrphans call.
+type OrphanCleanupReport struct {
+	// Checked and Orphans count stored entity IDs per entity type.
+	Checked map[string]int
+	Orphans map[string]int
+	// Cursors holds the last checked ID of each entity type that stopped at
+	// MaxBatches; entity types checked to the end are absent.
+	Cursors map[string]string
+}
+
+// CleanupOrphans deletes all searchkit state (lexical documents, embeddings,
+// captions, tasks, see pg.DeleteEntity) of entities that no longer exist in
+// the host app, e.g. after deletes that bypassed search_dirty. It pages
+// through the stored IDs of each entity type, asks ExistingEntityIDs which
+// still exist, and deletes the rest one batch per transaction.
+//
+// It covers ctx's tenant (see pg.WithTenant), so multi-tenant hosts run it
+// once per tenant. It is a maintenance job meant for an occasional cron run,
+// not for each SyncOnce tick.
+func CleanupOrphans(ctx context.Context, opts OrphanCleanupOptions) (OrphanCleanupReport, error) {
+	report := OrphanCleanupReport{Checked: map[string]int{}, Orphans: map[string]int{}, Cursors: map[string]string{}}
+	if opts.Pool == nil {
+		return report, fmt.Errorf("Pool is required")
+	}
+	if strings.TrimSpace(opts.Schema) == "" {
+		return report, fmt.Errorf("Schema is required")
+	}
+	if len(opts.EntityTypes) == 0 {
+		return report, fmt.Errorf("EntityTypes is required")
+	}
+	if opts.ExistingEntityIDs == nil {
+		return report, fmt.Errorf("ExistingEntityIDs is required")
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	for _, entityType := range opts.EntityTypes {
+		cursor := opts.Cursors[entityType]
+		for batch := 0; opts.MaxBatches <= 0 || batch < opts.MaxBatches; batch++ {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			ids, err := pg.ListStoredEntityIDs(ctx, opts.Pool, opts.Schema, entityType, cursor, batchSize)
+			if err != nil {
+				return report, fmt.Errorf("list %s ids: %w", entityType, err)
+			}
+			if len(ids) == 0 {
+				cursor = ""
+				break
+			}
+			existing, err := opts.ExistingEntityIDs(ctx, entityType, ids)
+			if err != nil {
+				return report, fmt.Errorf("check %s ids: %w", entityType, err)
+			}
+			exists := make(map[string]struct{}, len(existing))
+			for _, id := range existing {
+				exists[id] = struct{}{}
+			}
+			var orphans []string
+			for _, id := range ids {
+				if _, ok := exists[id]; !ok {
+					orphans = append(orphans, id)
+				}
+			}
+			if !opts.DryRun {
+				if err := pg.DeleteEntities(ctx, opts.Pool, opts.Schema, entityType, orphans); err != nil {
+					return report, fmt.Errorf("delete %s orphans: %w", entityType, err)
+				}
+			}
+			report.Checked[entityType] += len(ids)
+			report.Orphans[entityType] += len(orphans)
+			cursor = ids[len(ids)-1]
+			if len(ids) < batchSize {
+				cursor = ""
+				break
+			}
+		}
+		if cursor != "" {
+			report.Cursors[entityType] = cursor
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/open-rails/searchkit/runtime"
+	"github.com/open-rails/searchkit/tasks"
+)
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// ByModel gives a model its own drain loop with dedicated settings (poll
+	// interval, rate, concurrency, batch sizes). Options.Models is ignored and
+	// set to the map key; Options.Monitor is ignored for every loop, which
+	// share the Pool's.
+	ByModel map[string]Options
+
+	// Default is used for a shared loop that drains every active model not
+	// listed in ByModel. The shared loop is skipped when there are no such
+	// models.
+	Default Options
+}
+
+// Pool runs independent drain loops per model so that a slow model (e.g. VL)
+// does not set the pace for fast text models.
+type Pool struct {
+	rt      *runtime.Runtime
+	repo    *tasks.Repo
+	monitor *Monitor
+	loops   []Options
+}
+
+// NewPool builds a Pool for the runtime's active models.
+func NewPool(rt *runtime.Runtime, repo *tasks.Repo, opts PoolOptions) (*Pool, error) {
+	if rt == nil {
+		return nil, fmt.Errorf("runtime is required")
+	}
+	if repo == nil {
+		return nil, fmt.Errorf("repo is required")
+	}
+
+	active := make(map[string]struct{})
+	for _, m := range rt.ActiveModels() {
+		active[m] = struct{}{}
+	}
+
+	p := &Pool{rt: rt, repo: repo, monitor: NewMonitor()}
+
+	// ByModel keys may be aliases; loops run on canonical names.
+	byModel := make(map[string]Options, len(opts.ByModel))
+	for name, o := range opts.ByModel {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("PoolOptions.ByModel has empty model name")
+		}
+		model := rt.CanonicalModel(name)
+		if _, ok := active[model]; !ok {
+			return nil, fmt.Errorf("model %q is not configured", name)
+		}
+		if _, dup := byModel[model]; dup {
+			return nil, fmt.Errorf("PoolOptions.ByModel has multiple entries for model %q", model)
+		}
+		byModel[model] = o
+	}
+	dedicated := make([]string, 0, len(byModel))
+	for model := range byModel {
+		dedicated = append(dedicated, model)
+	}
+	sort.Strings(dedicated)
+
+	var shared []string
+	for m := range active {
+		if _, ok := byModel[m]; !ok {
+			shared = append(shared, m)
+		}
+	}
+	sort.Strings(shared)
+
+	for _, model := range dedicated {
+		o := byModel[model]
+		o.Models = []string{model}
+		o.Monitor = p.monitor
+		p.loops = append(p.loops, o)
+	}
+	if len(shared) > 0 {
+		o := opts.Default
+		o.Models = shared
+		o.Monitor = p.monitor
+		p.loops = append(p.loops, o)
+	}
+	return p, nil
+}
+
+// Health returns a snapshot of the liveness of the pool's drain loops.
+func (p *Pool) Health() HealthSnapshot {
+	return p.monitor.Health()
+}
+
+// Run runs all drain loops until ctx is done or one loop fails, in which case
+// the remaining loops are stopped and the first error is returned.
+func (p *Pool) Run(ctx context.Context) error {
+	if len(p.loops) == 0 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, o := range p.loops {
+		o := o
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Run(ctx, p.rt, p.repo, o)
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
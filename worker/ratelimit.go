@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"maps"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// newRateLimiters builds the request-rate limiters for l's options: one for
+// MaxRequestsPerSecond and one per model with a MaxRequestsPerSecondByModel
+// limit. Bursts match the concurrency caps so a full set of slots can start
+// together.
+func (l *embedLimiter) newRateLimiters() {
+	if rps := l.cfg.MaxRequestsPerSecond; rps > 0 {
+		l.globalRate = rate.NewLimiter(rate.Limit(rps), max(cap(l.global), 1))
+	}
+	l.rateByModel = make(map[string]*rate.Limiter, len(l.cfg.MaxRequestsPerSecondByModel))
+	for model, rps := range l.cfg.MaxRequestsPerSecondByModel {
+		if rps <= 0 {
+			continue
+		}
+		burst := cap(l.global)
+		if ms, ok := l.byModel[model]; ok {
+			burst = cap(ms)
+		}
+		l.rateByModel[model] = rate.NewLimiter(rate.Limit(rps), max(burst, 1))
+	}
+}
+
+// sameLimits reports whether o configures the same concurrency and rate
+// limits as l was built with.
+func (l *embedLimiter) sameLimits(o Options) bool {
+	return l.cfg.MaxConcurrentEmbeds == o.MaxConcurrentEmbeds &&
+		l.cfg.MaxRequestsPerSecond == o.MaxRequestsPerSecond &&
+		maps.Equal(l.cfg.MaxConcurrentEmbedsByModel, o.MaxConcurrentEmbedsByModel) &&
+		maps.Equal(l.cfg.MaxRequestsPerSecondByModel, o.MaxRequestsPerSecondByModel)
+}
+
+// drainLimiters keeps the limiter DrainOnce calls share through a Monitor, so
+// the request rate holds across calls. It is rebuilt when the limits change.
+type drainLimiters struct {
+	mu sync.Mutex
+	l  *embedLimiter
+}
+
+func (d *drainLimiters) get(cfg Options) *embedLimiter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.l == nil || !d.l.sameLimits(cfg) {
+		d.l = newEmbedLimiter(cfg)
+	}
+	return d.l
+}
@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -27,20 +28,91 @@ type SearchkitOptions struct {
 	// Which entity types are semantically embedded (stored in embedding_vectors).
 	SemanticEntityTypes []string
 
-	// Required for backfill.
+	// Required for backfill. It is called with language pg.AnyLanguage ("*")
+	// when backfilling language-agnostic models and should then list every
+	// entity.
 	ListEntityIDsPage ListEntityIDsPage
 
+	// ListChangedEntityIDs, when set, enables incremental re-sync: each
+	// SyncOnce first marks the entities it reports as changed since the
+	// stored watermark (per tenant, entity type and language) dirty, so the
+	// dirty phase refreshes them. It catches changes that were never marked,
+	// without re-listing every entity like a backfill reset.
+	ListChangedEntityIDs ListChangedEntityIDs
+
+	// Tenants to backfill, each with its own cursors (default: only the default
+	// tenant ""). ListEntityIDsPage reads the tenant with pg.TenantFromContext.
+	// Dirty rows and tasks carry their own tenant_id regardless.
+	Tenants []string
+
 	// Optional overrides.
 	TaskRepo *tasks.Repo
 
+	// SoftDelete marks deleted entities' lexical documents and embeddings
+	// (deleted_at) instead of deleting them; they are excluded from search and
+	// restored without re-embedding if the entity comes back. Purge them with
+	// pg.PurgeSoftDeleted.
+	SoftDelete bool
+
 	// Batch sizing (defaults are conservative).
 	DirtyBatchSize   int
 	BackfillPageSize int
 	// Upper bound on how much cursor backfill work to do per SyncOnce.
 	BackfillMaxPages int
-
-	// Embedding task draining settings (existing embedding worker).
+	// Incremental re-sync page size (default 1000; must exceed the number of
+	// entities sharing one change time) and pages per SyncOnce (default 5).
+	IncrementalPageSize int
+	IncrementalMaxPages int
+
+	// AutoAnalyze runs pg.AnalyzeStale with AnalyzeOptions after the backfill
+	// phase of each SyncOnce, so planner statistics keep up with bulk
+	// backfills instead of waiting for autovacuum.
+	AutoAnalyze    bool
+	AnalyzeOptions pg.AnalyzeOptions
+
+	// Embedding task draining settings (existing embedding worker). Set
+	// DrainOptions.Monitor to keep health and FailureBudget state across
+	// SyncOnce calls.
 	DrainOptions Options
+
+	// OnProgress, when set, is called after each SyncOnce phase with the
+	// cumulative report so far.
+	OnProgress func(phase SyncPhase, report SyncReport)
+}
+
+// SyncPhase identifies a SyncOnce phase.
+type SyncPhase string
+
+const (
+	SyncPhaseIncremental SyncPhase = "incremental"
+	SyncPhaseDirty       SyncPhase = "dirty"
+	SyncPhaseBackfill    SyncPhase = "backfill"
+	SyncPhaseDrain       SyncPhase = "drain"
+)
+
+// SyncReport summarizes the work done by one SyncOnce call, so cron-driven
+// hosts can log and alert on more than a nil error.
+type SyncReport struct {
+	// Incremental phase: entities marked dirty and pages listed.
+	ChangedEntitiesMarked    int
+	IncrementalPagesAdvanced int
+
+	// Dirty phase.
+	DirtyRowsProcessed int
+	DirtyDeletes       int
+
+	// Lexical docs upserted (dirty + backfill).
+	LexicalDocsUpserted int
+	// Embedding tasks enqueued (dirty + backfill), counted per (entity, model).
+	TasksEnqueued int
+
+	// Backfill phase.
+	BackfillPagesAdvanced int
+	// TablesAnalyzed lists the tables AutoAnalyze analyzed.
+	TablesAnalyzed []string
+
+	// Drain phase.
+	Drain DrainSummary
 }
 
 func (o SearchkitOptions) withDefaults() SearchkitOptions {
@@ -54,6 +126,12 @@ func (o SearchkitOptions) withDefaults() SearchkitOptions {
 	if out.BackfillMaxPages <= 0 {
 		out.BackfillMaxPages = 5
 	}
+	if out.IncrementalPageSize <= 0 {
+		out.IncrementalPageSize = 1000
+	}
+	if out.IncrementalMaxPages <= 0 {
+		out.IncrementalMaxPages = 5
+	}
 	out.DrainOptions = out.DrainOptions.withDefaults()
 	return out
 }
@@ -62,26 +140,36 @@ type dirtyRow struct {
 	EntityType string
 	EntityID   string
 	Language   string
+	TenantID   string
 	IsDeleted  bool
 	Reason     string
 }
 
-func SyncOnce(ctx context.Context, rt *runtime.Runtime, opts SearchkitOptions) error {
+// SyncOnce runs one tick of searchkit maintenance (incremental re-sync when
+// configured, dirty queue, bounded backfill, embedding drain) and reports the
+// work done.
+func SyncOnce(ctx context.Context, rt *runtime.Runtime, opts SearchkitOptions) (SyncReport, error) {
+	var report SyncReport
 	if rt == nil {
-		return fmt.Errorf("runtime is required")
+		return report, fmt.Errorf("runtime is required")
 	}
 	cfg := opts.withDefaults()
 	if cfg.Pool == nil {
-		return fmt.Errorf("pool is required")
+		return report, fmt.Errorf("pool is required")
 	}
 	if strings.TrimSpace(cfg.Schema) == "" {
-		return fmt.Errorf("schema is required")
+		return report, fmt.Errorf("schema is required")
 	}
 	if len(cfg.SupportedLanguages) == 0 {
-		return fmt.Errorf("SupportedLanguages is required")
+		return report, fmt.Errorf("SupportedLanguages is required")
 	}
 	if cfg.ListEntityIDsPage == nil {
-		return fmt.Errorf("ListEntityIDsPage is required")
+		return report, fmt.Errorf("ListEntityIDsPage is required")
+	}
+	progress := func(phase SyncPhase) {
+		if cfg.OnProgress != nil {
+			cfg.OnProgress(phase, report)
+		}
 	}
 	repo := cfg.TaskRepo
 	if repo == nil {
@@ -105,23 +193,59 @@ func SyncOnce(ctx context.Context, rt *runtime.Runtime, opts SearchkitOptions) e
 		semanticSet[t] = struct{}{}
 	}
 
+	// 0) Mark entities changed since the watermarks dirty, so step 1 picks
+	// them up in this tick.
+	if cfg.ListChangedEntityIDs != nil {
+		entityTypes := make([]string, 0, len(lexicalSet)+len(semanticSet))
+		for t := range lexicalSet {
+			entityTypes = append(entityTypes, t)
+		}
+		for t := range semanticSet {
+			if _, ok := lexicalSet[t]; !ok {
+				entityTypes = append(entityTypes, t)
+			}
+		}
+		sort.Strings(entityTypes)
+		if err := incrementalOnce(ctx, cfg.Pool, cfg.Schema, entityTypes, cfg.SupportedLanguages, cfg.Tenants, cfg.ListChangedEntityIDs, cfg.IncrementalPageSize, cfg.IncrementalMaxPages, &report); err != nil {
+			return report, err
+		}
+		progress(SyncPhaseIncremental)
+	}
+
 	// 1) Drain dirty queue (fast path).
-	if err := processDirtyOnce(ctx, cfg.Pool, cfg.Schema, repo, rt, lexicalSet, semanticSet, cfg.DirtyBatchSize); err != nil {
-		return err
+	if err := processDirtyOnce(ctx, cfg.Pool, cfg.Schema, repo, rt, lexicalSet, semanticSet, cfg.DirtyBatchSize, cfg.SoftDelete, &report); err != nil {
+		return report, err
 	}
+	progress(SyncPhaseDirty)
 
 	// 2) Bounded backfill tick (slow path).
-	if err := backfillOnce(ctx, cfg.Pool, cfg.Schema, repo, rt, lexicalSet, semanticSet, cfg.SupportedLanguages, cfg.ListEntityIDsPage, cfg.BackfillPageSize, cfg.BackfillMaxPages); err != nil {
-		return err
+	if err := backfillOnce(ctx, cfg.Pool, cfg.Schema, repo, rt, lexicalSet, semanticSet, cfg.SupportedLanguages, cfg.Tenants, cfg.ListEntityIDsPage, cfg.BackfillPageSize, cfg.BackfillMaxPages, &report); err != nil {
+		return report, err
+	}
+	if cfg.AutoAnalyze {
+		analyzed, err := pg.AnalyzeStale(ctx, cfg.Pool, cfg.Schema, cfg.AnalyzeOptions)
+		report.TablesAnalyzed = analyzed
+		if err != nil {
+			return report, err
+		}
 	}
+	progress(SyncPhaseBackfill)
 
 	// 3) Drain embedding tasks (provider calls + writes embedding_vectors).
 	// If no embedding models are configured, skip draining so tasks remain pending
-	// and lexical maintenance still succeeds.
+	// and lexical maintenance still succeeds. The phase is still reported (with
+	// an empty summary) so OnProgress sees every phase.
 	if len(rt.ActiveModels()) == 0 {
-		return nil
+		progress(SyncPhaseDrain)
+		return report, nil
 	}
-	return DrainOnce(ctx, rt, repo, cfg.DrainOptions)
+	drain, err := DrainOnce(ctx, rt, repo, cfg.DrainOptions)
+	report.Drain = drain
+	if err != nil {
+		return report, err
+	}
+	progress(SyncPhaseDrain)
+	return report, nil
 }
 
 func processDirtyOnce(
@@ -133,6 +257,8 @@ func processDirtyOnce(
 	lexicalSet map[string]struct{},
 	semanticSet map[string]struct{},
 	limit int,
+	softDelete bool,
+	report *SyncReport,
 ) error {
 	if limit <= 0 {
 		return nil
@@ -143,7 +269,7 @@ func processDirtyOnce(
 	}
 
 	rows, err := pool.Query(ctx, fmt.Sprintf(`
-		SELECT entity_type, entity_id, language, is_deleted, reason
+		SELECT entity_type, entity_id, language, tenant_id, is_deleted, reason
 		FROM %s.search_dirty
 		ORDER BY updated_at ASC
 		LIMIT $1
@@ -156,7 +282,7 @@ func processDirtyOnce(
 	var batch []dirtyRow
 	for rows.Next() {
 		var r dirtyRow
-		if err := rows.Scan(&r.EntityType, &r.EntityID, &r.Language, &r.IsDeleted, &r.Reason); err != nil {
+		if err := rows.Scan(&r.EntityType, &r.EntityID, &r.Language, &r.TenantID, &r.IsDeleted, &r.Reason); err != nil {
 			return err
 		}
 		if strings.TrimSpace(r.EntityType) == "" || strings.TrimSpace(r.EntityID) == "" || strings.TrimSpace(r.Language) == "" {
@@ -170,52 +296,93 @@ func processDirtyOnce(
 	if len(batch) == 0 {
 		return nil
 	}
+	report.DirtyRowsProcessed += len(batch)
 
 	// Process deletions first.
 	for _, r := range batch {
 		if !r.IsDeleted {
 			continue
 		}
-		if err := pg.DeleteSearchDocuments(ctx, pool, schema, r.EntityType, r.EntityID, r.Language); err != nil {
-			return err
+		report.DirtyDeletes++
+		tctx := pg.WithTenant(ctx, r.TenantID)
+		if softDelete {
+			if err := pg.SoftDeleteEntity(tctx, pool, schema, r.EntityType, r.EntityID, r.Language); err != nil {
+				return err
+			}
+		} else {
+			if err := pg.DeleteSearchDocuments(tctx, pool, schema, r.EntityType, r.EntityID, r.Language); err != nil {
+				return err
+			}
+			if err := pg.DeleteEmbeddingVectorsForEntity(tctx, pool, schema, r.EntityType, r.EntityID, r.Language); err != nil {
+				return err
+			}
 		}
-		if err := pg.DeleteEmbeddingVectorsForEntity(ctx, pool, schema, r.EntityType, r.EntityID, r.Language); err != nil {
+		if err := repo.DeleteAllForEntity(tctx, r.EntityType, r.EntityID, r.Language); err != nil {
 			return err
 		}
-		if err := repo.DeleteAllForEntity(ctx, r.EntityType, r.EntityID, r.Language); err != nil {
-			return err
+	}
+
+	// Un-deleted entities get their soft-deleted rows back; unchanged documents
+	// are then skipped by the content-hash check instead of re-embedded.
+	if softDelete {
+		for _, r := range batch {
+			if r.IsDeleted {
+				continue
+			}
+			if err := pg.RestoreEntity(pg.WithTenant(ctx, r.TenantID), pool, schema, r.EntityType, r.EntityID, r.Language); err != nil {
+				return err
+			}
 		}
 	}
 
-	// Lexical updates.
-	groupedLex := make(map[string]map[string][]string) // entity_type -> language -> ids
+	// Updates are grouped per (tenant, entity_type, language); writes carry the
+	// row's tenant via the context.
+	type dirtyGroup struct {
+		tenant     string
+		entityType string
+		language   string
+	}
+
+	// Lexical updates. Asset-only changes skip them unless lexical documents
+	// include asset text.
+	groupedLex := make(map[dirtyGroup][]string)
 	for _, r := range batch {
-		if r.IsDeleted {
+		if r.IsDeleted || r.Language == pg.AnyLanguage {
 			continue
 		}
-		if _, ok := lexicalSet[r.EntityType]; !ok {
+		if r.Reason == pg.ReasonAssetsChanged && !rt.LexicalUsesAssets() {
 			continue
 		}
-		if groupedLex[r.EntityType] == nil {
-			groupedLex[r.EntityType] = make(map[string][]string)
+		if _, ok := lexicalSet[r.EntityType]; !ok {
+			continue
 		}
-		groupedLex[r.EntityType][r.Language] = append(groupedLex[r.EntityType][r.Language], r.EntityID)
+		g := dirtyGroup{tenant: r.TenantID, entityType: r.EntityType, language: r.Language}
+		groupedLex[g] = append(groupedLex[g], r.EntityID)
 	}
-	for et, byLang := range groupedLex {
-		for lang, ids := range byLang {
-			docs, err := rt.BuildLexicalString(ctx, et, lang, ids)
-			if err != nil {
-				return err
-			}
-			if err := pg.UpsertSearchDocuments(ctx, pool, schema, et, lang, docs); err != nil {
-				return err
-			}
+	for g, ids := range groupedLex {
+		tctx := pg.WithTenant(ctx, g.tenant)
+		docs, err := rt.BuildLexicalString(tctx, g.entityType, g.language, ids)
+		if err != nil {
+			return err
+		}
+		if err := pg.UpsertSearchDocuments(tctx, pool, schema, g.entityType, g.language, docs); err != nil {
+			return err
 		}
+		report.LexicalDocsUpserted += len(docs)
 	}
 
-	// Semantic: enqueue tasks for all active models (no need to build docs here).
+	// Semantic: enqueue tasks for all active models (no need to build docs here),
+	// or only the asset-dependent ones for asset-only changes.
+	// Language-agnostic models collapse an entity's per-language marks into a
+	// single pg.AnyLanguage task; "*" marks only concern those models.
+	type semGroup struct {
+		dirtyGroup
+		model string
+	}
 	activeModels := rt.ActiveModels()
-	groupedSem := make(map[string]map[string][]string) // entity_type -> language -> ids
+	assetModels := rt.AssetDependentModels()
+	groupedSem := make(map[semGroup][]string)
+	seenSem := make(map[semGroup]map[string]struct{})
 	for _, r := range batch {
 		if r.IsDeleted {
 			continue
@@ -223,20 +390,33 @@ func processDirtyOnce(
 		if _, ok := semanticSet[r.EntityType]; !ok {
 			continue
 		}
-		if groupedSem[r.EntityType] == nil {
-			groupedSem[r.EntityType] = make(map[string][]string)
+		models := activeModels
+		if r.Reason == pg.ReasonAssetsChanged {
+			models = assetModels
 		}
-		groupedSem[r.EntityType][r.Language] = append(groupedSem[r.EntityType][r.Language], r.EntityID)
-	}
-	for et, byLang := range groupedSem {
-		for lang, ids := range byLang {
-			for _, model := range activeModels {
-				if err := repo.EnqueueMany(ctx, et, ids, model, lang, "dirty"); err != nil {
-					return err
-				}
+		for _, model := range models {
+			lang := rt.EmbeddingLanguage(model, r.Language)
+			if lang == pg.AnyLanguage && !rt.IsLanguageAgnostic(model) {
+				continue
+			}
+			g := semGroup{dirtyGroup: dirtyGroup{tenant: r.TenantID, entityType: r.EntityType, language: lang}, model: model}
+			if seenSem[g] == nil {
+				seenSem[g] = make(map[string]struct{})
+			}
+			if _, ok := seenSem[g][r.EntityID]; ok {
+				continue
 			}
+			seenSem[g][r.EntityID] = struct{}{}
+			groupedSem[g] = append(groupedSem[g], r.EntityID)
 		}
 	}
+	for g, ids := range groupedSem {
+		tctx := pg.WithTenant(ctx, g.tenant)
+		if err := repo.EnqueueMany(tctx, g.entityType, ids, g.model, g.language, "dirty"); err != nil {
+			return err
+		}
+		report.TasksEnqueued += len(ids)
+	}
 
 	// Clear dirty rows (processed).
 	tx, err := pool.Begin(ctx)
@@ -247,8 +427,8 @@ func processDirtyOnce(
 	for _, r := range batch {
 		if _, err := tx.Exec(ctx, fmt.Sprintf(`
 			DELETE FROM %s.search_dirty
-			WHERE entity_type = $1 AND entity_id = $2 AND language = $3
-		`, qs), r.EntityType, r.EntityID, r.Language); err != nil {
+			WHERE tenant_id = $4 AND entity_type = $1 AND entity_id = $2 AND language = $3
+		`, qs), r.EntityType, r.EntityID, r.Language, r.TenantID); err != nil {
 			return err
 		}
 	}
@@ -264,9 +444,11 @@ func backfillOnce(
 	lexicalSet map[string]struct{},
 	semanticSet map[string]struct{},
 	languages []string,
+	tenants []string,
 	list ListEntityIDsPage,
 	pageSize int,
 	maxPages int,
+	report *SyncReport,
 ) error {
 	if maxPages <= 0 || pageSize <= 0 {
 		return nil
@@ -275,109 +457,126 @@ func backfillOnce(
 	if err != nil {
 		return err
 	}
+	if len(tenants) == 0 {
+		tenants = []string{""}
+	}
 	activeModels := rt.ActiveModels()
 	pagesDone := 0
 
-	// Lexical docs: fill missing documents.
-	for et := range lexicalSet {
-		for _, lang := range languages {
-			if pagesDone >= maxPages {
-				return nil
-			}
-			if strings.TrimSpace(lang) == "" {
-				continue
-			}
-
-			cursor, state, err := ensureAndGetDocBackfillState(ctx, pool, qs, et, lang)
-			if err != nil {
-				return err
-			}
-			if state == "done" {
-				continue
-			}
-
-			ids, nextCursor, done, err := list(ctx, et, lang, cursor, pageSize)
-			if err != nil {
-				_, _ = pool.Exec(ctx, fmt.Sprintf(`
-					UPDATE %s.search_documents_backfill_state
-					SET last_error = $3, state = 'failed', updated_at = now()
-					WHERE entity_type = $1 AND language = $2
-				`, qs), et, lang, err.Error())
-				return err
-			}
-			if len(ids) > 0 {
-				docs, err := rt.BuildLexicalString(ctx, et, lang, ids)
-				if err != nil {
-					return err
-				}
-				if err := pg.UpsertSearchDocuments(ctx, pool, schema, et, lang, docs); err != nil {
-					return err
-				}
-			}
-			if done {
-				_, _ = pool.Exec(ctx, fmt.Sprintf(`
-					UPDATE %s.search_documents_backfill_state
-					SET cursor = $3, state = 'done', last_error = NULL, updated_at = now()
-					WHERE entity_type = $1 AND language = $2
-				`, qs), et, lang, nextCursor)
-			} else {
-				_, _ = pool.Exec(ctx, fmt.Sprintf(`
-					UPDATE %s.search_documents_backfill_state
-					SET cursor = $3, state = 'running', last_error = NULL, updated_at = now()
-					WHERE entity_type = $1 AND language = $2
-				`, qs), et, lang, nextCursor)
-			}
-
-			pagesDone++
-		}
-	}
+	for _, tenant := range tenants {
+		// The list callback and all writes see the tenant via the context.
+		tctx := pg.WithTenant(ctx, tenant)
 
-	// Semantic: enqueue missing embeddings for active models.
-	for et := range semanticSet {
-		for _, lang := range languages {
-			for _, model := range activeModels {
+		// Lexical docs: fill missing documents.
+		for et := range lexicalSet {
+			for _, lang := range languages {
 				if pagesDone >= maxPages {
 					return nil
 				}
-				cursor, state, err := ensureAndGetVecBackfillState(ctx, pool, qs, model, et, lang)
+				if strings.TrimSpace(lang) == "" {
+					continue
+				}
+
+				cursor, state, err := ensureAndGetDocBackfillState(ctx, pool, qs, tenant, et, lang)
 				if err != nil {
 					return err
 				}
 				if state == "done" {
 					continue
 				}
-				ids, nextCursor, done, err := list(ctx, et, lang, cursor, pageSize)
+
+				ids, nextCursor, done, err := list(tctx, et, lang, cursor, pageSize)
 				if err != nil {
 					_, _ = pool.Exec(ctx, fmt.Sprintf(`
-						UPDATE %s.embedding_vectors_backfill_state
+						UPDATE %s.search_documents_backfill_state
 						SET last_error = $4, state = 'failed', updated_at = now()
-						WHERE model = $1 AND entity_type = $2 AND language = $3
-					`, qs), model, et, lang, err.Error())
+						WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+					`, qs), tenant, et, lang, err.Error())
 					return err
 				}
 				if len(ids) > 0 {
-					missing, err := pg.FilterMissingEmbeddings(ctx, pool, schema, et, model, lang, ids)
+					docs, err := rt.BuildLexicalString(tctx, et, lang, ids)
 					if err != nil {
 						return err
 					}
-					if err := repo.EnqueueMany(ctx, et, missing, model, lang, "model_backfill"); err != nil {
+					if err := pg.UpsertSearchDocuments(tctx, pool, schema, et, lang, docs); err != nil {
 						return err
 					}
+					report.LexicalDocsUpserted += len(docs)
 				}
 				if done {
 					_, _ = pool.Exec(ctx, fmt.Sprintf(`
-						UPDATE %s.embedding_vectors_backfill_state
-						SET cursor = $4, state = 'done', last_error = NULL, updated_at = now()
-						WHERE model = $1 AND entity_type = $2 AND language = $3
-					`, qs), model, et, lang, nextCursor)
+						UPDATE %s.search_documents_backfill_state
+						SET cursor = $4, state = 'done', last_error = NULL, processed = processed + $5, updated_at = now()
+						WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+					`, qs), tenant, et, lang, nextCursor, len(ids))
 				} else {
 					_, _ = pool.Exec(ctx, fmt.Sprintf(`
-						UPDATE %s.embedding_vectors_backfill_state
-						SET cursor = $4, state = 'running', last_error = NULL, updated_at = now()
-						WHERE model = $1 AND entity_type = $2 AND language = $3
-					`, qs), model, et, lang, nextCursor)
+						UPDATE %s.search_documents_backfill_state
+						SET cursor = $4, state = 'running', last_error = NULL, processed = processed + $5, updated_at = now()
+						WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+					`, qs), tenant, et, lang, nextCursor, len(ids))
 				}
+
 				pagesDone++
+				report.BackfillPagesAdvanced++
+			}
+		}
+
+		// Semantic: enqueue missing embeddings for active models
+		// (language-agnostic models are backfilled once, under pg.AnyLanguage).
+		for et := range semanticSet {
+			for _, model := range activeModels {
+				modelLanguages := languages
+				if rt.IsLanguageAgnostic(model) {
+					modelLanguages = []string{pg.AnyLanguage}
+				}
+				for _, lang := range modelLanguages {
+					if pagesDone >= maxPages {
+						return nil
+					}
+					cursor, state, err := ensureAndGetVecBackfillState(ctx, pool, qs, tenant, model, et, lang)
+					if err != nil {
+						return err
+					}
+					if state == "done" {
+						continue
+					}
+					ids, nextCursor, done, err := list(tctx, et, lang, cursor, pageSize)
+					if err != nil {
+						_, _ = pool.Exec(ctx, fmt.Sprintf(`
+							UPDATE %s.embedding_vectors_backfill_state
+							SET last_error = $5, state = 'failed', updated_at = now()
+							WHERE tenant_id = $1 AND model = $2 AND entity_type = $3 AND language = $4
+						`, qs), tenant, model, et, lang, err.Error())
+						return err
+					}
+					if len(ids) > 0 {
+						missing, err := pg.FilterMissingEmbeddings(tctx, pool, schema, et, model, lang, ids)
+						if err != nil {
+							return err
+						}
+						if err := repo.EnqueueMany(tctx, et, missing, model, lang, "model_backfill"); err != nil {
+							return err
+						}
+						report.TasksEnqueued += len(missing)
+					}
+					if done {
+						_, _ = pool.Exec(ctx, fmt.Sprintf(`
+							UPDATE %s.embedding_vectors_backfill_state
+							SET cursor = $5, state = 'done', last_error = NULL, processed = processed + $6, updated_at = now()
+							WHERE tenant_id = $1 AND model = $2 AND entity_type = $3 AND language = $4
+						`, qs), tenant, model, et, lang, nextCursor, len(ids))
+					} else {
+						_, _ = pool.Exec(ctx, fmt.Sprintf(`
+							UPDATE %s.embedding_vectors_backfill_state
+							SET cursor = $5, state = 'running', last_error = NULL, processed = processed + $6, updated_at = now()
+							WHERE tenant_id = $1 AND model = $2 AND entity_type = $3 AND language = $4
+						`, qs), tenant, model, et, lang, nextCursor, len(ids))
+					}
+					pagesDone++
+					report.BackfillPagesAdvanced++
+				}
 			}
 		}
 	}
@@ -385,37 +584,42 @@ func backfillOnce(
 	return nil
 }
 
-func ensureAndGetDocBackfillState(ctx context.Context, pool *pgxpool.Pool, qs string, entityType string, language string) (cursor string, state string, err error) {
+// ensureAndGetDocBackfillState creates the state row on first use and, like
+// ensureAndGetVecBackfillState, stamps started_at when a pass begins (for
+// pg.BackfillProgress), including after a reset cleared it.
+func ensureAndGetDocBackfillState(ctx context.Context, pool *pgxpool.Pool, qs string, tenant string, entityType string, language string) (cursor string, state string, err error) {
 	if _, err := pool.Exec(ctx, fmt.Sprintf(`
-		INSERT INTO %s.search_documents_backfill_state (entity_type, language)
-		VALUES ($1, $2)
-		ON CONFLICT (entity_type, language) DO NOTHING
-	`, qs), entityType, language); err != nil {
+		INSERT INTO %s.search_documents_backfill_state AS s (tenant_id, entity_type, language, started_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (tenant_id, entity_type, language) DO UPDATE SET started_at = now()
+		WHERE s.started_at IS NULL AND s.state <> 'done'
+	`, qs), tenant, entityType, language); err != nil {
 		return "", "", err
 	}
 	if err := pool.QueryRow(ctx, fmt.Sprintf(`
 		SELECT cursor, state
 		FROM %s.search_documents_backfill_state
-		WHERE entity_type = $1 AND language = $2
-	`, qs), entityType, language).Scan(&cursor, &state); err != nil {
+		WHERE tenant_id = $1 AND entity_type = $2 AND language = $3
+	`, qs), tenant, entityType, language).Scan(&cursor, &state); err != nil {
 		return "", "", err
 	}
 	return cursor, state, nil
 }
 
-func ensureAndGetVecBackfillState(ctx context.Context, pool *pgxpool.Pool, qs string, model string, entityType string, language string) (cursor string, state string, err error) {
+func ensureAndGetVecBackfillState(ctx context.Context, pool *pgxpool.Pool, qs string, tenant string, model string, entityType string, language string) (cursor string, state string, err error) {
 	if _, err := pool.Exec(ctx, fmt.Sprintf(`
-		INSERT INTO %s.embedding_vectors_backfill_state (model, entity_type, language)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (model, entity_type, language) DO NOTHING
-	`, qs), model, entityType, language); err != nil {
+		INSERT INTO %s.embedding_vectors_backfill_state AS s (tenant_id, model, entity_type, language, started_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (tenant_id, model, entity_type, language) DO UPDATE SET started_at = now()
+		WHERE s.started_at IS NULL AND s.state <> 'done'
+	`, qs), tenant, model, entityType, language); err != nil {
 		return "", "", err
 	}
 	if err := pool.QueryRow(ctx, fmt.Sprintf(`
 		SELECT cursor, state
 		FROM %s.embedding_vectors_backfill_state
-		WHERE model = $1 AND entity_type = $2 AND language = $3
-	`, qs), model, entityType, language).Scan(&cursor, &state); err != nil {
+		WHERE tenant_id = $1 AND model = $2 AND entity_type = $3 AND language = $4
+	`, qs), tenant, model, entityType, language).Scan(&cursor, &state); err != nil {
 		return "", "", err
 	}
 	return cursor, state, nil
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSpendBudgetExceeded is the reason tasks are deferred when their model has
+// used up its daily SpendBudget. Deferred tasks keep their attempt count.
+var ErrSpendBudgetExceeded = errors.New("searchkit: daily spend budget exceeded")
+
+// SpendLimit caps one model's provider usage per UTC day. Zero fields are
+// unlimited.
+type SpendLimit struct {
+	// TokensPerDay caps provider-reported prompt tokens (providers that do not
+	// report usage never reach it).
+	TokensPerDay int64
+	// RequestsPerDay caps provider embedding requests (one per provider batch
+	// or VL task).
+	RequestsPerDay int64
+}
+
+// SpendBudget stops embedding a model for the rest of the UTC day once it has
+// spent its daily limit. Tasks fetched while the model is over budget are
+// deferred to the next day (without consuming an attempt) instead of failing.
+//
+// Usage is counted per Monitor (see Options.Monitor), so DrainOnce calls
+// need a shared Monitor for the limits to hold; with several workers, divide
+// the limits between them.
+type SpendBudget struct {
+	// Limits is keyed by canonical model name. Models without an entry are not
+	// limited.
+	Limits map[string]SpendLimit
+
+	// OnExceeded is called (synchronously) the first time a model goes over its
+	// limit each day. kind is "tokens" or "requests".
+	OnExceeded func(model string, kind string, used int64, limit int64, resetAt time.Time)
+}
+
+type spendDay struct {
+	day      time.Time
+	tokens   int64
+	requests int64
+	notified bool
+}
+
+type spendTracker struct {
+	mu      sync.Mutex
+	byModel map[string]*spendDay
+}
+
+// dayOf returns the start of now's UTC day.
+func dayOf(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour)
+}
+
+// current returns model's counters for now's day. t.mu must be held.
+func (t *spendTracker) current(model string, now time.Time) *spendDay {
+	day := dayOf(now)
+	d, ok := t.byModel[model]
+	if !ok || !d.day.Equal(day) {
+		d = &spendDay{day: day}
+		t.byModel[model] = d
+	}
+	return d
+}
+
+// exceeded reports whether model is over its budget and, if so, when the
+// budget resets. OnExceeded fires on the first hit of the day.
+func (t *spendTracker) exceeded(b *SpendBudget, model string, now time.Time) (time.Time, bool) {
+	if b == nil {
+		return time.Time{}, false
+	}
+	l, ok := b.Limits[model]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	var (
+		kind        string
+		used, limit int64
+		notify      bool
+	)
+	t.mu.Lock()
+	d := t.current(model, now)
+	switch {
+	case l.TokensPerDay > 0 && d.tokens >= l.TokensPerDay:
+		kind, used, limit = "tokens", d.tokens, l.TokensPerDay
+	case l.RequestsPerDay > 0 && d.requests >= l.RequestsPerDay:
+		kind, used, limit = "requests", d.requests, l.RequestsPerDay
+	}
+	if kind != "" && !d.notified {
+		d.notified = true
+		notify = true
+	}
+	resetAt := d.day.Add(24 * time.Hour)
+	t.mu.Unlock()
+
+	if kind == "" {
+		return time.Time{}, false
+	}
+	if notify && b.OnExceeded != nil {
+		b.OnExceeded(model, kind, used, limit, resetAt)
+	}
+	return resetAt, true
+}
+
+// record adds one provider request and its reported tokens to model's usage.
+func (t *spendTracker) record(b *SpendBudget, model string, tokens int, now time.Time) {
+	if b == nil {
+		return
+	}
+	if _, ok := b.Limits[model]; !ok {
+		return
+	}
+	t.mu.Lock()
+	d := t.current(model, now)
+	d.requests++
+	if tokens > 0 {
+		d.tokens += int64(tokens)
+	}
+	t.mu.Unlock()
+}
@@ -6,32 +6,114 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
+	"math/rand/v2"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/sashabaranov/go-openai"
-
+	"github.com/open-rails/searchkit/embedder"
+	"github.com/open-rails/searchkit/pg"
 	"github.com/open-rails/searchkit/runtime"
 	"github.com/open-rails/searchkit/tasks"
 	"github.com/open-rails/searchkit/vl"
+	"golang.org/x/time/rate"
 )
 
 type Options struct {
+	// Models restricts draining to tasks for these models. Empty means all
+	// models.
+	Models []string
+
 	BatchSize int
 	LockAhead time.Duration
 	PollEvery time.Duration
 
-	MaxConcurrentEmbeds  int
-	MaxRequestsPerSecond float64 // 0 = unlimited
+	MaxConcurrentEmbeds int
+	// MaxConcurrentEmbedsByModel caps concurrent provider requests per model
+	// (in addition to MaxConcurrentEmbeds), e.g. to hold a heavily rate-limited
+	// VL model to 1-2 requests while text models use the global limit.
+	MaxConcurrentEmbedsByModel map[string]int
+
+	// MaxRequestsPerSecond limits provider requests across all models
+	// (0 = unlimited). MaxRequestsPerSecondByModel adds per-model limits.
+	//
+	// Each Run loop (and so each Pool loop) has its own limiters; DrainOnce
+	// calls sharing a Monitor share theirs, so the rate holds across calls.
+	MaxRequestsPerSecond        float64
+	MaxRequestsPerSecondByModel map[string]float64
 
 	MaxAttempts int
 	BackoffBase time.Duration
 	BackoffMax  time.Duration
+
+	// BackoffStrategy selects how retry delays grow. Defaults to
+	// BackoffExponential.
+	BackoffStrategy BackoffStrategy
+	// JitterFraction is the maximum jitter added to exponential/fixed delays, as
+	// a fraction of the delay. Defaults to 0.25; negative disables jitter.
+	JitterFraction float64
+	// RateLimitBackoffBase is the base delay used instead of BackoffBase for
+	// rate-limit (429) errors. Defaults to 1 minute.
+	RateLimitBackoffBase time.Duration
+
+	// ProviderBatchSize is the number of texts sent per provider embedding
+	// request for embedders that do not report their limit
+	// (embedder.MaxBatcher). Defaults to 25.
+	ProviderBatchSize int
+	// ProviderBatchSizeByModel overrides ProviderBatchSize (and the embedder's
+	// own limit) per model, e.g. to send smaller requests to a slow provider.
+	ProviderBatchSizeByModel map[string]int
+
+	// FailureBudget, when set, pauses a model's processing for a cooldown when
+	// its task failure rate exceeds the budget.
+	FailureBudget *FailureBudget
+	// Monitor records health (see Monitor.Health) and keeps the
+	// FailureBudget and SpendBudget state across drains. Run requires one;
+	// without one each DrainOnce call starts afresh, so hosts calling
+	// DrainOnce pass a NewMonitor shared between the calls. Pool sets its own
+	// (see Pool.Health).
+	Monitor *Monitor
+
+	// SpendBudget, when set, caps each model's provider tokens/requests per
+	// day; tasks over budget are deferred to the next day rather than failed.
+	SpendBudget *SpendBudget
+
+	// AssetFetcher, when set, downloads VL assets so their bytes can be
+	// uploaded when the provider cannot fetch the presigned URLs (see
+	// runtime.WithAssetFetcher); e.g. vl.HTTPFetchPolicy{}.Fetcher(), which
+	// bounds size and time, retries and checks content types.
+	AssetFetcher vl.AssetFetcher
+	// AlwaysUploadAssets uploads bytes without trying the URLs first (for
+	// assets the provider can never reach).
+	AlwaysUploadAssets bool
+
+	// RefreshAssetURLs, when set, is asked once for fresh URLs of a VL task's
+	// entity when the provider cannot fetch its asset URLs (presigned URLs that
+	// expired while the task waited), before the task fails. Usually the same
+	// function as runtime.Options.ListAssetURLs.
+	RefreshAssetURLs vl.ListAssetURLs
+
+	// ProbeDeadLetterAssets HEADs each asset URL of a VL task that is being
+	// dead-lettered and records the per-asset HTTP status in the dead-letter
+	// error (see vl.ProbeAssets), so broken or expired URLs are told apart from
+	// provider failures. AssetProbeTimeout bounds the probes (default 5s).
+	ProbeDeadLetterAssets bool
+	AssetProbeTimeout     time.Duration
 }
 
-const providerEmbedBatchSize = 25
+const defaultProviderEmbedBatchSize = 25
+
+// BackoffStrategy controls retry delay growth for failed tasks.
+type BackoffStrategy string
+
+const (
+	// BackoffExponential doubles the delay per attempt (plus JitterFraction).
+	BackoffExponential BackoffStrategy = "exponential"
+	// BackoffExponentialFullJitter picks a uniform delay in [0, exponential].
+	BackoffExponentialFullJitter BackoffStrategy = "exponential_full_jitter"
+	// BackoffFixed always waits BackoffBase (plus JitterFraction).
+	BackoffFixed BackoffStrategy = "fixed"
+)
 
 func (o *Options) withDefaults() Options {
 	out := *o
@@ -56,34 +138,58 @@ func (o *Options) withDefaults() Options {
 	if out.BackoffMax <= 0 {
 		out.BackoffMax = 10 * time.Minute
 	}
+	if out.BackoffStrategy == "" {
+		out.BackoffStrategy = BackoffExponential
+	}
+	if out.JitterFraction == 0 {
+		out.JitterFraction = 0.25
+	}
+	if out.RateLimitBackoffBase <= 0 {
+		out.RateLimitBackoffBase = time.Minute
+	}
+	if out.ProviderBatchSize <= 0 {
+		out.ProviderBatchSize = defaultProviderEmbedBatchSize
+	}
+	if out.AssetProbeTimeout <= 0 {
+		out.AssetProbeTimeout = 5 * time.Second
+	}
+	if out.Monitor == nil {
+		out.Monitor = NewMonitor()
+	}
 	return out
 }
 
-func isRateLimit(err error) bool {
-	var apiErr *openai.APIError
-	if errors.As(err, &apiErr) {
-		return apiErr.HTTPStatusCode == 429
+// providerBatchSize returns the provider request batch size for model:
+// ProviderBatchSizeByModel, else the embedder's own limit (maxBatch), else
+// ProviderBatchSize.
+func (o Options) providerBatchSize(model string, maxBatch int) int {
+	if n, ok := o.ProviderBatchSizeByModel[model]; ok && n > 0 {
+		return n
+	}
+	if maxBatch > 0 {
+		return maxBatch
 	}
-	var reqErr *openai.RequestError
-	if errors.As(err, &reqErr) {
-		return reqErr.HTTPStatusCode == 429
+	if o.ProviderBatchSize > 0 {
+		return o.ProviderBatchSize
 	}
-	return false
+	return defaultProviderEmbedBatchSize
+}
+
+func isRateLimit(err error) bool {
+	code, ok := httpStatus(err)
+	return ok && code == 429
 }
 
 func httpStatus(err error) (int, bool) {
-	var apiErr *openai.APIError
-	if errors.As(err, &apiErr) {
-		return apiErr.HTTPStatusCode, true
-	}
-	var reqErr *openai.RequestError
-	if errors.As(err, &reqErr) {
-		return reqErr.HTTPStatusCode, true
-	}
-	return 0, false
+	return embedder.StatusCode(err)
 }
 
 func isRetryable(err error) bool {
+	// A corrupt, oversized or non-image asset and an unsupported asset kind
+	// fail the same way on every attempt.
+	if errors.Is(err, vl.ErrCorruptImage) || errors.Is(err, vl.ErrUnsupportedAssetKind) || errors.Is(err, vl.ErrAssetRejected) {
+		return false
+	}
 	code, ok := httpStatus(err)
 	if ok {
 		// Retry on rate limit/timeouts and server errors.
@@ -115,120 +221,360 @@ func expBackoff(base time.Duration, attempt int, max time.Duration) time.Duratio
 	return d
 }
 
-func addJitter(rng *rand.Rand, d time.Duration) time.Duration {
-	if d <= 0 {
+func addJitter(d time.Duration, fraction float64) time.Duration {
+	if d <= 0 || fraction <= 0 {
 		return d
 	}
-	// Up to 25% jitter.
-	j := time.Duration(rng.Int63n(int64(d / 4)))
-	return d + j
+	maxJitter := int64(float64(d) * fraction)
+	if maxJitter <= 0 {
+		return d
+	}
+	return d + time.Duration(rand.Int64N(maxJitter))
 }
 
-func makeTokenBucket(rps float64, burst int) <-chan struct{} {
-	ch := make(chan struct{}, burst)
-	for i := 0; i < burst; i++ {
-		ch <- struct{}{}
+// computeBackoff returns the retry delay for attempt under cfg's strategy.
+func computeBackoff(cfg Options, base time.Duration, attempt int, max time.Duration) time.Duration {
+	switch cfg.BackoffStrategy {
+	case BackoffFixed:
+		d := base
+		if d > max {
+			d = max
+		}
+		return addJitter(d, cfg.JitterFraction)
+	case BackoffExponentialFullJitter:
+		d := expBackoff(base, attempt, max)
+		if d <= 0 {
+			return d
+		}
+		return time.Duration(rand.Int64N(int64(d) + 1))
+	default:
+		return addJitter(expBackoff(base, attempt, max), cfg.JitterFraction)
 	}
-	if rps <= 0 {
-		return ch
+}
+
+// embedLimiter bounds concurrent provider requests and request rate, globally
+// and per model.
+type embedLimiter struct {
+	global  chan struct{}
+	byModel map[string]chan struct{}
+
+	globalRate  *rate.Limiter
+	rateByModel map[string]*rate.Limiter
+
+	cfg Options
+}
+
+func newEmbedLimiter(cfg Options) *embedLimiter {
+	l := &embedLimiter{
+		global:  make(chan struct{}, cfg.MaxConcurrentEmbeds),
+		byModel: make(map[string]chan struct{}, len(cfg.MaxConcurrentEmbedsByModel)),
+		cfg:     cfg,
 	}
-	interval := time.Duration(float64(time.Second) / rps)
-	if interval < time.Millisecond {
-		interval = time.Millisecond
+	for model, n := range cfg.MaxConcurrentEmbedsByModel {
+		if n <= 0 {
+			continue
+		}
+		l.byModel[model] = make(chan struct{}, n)
 	}
-	t := time.NewTicker(interval)
-	go func() {
-		for range t.C {
-			select {
-			case ch <- struct{}{}:
-			default:
-			}
+	l.newRateLimiters()
+	return l
+}
+
+// acquire blocks until a concurrency slot and a rate token are available for
+// model. The per-model slot is taken first so a saturated model does not hold
+// global slots while waiting. It returns false if ctx is done first.
+func (l *embedLimiter) acquire(ctx context.Context, model string) bool {
+	if ms, ok := l.byModel[model]; ok {
+		select {
+		case <-ctx.Done():
+			return false
+		case ms <- struct{}{}:
+		}
+	}
+	select {
+	case <-ctx.Done():
+		if ms, ok := l.byModel[model]; ok {
+			<-ms
+		}
+		return false
+	case l.global <- struct{}{}:
+	}
+	if err := l.waitRate(ctx, model); err != nil {
+		l.release(model)
+		return false
+	}
+	return true
+}
+
+func (l *embedLimiter) waitRate(ctx context.Context, model string) error {
+	if rl, ok := l.rateByModel[model]; ok {
+		if err := rl.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.globalRate != nil {
+		if err := l.globalRate.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *embedLimiter) release(model string) {
+	<-l.global
+	if ms, ok := l.byModel[model]; ok {
+		<-ms
+	}
+}
+
+// hydration holds the host-provided inputs for a batch. Host callback failures
+// are recorded per group so one failing (tenant, entity_type, language) group
+// does not abort the whole batch.
+type hydration struct {
+	docs   map[docGroup]map[string]string          // entity_id -> doc
+	assets map[assetGroup]map[string][]vl.AssetURL // entity_id -> assets
+
+	docErrs   map[docGroup]error
+	assetErrs map[assetGroup]error
+}
+
+// docGroup and assetGroup key hydration by tenant, as tenants may use the same
+// entity IDs.
+type docGroup struct {
+	tenant, entityType, language string
+}
+
+type assetGroup struct {
+	tenant, entityType string
+}
+
+func docGroupOf(task tasks.Task) docGroup {
+	return docGroup{tenant: task.TenantID, entityType: task.EntityType, language: task.Language}
+}
+
+func assetGroupOf(task tasks.Task) assetGroup {
+	return assetGroup{tenant: task.TenantID, entityType: task.EntityType}
+}
+
+// taskErr returns the hydration error affecting task, if any.
+func (h *hydration) taskErr(task tasks.Task, isVL bool) error {
+	if err, ok := h.docErrs[docGroupOf(task)]; ok {
+		return err
+	}
+	if isVL {
+		if err, ok := h.assetErrs[assetGroupOf(task)]; ok {
+			return err
 		}
-	}()
-	return ch
+	}
+	return nil
 }
 
+func (h *hydration) doc(task tasks.Task) string {
+	return h.docs[docGroupOf(task)][task.EntityID]
+}
+
+func (h *hydration) assetURLs(task tasks.Task) []vl.AssetURL {
+	return h.assets[assetGroupOf(task)][task.EntityID]
+}
+
+// hydrateBatch calls host callbacks once per (tenant, entity_type, language)
+// group, with the tenant in ctx (see pg.WithTenant). Callback errors are
+// recorded per group in the returned hydration; only context cancellation is
+// returned as an error.
 func hydrateBatch(
 	ctx context.Context,
 	rt *runtime.Runtime,
 	batch []tasks.Task,
-) (docsByType map[string]map[string]map[string]string, assetsByType map[string]map[string][]vl.AssetURL, err error) {
-	// docsByType[entity_type][language][entity_id] = doc
-	docsByType = map[string]map[string]map[string]string{}
-	assetsByType = map[string]map[string][]vl.AssetURL{}
+) (*hydration, error) {
+	h := &hydration{
+		docs:      map[docGroup]map[string]string{},
+		assets:    map[assetGroup]map[string][]vl.AssetURL{},
+		docErrs:   map[docGroup]error{},
+		assetErrs: map[assetGroup]error{},
+	}
 
-	idsByTypeLang := map[string]map[string]map[string]struct{}{}
-	assetsNeededByType := map[string]map[string]struct{}{}
+	idsByDocGroup := map[docGroup]map[string]struct{}{}
+	idsByAssetGroup := map[assetGroup]map[string]struct{}{}
 
 	for _, t := range batch {
 		if strings.TrimSpace(t.EntityType) == "" || strings.TrimSpace(t.EntityID) == "" || strings.TrimSpace(t.Language) == "" {
 			continue
 		}
-		if _, ok := idsByTypeLang[t.EntityType]; !ok {
-			idsByTypeLang[t.EntityType] = map[string]map[string]struct{}{}
-		}
-		if _, ok := idsByTypeLang[t.EntityType][t.Language]; !ok {
-			idsByTypeLang[t.EntityType][t.Language] = map[string]struct{}{}
+		g := docGroupOf(t)
+		if _, ok := idsByDocGroup[g]; !ok {
+			idsByDocGroup[g] = map[string]struct{}{}
 		}
-		idsByTypeLang[t.EntityType][t.Language][t.EntityID] = struct{}{}
+		idsByDocGroup[g][t.EntityID] = struct{}{}
 
 		if rt.IsVLModel(t.Model) {
-			if _, ok := assetsNeededByType[t.EntityType]; !ok {
-				assetsNeededByType[t.EntityType] = map[string]struct{}{}
+			ag := assetGroupOf(t)
+			if _, ok := idsByAssetGroup[ag]; !ok {
+				idsByAssetGroup[ag] = map[string]struct{}{}
 			}
-			assetsNeededByType[t.EntityType][t.EntityID] = struct{}{}
+			idsByAssetGroup[ag][t.EntityID] = struct{}{}
 		}
 	}
 
-	for et, byLang := range idsByTypeLang {
-		for lang, set := range byLang {
-			ids := make([]string, 0, len(set))
-			for id := range set {
-				ids = append(ids, id)
-			}
-			if len(ids) == 0 {
-				continue
-			}
-			m, err := rt.BuildSemanticDocument(ctx, et, lang, ids)
-			if err != nil {
-				return nil, nil, err
-			}
-			if _, ok := docsByType[et]; !ok {
-				docsByType[et] = map[string]map[string]string{}
+	for g, set := range idsByDocGroup {
+		ids := make([]string, 0, len(set))
+		for id := range set {
+			ids = append(ids, id)
+		}
+		m, err := rt.BuildSemanticDocument(pg.WithTenant(ctx, g.tenant), g.entityType, g.language, ids)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
 			}
-			docsByType[et][lang] = m
+			log.Printf("searchkit: BuildSemanticDocument failed tenant=%q entity_type=%s language=%s ids=%d err=%v", g.tenant, g.entityType, g.language, len(ids), err)
+			h.docErrs[g] = fmt.Errorf("BuildSemanticDocument: %w", err)
+			continue
 		}
+		h.docs[g] = m
 	}
 
-	for et, set := range assetsNeededByType {
+	for g, set := range idsByAssetGroup {
 		ids := make([]string, 0, len(set))
 		for id := range set {
 			ids = append(ids, id)
 		}
-		if len(ids) == 0 {
-			continue
-		}
-		m, err := rt.ListAssetURLs(ctx, et, ids)
+		m, err := rt.ListAssetURLs(pg.WithTenant(ctx, g.tenant), g.entityType, ids)
 		if err != nil {
-			return nil, nil, err
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			log.Printf("searchkit: ListAssetURLs failed tenant=%q entity_type=%s ids=%d err=%v", g.tenant, g.entityType, len(ids), err)
+			h.assetErrs[g] = fmt.Errorf("ListAssetURLs: %w", err)
+			continue
 		}
-		assetsByType[et] = m
+		h.assets[g] = m
+	}
+
+	return h, nil
+}
+
+type taskOutcome int
+
+const (
+	outcomeSucceeded taskOutcome = iota
+	outcomeNotFound
+	outcomeRetried
+	outcomeDeadLettered
+	outcomeDeferred
+)
+
+// DrainSummary reports the outcome of one DrainOnce call, so external job
+// runners can record results and decide whether to drain again immediately.
+type DrainSummary struct {
+	// Fetched is the number of tasks leased.
+	Fetched int
+	// More is true when a full batch was fetched, so more ready tasks are
+	// likely waiting.
+	More bool
+
+	Processed    int
+	Succeeded    int
+	NotFound     int
+	Retried      int
+	DeadLettered int
+	// Deferred counts tasks rescheduled because their model's SpendBudget was
+	// exhausted (attempts are not consumed).
+	Deferred int
+
+	// Latency holds provider+store request latency per model.
+	Latency map[string]LatencyStats
+
+	// PromptTokens is the provider-reported token usage of the drain (0 for
+	// providers that do not report usage).
+	PromptTokens int
+}
+
+// LatencyStats aggregates request latency for one model.
+type LatencyStats struct {
+	Requests int
+	Total    time.Duration
+	Max      time.Duration
+}
+
+// Mean returns the average request latency.
+func (l LatencyStats) Mean() time.Duration {
+	if l.Requests == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Requests)
+}
+
+// drainStats collects a DrainSummary. Safe for concurrent use.
+type drainStats struct {
+	mu sync.Mutex
+	s  DrainSummary
+}
+
+func (d *drainStats) add(o taskOutcome) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.s.Processed++
+	switch o {
+	case outcomeSucceeded:
+		d.s.Succeeded++
+	case outcomeNotFound:
+		d.s.NotFound++
+	case outcomeRetried:
+		d.s.Retried++
+	case outcomeDeadLettered:
+		d.s.DeadLettered++
+	case outcomeDeferred:
+		d.s.Deferred++
 	}
+}
 
-	return docsByType, assetsByType, nil
+func (d *drainStats) observe(model string, elapsed time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.s.Latency == nil {
+		d.s.Latency = map[string]LatencyStats{}
+	}
+	l := d.s.Latency[model]
+	l.Requests++
+	l.Total += elapsed
+	if elapsed > l.Max {
+		l.Max = elapsed
+	}
+	d.s.Latency[model] = l
+}
+
+func (d *drainStats) summary() DrainSummary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := d.s
+	if d.s.Latency != nil {
+		out.Latency = make(map[string]LatencyStats, len(d.s.Latency))
+		for m, l := range d.s.Latency {
+			out.Latency[m] = l
+		}
+	}
+	return out
 }
 
 func handleTaskResult(
 	ctx context.Context,
 	repo *tasks.Repo,
 	cfg Options,
-	rng *rand.Rand,
 	task tasks.Task,
+	assets []vl.AssetURL,
 	err error,
-) {
+) taskOutcome {
+	if err == nil {
+		cfg.Monitor.health.embedded(task.Model)
+	}
+	if until, paused := cfg.Monitor.budgets.record(cfg.FailureBudget, task.Model, err != nil && !errors.Is(err, runtime.ErrEntityNotFound), time.Now()); paused {
+		cfg.Monitor.health.paused(task.Model, until)
+	}
 	if err == nil || errors.Is(err, runtime.ErrEntityNotFound) {
-		_ = repo.Complete(ctx, task.EntityType, task.EntityID, task.Model, task.Language, task.NextRunAt)
-		return
+		_ = repo.Complete(ctx, task)
+		if err != nil {
+			return outcomeNotFound
+		}
+		return outcomeSucceeded
 	}
 
 	log.Printf(
@@ -242,25 +588,27 @@ func handleTaskResult(
 		err,
 	)
 
+	cfg.Monitor.health.failed(err)
+
 	// This failure counts as the next attempt (tasks.Attempts is prior failures).
 	task.Attempts = task.Attempts + 1
 
-	// Attempt cap: move to dead-letter queue.
-	if task.Attempts >= cfg.MaxAttempts {
-		_ = repo.DeadLetter(ctx, task, task.NextRunAt, err)
-		return
-	}
-
-	// Permanent errors: move to dead-letter queue.
-	if !isRetryable(err) {
-		_ = repo.DeadLetter(ctx, task, task.NextRunAt, err)
-		return
+	// Attempt cap or permanent error: move to dead-letter queue.
+	if task.Attempts >= cfg.MaxAttempts || !isRetryable(err) {
+		_ = repo.DeadLetter(ctx, task, probeAssets(ctx, cfg, assets, err))
+		return outcomeDeadLettered
 	}
 
 	attempt := task.Attempts
 	base := cfg.BackoffBase
 	max := cfg.BackoffMax
 
+	// Rate limits get their own (larger) base so retries back off the provider
+	// rather than hammering it at the regular cadence.
+	if isRateLimit(err) && base < cfg.RateLimitBackoffBase {
+		base = cfg.RateLimitBackoffBase
+	}
+
 	// For provider misconfiguration (4xx other than 429), we want a slower retry cadence
 	// (hours/days) so we can "catch up later" after creds/config are fixed without
 	// churning the provider.
@@ -273,12 +621,33 @@ func handleTaskResult(
 		}
 	}
 
-	backoff := expBackoff(base, attempt, max)
-	backoff = addJitter(rng, backoff)
-	_ = repo.Fail(ctx, task.EntityType, task.EntityID, task.Model, task.Language, task.NextRunAt, backoff)
+	backoff := computeBackoff(cfg, base, attempt, max)
+	// Honour the provider's Retry-After hint when it asks for longer.
+	var httpErr *embedder.HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > backoff {
+		backoff = httpErr.RetryAfter
+	}
+	_ = repo.Fail(ctx, task, backoff)
+	return outcomeRetried
 }
 
-func processBatch(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, cfg Options, batch []tasks.Task, docsByType map[string]map[string]map[string]string, assetsByType map[string]map[string][]vl.AssetURL, sem chan struct{}, tokens <-chan struct{}, rng *rand.Rand) {
+// deferTask reschedules task for until because its model is over its spend
+// budget. The attempt is not counted.
+func deferTask(ctx context.Context, repo *tasks.Repo, task tasks.Task, until time.Time) taskOutcome {
+	log.Printf(
+		"searchkit: task deferred entity_type=%s entity_id=%s model=%s language=%s until=%s reason=%v",
+		task.EntityType,
+		task.EntityID,
+		task.Model,
+		task.Language,
+		until.Format(time.RFC3339),
+		ErrSpendBudgetExceeded,
+	)
+	_ = repo.Defer(ctx, task, until)
+	return outcomeDeferred
+}
+
+func processBatch(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, cfg Options, batch []tasks.Task, h *hydration, limiter *embedLimiter, stats *drainStats) {
 	type textWorkItem struct {
 		task tasks.Task
 		doc  string
@@ -289,64 +658,90 @@ func processBatch(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, cf
 		assets []vl.AssetURL
 	}
 
-	textByModel := map[string][]textWorkItem{}
-	vlItems := make([]vlWorkItem, 0)
+	// record tallies a task outcome and forwards not-found completions to the
+	// runtime's metrics sink.
+	record := func(task tasks.Task, outcome taskOutcome) {
+		if outcome == outcomeNotFound {
+			rt.Metrics().NotFound(task.Model)
+		}
+		stats.add(outcome)
+	}
+
+	// Work is grouped per (model, tenant, reembed) so each runtime call runs
+	// under a single tenant and re-embed context.
+	type taskGroup struct {
+		model   string
+		tenant  string
+		reembed bool
+	}
+	textByModel := map[taskGroup][]textWorkItem{}
+	vlByModel := map[taskGroup][]vlWorkItem{}
 
 	for _, task := range batch {
-		doc := ""
-		if byLang, ok := docsByType[task.EntityType]; ok {
-			if m, ok := byLang[task.Language]; ok {
-				doc = m[task.EntityID]
-			}
+		if err := h.taskErr(task, rt.IsVLModel(task.Model)); err != nil {
+			record(task, handleTaskResult(ctx, repo, cfg, task, nil, err))
+			continue
 		}
+		doc := h.doc(task)
 		if strings.TrimSpace(doc) == "" {
-			_ = repo.Complete(ctx, task.EntityType, task.EntityID, task.Model, task.Language, task.NextRunAt)
+			_ = repo.Complete(ctx, task)
+			record(task, outcomeNotFound)
 			continue
 		}
 
 		if rt.IsVLModel(task.Model) {
-			var assets []vl.AssetURL
-			if m, ok := assetsByType[task.EntityType]; ok {
-				assets = m[task.EntityID]
-			}
+			assets := h.assetURLs(task)
 			if len(assets) == 0 {
-				_ = repo.Complete(ctx, task.EntityType, task.EntityID, task.Model, task.Language, task.NextRunAt)
+				_ = repo.Complete(ctx, task)
+				record(task, outcomeNotFound)
 				continue
 			}
-			vlItems = append(vlItems, vlWorkItem{task: task, doc: doc, assets: assets})
+			assets, err := rt.FilterAssets(task.Model, assets)
+			if err != nil {
+				record(task, handleTaskResult(ctx, repo, cfg, task, nil, err))
+				continue
+			}
+			g := taskGroup{model: task.Model, tenant: task.TenantID, reembed: task.Reason == runtime.ReasonReembed}
+			vlByModel[g] = append(vlByModel[g], vlWorkItem{task: task, doc: doc, assets: assets})
 			continue
 		}
 
-		textByModel[task.Model] = append(textByModel[task.Model], textWorkItem{task: task, doc: doc})
+		g := taskGroup{model: task.Model, tenant: task.TenantID, reembed: task.Reason == runtime.ReasonReembed}
+		textByModel[g] = append(textByModel[g], textWorkItem{task: task, doc: doc})
 	}
 
 	var wg sync.WaitGroup
 
-	// Text tasks are batched per model into providerEmbedBatchSize requests.
-	for model, items := range textByModel {
-		model := model
+	// Text tasks are batched per model into provider requests of at most
+	// cfg.providerBatchSize items.
+	for g, items := range textByModel {
+		model := g.model
+		tctx := pg.WithTenant(ctx, g.tenant)
+		if g.reembed {
+			tctx = runtime.WithReembed(tctx)
+		}
 		items := items
-		for start := 0; start < len(items); start += providerEmbedBatchSize {
-			end := start + providerEmbedBatchSize
+		batchSize := cfg.providerBatchSize(model, rt.MaxBatch(model))
+		for start := 0; start < len(items); start += batchSize {
+			end := start + batchSize
 			if end > len(items) {
 				end = len(items)
 			}
 			chunk := items[start:end]
 
-			sem <- struct{}{}
 			wg.Add(1)
 			go func() {
-				defer func() {
-					<-sem
-					wg.Done()
-				}()
-
-				if tokens != nil {
-					select {
-					case <-ctx.Done():
-						return
-					case <-tokens:
+				defer wg.Done()
+				if !limiter.acquire(ctx, model) {
+					return
+				}
+				defer limiter.release(model)
+
+				if until, over := cfg.Monitor.spend.exceeded(cfg.SpendBudget, model, time.Now()); over {
+					for _, it := range chunk {
+						record(it.task, deferTask(ctx, repo, it.task, until))
 					}
+					return
 				}
 
 				embedItems := make([]runtime.TextEmbeddingItem, len(chunk))
@@ -359,7 +754,11 @@ func processBatch(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, cf
 					}
 				}
 
-				perItemErrs, batchErr := rt.GenerateAndStoreTextEmbeddingsWithDocuments(ctx, model, embedItems)
+				uctx, usage := embedder.TrackUsage(tctx)
+				started := time.Now()
+				perItemErrs, batchErr := rt.GenerateAndStoreTextEmbeddingsWithDocuments(uctx, model, embedItems)
+				stats.observe(model, time.Since(started))
+				cfg.Monitor.spend.record(cfg.SpendBudget, model, usage().PromptTokens, time.Now())
 				if perItemErrs == nil {
 					perItemErrs = make([]error, len(chunk))
 				}
@@ -369,77 +768,214 @@ func processBatch(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, cf
 					if err == nil && batchErr != nil {
 						err = batchErr
 					}
-					handleTaskResult(ctx, repo, cfg, rng, it.task, err)
+					record(it.task, handleTaskResult(ctx, repo, cfg, it.task, nil, err))
 				}
 			}()
 		}
 	}
 
-	// VL tasks remain one request per task.
-	for _, it := range vlItems {
-		it := it
-		sem <- struct{}{}
-		wg.Add(1)
-		go func() {
-			defer func() {
-				<-sem
-				wg.Done()
-			}()
+	// VL tasks are batched the same way; embedders without vl.BatchEmbedder
+	// report a batch size of 1.
+	for g, items := range vlByModel {
+		model := g.model
+		vctx := runtime.WithAssetFetcher(pg.WithTenant(ctx, g.tenant), cfg.AssetFetcher, cfg.AlwaysUploadAssets)
+		if g.reembed {
+			vctx = runtime.WithReembed(vctx)
+		}
+		items := items
+		batchSize := cfg.providerBatchSize(model, rt.MaxBatch(model))
+		for start := 0; start < len(items); start += batchSize {
+			end := start + batchSize
+			if end > len(items) {
+				end = len(items)
+			}
+			chunk := items[start:end]
 
-			if tokens != nil {
-				select {
-				case <-ctx.Done():
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if !limiter.acquire(ctx, model) {
+					return
+				}
+				defer limiter.release(model)
+
+				if until, over := cfg.Monitor.spend.exceeded(cfg.SpendBudget, model, time.Now()); over {
+					for _, it := range chunk {
+						record(it.task, deferTask(ctx, repo, it.task, until))
+					}
 					return
-				case <-tokens:
 				}
-			}
 
-			err := rt.GenerateAndStoreVLEmbeddingWithInputs(ctx, it.task.EntityType, it.task.EntityID, it.task.Model, it.task.Language, it.doc, it.assets)
-			handleTaskResult(ctx, repo, cfg, rng, it.task, err)
-		}()
+				embedItems := make([]runtime.VLEmbeddingItem, len(chunk))
+				for i, it := range chunk {
+					embedItems[i] = runtime.VLEmbeddingItem{
+						EntityType: it.task.EntityType,
+						EntityID:   it.task.EntityID,
+						Language:   it.task.Language,
+						Document:   it.doc,
+						Assets:     it.assets,
+					}
+				}
+
+				uctx, usage := embedder.TrackUsage(vctx)
+				started := time.Now()
+				perItemErrs, batchErr := rt.GenerateAndStoreVLEmbeddingsWithInputs(uctx, model, embedItems)
+				if perItemErrs == nil {
+					perItemErrs = make([]error, len(chunk))
+				}
+				for i, it := range chunk {
+					if errors.Is(perItemErrs[i], vl.ErrAssetUnreachable) && cfg.RefreshAssetURLs != nil {
+						perItemErrs[i] = retryWithFreshURLs(uctx, rt, cfg, it.task, it.doc, perItemErrs[i])
+					}
+				}
+				stats.observe(model, time.Since(started))
+				cfg.Monitor.spend.record(cfg.SpendBudget, model, usage().PromptTokens, time.Now())
+
+				for i, it := range chunk {
+					err := perItemErrs[i]
+					if err == nil && batchErr != nil {
+						err = batchErr
+					}
+					record(it.task, handleTaskResult(ctx, repo, cfg, it.task, it.assets, err))
+				}
+			}()
+		}
 	}
 
 	wg.Wait()
 }
 
-// DrainOnce fetches and processes a single batch of ready tasks, then returns.
+// retryWithFreshURLs asks the host for fresh asset URLs for t's entity and
+// embeds once more; err is returned when the refresh itself fails.
+func retryWithFreshURLs(ctx context.Context, rt *runtime.Runtime, cfg Options, t tasks.Task, doc string, err error) error {
+	fresh, rerr := cfg.RefreshAssetURLs(ctx, t.EntityType, []string{t.EntityID})
+	if rerr != nil {
+		log.Printf("searchkit: RefreshAssetURLs failed entity_type=%s entity_id=%s err=%v", t.EntityType, t.EntityID, rerr)
+		return err
+	}
+	assets, ok := fresh[t.EntityID]
+	if !ok {
+		return runtime.ErrEntityNotFound
+	}
+	return rt.GenerateAndStoreVLEmbeddingWithInputs(ctx, t.EntityType, t.EntityID, t.Model, t.Language, doc, assets)
+}
+
+// probeAssets wraps a dead-lettered VL task's error with the status of each of
+// its asset URLs when cfg.ProbeDeadLetterAssets is set.
+func probeAssets(ctx context.Context, cfg Options, assets []vl.AssetURL, err error) error {
+	if !cfg.ProbeDeadLetterAssets || len(assets) == 0 {
+		return err
+	}
+	pctx, cancel := context.WithTimeout(ctx, cfg.AssetProbeTimeout)
+	defer cancel()
+	return &vl.ProbeError{Err: err, Probes: vl.ProbeAssets(pctx, nil, assets)}
+}
+
+// fetchReady leases the next batch of ready tasks and records fetch health
+// (including queue lag measured before leasing). Models paused by the failure
+// budget are skipped.
+func fetchReady(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, cfg Options) ([]tasks.Task, error) {
+	models := canonicalModels(rt, cfg.Models)
+	loop := strings.Join(models, ",")
+	if cfg.FailureBudget != nil {
+		models = unpausedModels(rt, cfg.Monitor, models, time.Now())
+		if models != nil && len(models) == 0 {
+			cfg.Monitor.health.fetched(loop, 0)
+			return nil, nil
+		}
+	}
+
+	var lag time.Duration
+	oldest, ok, err := repo.OldestReadyAt(ctx, models...)
+	if err != nil {
+		cfg.Monitor.health.failed(err)
+		return nil, err
+	}
+	if ok {
+		lag = time.Since(oldest)
+	}
+	batch, err := repo.FetchReadyForModels(ctx, models, cfg.BatchSize, cfg.LockAhead)
+	if err != nil {
+		cfg.Monitor.health.failed(err)
+		return nil, err
+	}
+	cfg.Monitor.health.fetched(loop, lag)
+	return batch, nil
+}
+
+// canonicalModels returns models by canonical name (nil for none, meaning
+// all active models).
+func canonicalModels(rt *runtime.Runtime, models []string) []string {
+	var out []string
+	for _, m := range models {
+		out = append(out, rt.CanonicalModel(m))
+	}
+	return out
+}
+
+// unpausedModels filters paused models out of models (or the runtime's active
+// models when models is empty). It returns models unchanged (possibly nil,
+// meaning "all") when nothing is paused.
+func unpausedModels(rt *runtime.Runtime, m *Monitor, models []string, now time.Time) []string {
+	paused := m.budgets.pausedUntil(now)
+	if len(paused) == 0 {
+		return models
+	}
+	if len(models) == 0 {
+		models = rt.ActiveModels()
+	}
+	out := make([]string, 0, len(models))
+	for _, m := range models {
+		if _, ok := paused[m]; ok {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// DrainOnce fetches and processes a single batch of ready tasks, then returns
+// a summary of the outcomes.
 //
 // This is useful for integrating searchkit into an external job runner (e.g.
 // River/Cron) where you do not want an internal infinite polling loop.
-func DrainOnce(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, opts Options) error {
+func DrainOnce(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, opts Options) (DrainSummary, error) {
+	stats := &drainStats{}
 	if rt == nil {
-		return fmt.Errorf("runtime is required")
+		return stats.summary(), fmt.Errorf("runtime is required")
 	}
 	if repo == nil {
-		return fmt.Errorf("repo is required")
+		return stats.summary(), fmt.Errorf("repo is required")
 	}
 	cfg := opts.withDefaults()
 
-	batch, err := repo.FetchReady(ctx, cfg.BatchSize, cfg.LockAhead)
+	batch, err := fetchReady(ctx, rt, repo, cfg)
 	if err != nil {
-		return err
+		return stats.summary(), err
 	}
+	stats.s.Fetched = len(batch)
+	stats.s.More = len(batch) >= cfg.BatchSize
 	if len(batch) == 0 {
-		return nil
+		return stats.summary(), nil
 	}
 
-	docsByType, assetsByType, err := hydrateBatch(ctx, rt, batch)
+	h, err := hydrateBatch(ctx, rt, batch)
 	if err != nil {
-		return err
+		cfg.Monitor.health.failed(err)
+		return stats.summary(), err
 	}
 
-	sem := make(chan struct{}, cfg.MaxConcurrentEmbeds)
-	var tokens <-chan struct{}
-	if cfg.MaxRequestsPerSecond > 0 {
-		tokens = makeTokenBucket(cfg.MaxRequestsPerSecond, cfg.MaxConcurrentEmbeds)
-	}
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	limiter := cfg.Monitor.limiters.get(cfg)
 
-	processBatch(ctx, rt, repo, cfg, batch, docsByType, assetsByType, sem, tokens, rng)
-	return nil
+	uctx, usage := embedder.TrackUsage(ctx)
+	processBatch(uctx, rt, repo, cfg, batch, h, limiter, stats)
+	summary := stats.summary()
+	summary.PromptTokens = usage().PromptTokens
+	return summary, nil
 }
 
-// Run drains embedding tasks using the provided runtime and repository.
+// Run drains embedding tasks using the provided runtime and repository. Its
+// health is read from opts.Monitor, which is required.
 //
 // This helper is optional; host apps can implement their own runner in River/Cron/etc.
 func Run(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, opts Options) error {
@@ -449,14 +985,13 @@ func Run(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, opts Option
 	if repo == nil {
 		return fmt.Errorf("repo is required")
 	}
+	if opts.Monitor == nil {
+		return fmt.Errorf("Options.Monitor is required")
+	}
 	cfg := opts.withDefaults()
+	cfg.Monitor.health.started(strings.Join(canonicalModels(rt, cfg.Models), ","))
 
-	sem := make(chan struct{}, cfg.MaxConcurrentEmbeds)
-	var tokens <-chan struct{}
-	if cfg.MaxRequestsPerSecond > 0 {
-		tokens = makeTokenBucket(cfg.MaxRequestsPerSecond, cfg.MaxConcurrentEmbeds)
-	}
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	limiter := newEmbedLimiter(cfg)
 
 	ticker := time.NewTicker(cfg.PollEvery)
 	defer ticker.Stop()
@@ -466,17 +1001,18 @@ func Run(ctx context.Context, rt *runtime.Runtime, repo *tasks.Repo, opts Option
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			batch, err := repo.FetchReady(ctx, cfg.BatchSize, cfg.LockAhead)
+			batch, err := fetchReady(ctx, rt, repo, cfg)
 			if err != nil {
 				return err
 			}
 
-			docsByType, assetsByType, err := hydrateBatch(ctx, rt, batch)
+			h, err := hydrateBatch(ctx, rt, batch)
 			if err != nil {
+				cfg.Monitor.health.failed(err)
 				return err
 			}
 
-			processBatch(ctx, rt, repo, cfg, batch, docsByType, assetsByType, sem, tokens, rng)
+			processBatch(ctx, rt, repo, cfg, batch, h, limiter, &drainStats{})
 		}
 	}
 }
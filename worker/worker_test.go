@@ -0,0 +1,336 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/open-rails/searchkit/pg"
+	"github.com/open-rails/searchkit/runtime"
+	"github.com/open-rails/searchkit/tasks"
+	"github.com/open-rails/searchkit/vl"
+)
+
+func TestOptionsProviderBatchSize(t *testing.T) {
+	cfg := (&Options{
+		ProviderBatchSize:        50,
+		ProviderBatchSizeByModel: map[string]int{"dashscope": 10, "zero": 0},
+	}).withDefaults()
+
+	if got := cfg.providerBatchSize("dashscope", 96); got != 10 {
+		t.Fatalf("expected 10, got %d", got)
+	}
+	if got := cfg.providerBatchSize("zero", 0); got != 50 {
+		t.Fatalf("expected fallback 50, got %d", got)
+	}
+	if got := cfg.providerBatchSize("cohere", 96); got != 96 {
+		t.Fatalf("expected the embedder's limit 96, got %d", got)
+	}
+	if got := (&Options{}).withDefaults().providerBatchSize("x", 0); got != defaultProviderEmbedBatchSize {
+		t.Fatalf("expected default %d, got %d", defaultProviderEmbedBatchSize, got)
+	}
+}
+
+func TestEmbedLimiter_PerModelCap(t *testing.T) {
+	l := newEmbedLimiter(Options{
+		MaxConcurrentEmbeds:        4,
+		MaxConcurrentEmbedsByModel: map[string]int{"vl": 1},
+	})
+	ctx := context.Background()
+
+	if !l.acquire(ctx, "vl") {
+		t.Fatalf("expected first vl acquire to succeed")
+	}
+
+	// A second vl request must wait for the per-model slot.
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if l.acquire(waitCtx, "vl") {
+		t.Fatalf("expected second vl acquire to block")
+	}
+
+	// Other models still use the remaining global slots.
+	if !l.acquire(ctx, "text") {
+		t.Fatalf("expected text acquire to succeed")
+	}
+
+	l.release("vl")
+	if !l.acquire(ctx, "vl") {
+		t.Fatalf("expected vl acquire after release to succeed")
+	}
+	if len(l.global) != 2 {
+		t.Fatalf("expected 2 global slots in use, got %d", len(l.global))
+	}
+}
+
+func TestDrainLimiters_KeptPerMonitor(t *testing.T) {
+	cfg := Options{MaxConcurrentEmbeds: 2, MaxRequestsPerSecond: 5}
+	m := NewMonitor()
+	l := m.limiters.get(cfg)
+	if l.globalRate == nil || l.globalRate.Limit() != 5 {
+		t.Fatalf("expected a 5 rps limiter, got %v", l.globalRate)
+	}
+	if m.limiters.get(cfg) != l {
+		t.Fatalf("expected DrainOnce calls with the same limits to share the limiter")
+	}
+	if NewMonitor().limiters.get(cfg) == l {
+		t.Fatalf("expected another Monitor to have its own limiter")
+	}
+
+	cfg.MaxRequestsPerSecond = 1
+	if got := m.limiters.get(cfg); got == l || got.globalRate.Limit() != 1 {
+		t.Fatalf("expected a rebuilt 1 rps limiter after the limits changed")
+	}
+}
+
+func TestBudgetTracker_PausesOnFailureRate(t *testing.T) {
+	tr := &budgetTracker{byModel: map[string]*budgetWindow{}}
+	var pausedModel string
+	b := &FailureBudget{
+		MaxFailureRate: 0.5,
+		MinSamples:     4,
+		Window:         time.Minute,
+		Cooldown:       time.Minute,
+		OnPause: func(model string, _ float64, _ time.Time) {
+			pausedModel = model
+		},
+	}
+	now := time.Now()
+
+	tr.record(b, "m", false, now)
+	tr.record(b, "m", true, now)
+	tr.record(b, "m", true, now)
+	if len(tr.pausedUntil(now)) != 0 {
+		t.Fatalf("expected no pause below MinSamples")
+	}
+	tr.record(b, "m", true, now)
+	if pausedModel != "m" {
+		t.Fatalf("expected OnPause for model m, got %q", pausedModel)
+	}
+	if _, ok := tr.pausedUntil(now)["m"]; !ok {
+		t.Fatalf("expected model m to be paused")
+	}
+	if len(tr.pausedUntil(now.Add(2*time.Minute))) != 0 {
+		t.Fatalf("expected pause to expire after cooldown")
+	}
+}
+
+func TestUnpausedModels_PerMonitor(t *testing.T) {
+	b := &FailureBudget{MaxFailureRate: 0.5, MinSamples: 1}
+	now := time.Now()
+	a, other := NewMonitor(), NewMonitor()
+	a.budgets.record(b, "m", true, now)
+
+	if got := unpausedModels(nil, a, []string{"m", "n"}, now); len(got) != 1 || got[0] != "n" {
+		t.Fatalf("expected m to be paused on its monitor, got %v", got)
+	}
+	if got := unpausedModels(nil, other, []string{"m", "n"}, now); len(got) != 2 {
+		t.Fatalf("expected another monitor's pause not to apply, got %v", got)
+	}
+}
+
+func TestMonitor_HealthIsPerMonitor(t *testing.T) {
+	a, other := NewMonitor(), NewMonitor()
+	a.health.failed(errors.New("boom"))
+	if h := a.Health(); h.ErrorStreak != 1 || h.LastError != "boom" {
+		t.Fatalf("expected the failure on its monitor, got %+v", h)
+	}
+	if h := other.Health(); h.ErrorStreak != 0 || h.LastError != "" {
+		t.Fatalf("expected another monitor to stay healthy, got %+v", h)
+	}
+}
+
+func TestMonitor_HealthTracksEachLoop(t *testing.T) {
+	m := NewMonitor()
+	m.health.started("vl")
+	m.health.failed(errors.New("boom"))
+	m.health.fetched("text-a,text-b", time.Second)
+	h := m.Health()
+	if h.ErrorStreak != 0 {
+		t.Fatalf("expected a successful fetch to end the error streak, got %d", h.ErrorStreak)
+	}
+	if !h.LastFetchAt.IsZero() || h.IsLive(time.Now(), time.Hour) {
+		t.Fatalf("expected a loop that never fetched to keep the worker not live, got %+v", h)
+	}
+	if h.LastFetchAtByLoop["text-a,text-b"].IsZero() || h.QueueLag != time.Second {
+		t.Fatalf("expected the text loop's fetch, got %+v", h)
+	}
+
+	m.health.fetched("vl", 0)
+	if h := m.Health(); !h.IsLive(time.Now(), time.Hour) {
+		t.Fatalf("expected the worker live once every loop fetched, got %+v", h)
+	}
+}
+
+func TestRun_RequiresMonitor(t *testing.T) {
+	err := Run(context.Background(), &runtime.Runtime{}, &tasks.Repo{}, Options{})
+	if err == nil || !strings.Contains(err.Error(), "Monitor") {
+		t.Fatalf("expected a missing Monitor error, got %v", err)
+	}
+}
+
+func TestSpendTracker_DefersUntilNextDay(t *testing.T) {
+	tr := &NewMonitor().spend
+	var calls int
+	var kind string
+	b := &SpendBudget{
+		Limits: map[string]SpendLimit{"m": {TokensPerDay: 100, RequestsPerDay: 10}},
+		OnExceeded: func(_ string, k string, _ int64, _ int64, _ time.Time) {
+			calls++
+			kind = k
+		},
+	}
+	now := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+
+	tr.record(b, "m", 60, now)
+	if _, over := tr.exceeded(b, "m", now); over {
+		t.Fatalf("expected model under budget")
+	}
+	tr.record(b, "m", 60, now)
+	until, over := tr.exceeded(b, "m", now)
+	if !over || kind != "tokens" {
+		t.Fatalf("expected token budget exceeded, got over=%v kind=%q", over, kind)
+	}
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC); !until.Equal(want) {
+		t.Fatalf("resetAt = %s, want %s", until, want)
+	}
+	_, _ = tr.exceeded(b, "m", now)
+	if calls != 1 {
+		t.Fatalf("expected OnExceeded once per day, got %d", calls)
+	}
+	if _, over := tr.exceeded(b, "m", until); over {
+		t.Fatalf("expected budget to reset at the next UTC day")
+	}
+	if _, over := tr.exceeded(b, "other", now); over {
+		t.Fatalf("expected models without a limit to be unlimited")
+	}
+	if _, over := NewMonitor().spend.exceeded(b, "m", now); over {
+		t.Fatalf("expected another Monitor's usage to be counted separately")
+	}
+}
+
+func TestComputeBackoff_Strategies(t *testing.T) {
+	base := 10 * time.Second
+	max := time.Hour
+
+	fixed := (&Options{BackoffStrategy: BackoffFixed, JitterFraction: -1}).withDefaults()
+	if got := computeBackoff(fixed, base, 5, max); got != base {
+		t.Fatalf("fixed: expected %v, got %v", base, got)
+	}
+
+	exp := (&Options{JitterFraction: -1}).withDefaults()
+	if got := computeBackoff(exp, base, 3, max); got != 40*time.Second {
+		t.Fatalf("exponential: expected 40s, got %v", got)
+	}
+	if got := computeBackoff(exp, base, 30, max); got != max {
+		t.Fatalf("exponential: expected cap %v, got %v", max, got)
+	}
+
+	jittered := (&Options{}).withDefaults()
+	for i := 0; i < 50; i++ {
+		got := computeBackoff(jittered, base, 1, max)
+		if got < base || got > base+base/4 {
+			t.Fatalf("jitter: %v outside [%v, %v]", got, base, base+base/4)
+		}
+	}
+
+	full := (&Options{BackoffStrategy: BackoffExponentialFullJitter}).withDefaults()
+	for i := 0; i < 50; i++ {
+		got := computeBackoff(full, base, 2, max)
+		if got < 0 || got > 20*time.Second {
+			t.Fatalf("full jitter: %v outside [0, 20s]", got)
+		}
+	}
+}
+
+type expiringURLEmbedder struct{}
+
+func (expiringURLEmbedder) Model() string   { return "vl" }
+func (expiringURLEmbedder) Dimensions() int { return 2 }
+
+func (expiringURLEmbedder) EmbedTextAndAssetURLs(ctx context.Context, text string, assets []vl.AssetURL) ([]float32, error) {
+	if strings.Contains(assets[0].URL, "expired") {
+		return nil, fmt.Errorf("403 fetching %s: %w", assets[0].URL, vl.ErrAssetUnreachable)
+	}
+	return []float32{1, 0}, nil
+}
+
+func TestRetryWithFreshURLs(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	store := runtime.NewMemoryStorage()
+	rt, err := runtime.New(runtime.Options{
+		Pool:                  pool,
+		Schema:                "app",
+		VLEmbedders:           []vl.Embedder{expiringURLEmbedder{}},
+		ListAssetURLs:         func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+		BuildSemanticDocument: func(context.Context, string, string, []string) (map[string]string, error) { return nil, nil },
+		Storage:               store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var refreshed []string
+	cfg := Options{RefreshAssetURLs: func(_ context.Context, _ string, ids []string) (map[string][]vl.AssetURL, error) {
+		refreshed = append(refreshed, ids...)
+		return map[string][]vl.AssetURL{"1": {{Kind: vl.AssetKindImage, URL: "https://cdn/cover.jpg?sig=fresh", Key: "cover"}}}, nil
+	}}
+	task := tasks.Task{EntityType: "gallery", EntityID: "1", Model: "vl", Language: "en"}
+	stale := fmt.Errorf("wrapped: %w", vl.ErrAssetUnreachable)
+	if err := retryWithFreshURLs(context.Background(), rt, cfg, task, "doc", stale); err != nil {
+		t.Fatal(err)
+	}
+	if len(refreshed) != 1 || len(store.Vectors("vl", pg.EmbeddingKey{EntityType: "gallery", EntityID: "1", Language: "en"})) != 1 {
+		t.Fatalf("expected one refresh and a stored vector, got refreshes %v", refreshed)
+	}
+
+	task.EntityID = "2"
+	if err := retryWithFreshURLs(context.Background(), rt, cfg, task, "doc", stale); !errors.Is(err, runtime.ErrEntityNotFound) {
+		t.Fatalf("expected entities missing from the refresh to be not found, got %v", err)
+	}
+}
+
+func TestHydrateBatch_KeysByTenant(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), "postgres://localhost:1/unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	rt, err := runtime.New(runtime.Options{
+		Pool:          pool,
+		Schema:        "app",
+		VLEmbedders:   []vl.Embedder{expiringURLEmbedder{}},
+		ListAssetURLs: func(context.Context, string, []string) (map[string][]vl.AssetURL, error) { return nil, nil },
+		BuildSemanticDocument: func(ctx context.Context, _ string, _ string, ids []string) (map[string]string, error) {
+			out := map[string]string{}
+			for _, id := range ids {
+				out[id] = pg.TenantFromContext(ctx) + ":" + id
+			}
+			return out, nil
+		},
+		Storage: runtime.NewMemoryStorage(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := tasks.Task{TenantID: "a", EntityType: "gallery", EntityID: "42", Model: "vl", Language: "en"}
+	b := a
+	b.TenantID = "b"
+	h, err := hydrateBatch(context.Background(), rt, []tasks.Task{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.doc(a) != "a:42" || h.doc(b) != "b:42" {
+		t.Fatalf("expected each tenant's own document, got %q and %q", h.doc(a), h.doc(b))
+	}
+}